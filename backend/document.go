@@ -2,6 +2,8 @@ package backend
 
 import (
 	"time"
+
+	"github.com/benoitkugler/webrender/css/parser"
 )
 
 type Anchor struct {
@@ -24,6 +26,27 @@ type BookmarkNode struct {
 	X, Y      Fl   // position in the page
 }
 
+// PageLabelStyle is the numbering style of a range of page labels.
+type PageLabelStyle uint8
+
+const (
+	LabelNone       PageLabelStyle = iota // only the prefix is displayed
+	LabelDecimal                          // 1, 2, 3
+	LabelLowerRoman                       // i, ii, iii
+	LabelUpperRoman                       // I, II, III
+	LabelLowerAlpha                       // a, b, c (then aa, bb, cc)
+	LabelUpperAlpha                       // A, B, C (then AA, BB, CC)
+)
+
+// PageLabel defines the labels of the pages starting at [PageIndex],
+// up to the next range.
+type PageLabel struct {
+	PageIndex int // 0-based index of the first page of the range
+	Style     PageLabelStyle
+	Prefix    string
+	Start     int // numeric value of the first page of the range (at least 1)
+}
+
 // Document is the main target to whole the laid out document,
 // consisting in pages, metadata and embedded files.
 type Document interface {
@@ -59,6 +82,12 @@ type Document interface {
 
 	// SetBookmarks setup the document outline
 	SetBookmarks(root []BookmarkNode)
+
+	// SetPageLabels setup the labels displayed by viewers
+	// instead of the page indexes, as printed on the pages.
+	// [labels] is sorted by page index, and its first range
+	// starts at page 0.
+	SetPageLabels(labels []PageLabel)
 }
 
 // Page is the target of one laid out page,
@@ -76,11 +105,117 @@ type Page interface {
 	// The file content has been added with `Output.EmbedFile`.
 	AddFileAnnotation(xMin, yMin, xMax, yMax Fl, fileID string)
 
+	// AddFormField adds an interactive form field on the current page.
+	// Its static appearance (background and borders) is drawn
+	// as usual on the page.
+	AddFormField(xMin, yMin, xMax, yMax Fl, field FormField)
+
 	// Adjust the media boxes
 
 	SetMediaBox(left, top, right, bottom Fl)
 	SetTrimBox(left, top, right, bottom Fl)
 	SetBleedBox(left, top, right, bottom Fl)
 
+	// BeginStructure starts a structure element, describing the logical role
+	// of the content drawn, and of the links added, until the matching call to `EndStructure`.
+	// Structure elements may be nested.
+	BeginStructure(element StructureElement)
+
+	// EndStructure ends the last structure element started
+	// with `BeginStructure`.
+	EndStructure()
+
 	Canvas
 }
+
+// StructureTag is the role of a structure element, using
+// the standard structure types defined by PDF.
+type StructureTag string
+
+const (
+	// TagArtifact marks content which is not part of the logical
+	// structure, like page decorations or running headers.
+	TagArtifact StructureTag = "Artifact"
+
+	TagSection    StructureTag = "Sect"
+	TagBlockQuote StructureTag = "BlockQuote"
+	TagParagraph  StructureTag = "P"
+	TagHeading1   StructureTag = "H1"
+	TagHeading2   StructureTag = "H2"
+	TagHeading3   StructureTag = "H3"
+	TagHeading4   StructureTag = "H4"
+	TagHeading5   StructureTag = "H5"
+	TagHeading6   StructureTag = "H6"
+
+	TagList     StructureTag = "L"
+	TagListItem StructureTag = "LI"
+
+	TagTable           StructureTag = "Table"
+	TagTableHead       StructureTag = "THead"
+	TagTableBody       StructureTag = "TBody"
+	TagTableFoot       StructureTag = "TFoot"
+	TagTableRow        StructureTag = "TR"
+	TagTableHeaderCell StructureTag = "TH"
+	TagTableDataCell   StructureTag = "TD"
+
+	TagCaption StructureTag = "Caption"
+	TagFigure  StructureTag = "Figure"
+	TagLink    StructureTag = "Link"
+)
+
+// StructureElement is a node of the logical structure of a document.
+type StructureElement struct {
+	Tag StructureTag
+
+	// ID identifies the element across calls : an element split
+	// by page breaks is started once per page, with the same ID.
+	// 0 is used for anonymous elements.
+	ID int
+
+	Alt  string // (optional) alternate description, used by figures
+	Lang string // (optional) language of the content, as a BCP 47 tag
+}
+
+// FormFieldKind is the type of a form field
+type FormFieldKind uint8
+
+const (
+	FieldText FormFieldKind = iota
+	FieldCheckbox
+	FieldRadio
+	FieldSelect
+	FieldButton
+)
+
+// FormOption is an option of a select field
+type FormOption struct {
+	Value, Label string
+}
+
+// FormField is an interactive field, built from an HTML input,
+// select, textarea or button element.
+type FormField struct {
+	Kind FormFieldKind
+	// Name identifies the field. Radio buttons with the same name
+	// form a group. It may be empty.
+	Name string
+	// Value is the current value of the field, the label of buttons,
+	// or the value exported by checked checkboxes and radio buttons.
+	Value string
+
+	Options  []FormOption // for select fields
+	Selected []string     // the values of the selected options
+
+	Checked   bool // for checkboxes and radio buttons
+	Multiline bool // for text areas
+	Password  bool // for text fields
+	Multiple  bool // for select fields
+	ReadOnly  bool
+	MaxLength int // for text fields, 0 for no limit
+
+	// Font is the font used by the element, which may be
+	// nil if no suitable font is found.
+	Font     Font
+	FontSize Fl
+	Color    parser.RGBA
+}
@@ -37,6 +37,10 @@ type GradientLayout struct {
 	// 0 at the starting point, 1 at the ending point.
 	Positions []Fl
 	Colors    []parser.RGBA
+	// Devices is either empty or has the same length as [Colors],
+	// when all the colors are in the same print color space.
+	// Backends not supporting this space use [Colors].
+	Devices []parser.DeviceColor `json:",omitempty"`
 
 	GradientKind
 
@@ -45,6 +49,36 @@ type GradientLayout struct {
 	Reapeating bool
 }
 
+// SetColors sets [Colors] and [Devices] from [colors].
+func (gl *GradientLayout) SetColors(colors []parser.Color) {
+	gl.Colors = make([]parser.RGBA, len(colors))
+	for i, color := range colors {
+		gl.Colors[i] = color.RGBA
+	}
+	gl.Devices = nil
+	if len(colors) == 0 || colors[0].Device.Space == parser.SpaceRGB {
+		return
+	}
+	ref := colors[0].Device
+	devices := make([]parser.DeviceColor, len(colors))
+	for i, color := range colors {
+		if color.Device.Space != ref.Space || color.Device.Spot != ref.Spot {
+			return
+		}
+		devices[i] = color.Device
+	}
+	gl.Devices = devices
+}
+
+// Color returns the i-th color, including its print color space, if any.
+func (gl *GradientLayout) Color(i int) parser.Color {
+	out := parser.Color{Type: parser.ColorRGBA, RGBA: gl.Colors[i]}
+	if len(gl.Devices) == len(gl.Colors) {
+		out.Device = gl.Devices[i]
+	}
+	return out
+}
+
 // RasterImage is an image to be included in the ouput.
 type RasterImage struct {
 	Content  io.Reader
@@ -58,6 +92,16 @@ type RasterImage struct {
 	ID int
 }
 
+// IsPixelated returns true for the CSS image-rendering values
+// disabling smoothing.
+func IsPixelated(rendering string) bool {
+	switch rendering {
+	case "pixelated", "crisp-edges", "optimizeSpeed":
+		return true
+	}
+	return false
+}
+
 // Image groups all possible image format,
 // like raster image, svg, or gradients.
 type Image interface {
@@ -180,6 +224,11 @@ type GraphicState interface {
 	// `stroke` controls whether stroking or filling operations are concerned.
 	SetColorRgba(color parser.RGBA, stroke bool)
 
+	// SetColor is the same as [SetColorRgba], but also supports
+	// the colors defined in a print color space (CMYK or spot colors).
+	// Backends not supporting these spaces use the RGB fallback [parser.Color.RGBA].
+	SetColor(color parser.Color, stroke bool)
+
 	// SetColorPattern set the current paint color to the given pattern.
 	// A pattern acts as a fill or stroke color, but permits complex textures.
 	// It consists of a rectangle, fill with arbitrary content, which will be replicated
@@ -317,3 +366,14 @@ type Canvas interface {
 	// must be taken care of.
 	DrawGradient(gradient GradientLayout, width, height Fl)
 }
+
+// BlurCanvas is an optional interface implemented by the [Canvas]
+// which are able to blur their content, like raster outputs.
+// Callers should check for it with a type assertion, and fallback
+// to [Canvas.DrawWithOpacity] when it is not supported.
+type BlurCanvas interface {
+	// DrawWithBlur draws the given target to the main target, applying
+	// a gaussian blur with the given standard deviation, in user space units.
+	// The blurred content is clipped to the bounding box of the group.
+	DrawWithBlur(stdDeviation Fl, group Canvas)
+}
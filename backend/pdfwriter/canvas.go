@@ -0,0 +1,310 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+var (
+	_ backend.Canvas       = (*canvas)(nil)
+	_ backend.GraphicState = (*canvas)(nil)
+)
+
+// canvas implements [backend.Canvas] by writing a PDF content stream.
+// Groups are written as form XObjects, using their own coordinate system.
+type canvas struct {
+	res  *resources // shared by all the pages
+	bbox [4]Fl      // left, top, right, bottom
+
+	content   bytes.Buffer
+	resources resourceDict
+
+	// the CTM is tracked to implement GetTransform
+	ctm matrix.Transform
+	// the text rendering mode is tracked to restore it
+	// after hidden glyphs
+	textMode int
+	stack    []canvasState
+}
+
+// canvasState is the part of the graphic state
+// saved by OnNewStack
+type canvasState struct {
+	ctm      matrix.Transform
+	textMode int
+}
+
+func newCanvas(res *resources, left, top, right, bottom Fl) *canvas {
+	return &canvas{
+		res:       res,
+		bbox:      [4]Fl{left, top, right, bottom},
+		resources: make(resourceDict),
+		ctm:       matrix.Identity(),
+	}
+}
+
+// resourceDict maps the resource category (like "Font")
+// to the names used in the content stream
+type resourceDict map[string]map[string]ref
+
+// add registers [r] and returns the name (without the slash) used for it,
+// made of [prefix] and the object number, so that names are unique
+// in the whole file
+func (rd resourceDict) add(category, prefix string, r ref) string {
+	name := fmt.Sprintf("%s%d", prefix, r)
+	if rd[category] == nil {
+		rd[category] = make(map[string]ref)
+	}
+	rd[category][name] = r
+	return name
+}
+
+func (rd resourceDict) String() string {
+	var out strings.Builder
+	out.WriteString("<< /ProcSet [/PDF /Text /ImageB /ImageC]")
+	categories := make([]string, 0, len(rd))
+	for category := range rd {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		fmt.Fprintf(&out, " /%s <<", category)
+		names := make([]string, 0, len(rd[category]))
+		for name := range rd[category] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&out, " /%s %s", name, rd[category][name])
+		}
+		out.WriteString(" >>")
+	}
+	out.WriteString(" >>")
+	return out.String()
+}
+
+func (c *canvas) printf(format string, args ...any) {
+	fmt.Fprintf(&c.content, format, args...)
+	c.content.WriteByte('\n')
+}
+
+// extGState registers a graphic state parameter dictionary
+// and applies it
+func (c *canvas) extGState(dict string) {
+	r := c.res.extGState(dict)
+	c.printf("/%s gs", c.resources.add("ExtGState", "GS", r))
+}
+
+// form returns the XObject drawing the content of [group]
+func (c *canvas) form(group *canvas) string {
+	return c.resources.add("XObject", "X", c.res.form(group))
+}
+
+func (c *canvas) GetBoundingBox() (left, top, right, bottom Fl) {
+	return c.bbox[0], c.bbox[1], c.bbox[2], c.bbox[3]
+}
+
+func (c *canvas) SetBoundingBox(left, top, right, bottom Fl) {
+	c.bbox = [4]Fl{left, top, right, bottom}
+}
+
+func (c *canvas) OnNewStack(f func()) {
+	c.stack = append(c.stack, canvasState{c.ctm, c.textMode})
+	c.printf("q")
+	f()
+	c.printf("Q")
+	saved := c.stack[len(c.stack)-1]
+	c.ctm, c.textMode = saved.ctm, saved.textMode
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+func (c *canvas) State() backend.GraphicState { return c }
+
+func (c *canvas) NewGroup(x, y, width, height Fl) backend.Canvas {
+	return newCanvas(c.res, x, y, x+width, y+height)
+}
+
+func (c *canvas) DrawWithOpacity(opacity Fl, group backend.Canvas) {
+	g, ok := group.(*canvas)
+	if !ok {
+		return
+	}
+	name := c.form(g)
+	if opacity >= 1 {
+		c.printf("/%s Do", name)
+		return
+	}
+	c.printf("q")
+	c.extGState(fmt.Sprintf("/ca %s /CA %s", fmtFl(opacity), fmtFl(opacity)))
+	c.printf("/%s Do", name)
+	c.printf("Q")
+}
+
+func (c *canvas) Paint(op backend.PaintOp) {
+	stroke := op&backend.Stroke != 0
+	switch {
+	case op&backend.FillEvenOdd != 0 && stroke:
+		c.printf("B*")
+	case op&backend.FillNonZero != 0 && stroke:
+		c.printf("B")
+	case op&backend.FillEvenOdd != 0:
+		c.printf("f*")
+	case op&backend.FillNonZero != 0:
+		c.printf("f")
+	case stroke:
+		c.printf("S")
+	default:
+		c.printf("n")
+	}
+}
+
+func (c *canvas) Rectangle(x, y, width, height Fl) {
+	c.printf("%s re", fmtFls(x, y, width, height))
+}
+
+func (c *canvas) MoveTo(x, y Fl) { c.printf("%s m", fmtFls(x, y)) }
+
+func (c *canvas) LineTo(x, y Fl) { c.printf("%s l", fmtFls(x, y)) }
+
+func (c *canvas) CubicTo(x1, y1, x2, y2, x3, y3 Fl) {
+	c.printf("%s c", fmtFls(x1, y1, x2, y2, x3, y3))
+}
+
+func (c *canvas) ClosePath() { c.printf("h") }
+
+func (c *canvas) AddFont(font backend.Font, content []byte) *backend.FontChars {
+	return c.res.addFont(font, content).chars
+}
+
+// GraphicState methods
+
+func (c *canvas) SetAlphaMask(mask backend.Canvas) {
+	m, ok := mask.(*canvas)
+	if !ok {
+		return
+	}
+	form := c.res.form(m)
+	c.extGState(fmt.Sprintf("/SMask << /Type /Mask /S /Luminosity /G %s >>", form))
+}
+
+func (c *canvas) Clip(evenOdd bool) {
+	if evenOdd {
+		c.printf("W* n")
+	} else {
+		c.printf("W n")
+	}
+}
+
+func (c *canvas) SetAlpha(alpha Fl, stroke bool) {
+	if stroke {
+		c.extGState("/CA " + fmtFl(alpha))
+	} else {
+		c.extGState("/ca " + fmtFl(alpha))
+	}
+}
+
+func (c *canvas) SetColorRgba(color parser.RGBA, stroke bool) {
+	op := "rg"
+	if stroke {
+		op = "RG"
+	}
+	c.printf("%s %s", fmtFls(clamp(color.R), clamp(color.G), clamp(color.B)), op)
+	c.SetAlpha(clamp(color.A), stroke)
+}
+
+func clamp(v Fl) Fl { return max(0, min(1, v)) }
+
+func (c *canvas) SetColorPattern(pattern backend.Canvas, contentWidth, contentHeight Fl, mat matrix.Transform, stroke bool) {
+	p, ok := pattern.(*canvas)
+	if !ok {
+		return
+	}
+	left, top, right, bottom := p.GetBoundingBox()
+	if right <= left || bottom <= top {
+		return
+	}
+	r := c.res.file.alloc()
+	// the pattern space is relative to the default space
+	// of the content stream where it is used
+	patternMatrix := matrix.Mul(c.ctm, mat)
+	c.res.file.set(r, func() []byte {
+		dict := fmt.Sprintf("/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox %s /XStep %s /YStep %s /Matrix %s /Resources %s",
+			fmtRect(p.bbox), fmtFl(right-left), fmtFl(bottom-top), fmtMatrix(patternMatrix), p.resources)
+		return stream(dict, p.content.Bytes())
+	})
+	name := c.resources.add("Pattern", "P", r)
+	if stroke {
+		c.printf("/Pattern CS /%s SCN", name)
+	} else {
+		c.printf("/Pattern cs /%s scn", name)
+	}
+}
+
+// blendModes maps the CSS blend mode keywords to their PDF names
+var blendModes = map[string]string{
+	"normal":      "Normal",
+	"multiply":    "Multiply",
+	"screen":      "Screen",
+	"overlay":     "Overlay",
+	"darken":      "Darken",
+	"lighten":     "Lighten",
+	"color-dodge": "ColorDodge",
+	"color-burn":  "ColorBurn",
+	"hard-light":  "HardLight",
+	"soft-light":  "SoftLight",
+	"difference":  "Difference",
+	"exclusion":   "Exclusion",
+	"hue":         "Hue",
+	"saturation":  "Saturation",
+	"color":       "Color",
+	"luminosity":  "Luminosity",
+}
+
+func (c *canvas) SetBlendingMode(mode string) {
+	if pdfMode, ok := blendModes[mode]; ok {
+		c.extGState("/BM /" + pdfMode)
+	}
+}
+
+func (c *canvas) SetLineWidth(width Fl) { c.printf("%s w", fmtFl(width)) }
+
+func (c *canvas) SetDash(dashes []Fl, offset Fl) {
+	c.printf("[%s] %s d", fmtFls(dashes...), fmtFl(offset))
+}
+
+func (c *canvas) SetStrokeOptions(opts backend.StrokeOptions) {
+	// the PDF values match the backend enums
+	c.printf("%d J %d j", opts.LineCap, opts.LineJoin)
+	if opts.MiterLimit > 0 {
+		c.printf("%s M", fmtFl(opts.MiterLimit))
+	}
+}
+
+func (c *canvas) GetTransform() matrix.Transform { return c.ctm }
+
+func (c *canvas) Transform(mt matrix.Transform) {
+	c.ctm.RightMultBy(mt)
+	c.printf("%s cm", fmtFls(mt.A, mt.B, mt.C, mt.D, mt.E, mt.F))
+}
+
+func (c *canvas) SetTextPaint(op backend.PaintOp) {
+	fill := op&(backend.FillEvenOdd|backend.FillNonZero) != 0
+	stroke := op&backend.Stroke != 0
+	mode := 3 // invisible
+	switch {
+	case fill && stroke:
+		mode = 2
+	case stroke:
+		mode = 1
+	case fill:
+		mode = 0
+	}
+	c.textMode = mode
+	c.printf("%d Tr", mode)
+}
@@ -0,0 +1,75 @@
+package pdfwriter
+
+import (
+	"fmt"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+)
+
+// separation returns the Separation color space used for [spot],
+// with a DeviceCMYK alternate.
+func (res *resources) separation(spot parser.DeviceColor) ref {
+	key := spot.Spot + " " + fmtFls(spot.CMYK[:]...)
+	if r, has := res.separations[key]; has {
+		return r
+	}
+	r := res.file.add([]byte(fmt.Sprintf("[/Separation %s /DeviceCMYK << /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [%s] /N 1 >>]",
+		name(spot.Spot), fmtFls(clampAll(spot.CMYK[:])...))))
+	res.separations[key] = r
+	return r
+}
+
+func clampAll(vs []Fl) []Fl {
+	out := make([]Fl, len(vs))
+	for i, v := range vs {
+		out[i] = clamp(v)
+	}
+	return out
+}
+
+func (c *canvas) SetColor(color parser.Color, stroke bool) {
+	switch device := color.Device; device.Space {
+	case parser.SpaceCMYK:
+		op := "k"
+		if stroke {
+			op = "K"
+		}
+		c.printf("%s %s", fmtFls(clampAll(device.CMYK[:])...), op)
+	case parser.SpaceSeparation:
+		space := c.resources.add("ColorSpace", "CS", c.res.separation(device))
+		if stroke {
+			c.printf("/%s CS %s SCN", space, fmtFl(clamp(device.Tint)))
+		} else {
+			c.printf("/%s cs %s scn", space, fmtFl(clamp(device.Tint)))
+		}
+	default:
+		c.SetColorRgba(color.RGBA, stroke)
+		return
+	}
+	c.SetAlpha(clamp(color.RGBA.A), stroke)
+}
+
+// shadingComponents returns the color space and the color components
+// to use for the shading of [gradient].
+func (c *canvas) shadingComponents(gradient backend.GradientLayout) (string, [][]Fl) {
+	out := make([][]Fl, len(gradient.Colors))
+	if devices := gradient.Devices; len(devices) == len(gradient.Colors) {
+		switch devices[0].Space {
+		case parser.SpaceCMYK:
+			for i, device := range devices {
+				out[i] = clampAll(device.CMYK[:])
+			}
+			return "/DeviceCMYK", out
+		case parser.SpaceSeparation:
+			for i, device := range devices {
+				out[i] = []Fl{clamp(device.Tint)}
+			}
+			return c.res.separation(devices[0]).String(), out
+		}
+	}
+	for i, color := range gradient.Colors {
+		out[i] = []Fl{clamp(color.R), clamp(color.G), clamp(color.B)}
+	}
+	return "/DeviceRGB", out
+}
@@ -0,0 +1,206 @@
+package pdfwriter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+// ref is an indirect object number (starting at 1)
+type ref int
+
+func (r ref) String() string { return strconv.Itoa(int(r)) + " 0 R" }
+
+// file stores the objects of a PDF file.
+//
+// The serialization of an object may be deferred until the
+// file is written, so that its content (like a content stream)
+// may be completed after its reference has been used.
+type file struct {
+	objects []func() []byte
+}
+
+// alloc reserves a new object number, whose content
+// must be set with [set] before writing.
+func (f *file) alloc() ref {
+	f.objects = append(f.objects, nil)
+	return ref(len(f.objects))
+}
+
+func (f *file) set(r ref, object func() []byte) { f.objects[r-1] = object }
+
+// add stores a new object with a known content
+func (f *file) add(object []byte) ref {
+	r := f.alloc()
+	f.set(r, func() []byte { return object })
+	return r
+}
+
+// write serializes the objects, the cross-reference table
+// and the trailer.
+func (f *file) write(w io.Writer, root, info ref) error {
+	out := &countWriter{w: bufio.NewWriter(w)}
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(f.objects))
+	for i, object := range f.objects {
+		offsets[i] = out.n
+		fmt.Fprintf(out, "%d 0 obj\n", i+1)
+		if object == nil { // should not happen
+			out.WriteString("null")
+		} else {
+			out.Write(object())
+		}
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(f.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root %s /Info %s >>\nstartxref\n%d\n%%%%EOF\n",
+		len(f.objects)+1, root, info, xref)
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	if err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+func (cw *countWriter) WriteString(s string) { cw.Write([]byte(s)) }
+
+// stream returns a stream object, compressing [content].
+// [dict] is the content of the stream dictionary, without the
+// enclosing brackets, the Length and the Filter entries.
+func stream(dict string, content []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	zw.Write(content)
+	zw.Close()
+	return rawStream(dict+" /Filter /FlateDecode", buf.Bytes())
+}
+
+// rawStream returns a stream object, storing [content] as it is
+func rawStream(dict string, content []byte) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "<< %s /Length %d >>\nstream\n", dict, len(content))
+	out.Write(content)
+	out.WriteString("\nendstream")
+	return out.Bytes()
+}
+
+// textString encodes [s] as a PDF text string, using
+// UTF-16 if needed.
+func textString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return literalString(s)
+	}
+	var out strings.Builder
+	out.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&out, "%04X", u)
+	}
+	out.WriteByte('>')
+	return out.String()
+}
+
+// literalString escapes [s] as a PDF literal string
+func literalString(s string) string {
+	var out strings.Builder
+	out.WriteByte('(')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', ')', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte(')')
+	return out.String()
+}
+
+// name encodes [s] as a PDF name, including the leading slash
+func name(s string) string {
+	var out strings.Builder
+	out.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) != -1 {
+			fmt.Fprintf(&out, "#%02X", c)
+		} else {
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// date formats [t] as a PDF date string
+func date(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("(D:%s%c%02d'%02d')", t.Format("20060102150405"), sign, offset/3600, (offset%3600)/60)
+}
+
+func fmtFl(v Fl) string {
+	if v == 0 { // avoid -0
+		return "0"
+	}
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+// fmtFls returns the space separated values
+func fmtFls(vs ...Fl) string {
+	chunks := make([]string, len(vs))
+	for i, v := range vs {
+		chunks[i] = fmtFl(v)
+	}
+	return strings.Join(chunks, " ")
+}
+
+func fmtMatrix(mt matrix.Transform) string {
+	return "[" + fmtFls(mt.A, mt.B, mt.C, mt.D, mt.E, mt.F) + "]"
+}
+
+func fmtRect(r [4]Fl) string { return "[" + fmtFls(r[0], r[1], r[2], r[3]) + "]" }
+
+func fmtBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
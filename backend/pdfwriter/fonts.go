@@ -0,0 +1,298 @@
+package pdfwriter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/go-text/typesetting/font"
+)
+
+// fontResource is a font used in the document,
+// written as a Type0 font with the Identity-H encoding,
+// so that glyphs are shown by their index.
+type fontResource struct {
+	ref     ref // the Type0 font dictionary
+	font    backend.Font
+	content []byte
+	chars   *backend.FontChars
+	used    map[uint16]bool // glyphs to embed
+
+	// full is true for the fonts used by form fields, which are not
+	// subsetted, so that any text may be typed in the fields
+	full bool
+}
+
+func (res *resources) addFont(font backend.Font, content []byte) *fontResource {
+	if f, has := res.fonts[font]; has {
+		return f
+	}
+	res.faces.Load(font, content)
+	f := &fontResource{
+		ref:     res.file.alloc(),
+		font:    font,
+		content: content,
+		chars: &backend.FontChars{
+			Cmap:    make(map[backend.GID][]rune),
+			Extents: make(map[backend.GID]backend.GlyphExtents),
+		},
+		used: make(map[uint16]bool),
+	}
+	res.fonts[font] = f
+	res.fontsOrder = append(res.fontsOrder, f)
+	return f
+}
+
+func (c *canvas) DrawText(texts []backend.TextDrawing) {
+	for _, td := range texts {
+		c.printf("BT")
+		mt := td.Matrix()
+		c.printf("%s Tm", fmtFls(mt.A, mt.B, mt.C, mt.D, mt.E, mt.F))
+		var (
+			pen    Fl // pending move, in 1/1000 of font size
+			rise   Fl
+			hidden bool
+			tj     []string // current TJ operands
+		)
+		flush := func() {
+			if len(tj) != 0 {
+				c.printf("[%s] TJ", strings.Join(tj, " "))
+				tj = tj[:0]
+			}
+		}
+		for _, run := range td.Runs {
+			f := c.res.fonts[run.Font]
+			if f == nil { // should not happen: fonts are registered by AddFont
+				continue
+			}
+			flush()
+			c.printf("/%s %s Tf", c.resources.add("Font", "F", f.ref), fmtFl(td.FontSize))
+			for _, glyph := range run.Glyphs {
+				pen += glyph.Offset
+				if glyph.Glyph == backend.GID(font.EmptyGlyph) || glyph.Glyph > 0xFFFF {
+					pen -= Fl(glyph.Kerning)
+					continue
+				}
+				if glyph.Hidden != hidden {
+					flush()
+					if glyph.Hidden {
+						c.printf("3 Tr") // invisible
+					} else {
+						c.printf("%d Tr", c.textMode)
+					}
+					hidden = glyph.Hidden
+				}
+				if r := -glyph.Rise / 1000; r != rise {
+					flush()
+					c.printf("%s Ts", fmtFl(r))
+					rise = r
+				}
+				if pen != 0 {
+					tj = append(tj, fmtFl(-pen))
+					pen = 0
+				}
+				hex := fmt.Sprintf("%04X", glyph.Glyph)
+				if last := len(tj) - 1; last >= 0 && strings.HasPrefix(tj[last], "<") {
+					tj[last] = tj[last][:len(tj[last])-1] + hex + ">"
+				} else {
+					tj = append(tj, "<"+hex+">")
+				}
+				f.used[uint16(glyph.Glyph)] = true
+				pen -= Fl(glyph.Kerning)
+			}
+		}
+		flush()
+		if rise != 0 { // Ts is not reset by ET
+			c.printf("0 Ts")
+		}
+		if hidden { // neither is Tr
+			c.printf("%d Tr", c.textMode)
+		}
+		c.printf("ET")
+	}
+}
+
+// glyphWidth returns the advance of [gid], in 1/1000 of font size
+func (res *resources) glyphWidth(f *fontResource, gid uint16) int {
+	return int(f.chars.GlyphWidth(res.faces.Face(f.font), backend.GID(gid)))
+}
+
+// writeFont sets the content of the font objects,
+// embedding a subset of the font file, or the whole file if [f.full] is true.
+func (res *resources) writeFont(f *fontResource) {
+	if f.full {
+		res.addCmapGlyphs(f)
+	}
+	gids := sortedGIDs(f.used)
+	desc := f.font.Description()
+	origin := f.font.Origin()
+
+	baseFont := postscriptName(desc.Family)
+	programGIDs := gids
+	if f.full {
+		programGIDs = nil // keep all the glyphs
+	} else {
+		baseFont = subsetTag(origin, gids) + "+" + baseFont
+	}
+
+	// widths, grouped by consecutive glyphs
+	var widths strings.Builder
+	for i, gid := range gids {
+		if i == 0 || gids[i-1] != gid-1 {
+			if i != 0 {
+				widths.WriteString("] ")
+			}
+			fmt.Fprintf(&widths, "%d [", gid)
+		} else {
+			widths.WriteByte(' ')
+		}
+		fmt.Fprintf(&widths, "%d", res.glyphWidth(f, gid))
+	}
+	if len(gids) != 0 {
+		widths.WriteByte(']')
+	}
+
+	descriptor := res.fontDescriptor(f, baseFont, desc)
+	var instance *font.Face
+	if desc.Variations != "" {
+		instance = res.faces.Face(f.font)
+	}
+	program, err := newFontProgram(f.content, int(origin.Index), programGIDs, instance)
+	if err != nil {
+		logger.WarningLogger.Printf("can't embed font %s: %s", origin.File, err)
+	} else if program.isCFF {
+		fontFile := res.file.add(stream("/Subtype /OpenType", program.content))
+		descriptor += " /FontFile3 " + fontFile.String()
+	} else {
+		fontFile := res.file.add(stream(fmt.Sprintf("/Length1 %d", len(program.content)), program.content))
+		descriptor += " /FontFile2 " + fontFile.String()
+	}
+	descriptorRef := res.file.add([]byte("<< " + descriptor + " >>"))
+
+	subtype, cidToGID := "/CIDFontType2", " /CIDToGIDMap /Identity"
+	if program.isCFF {
+		subtype, cidToGID = "/CIDFontType0", ""
+	}
+	cidFont := res.file.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype %s /BaseFont %s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %s /W [%s]%s >>",
+		subtype, name(baseFont), descriptorRef, widths.String(), cidToGID)))
+
+	toUnicode := res.file.add(stream("", toUnicodeCMap(f.chars.Cmap, gids)))
+
+	font := fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont %s /Encoding /Identity-H /DescendantFonts [%s] /ToUnicode %s >>",
+		name(baseFont), cidFont, toUnicode)
+	res.file.set(f.ref, func() []byte { return []byte(font) })
+}
+
+// addCmapGlyphs marks as used the glyphs mapped by the cmap of the font,
+// so that their widths and text are written.
+func (res *resources) addCmapGlyphs(f *fontResource) {
+	face := res.faces.Face(f.font)
+	if face == nil {
+		return
+	}
+	for iter := face.Cmap.Iter(); iter.Next(); {
+		r, gid := iter.Char()
+		if gid > 0xFFFF {
+			continue
+		}
+		f.used[uint16(gid)] = true
+		if _, has := f.chars.Cmap[backend.GID(gid)]; !has {
+			f.chars.Cmap[backend.GID(gid)] = []rune{r}
+		}
+	}
+}
+
+// fontDescriptor returns the content of the font descriptor dictionary,
+// without the font file.
+func (res *resources) fontDescriptor(f *fontResource, baseFont string, desc backend.FontDescription) string {
+	flags := 4 // symbolic
+	if f.chars.IsFixedPitch() {
+		flags |= 1
+	}
+	italicAngle := 0
+	if desc.Style != text.FSyNormal {
+		flags |= 64
+		italicAngle = -12
+	}
+	ascent, descent := desc.Ascent, -abs(desc.Descent)
+	if face := res.faces.Face(f.font); face != nil {
+		if extents, ok := face.FontHExtents(); ok {
+			upem := Fl(face.Upem())
+			ascent, descent = Fl(extents.Ascender)*1000/upem, Fl(extents.Descender)*1000/upem
+		}
+	}
+	bbox := f.chars.Bbox
+	return fmt.Sprintf("/Type /FontDescriptor /FontName %s /Flags %d /FontBBox [%d %d %d %d] /ItalicAngle %d "+
+		"/Ascent %s /Descent %s /CapHeight %s /StemV 80",
+		name(baseFont), flags, bbox[0], bbox[1], bbox[2], bbox[3], italicAngle,
+		fmtFl(ascent), fmtFl(descent), fmtFl(ascent))
+}
+
+func abs(v Fl) Fl {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// subsetTag returns the six uppercase letters prefixing the name
+// of a font subset
+func subsetTag(origin text.FontOrigin, gids []uint16) string {
+	h := fnv.New32a()
+	fmt.Fprint(h, origin.File, origin.Index, gids)
+	sum := h.Sum32()
+	var tag [6]byte
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag[:])
+}
+
+// postscriptName removes the characters not allowed in a PostScript name
+func postscriptName(family string) string {
+	out := strings.Map(func(r rune) rune {
+		if r > ' ' && r < 0x7F && !strings.ContainsRune("[](){}<>/%", r) {
+			return r
+		}
+		return -1
+	}, family)
+	if out == "" {
+		return "Font"
+	}
+	return out
+}
+
+// toUnicodeCMap returns the CMap mapping the glyphs to their text
+func toUnicodeCMap(cmap map[backend.GID][]rune, gids []uint16) []byte {
+	var entries []string
+	for _, gid := range gids {
+		runes := cmap[backend.GID(gid)]
+		if len(runes) == 0 {
+			continue
+		}
+		var dst strings.Builder
+		for _, u := range utf16.Encode(runes) {
+			fmt.Fprintf(&dst, "%04X", u)
+		}
+		entries = append(entries, fmt.Sprintf("<%04X> <%s>", gid, dst.String()))
+	}
+
+	var out strings.Builder
+	out.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for len(entries) != 0 { // at most 100 entries per block
+		n := min(len(entries), 100)
+		fmt.Fprintf(&out, "%d beginbfchar\n%s\nendbfchar\n", n, strings.Join(entries[:n], "\n"))
+		entries = entries[n:]
+	}
+	out.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return []byte(out.String())
+}
@@ -0,0 +1,262 @@
+package pdfwriter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/text"
+)
+
+// formField is an interactive field added to a page
+type formField struct {
+	rect  [4]Fl // xMin, yMin, xMax, yMax
+	field backend.FormField
+}
+
+// acroForm stores the interactive fields of the document
+type acroForm struct {
+	fields []ref                  // terminal fields and radio groups
+	radios map[string]*radioGroup // by name
+	fonts  map[string]ref         // default resources, by name
+}
+
+// radioGroup is the parent field of the radio buttons sharing the same name.
+type radioGroup struct {
+	ref   ref
+	kids  []string
+	value string // the checked value, if any
+	flags int
+}
+
+// field flags
+const (
+	ffReadOnly      = 1
+	ffMultiline     = 1 << 12
+	ffPassword      = 1 << 13
+	ffNoToggleToOff = 1 << 14
+	ffRadio         = 1 << 15
+	ffPushbutton    = 1 << 16
+	ffCombo         = 1 << 17
+	ffMultiSelect   = 1 << 21
+)
+
+const (
+	annotationPrint   = 4   // annotation flag
+	checkboxCharacter = "4" // a check mark in ZapfDingbats
+	radioCharacter    = "l" // a bullet in ZapfDingbats
+)
+
+func (p *Page) AddFormField(xMin, yMin, xMax, yMax Fl, field backend.FormField) {
+	if f := p.res.fonts[field.Font]; field.Font != nil && f != nil {
+		f.full = true // the fonts are written before the fields
+	}
+	p.fields = append(p.fields, formField{rect: [4]Fl{xMin, yMin, xMax, yMax}, field: field})
+}
+
+// fieldFont returns the resource name of the font used by a field,
+// registering it in the default resources of the form.
+//
+// The fonts registered with AddFont are embedded without subsetting,
+// so that the viewer may display any text typed in the field. The
+// others are replaced by the standard font approaching them.
+func (d *Document) fieldFont(font backend.Font) string {
+	f := d.res.fonts[font]
+	if font == nil || f == nil || !f.full {
+		return d.standardFont(standardFontName(font))
+	}
+	resourceName := fmt.Sprintf("F%d", f.ref)
+	d.form.fonts[resourceName] = f.ref
+	return name(resourceName)
+}
+
+// standardFontName returns the standard font approaching [font].
+func standardFontName(font backend.Font) string {
+	if font == nil {
+		return "Helvetica"
+	}
+	desc := font.Description()
+	family := strings.ToLower(desc.Family)
+	bold, italic := desc.Weight >= 600, desc.Style != text.FSyNormal
+	switch {
+	case strings.Contains(family, "mono") || strings.Contains(family, "courier"):
+		return standardVariant("Courier", "Oblique", bold, italic)
+	case strings.Contains(family, "serif") && !strings.Contains(family, "sans") || strings.Contains(family, "times"):
+		if !bold && !italic {
+			return "Times-Roman"
+		}
+		return standardVariant("Times", "Italic", bold, italic)
+	default:
+		return standardVariant("Helvetica", "Oblique", bold, italic)
+	}
+}
+
+func standardVariant(base, italicName string, bold, italic bool) string {
+	switch {
+	case bold && italic:
+		return base + "-Bold" + italicName
+	case bold:
+		return base + "-Bold"
+	case italic:
+		return base + "-" + italicName
+	default:
+		return base
+	}
+}
+
+// standardFont returns the resource name of the given standard font,
+// registering it in the default resources of the form.
+func (d *Document) standardFont(baseFont string) string {
+	if _, has := d.form.fonts[baseFont]; !has {
+		encoding := " /Encoding /WinAnsiEncoding"
+		if baseFont == "ZapfDingbats" {
+			encoding = ""
+		}
+		d.form.fonts[baseFont] = d.res.file.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont %s%s >>",
+			name(baseFont), encoding)))
+	}
+	return name(baseFont)
+}
+
+// onOffAppearance returns the appearance dictionary of a checkbox
+// or a radio button, drawing [char] when checked.
+func (d *Document) onOffAppearance(onState string, width, height Fl, char string, color [3]Fl) string {
+	font := d.standardFont("ZapfDingbats")
+	size := min(width, height) * 0.8
+	resources := fmt.Sprintf("/Resources << /Font << %s %s >> >>", font, d.form.fonts["ZapfDingbats"])
+	bbox := "/Type /XObject /Subtype /Form /BBox " + fmtRect([4]Fl{0, 0, width, height})
+	on := fmt.Sprintf("q BT %s %s Tf %s rg %s Td (%s) Tj ET Q", font, fmtFl(size),
+		fmtFls(color[0], color[1], color[2]), fmtFls((width-size*0.8)/2, (height-size*0.7)/2), char)
+	onRef := d.res.file.add(stream(bbox+" "+resources, []byte(on)))
+	offRef := d.res.file.add(stream(bbox, nil))
+	return fmt.Sprintf("/AP << /N << %s %s /Off %s >> >>", name(onState), onRef, offRef)
+}
+
+// writeField writes the widget annotation of [f], and returns its reference.
+func (d *Document) writeField(f formField, pageRef ref) ref {
+	field := f.field
+	rect := [4]Fl{
+		min(f.rect[0], f.rect[2]), min(f.rect[1], f.rect[3]),
+		max(f.rect[0], f.rect[2]), max(f.rect[1], f.rect[3]),
+	}
+	width, height := rect[2]-rect[0], rect[3]-rect[1]
+	color := [3]Fl{clamp(field.Color.R), clamp(field.Color.G), clamp(field.Color.B)}
+
+	if field.Name == "" {
+		field.Name = fmt.Sprintf("field-%d", len(d.form.fields)+1)
+	}
+	flags := 0
+	if field.ReadOnly {
+		flags |= ffReadOnly
+	}
+
+	widget := fmt.Sprintf("/Type /Annot /Subtype /Widget /Rect %s /P %s /F %d", fmtRect(rect), pageRef, annotationPrint)
+	var dict string
+	switch field.Kind {
+	case backend.FieldCheckbox, backend.FieldRadio:
+		onState := field.Value
+		if onState == "" {
+			onState = "on"
+		}
+		state := "/Off"
+		if field.Checked {
+			state = name(onState)
+		}
+		char := checkboxCharacter
+		if field.Kind == backend.FieldRadio {
+			char = radioCharacter
+		}
+		widget += fmt.Sprintf(" /AS %s %s /MK << /CA (%s) >>", state, d.onOffAppearance(onState, width, height, char, color), char)
+		if field.Kind == backend.FieldRadio {
+			// radio buttons are the kids of their group, which is the actual field
+			return d.addRadio(field.Name, widget, onState, field.Checked, flags|ffRadio|ffNoToggleToOff)
+		}
+		dict = fmt.Sprintf("/FT /Btn /V %s", state)
+	case backend.FieldButton:
+		flags |= ffPushbutton
+		dict = fmt.Sprintf("/FT /Btn /MK << /CA %s >>", textString(field.Value))
+	case backend.FieldSelect:
+		flags |= ffCombo
+		if field.Multiple {
+			flags = flags&^ffCombo | ffMultiSelect
+		}
+		options := make([]string, len(field.Options))
+		for i, option := range field.Options {
+			options[i] = fmt.Sprintf("[%s %s]", textString(option.Value), textString(option.Label))
+		}
+		dict = fmt.Sprintf("/FT /Ch /Opt [%s]", strings.Join(options, " "))
+		switch {
+		case field.Multiple && len(field.Selected) != 0:
+			values := make([]string, len(field.Selected))
+			for i, v := range field.Selected {
+				values[i] = textString(v)
+			}
+			dict += " /V [" + strings.Join(values, " ") + "]"
+		case len(field.Selected) != 0:
+			dict += " /V " + textString(field.Selected[0])
+		}
+	default:
+		if field.Multiline {
+			flags |= ffMultiline
+		}
+		if field.Password {
+			flags |= ffPassword
+		}
+		dict = "/FT /Tx /V " + textString(field.Value)
+		if field.MaxLength > 0 {
+			dict += fmt.Sprintf(" /MaxLen %d", field.MaxLength)
+		}
+	}
+	font := d.fieldFont(field.Font)
+	dict += fmt.Sprintf(" /T %s /Ff %d /DA (%s %s Tf %s rg)", textString(field.Name), flags,
+		font, fmtFl(field.FontSize), fmtFls(color[0], color[1], color[2]))
+
+	r := d.res.file.add([]byte("<< " + widget + " " + dict + " >>"))
+	d.form.fields = append(d.form.fields, r)
+	return r
+}
+
+// addRadio adds a widget to the group [groupName], and returns the widget reference
+func (d *Document) addRadio(groupName, widget, onState string, checked bool, flags int) ref {
+	group := d.form.radios[groupName]
+	if group == nil {
+		group = &radioGroup{ref: d.res.file.alloc(), flags: flags}
+		d.form.radios[groupName] = group
+		d.form.fields = append(d.form.fields, group.ref)
+		d.res.file.set(group.ref, func() []byte {
+			value := "/Off"
+			if group.value != "" {
+				value = name(group.value)
+			}
+			return []byte(fmt.Sprintf("<< /FT /Btn /T %s /Ff %d /V %s /Kids [%s] >>",
+				textString(groupName), group.flags, value, strings.Join(group.kids, " ")))
+		})
+	}
+	if checked {
+		group.value = onState
+	}
+	r := d.res.file.add([]byte("<< " + widget + " /Parent " + group.ref.String() + " >>"))
+	group.kids = append(group.kids, r.String())
+	return r
+}
+
+// writeAcroForm returns the interactive form dictionary, or an empty string
+func (d *Document) writeAcroForm() string {
+	if len(d.form.fields) == 0 {
+		return ""
+	}
+	defaultFont := d.standardFont("Helvetica")
+	fields := make([]string, len(d.form.fields))
+	for i, r := range d.form.fields {
+		fields[i] = r.String()
+	}
+	fonts := make([]string, 0, len(d.form.fonts))
+	for resourceName, r := range d.form.fonts {
+		fonts = append(fonts, name(resourceName)+" "+r.String())
+	}
+	sort.Strings(fonts)
+	// let the viewer build the appearance of text and choice fields
+	return fmt.Sprintf("<< /Fields [%s] /NeedAppearances true /DR << /Font << %s >> >> /DA (%s 0 Tf 0 g) >>",
+		strings.Join(fields, " "), strings.Join(fonts, " "), defaultFont)
+}
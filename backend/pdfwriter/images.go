@@ -0,0 +1,182 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/logger"
+	_ "golang.org/x/image/tiff" // used by bitmap glyphs
+)
+
+func (c *canvas) DrawRasterImage(img backend.RasterImage, width, height Fl) {
+	r, ok := c.res.image(img)
+	if !ok {
+		return
+	}
+	name := c.resources.add("XObject", "Im", r)
+	// the image space is the unit square, with its first row at y = 1
+	c.printf("q %s cm /%s Do Q", fmtFls(width, 0, 0, -height, 0, height), name)
+}
+
+type imageKey struct {
+	id          int
+	interpolate bool
+}
+
+// image returns false (after logging) if the image is invalid.
+func (res *resources) image(img backend.RasterImage) (ref, bool) {
+	key := imageKey{img.ID, !backend.IsPixelated(img.Rendering)}
+	if r, has := res.images[key]; has && img.ID != 0 {
+		return r, r != 0
+	}
+	if seeker, ok := img.Content.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+	var r ref
+	content, err := io.ReadAll(img.Content)
+	if err == nil {
+		r, err = res.addImage(content, key.interpolate)
+	}
+	if err != nil {
+		logger.WarningLogger.Printf("invalid raster image (%s): %s", img.MimeType, err)
+	}
+	if img.ID != 0 {
+		res.images[key] = r
+	}
+	return r, r != 0
+}
+
+// addImage writes the image XObject. JPEG images are directly embedded,
+// other formats are decoded and compressed.
+func (res *resources) addImage(content []byte, interpolate bool) (ref, error) {
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Interpolate %s", fmtBool(interpolate))
+
+	if config, err := jpeg.DecodeConfig(bytes.NewReader(content)); err == nil {
+		if colorSpace := jpegColorSpace(config); colorSpace != "" {
+			dict += fmt.Sprintf(" /Width %d /Height %d /BitsPerComponent 8 /ColorSpace %s /Filter /DCTDecode",
+				config.Width, config.Height, colorSpace)
+			return res.file.add(rawStream(dict, content)), nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return 0, err
+	}
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, 3*bounds.Dx()*bounds.Dy())
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a != 0 && a != 0xffff { // un-premultiply
+				r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+			}
+			rgb = append(rgb, uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			alpha = append(alpha, uint8(a>>8))
+			opaque = opaque && a == 0xffff
+		}
+	}
+	dict += fmt.Sprintf(" /Width %d /Height %d /BitsPerComponent 8 /ColorSpace /DeviceRGB", bounds.Dx(), bounds.Dy())
+	if !opaque {
+		smask := res.file.add(stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8 /ColorSpace /DeviceGray /Interpolate %s",
+			bounds.Dx(), bounds.Dy(), fmtBool(interpolate)), alpha))
+		dict += " /SMask " + smask.String()
+	}
+	return res.file.add(stream(dict, rgb)), nil
+}
+
+// jpegColorSpace returns an empty string for the JPEG images
+// which must be re-encoded
+func jpegColorSpace(config image.Config) string {
+	switch config.ColorModel {
+	case color.GrayModel:
+		return "/DeviceGray"
+	case color.YCbCrModel:
+		return "/DeviceRGB"
+	default: // CMYK JPEG have inconsistent conventions
+		return ""
+	}
+}
+
+func (c *canvas) DrawGradient(gradient backend.GradientLayout, width, height Fl) {
+	if len(gradient.Colors) == 0 {
+		return
+	}
+	c.printf("q")
+	defer c.printf("Q")
+
+	c.printf("0 0 %s re W n", fmtFls(width, height))
+	if gradient.Kind == "solid" || len(gradient.Colors) == 1 {
+		c.SetColor(gradient.Color(0), false)
+		c.printf("0 0 %s re f", fmtFls(width, height))
+		return
+	}
+
+	var (
+		transparent bool
+		alphas      = make([][]Fl, len(gradient.Colors))
+	)
+	for i, color := range gradient.Colors {
+		alphas[i] = []Fl{clamp(color.A)}
+		transparent = transparent || color.A < 1
+	}
+
+	if transparent {
+		// use a soft mask built from a gray shading of the alpha values
+		mask := c.NewGroup(0, 0, width, height).(*canvas)
+		mask.drawShading(gradient, alphas, "/DeviceGray")
+		c.SetAlphaMask(mask)
+	}
+	colorSpace, colors := c.shadingComponents(gradient)
+	c.drawShading(gradient, colors, colorSpace)
+}
+
+// drawShading paints the whole clip area with the shading defined
+// by [gradient] and [colors], the components in [colorSpace]
+// (which override the gradient colors).
+func (c *canvas) drawShading(gradient backend.GradientLayout, colors [][]Fl, colorSpace string) {
+	// the coordinates are defined for the first and last positions
+	positions := gradient.Positions
+	first, last := positions[0], positions[len(positions)-1]
+	var functions []string
+	for i := 0; i < len(colors)-1; i++ {
+		functions = append(functions, fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%s] /C1 [%s] /N 1 >>",
+			fmtFls(colors[i]...), fmtFls(colors[i+1]...)))
+	}
+	function := functions[0]
+	if len(functions) > 1 {
+		bounds := make([]Fl, len(positions)-2)
+		for i := range bounds {
+			if last != first {
+				bounds[i] = (positions[i+1] - first) / (last - first)
+			}
+		}
+		encode := strings.Repeat("0 1 ", len(functions))
+		function = fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+			strings.Join(functions, " "), fmtFls(bounds...), strings.TrimSpace(encode))
+	}
+
+	extend := fmtBool(!gradient.Reapeating)
+	shadingType, coords := 2, gradient.Coords[:4]
+	if gradient.Kind == "radial" {
+		shadingType, coords = 3, gradient.Coords[:]
+	}
+	r := c.res.file.add([]byte(fmt.Sprintf("<< /ShadingType %d /ColorSpace %s /Coords [%s] /Function %s /Extend [%s %s] >>",
+		shadingType, colorSpace, fmtFls(coords...), function, extend, extend)))
+	name := c.resources.add("Shading", "Sh", r)
+	if scaleY := gradient.ScaleY; scaleY != 1 && scaleY != 0 {
+		c.printf("1 0 0 %s 0 0 cm", fmtFl(scaleY))
+	}
+	c.printf("/%s sh", name)
+}
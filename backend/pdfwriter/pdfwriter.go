@@ -0,0 +1,455 @@
+// Package pdfwriter implements a [backend.Document] producing
+// a PDF file, without external dependencies.
+//
+// Fonts are embedded as Type0 fonts, TrueType outlines being subsetted
+// to the glyphs actually drawn. Groups are written as transparency groups
+// XObjects, gradients as shadings and alpha masks as luminosity soft masks.
+// Form fields are written as an AcroForm, using the standard fonts.
+package pdfwriter
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/utils"
+
+	drawText "github.com/benoitkugler/webrender/text/draw"
+)
+
+type Fl = utils.Fl
+
+var (
+	_ backend.Document = (*Document)(nil)
+	_ backend.Page     = (*Page)(nil)
+)
+
+// Document implements [backend.Document].
+// Use [Document.Write] to obtain the PDF file once
+// the document is drawn.
+type Document struct {
+	pages []*Page
+
+	anchors     [][]backend.Anchor
+	attachments []backend.Attachment
+	files       map[string]ref // embedded files, by id
+	bookmarks   []backend.BookmarkNode
+	pageLabels  []backend.PageLabel
+	structure   structTree
+	form        acroForm
+
+	title, description, creator, producer string
+	authors, keywords                     []string
+	created, modified                     time.Time
+
+	res *resources
+}
+
+// NewDocument returns an empty document.
+func NewDocument() *Document {
+	return &Document{
+		files: make(map[string]ref),
+		form:  acroForm{radios: make(map[string]*radioGroup), fonts: make(map[string]ref)},
+		res:   newResources(),
+	}
+}
+
+// AddPage creates a new page, whose media box is initially
+// the given rectangle.
+func (d *Document) AddPage(left, top, right, bottom Fl) backend.Page {
+	page := &Page{canvas: newCanvas(d.res, left, top, right, bottom), doc: d}
+	page.structure.index = len(d.pages)
+	page.mediaBox = [4]Fl{left, top, right, bottom}
+	page.trimBox, page.bleedBox = page.mediaBox, page.mediaBox
+	d.pages = append(d.pages, page)
+	return page
+}
+
+func (d *Document) CreateAnchors(anchors [][]backend.Anchor) { d.anchors = anchors }
+
+func (d *Document) SetAttachments(as []backend.Attachment) { d.attachments = as }
+
+func (d *Document) EmbedFile(fileID string, a backend.Attachment) {
+	if _, has := d.files[fileID]; has {
+		return
+	}
+	d.files[fileID] = d.res.fileSpec(a, fileID)
+}
+
+func (d *Document) SetTitle(title string)                    { d.title = title }
+func (d *Document) SetDescription(description string)        { d.description = description }
+func (d *Document) SetCreator(creator string)                { d.creator = creator }
+func (d *Document) SetAuthors(authors []string)              { d.authors = authors }
+func (d *Document) SetKeywords(keywords []string)            { d.keywords = keywords }
+func (d *Document) SetProducer(producer string)              { d.producer = producer }
+func (d *Document) SetDateCreation(date time.Time)           { d.created = date }
+func (d *Document) SetDateModification(date time.Time)       { d.modified = date }
+func (d *Document) SetBookmarks(root []backend.BookmarkNode) { d.bookmarks = root }
+func (d *Document) SetPageLabels(labels []backend.PageLabel) { d.pageLabels = labels }
+
+// Page implements [backend.Page].
+type Page struct {
+	*canvas
+
+	doc *Document
+
+	mediaBox, trimBox, bleedBox [4]Fl // left, top, right, bottom
+	annotations                 []annotation
+	fields                      []formField
+	structure                   pageStructure
+}
+
+// annotation is either a link (to an URL or an anchor)
+// or a file attachment
+type annotation struct {
+	rect        [4]Fl // xMin, yMin, xMax, yMax
+	url, anchor string
+	fileID      string
+
+	parent *structElem // the structure element started when adding the annotation, or nil
+}
+
+func (p *Page) AddInternalLink(xMin, yMin, xMax, yMax Fl, anchorName string) {
+	p.annotations = append(p.annotations, annotation{rect: [4]Fl{xMin, yMin, xMax, yMax}, anchor: anchorName, parent: p.structure.current()})
+}
+
+func (p *Page) AddExternalLink(xMin, yMin, xMax, yMax Fl, url string) {
+	p.annotations = append(p.annotations, annotation{rect: [4]Fl{xMin, yMin, xMax, yMax}, url: url, parent: p.structure.current()})
+}
+
+func (p *Page) AddFileAnnotation(xMin, yMin, xMax, yMax Fl, fileID string) {
+	p.annotations = append(p.annotations, annotation{rect: [4]Fl{xMin, yMin, xMax, yMax}, fileID: fileID, parent: p.structure.current()})
+}
+
+func (p *Page) SetMediaBox(left, top, right, bottom Fl) {
+	p.mediaBox = [4]Fl{left, top, right, bottom}
+}
+
+func (p *Page) SetTrimBox(left, top, right, bottom Fl) {
+	p.trimBox = [4]Fl{left, top, right, bottom}
+}
+
+func (p *Page) SetBleedBox(left, top, right, bottom Fl) {
+	p.bleedBox = [4]Fl{left, top, right, bottom}
+}
+
+// resources are shared by all the pages and groups of a document.
+type resources struct {
+	file *file
+
+	faces      drawText.Faces
+	fonts      map[backend.Font]*fontResource
+	fontsOrder []*fontResource // for reproducible outputs
+
+	images     map[imageKey]ref // 0 for invalid images
+	forms      map[*canvas]ref
+	extGStates map[string]ref // by content
+
+	separations map[string]ref // by spot name and alternate
+}
+
+func newResources() *resources {
+	return &resources{
+		file:       &file{},
+		faces:      make(drawText.Faces),
+		fonts:      make(map[backend.Font]*fontResource),
+		images:     make(map[imageKey]ref),
+		forms:      make(map[*canvas]ref),
+		extGStates: make(map[string]ref),
+
+		separations: make(map[string]ref),
+	}
+}
+
+func (res *resources) extGState(dict string) ref {
+	if r, has := res.extGStates[dict]; has {
+		return r
+	}
+	r := res.file.add([]byte("<< /Type /ExtGState " + dict + " >>"))
+	res.extGStates[dict] = r
+	return r
+}
+
+// form returns the transparency group XObject for [group].
+// Its content is read when writing the file, so that the group
+// may be used before being completed.
+func (res *resources) form(group *canvas) ref {
+	if r, has := res.forms[group]; has {
+		return r
+	}
+	r := res.file.alloc()
+	res.file.set(r, func() []byte {
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Form /BBox %s /Group << /S /Transparency /CS /DeviceRGB >> /Resources %s",
+			fmtRect(group.bbox), group.resources)
+		return stream(dict, group.content.Bytes())
+	})
+	res.forms[group] = r
+	return r
+}
+
+// fileSpec embeds the attachment and returns its file specification
+func (res *resources) fileSpec(a backend.Attachment, defaultName string) ref {
+	checksum := md5.Sum(a.Content)
+	content := res.file.add(stream(fmt.Sprintf("/Type /EmbeddedFile /Params << /Size %d /CheckSum <%x> >>",
+		len(a.Content), checksum), a.Content))
+	filename := a.Title
+	if filename == "" {
+		filename = defaultName
+	}
+	return res.file.add([]byte(fmt.Sprintf("<< /Type /Filespec /F %s /UF %s /Desc %s /EF << /F %s >> >>",
+		textString(filename), textString(filename), textString(a.Description), content)))
+}
+
+// Write finalizes the document and writes the PDF file.
+// It should only be called once.
+func (d *Document) Write(w io.Writer) error {
+	res := d.res
+	for _, f := range res.fontsOrder {
+		res.writeFont(f)
+	}
+
+	pagesRef := res.file.alloc()
+	pageRefs := make([]ref, len(d.pages))
+	for i := range d.pages {
+		pageRefs[i] = res.file.alloc()
+	}
+	for i, page := range d.pages {
+		d.writePage(page, pageRefs[i], pagesRef)
+	}
+	kids := make([]string, len(pageRefs))
+	for i, r := range pageRefs {
+		kids[i] = r.String()
+	}
+	res.file.set(pagesRef, func() []byte {
+		return []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	})
+
+	catalog := "/Type /Catalog /Pages " + pagesRef.String()
+	if tagged := d.writeStructure(pageRefs); tagged != "" {
+		catalog += " " + tagged
+	}
+	if outlines := d.writeOutlines(pageRefs); outlines != 0 {
+		catalog += " /Outlines " + outlines.String() + " /PageMode /UseOutlines"
+	}
+	if labels := d.writePageLabels(); labels != "" {
+		catalog += " /PageLabels " + labels
+	}
+	if form := d.writeAcroForm(); form != "" {
+		catalog += " /AcroForm " + form
+	}
+	var names []string
+	if dests := d.destinations(pageRefs); len(dests) != 0 {
+		names = append(names, "/Dests "+res.file.add(nameTree(dests)).String())
+	}
+	if len(d.attachments) != 0 {
+		files := make(map[string]string)
+		for i, a := range d.attachments {
+			key := fmt.Sprintf("attachment-%d", i+1)
+			files[key] = res.fileSpec(a, key).String()
+		}
+		names = append(names, "/EmbeddedFiles "+res.file.add(nameTree(files)).String())
+	}
+	if len(names) != 0 {
+		catalog += " /Names << " + strings.Join(names, " ") + " >>"
+	}
+	root := res.file.add([]byte("<< " + catalog + " >>"))
+
+	return res.file.write(w, root, res.file.add(d.info()))
+}
+
+func (d *Document) writePage(page *Page, pageRef, parent ref) {
+	res := d.res
+	page.endMarkedContent()
+	contents := res.file.add(stream("", page.content.Bytes()))
+	resources := res.file.add([]byte(page.resources.String()))
+
+	var annots []string
+	for _, annot := range page.annotations {
+		rect := fmtRect([4]Fl{
+			min(annot.rect[0], annot.rect[2]), min(annot.rect[1], annot.rect[3]),
+			max(annot.rect[0], annot.rect[2]), max(annot.rect[1], annot.rect[3]),
+		})
+		var dict string
+		switch {
+		case annot.fileID != "":
+			spec, ok := d.files[annot.fileID]
+			if !ok {
+				continue
+			}
+			// hide the default icon with an empty appearance
+			appearance := res.file.add(stream("/Type /XObject /Subtype /Form /BBox [0 0 0 0]", nil))
+			dict = fmt.Sprintf("/Subtype /FileAttachment /Rect %s /FS %s /AP << /N %s >>", rect, spec, appearance)
+		case annot.anchor != "":
+			dict = fmt.Sprintf("/Subtype /Link /Rect %s /Border [0 0 0] /Dest %s", rect, textString(annot.anchor))
+		default:
+			dict = fmt.Sprintf("/Subtype /Link /Rect %s /Border [0 0 0] /A << /S /URI /URI %s >>", rect, literalString(annot.url))
+		}
+		if annot.parent != nil {
+			dict += fmt.Sprintf(" /StructParent %d", len(d.pages)+len(d.structure.annotations))
+			d.structure.annotations = append(d.structure.annotations, annot.parent)
+		}
+		annotRef := res.file.add([]byte("<< /Type /Annot " + dict + " >>"))
+		if annot.parent != nil {
+			annot.parent.kids = append(annot.parent.kids, structKid{page: page.structure.index, annot: annotRef})
+		}
+		annots = append(annots, annotRef.String())
+	}
+	for _, field := range page.fields {
+		annots = append(annots, d.writeField(field, pageRef).String())
+	}
+
+	dict := fmt.Sprintf("/Type /Page /Parent %s /MediaBox %s /TrimBox %s /BleedBox %s /Contents %s /Resources %s "+
+		"/Group << /S /Transparency /CS /DeviceRGB >>",
+		parent, fmtRect(page.mediaBox), fmtRect(page.trimBox), fmtRect(page.bleedBox), contents, resources)
+	if len(annots) != 0 {
+		dict += " /Annots [" + strings.Join(annots, " ") + "]"
+	}
+	if d.structure.root != nil {
+		dict += fmt.Sprintf(" /StructParents %d /Tabs /S", page.structure.index)
+	}
+	res.file.set(pageRef, func() []byte { return []byte("<< " + dict + " >>") })
+}
+
+// destinations returns the named destinations, defined by the anchors
+func (d *Document) destinations(pageRefs []ref) map[string]string {
+	out := make(map[string]string)
+	for i, anchors := range d.anchors {
+		if i >= len(pageRefs) {
+			break
+		}
+		for _, anchor := range anchors {
+			out[anchor.Name] = fmt.Sprintf("[%s /XYZ %s 0]", pageRefs[i], fmtFls(anchor.X, anchor.Y))
+		}
+	}
+	return out
+}
+
+// nameTree returns a name tree with only one (root) node.
+// Keys are sorted as required by the PDF spec.
+func nameTree(values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var out strings.Builder
+	out.WriteString("<< /Names [")
+	for i, key := range keys {
+		if i != 0 {
+			out.WriteByte(' ')
+		}
+		fmt.Fprintf(&out, "%s %s", textString(key), values[key])
+	}
+	out.WriteString("] >>")
+	return []byte(out.String())
+}
+
+// labelStyles maps the numbering styles to the PDF ones
+var labelStyles = [...]string{
+	backend.LabelDecimal:    "/D",
+	backend.LabelLowerRoman: "/r",
+	backend.LabelUpperRoman: "/R",
+	backend.LabelLowerAlpha: "/a",
+	backend.LabelUpperAlpha: "/A",
+}
+
+// writePageLabels returns the page labels number tree, or an empty string
+func (d *Document) writePageLabels() string {
+	var nums []string
+	for _, label := range d.pageLabels {
+		if label.PageIndex < 0 || label.PageIndex >= len(d.pages) {
+			continue
+		}
+		dict := "<<"
+		if label.Style != backend.LabelNone && int(label.Style) < len(labelStyles) {
+			dict += " /S " + labelStyles[label.Style]
+			if label.Start > 1 {
+				dict += fmt.Sprintf(" /St %d", label.Start)
+			}
+		}
+		if label.Prefix != "" {
+			dict += " /P " + textString(label.Prefix)
+		}
+		nums = append(nums, fmt.Sprintf("%d %s >>", label.PageIndex, dict))
+	}
+	if len(nums) == 0 {
+		return ""
+	}
+	return "<< /Nums [" + strings.Join(nums, " ") + "] >>"
+}
+
+// writeOutlines returns 0 if there is no bookmarks
+func (d *Document) writeOutlines(pageRefs []ref) ref {
+	if len(d.bookmarks) == 0 {
+		return 0
+	}
+	root := d.res.file.alloc()
+	first, last, count := d.writeOutlineItems(d.bookmarks, root, pageRefs)
+	d.res.file.set(root, func() []byte {
+		return []byte(fmt.Sprintf("<< /Type /Outlines /First %s /Last %s /Count %d >>", first, last, count))
+	})
+	return root
+}
+
+// writeOutlineItems writes the given siblings, and returns
+// the first and last items, and the number of visible descendants
+func (d *Document) writeOutlineItems(nodes []backend.BookmarkNode, parent ref, pageRefs []ref) (first, last ref, count int) {
+	refs := make([]ref, len(nodes))
+	for i := range nodes {
+		refs[i] = d.res.file.alloc()
+	}
+	for i, node := range nodes {
+		dict := fmt.Sprintf("/Title %s /Parent %s", textString(node.Label), parent)
+		if i > 0 {
+			dict += " /Prev " + refs[i-1].String()
+		}
+		if i < len(nodes)-1 {
+			dict += " /Next " + refs[i+1].String()
+		}
+		if node.PageIndex >= 0 && node.PageIndex < len(pageRefs) {
+			// the bookmark position is in CSS pixels, from the top-left of the page box
+			page := d.pages[node.PageIndex]
+			x, y := page.trimBox[0]+node.X*0.75, page.trimBox[3]-node.Y*0.75
+			dict += fmt.Sprintf(" /Dest [%s /XYZ %s 0]", pageRefs[node.PageIndex], fmtFls(x, y))
+		}
+		count++
+		if len(node.Children) != 0 {
+			childFirst, childLast, childCount := d.writeOutlineItems(node.Children, refs[i], pageRefs)
+			if !node.Open {
+				childCount = -childCount
+			} else {
+				count += childCount
+			}
+			dict += fmt.Sprintf(" /First %s /Last %s /Count %d", childFirst, childLast, childCount)
+		}
+		d.res.file.set(refs[i], func() []byte { return []byte("<< " + dict + " >>") })
+	}
+	return refs[0], refs[len(refs)-1], count
+}
+
+// info returns the document information dictionary
+func (d *Document) info() []byte {
+	var entries []string
+	add := func(key, value string) {
+		if value != "" {
+			entries = append(entries, "/"+key+" "+textString(value))
+		}
+	}
+	add("Title", d.title)
+	add("Subject", d.description)
+	add("Author", strings.Join(d.authors, ", "))
+	add("Keywords", strings.Join(d.keywords, ", "))
+	add("Creator", d.creator)
+	add("Producer", d.producer)
+	if !d.created.IsZero() {
+		entries = append(entries, "/CreationDate "+date(d.created))
+	}
+	if !d.modified.IsZero() {
+		entries = append(entries, "/ModDate "+date(d.modified))
+	}
+	return []byte("<< " + strings.Join(entries, " ") + " >>")
+}
@@ -0,0 +1,398 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/utils/testutils/htmltest"
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/font/opentype/tables"
+)
+
+var (
+	red  = parser.RGBA{R: 1, A: 1}
+	blue = parser.RGBA{B: 1, A: 1}
+)
+
+func writePDF(t *testing.T, doc *Document) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkXref(t, buf.Bytes())
+	return buf.Bytes()
+}
+
+var startxrefRe = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+
+// checkXref verifies that the cross-reference table points to the objects
+func checkXref(t *testing.T, pdf []byte) {
+	t.Helper()
+	match := startxrefRe.FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("invalid startxref offset %d", xref)
+	}
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	var size int
+	fmt.Sscanf(lines[1], "0 %d", &size)
+	for i := 1; i < size; i++ {
+		var offset int
+		fmt.Sscanf(lines[2+i], "%d", &offset)
+		if header := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(pdf[offset:], []byte(header)) {
+			t.Fatalf("invalid offset for object %d", i)
+		}
+	}
+}
+
+func TestPaths(t *testing.T) {
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	page.OnNewStack(func() {
+		page.State().Transform(matrix.New(1, 0, 0, -1, 0, 100))
+		page.State().SetColorRgba(parser.RGBA{R: 1, A: 0.5}, false)
+		page.Rectangle(10, 20, 30, 40)
+		page.Paint(backend.FillNonZero)
+	})
+	if tr := page.State().GetTransform(); tr != matrix.Identity() {
+		t.Fatalf("transform not restored: %v", tr)
+	}
+
+	out := string(writePDF(t, doc))
+	for _, expected := range []string{
+		"/MediaBox [0 0 100 100]",
+		"<< /Type /ExtGState /ca 0.5 >>",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output", expected)
+		}
+	}
+}
+
+func TestGroupsAndGradients(t *testing.T) {
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+
+	group := page.NewGroup(0, 0, 50, 50)
+	group.State().SetColorRgba(red, false)
+	group.Rectangle(0, 0, 100, 100)
+	group.Paint(backend.FillNonZero)
+	page.DrawWithOpacity(0.5, group)
+
+	page.DrawGradient(backend.GradientLayout{
+		Positions:    []Fl{0, 0.5, 1},
+		Colors:       []parser.RGBA{red, blue, {G: 1, A: 0.5}},
+		GradientKind: backend.GradientKind{Kind: "linear", Coords: [6]Fl{0, 0, 100, 0}},
+		ScaleY:       1,
+	}, 100, 100)
+
+	out := string(writePDF(t, doc))
+	for _, expected := range []string{
+		"/Subtype /Form /BBox [0 0 50 50] /Group << /S /Transparency",
+		"/ShadingType 2 /ColorSpace /DeviceRGB",
+		"/ShadingType 2 /ColorSpace /DeviceGray",
+		"/FunctionType 3 /Domain [0 1]",
+		"/SMask << /Type /Mask /S /Luminosity",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output", expected)
+		}
+	}
+}
+
+func TestSubset(t *testing.T) {
+	content, err := os.ReadFile("../../resources_test/AHEM____.TTF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft, err := font.ParseTTF(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gid, _ := ft.NominalGlyph('X')
+	program, err := newFontProgram(content, 0, []uint16{uint16(gid)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.isCFF {
+		t.Fatal("expected TrueType outlines")
+	}
+	if len(program.content) >= len(content) {
+		t.Fatalf("font not subsetted: %d >= %d", len(program.content), len(content))
+	}
+	subset, err := font.ParseTTF(bytes.NewReader(program.content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outline, ok := subset.GlyphData(gid).(font.GlyphOutline); !ok || len(outline.Segments) == 0 {
+		t.Fatal("missing glyph outline in subset")
+	}
+}
+
+func TestSubsetInstance(t *testing.T) {
+	content, err := os.ReadFile("/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf")
+	if err != nil {
+		t.Skip(err)
+	}
+	ft, err := font.ParseTTF(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gid, _ := ft.NominalGlyph('a')
+	// the font is not variable, so that the outlines are only re-encoded
+	instance := font.NewFace(ft.Font)
+	instance.SetCoords([]tables.Coord{0})
+	program, err := newFontProgram(content, 0, []uint16{uint16(gid)}, instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset, err := font.ParseTTF(bytes.NewReader(program.content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ft.GlyphData(gid).(font.GlyphOutline).Segments
+	got := subset.GlyphData(gid).(font.GlyphOutline).Segments
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d segments, got %d", len(expected), len(got))
+	}
+	for i, seg := range got {
+		for j, p := range seg.ArgsSlice() {
+			if exp := expected[i].Args[j]; seg.Op != expected[i].Op || abs(p.X-exp.X) > 0.5 || abs(p.Y-exp.Y) > 0.5 {
+				t.Fatalf("segment %d: expected %v, got %v", i, expected[i], seg)
+			}
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := htmltest.Document(t, `
+	<title>My title</title>
+	<style>@page { size: 200px 100px; margin: 0 } body { margin: 0 }</style>
+	<h1 style="font-size: 10px; margin: 0">Chapter</h1>
+	<p style="margin: 0"><a href="#target">Internal</a> <a id="target" href="https://example.com">External</a></p>`)
+
+	output := NewDocument()
+	doc.Write(output, 1, []backend.Attachment{{Title: "data.txt", Content: []byte("some data")}})
+	output.SetDateCreation(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if len(output.pages) != 1 {
+		t.Fatalf("expected one page, got %d", len(output.pages))
+	}
+
+	out := string(writePDF(t, output))
+	for _, expected := range []string{
+		"/MediaBox [0 0 150 75]",
+		"/Title (My title)",
+		"/CreationDate (D:20200101000000+00'00')",
+		"/Subtype /Type0",
+		"/CIDToGIDMap /Identity",
+		"/FontFile2",
+		"/ToUnicode",
+		"/S /URI /URI (https://example.com)",
+		"/Dest (target)",
+		"/Dests",
+		"/EmbeddedFiles",
+		"/Type /Outlines",
+		"/Title (Chapter)",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestStructure(t *testing.T) {
+	doc := NewDocument()
+	for range [2]int{} {
+		page := doc.AddPage(0, 0, 100, 100)
+		page.BeginStructure(backend.StructureElement{Tag: backend.TagArtifact})
+		page.BeginStructure(backend.StructureElement{Tag: backend.TagParagraph}) // ignored
+		page.Rectangle(0, 0, 10, 10)
+		page.Paint(backend.FillNonZero)
+		page.EndStructure()
+		page.EndStructure()
+		page.BeginStructure(backend.StructureElement{Tag: backend.TagParagraph, ID: 1, Lang: "fr"})
+		page.BeginStructure(backend.StructureElement{Tag: backend.TagFigure, Alt: "An image"})
+		page.Rectangle(0, 0, 10, 10)
+		page.Paint(backend.FillNonZero)
+		page.EndStructure()
+		page.BeginStructure(backend.StructureElement{Tag: backend.TagLink, ID: 2})
+		page.AddExternalLink(0, 0, 10, 10, "https://example.com")
+		page.EndStructure()
+		page.EndStructure()
+	}
+
+	// the empty marked content sequences are removed
+	if content := doc.pages[0].content.String(); !strings.Contains(content, "/Artifact BMC") ||
+		!strings.Contains(content, "/Figure << /MCID 0 >> BDC") || strings.Count(content, "BDC") != 1 {
+		t.Fatalf("unexpected marked content:\n%s", content)
+	}
+
+	out := string(writePDF(t, doc))
+	for _, expected := range []string{
+		"/MarkInfo << /Marked true >>",
+		"/StructParents 1",
+		"/S /P /P 1 0 R /Lang (fr) /K [3 0 R 4 0 R 5 0 R]",
+		"/S /Figure /P 2 0 R /Alt (An image) /K [<< /Type /MCR /Pg 7 0 R /MCID 0 >>]",
+		// the link annotations are referenced by their element
+		"/S /Link /P 2 0 R /K [<< /Type /OBJR /Pg 7 0 R /Obj 11 0 R >> << /Type /OBJR /Pg 8 0 R /Obj 14 0 R >>]",
+		"/URI (https://example.com) >> /StructParent 2",
+		"/Nums [0 [3 0 R] 1 [5 0 R] 2 4 0 R 3 4 0 R]",
+		"/ParentTreeNextKey 4",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestFormFields(t *testing.T) {
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	page.AddFormField(10, 20, 50, 30, backend.FormField{Kind: backend.FieldText, Name: "name", Value: "Jane", FontSize: 12, MaxLength: 20})
+	page.AddFormField(10, 40, 20, 50, backend.FormField{Kind: backend.FieldCheckbox, Value: "on", Checked: true})
+	page.AddFormField(10, 60, 20, 70, backend.FormField{Kind: backend.FieldRadio, Name: "choice", Value: "a"})
+	page.AddFormField(30, 60, 40, 70, backend.FormField{Kind: backend.FieldRadio, Name: "choice", Value: "b", Checked: true})
+	page.AddFormField(10, 80, 50, 90, backend.FormField{
+		Kind: backend.FieldSelect, Name: "color", Selected: []string{"b"},
+		Options: []backend.FormOption{{Value: "r", Label: "Red"}, {Value: "b", Label: "Blue"}},
+	})
+
+	out := string(writePDF(t, doc))
+	for _, expected := range []string{
+		"/FT /Tx /V (Jane) /MaxLen 20 /T (name) /Ff 0 /DA (/Helvetica 12 Tf 0 0 0 rg)",
+		"/AS /on",
+		"/FT /Btn /V /on /T (field-2)",
+		"/FT /Btn /T (choice) /Ff 49152 /V /b /Kids [",
+		"/FT /Ch /Opt [[(r) (Red)] [(b) (Blue)]] /V (b) /T (color) /Ff 131072",
+		"/NeedAppearances true",
+		"/BaseFont /ZapfDingbats",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output:\n%s", expected, out)
+		}
+	}
+	if n := strings.Count(out, "/Subtype /Widget"); n != 5 {
+		t.Fatalf("expected 5 widgets, got %d", n)
+	}
+}
+
+func TestFormFieldFont(t *testing.T) {
+	content, err := os.ReadFile("../../resources_test/AHEM____.TTF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	f := testFont{File: "ahem"}
+	page.AddFont(f, content)
+	page.AddFormField(10, 20, 50, 30, backend.FormField{Kind: backend.FieldText, Name: "name", Font: f, FontSize: 12})
+
+	out := string(writePDF(t, doc))
+	ref := doc.res.fonts[f].ref
+	for _, expected := range []string{
+		fmt.Sprintf("/DA (/F%d 12 Tf 0 0 0 rg)", ref),
+		fmt.Sprintf("/DR << /Font << /F%d %s /Helvetica", ref, ref),
+		// the font is not subsetted
+		"/Subtype /Type0 /BaseFont /Ahem /Encoding /Identity-H",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestPrintColors(t *testing.T) {
+	gold := parser.NewSpotColor("test-pdf-gold", [4]Fl{0, 0.2, 0.8, 0}, 0.5)
+
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	page.State().SetColor(parser.NewCMYKColor(0.1, 0.2, 0.3, 0.4), false)
+	page.State().SetColor(gold, true)
+	page.Rectangle(0, 0, 10, 10)
+	page.Paint(backend.FillNonZero | backend.Stroke)
+
+	var gradient backend.GradientLayout
+	gradient.GradientKind = backend.GradientKind{Kind: "linear", Coords: [6]Fl{0, 0, 100, 0}}
+	gradient.Positions = []Fl{0, 1}
+	gradient.ScaleY = 1
+	gradient.SetColors([]parser.Color{parser.NewCMYKColor(1, 0, 0, 0), parser.NewCMYKColor(0, 1, 0, 0)})
+	if len(gradient.Devices) != 2 {
+		t.Fatal("expected device colors")
+	}
+	page.DrawGradient(gradient, 100, 100)
+
+	if content := doc.pages[0].content.String(); !strings.Contains(content, "0.1 0.2 0.3 0.4 k") ||
+		!strings.Contains(content, " CS 0.5 SCN") {
+		t.Fatalf("unexpected colors:\n%s", content)
+	}
+
+	out := string(writePDF(t, doc))
+	for _, expected := range []string{
+		"[/Separation /test-pdf-gold /DeviceCMYK << /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [0 0.2 0.8 0] /N 1 >>]",
+		"/ShadingType 2 /ColorSpace /DeviceCMYK",
+		"/C0 [1 0 0 0] /C1 [0 1 0 0]",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestPageLabels(t *testing.T) {
+	doc := NewDocument()
+	for range [4]int{} {
+		doc.AddPage(0, 0, 100, 100)
+	}
+	doc.SetPageLabels([]backend.PageLabel{
+		{PageIndex: 0, Style: backend.LabelLowerRoman, Start: 1},
+		{PageIndex: 2, Style: backend.LabelDecimal, Start: 1},
+		{PageIndex: 3, Prefix: "A-"},
+	})
+	out := string(writePDF(t, doc))
+	if expected := "/PageLabels << /Nums [0 << /S /r >> 2 << /S /D >> 3 << /P (A-) >>] >>"; !strings.Contains(out, expected) {
+		t.Fatalf("missing %s in output:\n%s", expected, out)
+	}
+}
+
+type testFont text.FontOrigin
+
+func (f testFont) Origin() text.FontOrigin { return text.FontOrigin(f) }
+
+func (testFont) Description() backend.FontDescription { return backend.FontDescription{Family: "Ahem"} }
+
+func TestHiddenGlyphs(t *testing.T) {
+	content, err := os.ReadFile("../../resources_test/AHEM____.TTF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	f := testFont{File: "ahem"}
+	page.AddFont(f, content)
+	page.State().SetTextPaint(backend.Stroke)
+	page.DrawText([]backend.TextDrawing{{
+		Runs: []backend.TextRun{{Font: f, Glyphs: []backend.TextGlyph{
+			{Glyph: 1}, {Glyph: 2, Hidden: true}, {Glyph: 3},
+		}}},
+		FontSize: 10, ScaleX: 1,
+	}})
+	writePDF(t, doc)
+
+	if content := doc.pages[0].content.String(); !strings.Contains(content, "[<0001>] TJ\n3 Tr\n[<0002>] TJ\n1 Tr\n[<0003>] TJ") {
+		t.Fatalf("unexpected content %s", content)
+	}
+}
@@ -0,0 +1,209 @@
+package pdfwriter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+)
+
+// structElem is a node of the structure tree.
+type structElem struct {
+	ref     ref
+	element backend.StructureElement
+	kids    []structKid
+}
+
+// structKid is either an element, a marked content sequence
+// or an annotation
+type structKid struct {
+	elem *structElem // nil for marked content and annotations
+
+	page  int // index of the page containing the content
+	mcid  int
+	annot ref // 0 for marked content
+}
+
+// structTree is the logical structure of the document,
+// built by the calls to BeginStructure and EndStructure
+type structTree struct {
+	root *structElem         // the Document element, nil if the document is not tagged
+	byID map[int]*structElem // elements split across pages
+
+	// the parent of each annotation, whose key in the parent tree
+	// follows the keys of the pages
+	annotations []*structElem
+}
+
+// pageStructure tracks the structure elements of a page.
+//
+// Since marked content sequences with an MCID may not be nested,
+// starting a structure element ends the sequence of its parent,
+// which is resumed after the element ends.
+type pageStructure struct {
+	index int // page index
+
+	stack    []*structElem // nil for artifacts
+	artifact int           // depth of nested elements in an artifact
+	marked   bool          // true if a marked content sequence is opened
+	// the length of the content before and after
+	// the start of the marked content sequence
+	markStart, markEnd int
+
+	mcids []*structElem // the parent of each marked content sequence
+}
+
+func (d *Document) structElement(element backend.StructureElement, parent *structElem) *structElem {
+	if d.structure.root == nil {
+		d.structure.root = &structElem{ref: d.res.file.alloc(), element: backend.StructureElement{Tag: "Document"}}
+		d.structure.byID = make(map[int]*structElem)
+	}
+	if parent == nil {
+		parent = d.structure.root
+	}
+	if elem, has := d.structure.byID[element.ID]; has && element.ID != 0 {
+		return elem
+	}
+	elem := &structElem{ref: d.res.file.alloc(), element: element}
+	parent.kids = append(parent.kids, structKid{elem: elem})
+	if element.ID != 0 {
+		d.structure.byID[element.ID] = elem
+	}
+	return elem
+}
+
+// current returns the element of the content being drawn, or nil
+func (st *pageStructure) current() *structElem {
+	if len(st.stack) == 0 {
+		return nil
+	}
+	return st.stack[len(st.stack)-1]
+}
+
+// endMarkedContent ends the current sequence, which is
+// removed if it is empty
+func (p *Page) endMarkedContent() {
+	st := &p.structure
+	if !st.marked {
+		return
+	}
+	st.marked = false
+	if p.content.Len() != st.markEnd {
+		p.printf("EMC")
+		return
+	}
+	p.content.Truncate(st.markStart)
+	if elem := st.current(); elem != nil {
+		st.mcids = st.mcids[:len(st.mcids)-1]
+		elem.kids = elem.kids[:len(elem.kids)-1]
+	}
+}
+
+// beginMarkedContent starts a sequence for the current element
+func (p *Page) beginMarkedContent() {
+	st := &p.structure
+	if len(st.stack) == 0 {
+		return
+	}
+	elem := st.stack[len(st.stack)-1]
+	st.markStart = p.content.Len()
+	if elem == nil {
+		p.printf("/Artifact BMC")
+	} else {
+		mcid := len(st.mcids)
+		st.mcids = append(st.mcids, elem)
+		elem.kids = append(elem.kids, structKid{page: st.index, mcid: mcid})
+		p.printf("%s << /MCID %d >> BDC", name(string(elem.element.Tag)), mcid)
+	}
+	st.markEnd = p.content.Len()
+	st.marked = true
+}
+
+func (p *Page) BeginStructure(element backend.StructureElement) {
+	st := &p.structure
+	if st.artifact > 0 { // the content of an artifact is not tagged
+		st.artifact++
+		return
+	}
+	p.endMarkedContent()
+	if element.Tag == backend.TagArtifact {
+		st.artifact = 1
+		st.stack = append(st.stack, nil)
+	} else {
+		var parent *structElem
+		if len(st.stack) != 0 {
+			parent = st.stack[len(st.stack)-1]
+		}
+		st.stack = append(st.stack, p.doc.structElement(element, parent))
+	}
+	p.beginMarkedContent()
+}
+
+func (p *Page) EndStructure() {
+	st := &p.structure
+	if len(st.stack) == 0 {
+		return
+	}
+	if st.artifact > 1 {
+		st.artifact--
+		return
+	}
+	st.artifact = 0
+	p.endMarkedContent()
+	st.stack = st.stack[:len(st.stack)-1]
+	p.beginMarkedContent()
+}
+
+// writeStructure writes the structure tree and returns the catalog entries
+// required by tagged documents, or an empty string.
+func (d *Document) writeStructure(pageRefs []ref) string {
+	root := d.structure.root
+	if root == nil {
+		return ""
+	}
+	var writeElem func(elem *structElem, parent ref)
+	writeElem = func(elem *structElem, parent ref) {
+		dict := "/Type /StructElem /S " + name(string(elem.element.Tag)) + " /P " + parent.String()
+		if alt := elem.element.Alt; alt != "" {
+			dict += " /Alt " + textString(alt)
+		}
+		if lang := elem.element.Lang; lang != "" {
+			dict += " /Lang " + textString(lang)
+		}
+		kids := make([]string, len(elem.kids))
+		for i, kid := range elem.kids {
+			if kid.elem != nil {
+				writeElem(kid.elem, elem.ref)
+				kids[i] = kid.elem.ref.String()
+			} else if kid.annot != 0 {
+				kids[i] = fmt.Sprintf("<< /Type /OBJR /Pg %s /Obj %s >>", pageRefs[kid.page], kid.annot)
+			} else {
+				kids[i] = fmt.Sprintf("<< /Type /MCR /Pg %s /MCID %d >>", pageRefs[kid.page], kid.mcid)
+			}
+		}
+		dict += " /K [" + strings.Join(kids, " ") + "]"
+		d.res.file.set(elem.ref, func() []byte { return []byte("<< " + dict + " >>") })
+	}
+	treeRoot := d.res.file.alloc()
+	writeElem(root, treeRoot)
+
+	// the parent tree maps the marked content of each page,
+	// and then each annotation, to its structure element
+	var nums []string
+	for i, page := range d.pages {
+		parents := make([]string, len(page.structure.mcids))
+		for j, elem := range page.structure.mcids {
+			parents[j] = elem.ref.String()
+		}
+		nums = append(nums, fmt.Sprintf("%d [%s]", i, strings.Join(parents, " ")))
+	}
+	for i, elem := range d.structure.annotations {
+		nums = append(nums, fmt.Sprintf("%d %s", len(d.pages)+i, elem.ref))
+	}
+	parentTree := d.res.file.add([]byte("<< /Nums [" + strings.Join(nums, " ") + "] >>"))
+	d.res.file.set(treeRoot, func() []byte {
+		return []byte(fmt.Sprintf("<< /Type /StructTreeRoot /K %s /ParentTree %s /ParentTreeNextKey %d >>",
+			root.ref, parentTree, len(d.pages)+len(d.structure.annotations)))
+	})
+	return fmt.Sprintf("/StructTreeRoot %s /MarkInfo << /Marked true >> /ViewerPreferences << /DisplayDocTitle true >>", treeRoot)
+}
@@ -0,0 +1,301 @@
+package pdfwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+// fontProgram is an embeddable font file
+type fontProgram struct {
+	content []byte
+	isCFF   bool // true for OpenType fonts with PostScript outlines
+}
+
+// tables kept in TrueType subsets : the ones required by the PDF spec for
+// CIDFontType2 fonts, and the 'cmap' table, expected by most font parsers
+var subsetTables = [...]string{"cmap", "head", "hhea", "hmtx", "maxp", "loca", "glyf", "cvt ", "fpgm", "prep"}
+
+// newFontProgram returns the font file for the face at [index] in [content],
+// keeping only the glyphs in [gids] for TrueType fonts.
+// All the glyphs are kept if [gids] is nil.
+// Glyph indices are preserved, so that no mapping is required.
+//
+// If not nil, [instance] is a variable font face whose outlines replace
+// the default ones. Only TrueType outlines are instantiated.
+func newFontProgram(content []byte, index int, gids []uint16, instance *font.Face) (fontProgram, error) {
+	loaders, err := ot.NewLoaders(bytes.NewReader(content))
+	if err != nil {
+		return fontProgram{}, err
+	}
+	if index >= len(loaders) {
+		return fontProgram{}, errors.New("invalid index in font collection")
+	}
+	ld := loaders[index]
+
+	if !ld.HasTable(ot.MustNewTag("glyf")) {
+		// PostScript outlines are not subsetted
+		var tables []ot.Table
+		for _, tag := range ld.Tables() {
+			table, err := ld.RawTable(tag)
+			if err != nil {
+				return fontProgram{}, err
+			}
+			tables = append(tables, ot.Table{Tag: tag, Content: table})
+		}
+		return fontProgram{content: ot.WriteTTF(tables), isCFF: true}, nil
+	}
+
+	raw := make(map[string][]byte)
+	for _, tag := range subsetTables {
+		t := ot.MustNewTag(tag)
+		if !ld.HasTable(t) {
+			continue
+		}
+		raw[tag], err = ld.RawTable(t)
+		if err != nil {
+			return fontProgram{}, err
+		}
+	}
+	if err := subsetGlyf(raw, gids); err != nil {
+		return fontProgram{}, err
+	}
+	if instance != nil && len(instance.Coords()) != 0 {
+		instanceGlyf(raw, instance)
+	}
+
+	var tables []ot.Table
+	for _, tag := range subsetTables {
+		if table, ok := raw[tag]; ok {
+			tables = append(tables, ot.Table{Tag: ot.MustNewTag(tag), Content: table})
+		}
+	}
+	return fontProgram{content: ot.WriteTTF(tables)}, nil
+}
+
+var errInvalidGlyf = errors.New("invalid glyf table")
+
+// subsetGlyf empties the glyphs not in [gids] (nor used by composite glyphs),
+// rewriting the 'glyf' and 'loca' tables (the later in long format).
+// A nil [gids] keeps all the glyphs.
+func subsetGlyf(tables map[string][]byte, gids []uint16) error {
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || len(maxp) < 6 {
+		return errInvalidGlyf
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+
+	offsets := make([]uint32, numGlyphs+1)
+	for i := range offsets {
+		if longLoca {
+			if 4*i+4 > len(loca) {
+				return errInvalidGlyf
+			}
+			offsets[i] = binary.BigEndian.Uint32(loca[4*i:])
+		} else {
+			if 2*i+2 > len(loca) {
+				return errInvalidGlyf
+			}
+			offsets[i] = 2 * uint32(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+	}
+	glyphData := func(gid int) []byte {
+		start, end := offsets[gid], offsets[gid+1]
+		if start >= end || int(end) > len(glyf) {
+			return nil
+		}
+		return glyf[start:end]
+	}
+
+	// resolve the composite glyphs, and always keep the .notdef glyph
+	used := map[int]bool{0: true}
+	queue := []int{0}
+	for _, gid := range gids {
+		queue = append(queue, int(gid))
+	}
+	for len(queue) != 0 {
+		gid := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if gid >= numGlyphs {
+			continue
+		}
+		used[gid] = true
+		for _, component := range compositeComponents(glyphData(gid)) {
+			if !used[component] {
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if used[gid] || gids == nil {
+			newGlyf = append(newGlyf, glyphData(gid)...)
+			for len(newGlyf)%4 != 0 { // keep the glyphs aligned
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	tables["head"], tables["loca"], tables["glyf"] = newHead, newLoca, newGlyf
+	return nil
+}
+
+// instanceGlyf replaces the glyphs of the 'glyf' table written by [subsetGlyf]
+// by the outlines of [instance], as simple glyphs.
+// The hinting instructions are dropped, since they are designed for the default outlines.
+func instanceGlyf(tables map[string][]byte, instance *font.Face) {
+	loca, glyf := tables["loca"], tables["glyf"]
+	numGlyphs := len(loca)/4 - 1
+	var newGlyf []byte
+	newLoca := make([]byte, len(loca))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		start, end := binary.BigEndian.Uint32(loca[4*gid:]), binary.BigEndian.Uint32(loca[4*gid+4:])
+		if start >= end {
+			continue
+		}
+		data := glyf[start:end]
+		if outline, ok := instance.GlyphData(font.GID(gid)).(font.GlyphOutline); ok {
+			if encoded := encodeOutline(outline); encoded != nil {
+				data = encoded
+			}
+		}
+		newGlyf = append(newGlyf, data...)
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+	tables["loca"], tables["glyf"] = newLoca, newGlyf
+}
+
+// encodeOutline returns the 'glyf' data of a simple glyph drawing [outline],
+// or nil if it is empty or uses cubic segments.
+func encodeOutline(outline font.GlyphOutline) []byte {
+	const onCurve = 1
+	var (
+		endPts []uint16
+		flags  []byte
+		xs, ys []int16
+		start  int // first point of the current contour
+	)
+	addPoint := func(p font.SegmentPoint, flag byte) {
+		flags = append(flags, flag)
+		xs = append(xs, int16(math.Round(float64(p.X))))
+		ys = append(ys, int16(math.Round(float64(p.Y))))
+	}
+	closeContour := func() {
+		// contours are implicitly closed
+		if last := len(xs) - 1; last > start && xs[last] == xs[start] && ys[last] == ys[start] && flags[last] == onCurve {
+			flags, xs, ys = flags[:last], xs[:last], ys[:last]
+		}
+		endPts = append(endPts, uint16(len(xs)-1))
+	}
+	for _, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			if len(xs) != 0 {
+				closeContour()
+			}
+			start = len(xs)
+			addPoint(seg.Args[0], onCurve)
+		case ot.SegmentOpLineTo:
+			addPoint(seg.Args[0], onCurve)
+		case ot.SegmentOpQuadTo:
+			addPoint(seg.Args[0], 0)
+			addPoint(seg.Args[1], onCurve)
+		default:
+			return nil
+		}
+	}
+	if len(xs) == 0 {
+		return nil
+	}
+	closeContour()
+
+	xMin, xMax, yMin, yMax := xs[0], xs[0], ys[0], ys[0]
+	for i := range xs {
+		xMin, xMax = min(xMin, xs[i]), max(xMax, xs[i])
+		yMin, yMax = min(yMin, ys[i]), max(yMax, ys[i])
+	}
+
+	// coordinates are written as 2 bytes deltas
+	out := make([]byte, 0, 12+2*len(endPts)+5*len(xs))
+	for _, v := range [...]int16{int16(len(endPts)), xMin, yMin, xMax, yMax} {
+		out = binary.BigEndian.AppendUint16(out, uint16(v))
+	}
+	for _, end := range endPts {
+		out = binary.BigEndian.AppendUint16(out, end)
+	}
+	out = binary.BigEndian.AppendUint16(out, 0) // no instructions
+	out = append(out, flags...)
+	for _, coords := range [2][]int16{xs, ys} {
+		var last int16
+		for _, v := range coords {
+			out = binary.BigEndian.AppendUint16(out, uint16(v-last))
+			last = v
+		}
+	}
+	return out
+}
+
+// compositeComponents returns the glyphs referenced by a composite glyph
+func compositeComponents(data []byte) []int {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil // simple glyph
+	}
+	const (
+		argsAreWords    = 0x0001
+		haveScale       = 0x0008
+		moreComponents  = 0x0020
+		haveXYScale     = 0x0040
+		haveTwoByTwo    = 0x0080
+		componentHeader = 4
+	)
+	var out []int
+	pos := 10
+	for pos+componentHeader <= len(data) {
+		flags := binary.BigEndian.Uint16(data[pos:])
+		out = append(out, int(binary.BigEndian.Uint16(data[pos+2:])))
+		pos += componentHeader
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			pos += 2
+		case flags&haveXYScale != 0:
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return out
+}
+
+// sortedGIDs returns the keys of [set], sorted
+func sortedGIDs(set map[uint16]bool) []uint16 {
+	out := make([]uint16, 0, len(set))
+	for gid := range set {
+		out = append(out, gid)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
@@ -0,0 +1,188 @@
+package raster
+
+import (
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+var (
+	_ backend.Canvas       = (*canvas)(nil)
+	_ backend.GraphicState = (*canvas)(nil)
+	_ backend.BlurCanvas   = (*canvas)(nil)
+)
+
+// op is one recorded drawing operation
+type op func(r *renderer)
+
+// canvas implements [backend.Canvas] by recording the
+// drawing operations, so that groups may be rendered several times,
+// at the resolution required by their usage (patterns, masks, opacity groups).
+type canvas struct {
+	res  *resources // shared by all the pages
+	bbox [4]Fl      // left, top, right, bottom
+	ops  []op
+
+	// the CTM is tracked to implement GetTransform
+	ctm   matrix.Transform
+	stack []matrix.Transform
+}
+
+func newCanvas(res *resources, left, top, right, bottom Fl) *canvas {
+	return &canvas{res: res, bbox: [4]Fl{left, top, right, bottom}, ctm: matrix.Identity()}
+}
+
+func (c *canvas) record(o op) { c.ops = append(c.ops, o) }
+
+func (c *canvas) GetBoundingBox() (left, top, right, bottom Fl) {
+	return c.bbox[0], c.bbox[1], c.bbox[2], c.bbox[3]
+}
+
+func (c *canvas) SetBoundingBox(left, top, right, bottom Fl) {
+	c.bbox = [4]Fl{left, top, right, bottom}
+}
+
+func (c *canvas) OnNewStack(f func()) {
+	c.stack = append(c.stack, c.ctm)
+	c.record(func(r *renderer) { r.save() })
+	f()
+	c.record(func(r *renderer) { r.restore() })
+	c.ctm = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+func (c *canvas) State() backend.GraphicState { return c }
+
+func (c *canvas) NewGroup(x, y, width, height Fl) backend.Canvas {
+	return newCanvas(c.res, x, y, x+width, y+height)
+}
+
+func (c *canvas) DrawWithOpacity(opacity Fl, group backend.Canvas) {
+	g, ok := group.(*canvas)
+	if !ok {
+		return
+	}
+	c.record(func(r *renderer) { r.drawGroup(g, opacity) })
+}
+
+func (c *canvas) DrawWithBlur(stdDeviation Fl, group backend.Canvas) {
+	g, ok := group.(*canvas)
+	if !ok {
+		return
+	}
+	c.record(func(r *renderer) { r.drawBlurredGroup(g, stdDeviation) })
+}
+
+func (c *canvas) Paint(op backend.PaintOp) {
+	c.record(func(r *renderer) { r.paint(op) })
+}
+
+func (c *canvas) Rectangle(x, y, width, height Fl) {
+	c.record(func(r *renderer) {
+		r.path.rectangle(float64(x), float64(y), float64(width), float64(height))
+	})
+}
+
+func (c *canvas) MoveTo(x, y Fl) {
+	c.record(func(r *renderer) { r.path.moveTo(point{float64(x), float64(y)}) })
+}
+
+func (c *canvas) LineTo(x, y Fl) {
+	c.record(func(r *renderer) { r.path.lineTo(point{float64(x), float64(y)}) })
+}
+
+func (c *canvas) CubicTo(x1, y1, x2, y2, x3, y3 Fl) {
+	c.record(func(r *renderer) {
+		r.path.cubicTo(point{float64(x1), float64(y1)}, point{float64(x2), float64(y2)}, point{float64(x3), float64(y3)})
+	})
+}
+
+func (c *canvas) ClosePath() { c.record(func(r *renderer) { r.path.close() }) }
+
+func (c *canvas) AddFont(font backend.Font, content []byte) *backend.FontChars {
+	return c.res.addFont(font, content)
+}
+
+func (c *canvas) DrawText(texts []backend.TextDrawing) {
+	texts = append([]backend.TextDrawing(nil), texts...)
+	c.record(func(r *renderer) { r.drawText(texts) })
+}
+
+func (c *canvas) DrawRasterImage(img backend.RasterImage, width, height Fl) {
+	decoded := c.res.decodeImage(img)
+	if decoded == nil {
+		return
+	}
+	smooth := !backend.IsPixelated(img.Rendering)
+	c.record(func(r *renderer) { r.drawImage(decoded, width, height, smooth) })
+}
+
+func (c *canvas) DrawGradient(gradient backend.GradientLayout, width, height Fl) {
+	c.record(func(r *renderer) { r.drawGradient(gradient, width, height) })
+}
+
+// GraphicState methods
+
+func (c *canvas) SetAlphaMask(mask backend.Canvas) {
+	m, ok := mask.(*canvas)
+	if !ok {
+		return
+	}
+	c.record(func(r *renderer) { r.setAlphaMask(m) })
+}
+
+func (c *canvas) Clip(evenOdd bool) { c.record(func(r *renderer) { r.clip(evenOdd) }) }
+
+func (c *canvas) SetAlpha(alpha Fl, stroke bool) {
+	c.record(func(r *renderer) { r.paintState(stroke).alpha = alpha })
+}
+
+func (c *canvas) SetColorRgba(color parser.RGBA, stroke bool) {
+	c.record(func(r *renderer) {
+		*r.paintState(stroke) = paintState{color: color, alpha: color.A}
+	})
+}
+
+// SetColor uses the RGB approximation of print colors.
+func (c *canvas) SetColor(color parser.Color, stroke bool) { c.SetColorRgba(color.RGBA, stroke) }
+
+func (c *canvas) SetColorPattern(pattern backend.Canvas, contentWidth, contentHeight Fl, mat matrix.Transform, stroke bool) {
+	p, ok := pattern.(*canvas)
+	if !ok {
+		return
+	}
+	c.record(func(r *renderer) { r.setPattern(p, mat, stroke) })
+}
+
+func (c *canvas) SetBlendingMode(mode string) {
+	blend := newBlendMode(mode)
+	c.record(func(r *renderer) { r.state.blend = blend })
+}
+
+func (c *canvas) SetLineWidth(width Fl) {
+	c.record(func(r *renderer) { r.state.line.width = float64(width) })
+}
+
+func (c *canvas) SetDash(dashes []Fl, offset Fl) {
+	ds := make([]float64, len(dashes))
+	for i, d := range dashes {
+		ds[i] = float64(d)
+	}
+	ds = normalizeDashes(ds)
+	c.record(func(r *renderer) { r.state.line.dashes, r.state.line.dashOffset = ds, float64(offset) })
+}
+
+func (c *canvas) SetStrokeOptions(opts backend.StrokeOptions) {
+	c.record(func(r *renderer) { r.state.line.StrokeOptions = opts })
+}
+
+func (c *canvas) GetTransform() matrix.Transform { return c.ctm }
+
+func (c *canvas) Transform(mt matrix.Transform) {
+	c.ctm.RightMultBy(mt)
+	c.record(func(r *renderer) { r.state.ctm.RightMultBy(mt) })
+}
+
+func (c *canvas) SetTextPaint(op backend.PaintOp) {
+	c.record(func(r *renderer) { r.state.textPaint = op })
+}
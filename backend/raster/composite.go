@@ -0,0 +1,325 @@
+package raster
+
+import (
+	"image"
+	"math"
+)
+
+// rgba is a premultiplied color
+type rgba [4]float32
+
+// layer is a premultiplied RGBA float buffer covering [rect]
+type layer struct {
+	rect image.Rectangle
+	pix  []float32
+}
+
+func newLayer(rect image.Rectangle) *layer {
+	return &layer{rect: rect, pix: make([]float32, 4*rect.Dx()*rect.Dy())}
+}
+
+func (l *layer) offset(x, y int) int {
+	return 4 * ((y-l.rect.Min.Y)*l.rect.Dx() + x - l.rect.Min.X)
+}
+
+func (l *layer) at(x, y int) rgba {
+	if !(image.Point{x, y}).In(l.rect) {
+		return rgba{}
+	}
+	i := l.offset(x, y)
+	return rgba{l.pix[i], l.pix[i+1], l.pix[i+2], l.pix[i+3]}
+}
+
+// toImage converts the layer to a 8-bit image
+func (l *layer) toImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, l.rect.Dx(), l.rect.Dy()))
+	for i, v := range l.pix {
+		img.Pix[i] = to8bit(v)
+	}
+	return img
+}
+
+func to8bit(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	} else if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
+
+// luminosity converts the layer to a mask, as
+// required by SVG masks
+func (l *layer) luminosity() *mask {
+	out := newMask(l.rect)
+	for i := range out.alpha {
+		r, g, b := l.pix[4*i], l.pix[4*i+1], l.pix[4*i+2]
+		out.alpha[i] = 0.2125*r + 0.7154*g + 0.0721*b
+	}
+	return out
+}
+
+// blur approximates a gaussian blur with standard deviation [sigma] (in pixels)
+// by three successive box blurs, as explained in
+// https://www.w3.org/TR/filter-effects-1/#feGaussianBlurElement.
+// Pixels outside of the layer are considered transparent.
+func (l *layer) blur(sigma float64) {
+	if sigma <= 0 || l.rect.Empty() {
+		return
+	}
+	size := int(math.Floor(sigma*3*math.Sqrt(2*math.Pi)/4 + 0.5))
+	if size < 1 {
+		return
+	}
+	// for even sizes, the first two windows are centered on the
+	// left and right boundaries of the pixel, and the last one is one pixel larger
+	sizes, befores := [3]int{size, size, size}, [3]int{size / 2, size / 2, size / 2}
+	if size%2 == 0 {
+		befores[1] = size/2 - 1
+		sizes[2] = size + 1
+	}
+	width, height := l.rect.Dx(), l.rect.Dy()
+	line := make([]float32, 4*max(width, height))
+	for pass := range sizes {
+		for y := 0; y < height; y++ {
+			boxBlur(l.pix[4*y*width:], 4, width, sizes[pass], befores[pass], line)
+		}
+		for x := 0; x < width; x++ {
+			boxBlur(l.pix[4*x:], 4*width, height, sizes[pass], befores[pass], line)
+		}
+	}
+}
+
+// boxBlur averages the [n] pixels of [pix], separated by [stride] values,
+// over a window of [size] pixels, starting [before] pixels before the current one.
+// [tmp] is used as scratch space.
+func boxBlur(pix []float32, stride, n, size, before int, tmp []float32) {
+	after := size - 1 - before
+	var sum [4]float32
+	for i := 0; i < after && i < n; i++ {
+		for c := 0; c < 4; c++ {
+			sum[c] += pix[i*stride+c]
+		}
+	}
+	for i := 0; i < n; i++ {
+		if j := i + after; j < n {
+			for c := 0; c < 4; c++ {
+				sum[c] += pix[j*stride+c]
+			}
+		}
+		for c := 0; c < 4; c++ {
+			tmp[4*i+c] = sum[c] / float32(size)
+		}
+		if j := i - before; j >= 0 {
+			for c := 0; c < 4; c++ {
+				sum[c] -= pix[j*stride+c]
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		copy(pix[i*stride:i*stride+4], tmp[4*i:4*i+4])
+	}
+}
+
+type blendMode uint8
+
+const (
+	blendNormal blendMode = iota
+	blendMultiply
+	blendScreen
+	blendOverlay
+	blendDarken
+	blendLighten
+	blendColorDodge
+	blendColorBurn
+	blendHardLight
+	blendSoftLight
+	blendDifference
+	blendExclusion
+	blendHue
+	blendSaturation
+	blendColor
+	blendLuminosity
+)
+
+func newBlendMode(mode string) blendMode {
+	switch mode {
+	case "multiply":
+		return blendMultiply
+	case "screen":
+		return blendScreen
+	case "overlay":
+		return blendOverlay
+	case "darken":
+		return blendDarken
+	case "lighten":
+		return blendLighten
+	case "color-dodge":
+		return blendColorDodge
+	case "color-burn":
+		return blendColorBurn
+	case "hard-light":
+		return blendHardLight
+	case "soft-light":
+		return blendSoftLight
+	case "difference":
+		return blendDifference
+	case "exclusion":
+		return blendExclusion
+	case "hue":
+		return blendHue
+	case "saturation":
+		return blendSaturation
+	case "color":
+		return blendColor
+	case "luminosity":
+		return blendLuminosity
+	default:
+		return blendNormal
+	}
+}
+
+// see https://www.w3.org/TR/compositing-1/#blendingseparable
+func (mode blendMode) separable(cb, cs float32) float32 {
+	switch mode {
+	case blendMultiply:
+		return cb * cs
+	case blendScreen:
+		return cb + cs - cb*cs
+	case blendOverlay:
+		return blendHardLight.separable(cs, cb)
+	case blendDarken:
+		return float32(math.Min(float64(cb), float64(cs)))
+	case blendLighten:
+		return float32(math.Max(float64(cb), float64(cs)))
+	case blendColorDodge:
+		if cb == 0 {
+			return 0
+		} else if cs >= 1 {
+			return 1
+		}
+		return float32(math.Min(1, float64(cb/(1-cs))))
+	case blendColorBurn:
+		if cb >= 1 {
+			return 1
+		} else if cs <= 0 {
+			return 0
+		}
+		return 1 - float32(math.Min(1, float64((1-cb)/cs)))
+	case blendHardLight:
+		if cs <= 0.5 {
+			return blendMultiply.separable(cb, 2*cs)
+		}
+		return blendScreen.separable(cb, 2*cs-1)
+	case blendSoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		var d float32
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		} else {
+			d = float32(math.Sqrt(float64(cb)))
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case blendDifference:
+		return float32(math.Abs(float64(cb - cs)))
+	case blendExclusion:
+		return cb + cs - 2*cb*cs
+	default:
+		return cs
+	}
+}
+
+func lum(c [3]float32) float32 { return 0.3*c[0] + 0.59*c[1] + 0.11*c[2] }
+
+func clipColor(c [3]float32) [3]float32 {
+	l := lum(c)
+	n := min(c[0], c[1], c[2])
+	x := max(c[0], c[1], c[2])
+	if n < 0 {
+		for i := range c {
+			c[i] = l + (c[i]-l)*l/(l-n)
+		}
+	}
+	if x > 1 {
+		for i := range c {
+			c[i] = l + (c[i]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
+
+func setLum(c [3]float32, l float32) [3]float32 {
+	d := l - lum(c)
+	return clipColor([3]float32{c[0] + d, c[1] + d, c[2] + d})
+}
+
+func sat(c [3]float32) float32 { return max(c[0], c[1], c[2]) - min(c[0], c[1], c[2]) }
+
+func setSat(c [3]float32, s float32) [3]float32 {
+	// find the indices of max, mid, min
+	imax, imid, imin := 0, 1, 2
+	if c[imax] < c[imid] {
+		imax, imid = imid, imax
+	}
+	if c[imid] < c[imin] {
+		imid, imin = imin, imid
+	}
+	if c[imax] < c[imid] {
+		imax, imid = imid, imax
+	}
+	var out [3]float32
+	if c[imax] > c[imin] {
+		out[imid] = (c[imid] - c[imin]) * s / (c[imax] - c[imin])
+		out[imax] = s
+	}
+	return out
+}
+
+// see https://www.w3.org/TR/compositing-1/#blendingnonseparable
+func (mode blendMode) nonSeparable(cb, cs [3]float32) [3]float32 {
+	switch mode {
+	case blendHue:
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case blendSaturation:
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case blendColor:
+		return setLum(cs, lum(cb))
+	default: // luminosity
+		return setLum(cb, lum(cs))
+	}
+}
+
+// blend composes [src] over [dst] (both premultiplied)
+func (mode blendMode) blend(dst, src rgba) rgba {
+	sa, da := src[3], dst[3]
+	if mode == blendNormal || da == 0 {
+		return rgba{
+			src[0] + dst[0]*(1-sa),
+			src[1] + dst[1]*(1-sa),
+			src[2] + dst[2]*(1-sa),
+			sa + da*(1-sa),
+		}
+	}
+	if sa == 0 {
+		return dst
+	}
+	var cb, cs, b [3]float32
+	for i := range cb {
+		cb[i], cs[i] = dst[i]/da, src[i]/sa
+	}
+	if mode >= blendHue {
+		b = mode.nonSeparable(cb, cs)
+	} else {
+		for i := range b {
+			b[i] = mode.separable(cb[i], cs[i])
+		}
+	}
+	var out rgba
+	for i := range b {
+		out[i] = (1-da)*src[i] + (1-sa)*dst[i] + sa*da*b[i]
+	}
+	out[3] = sa + da*(1-sa)
+	return out
+}
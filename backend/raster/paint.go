@@ -0,0 +1,224 @@
+package raster
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+// source is a paint source, returning the premultiplied color
+// at the center of a device pixel
+type source interface {
+	at(x, y int) rgba
+}
+
+func premultiply(c parser.RGBA) rgba {
+	clamp := func(v float32) float32 { return float32(math.Max(0, math.Min(1, float64(v)))) }
+	a := clamp(c.A)
+	return rgba{clamp(c.R) * a, clamp(c.G) * a, clamp(c.B) * a, a}
+}
+
+type solid rgba
+
+func (s solid) at(x, y int) rgba { return rgba(s) }
+
+// pixelCenter returns the center of the device pixel (x, y),
+// mapped by [inv]
+func pixelCenter(inv matrix.Transform, x, y int) point {
+	return transform(inv, point{float64(x) + 0.5, float64(y) + 0.5})
+}
+
+// patternSource repeats [tile], which is the rendering of
+// the rectangle (x, y, width, height) in pattern space, at resolution (sx, sy).
+type patternSource struct {
+	tile *layer
+	inv  matrix.Transform // device to pattern space
+
+	x, y, width, height float64
+	sx, sy              float64
+}
+
+func (ps *patternSource) at(x, y int) rgba {
+	p := pixelCenter(ps.inv, x, y)
+	u := math.Mod(p.x-ps.x, ps.width)
+	if u < 0 {
+		u += ps.width
+	}
+	v := math.Mod(p.y-ps.y, ps.height)
+	if v < 0 {
+		v += ps.height
+	}
+	return ps.tile.at(int(u*ps.sx), int(v*ps.sy))
+}
+
+type gradientSource struct {
+	inv       matrix.Transform // device to user space
+	scaleY    float64
+	radial    bool
+	coords    [6]float64
+	positions []float64
+	colors    []rgba
+	extend    bool
+}
+
+func newGradientSource(gradient backend.GradientLayout, inv matrix.Transform) *gradientSource {
+	out := &gradientSource{
+		inv:    inv,
+		scaleY: float64(gradient.ScaleY),
+		radial: gradient.Kind == "radial",
+		extend: !gradient.Reapeating,
+	}
+	if out.scaleY == 0 {
+		out.scaleY = 1
+	}
+	for i, c := range gradient.Coords {
+		out.coords[i] = float64(c)
+	}
+	out.positions = make([]float64, len(gradient.Positions))
+	for i, p := range gradient.Positions {
+		out.positions[i] = float64(p)
+	}
+	out.colors = make([]rgba, len(gradient.Colors))
+	for i, c := range gradient.Colors {
+		out.colors[i] = premultiply(c)
+	}
+	return out
+}
+
+// parameter returns the position on the gradient axis of [p],
+// with 0 at the starting point (or circle) and 1 at the ending point,
+// or false if the point should not be painted
+func (gs *gradientSource) parameter(p point) (float64, bool) {
+	c := gs.coords
+	if !gs.radial {
+		d := point{c[2] - c[0], c[3] - c[1]}
+		l := d.dot(d)
+		if l == 0 {
+			return 0, false
+		}
+		t := p.sub(point{c[0], c[1]}).dot(d) / l
+		return gs.clamp(t)
+	}
+
+	// two points conical gradient, as in PDF
+	c0, r0 := point{c[0], c[1]}, c[2]
+	dc, dr := point{c[3] - c[0], c[4] - c[1]}, c[5]-c[2]
+	pd := p.sub(c0)
+	a := dc.dot(dc) - dr*dr
+	b := pd.dot(dc) + r0*dr
+	cc := pd.dot(pd) - r0*r0
+	var candidates []float64
+	if math.Abs(a) < 1e-9 {
+		if b == 0 {
+			return 0, false
+		}
+		candidates = []float64{cc / (2 * b)}
+	} else {
+		disc := b*b - a*cc
+		if disc < 0 {
+			return 0, false
+		}
+		sq := math.Sqrt(disc)
+		t1, t2 := (b+sq)/a, (b-sq)/a
+		candidates = []float64{math.Max(t1, t2), math.Min(t1, t2)}
+	}
+	for _, t := range candidates {
+		if r0+t*dr < 0 {
+			continue
+		}
+		if t, ok := gs.clamp(t); ok {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func (gs *gradientSource) clamp(t float64) (float64, bool) {
+	if t < 0 {
+		return 0, gs.extend
+	} else if t > 1 {
+		return 1, gs.extend
+	}
+	return t, true
+}
+
+// colorAt interpolates the stops, in premultiplied space
+func (gs *gradientSource) colorAt(pos float64) rgba {
+	ps := gs.positions
+	n := len(ps)
+	if pos <= ps[0] {
+		return gs.colors[0]
+	} else if pos >= ps[n-1] {
+		return gs.colors[n-1]
+	}
+	// ps[i-1] <= pos < ps[i]
+	i := sort.Search(n, func(i int) bool { return ps[i] > pos })
+	start, end := ps[i-1], ps[i]
+	c0, c1 := gs.colors[i-1], gs.colors[i]
+	if end == start {
+		return c1
+	}
+	t := float32((pos - start) / (end - start))
+	return rgba{
+		c0[0] + (c1[0]-c0[0])*t,
+		c0[1] + (c1[1]-c0[1])*t,
+		c0[2] + (c1[2]-c0[2])*t,
+		c0[3] + (c1[3]-c0[3])*t,
+	}
+}
+
+func (gs *gradientSource) at(x, y int) rgba {
+	if len(gs.colors) == 0 {
+		return rgba{}
+	} else if len(gs.colors) == 1 || len(gs.positions) < 2 {
+		return gs.colors[0]
+	}
+	p := pixelCenter(gs.inv, x, y)
+	p.y /= gs.scaleY
+	t, ok := gs.parameter(p)
+	if !ok {
+		return rgba{}
+	}
+	first, last := gs.positions[0], gs.positions[len(gs.positions)-1]
+	return gs.colorAt(first + t*(last-first))
+}
+
+// imageSource samples an image
+type imageSource struct {
+	img    *image.RGBA // premultiplied
+	inv    matrix.Transform
+	smooth bool
+}
+
+func (is *imageSource) pixel(x, y int) rgba {
+	b := is.img.Rect
+	x = max(b.Min.X, min(x, b.Max.X-1))
+	y = max(b.Min.Y, min(y, b.Max.Y-1))
+	i := is.img.PixOffset(x, y)
+	pix := is.img.Pix[i : i+4 : i+4]
+	return rgba{float32(pix[0]) / 255, float32(pix[1]) / 255, float32(pix[2]) / 255, float32(pix[3]) / 255}
+}
+
+func (is *imageSource) at(x, y int) rgba {
+	p := pixelCenter(is.inv, x, y)
+	if !is.smooth {
+		return is.pixel(int(math.Floor(p.x)), int(math.Floor(p.y)))
+	}
+	// bilinear interpolation
+	fx, fy := p.x-0.5, p.y-0.5
+	x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+	tx, ty := float32(fx-float64(x0)), float32(fy-float64(y0))
+	c00, c10 := is.pixel(x0, y0), is.pixel(x0+1, y0)
+	c01, c11 := is.pixel(x0, y0+1), is.pixel(x0+1, y0+1)
+	var out rgba
+	for i := range out {
+		top := c00[i] + (c10[i]-c00[i])*tx
+		bottom := c01[i] + (c11[i]-c01[i])*tx
+		out[i] = top + (bottom-top)*ty
+	}
+	return out
+}
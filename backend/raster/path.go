@@ -0,0 +1,202 @@
+package raster
+
+import (
+	"math"
+
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+// geometry is done with float64 to avoid precision issues
+// with large pages.
+
+type point struct{ x, y float64 }
+
+func (p point) add(q point) point             { return point{p.x + q.x, p.y + q.y} }
+func (p point) sub(q point) point             { return point{p.x - q.x, p.y - q.y} }
+func (p point) scale(s float64) point         { return point{p.x * s, p.y * s} }
+func (p point) dot(q point) float64           { return p.x*q.x + p.y*q.y }
+func (p point) cross(q point) float64         { return p.x*q.y - p.y*q.x }
+func (p point) norm() float64                 { return math.Hypot(p.x, p.y) }
+func (p point) perp() point                   { return point{-p.y, p.x} }
+func (p point) equal(q point) bool            { return p.x == q.x && p.y == q.y }
+func (p point) lerp(q point, t float64) point { return p.add(q.sub(p).scale(t)) }
+
+// apply the affine transformation [mt] to [p]
+func transform(mt matrix.Transform, p point) point {
+	return point{
+		float64(mt.A)*p.x + float64(mt.C)*p.y + float64(mt.E),
+		float64(mt.B)*p.x + float64(mt.D)*p.y + float64(mt.F),
+	}
+}
+
+// maxScale returns an upper bound of the scaling factor
+// applied by [mt], used to choose the flattening tolerance.
+func maxScale(mt matrix.Transform) float64 {
+	sx := math.Hypot(float64(mt.A), float64(mt.B))
+	sy := math.Hypot(float64(mt.C), float64(mt.D))
+	return math.Max(sx, sy)
+}
+
+type pathOp uint8
+
+const (
+	opMoveTo pathOp = iota
+	opLineTo
+	opCubicTo
+	opClose
+)
+
+type pathCmd struct {
+	op   pathOp
+	args [3]point
+}
+
+// path stores the drawing instructions, in user space.
+type path struct {
+	cmds []pathCmd
+
+	start, current point // used by ClosePath
+	hasCurrent     bool
+}
+
+func (pa *path) moveTo(p point) {
+	pa.cmds = append(pa.cmds, pathCmd{op: opMoveTo, args: [3]point{p}})
+	pa.start, pa.current, pa.hasCurrent = p, p, true
+}
+
+func (pa *path) lineTo(p point) {
+	if !pa.hasCurrent {
+		pa.moveTo(p)
+		return
+	}
+	pa.cmds = append(pa.cmds, pathCmd{op: opLineTo, args: [3]point{p}})
+	pa.current = p
+}
+
+func (pa *path) cubicTo(p1, p2, p3 point) {
+	if !pa.hasCurrent {
+		pa.moveTo(p1)
+	}
+	pa.cmds = append(pa.cmds, pathCmd{op: opCubicTo, args: [3]point{p1, p2, p3}})
+	pa.current = p3
+}
+
+// quadTo converts the quadratic curve to a cubic one
+func (pa *path) quadTo(p1, p2 point) {
+	p0 := pa.current
+	c1 := p0.lerp(p1, 2./3)
+	c2 := p2.lerp(p1, 2./3)
+	pa.cubicTo(c1, c2, p2)
+}
+
+func (pa *path) close() {
+	if !pa.hasCurrent {
+		return
+	}
+	pa.cmds = append(pa.cmds, pathCmd{op: opClose})
+	pa.current = pa.start
+}
+
+func (pa *path) rectangle(x, y, w, h float64) {
+	pa.moveTo(point{x, y})
+	pa.lineTo(point{x + w, y})
+	pa.lineTo(point{x + w, y + h})
+	pa.lineTo(point{x, y + h})
+	pa.close()
+}
+
+func (pa *path) clear() { *pa = path{cmds: pa.cmds[:0]} }
+
+// polyline is a flattened sub-path
+type polyline struct {
+	points []point
+	closed bool
+
+	// for degenerated (one point) polylines,
+	// the tangent direction at this point, if known.
+	dir point
+}
+
+// flatten applies [mt] to the path and approximates the curves
+// with segments, with a precision [tol] (expressed after transformation).
+// Sub-paths reduced to a single MoveTo are dropped.
+func (pa *path) flatten(mt matrix.Transform, tol float64) []polyline {
+	var (
+		out     []polyline
+		current polyline
+		started bool
+	)
+	flush := func() {
+		if started && len(current.points) >= 2 {
+			out = append(out, current)
+		}
+		current, started = polyline{}, false
+	}
+	for _, cmd := range pa.cmds {
+		switch cmd.op {
+		case opMoveTo:
+			flush()
+			current.points = []point{transform(mt, cmd.args[0])}
+			started = true
+		case opLineTo:
+			current.points = append(current.points, transform(mt, cmd.args[0]))
+		case opCubicTo:
+			p0 := current.points[len(current.points)-1]
+			p1, p2, p3 := transform(mt, cmd.args[0]), transform(mt, cmd.args[1]), transform(mt, cmd.args[2])
+			current.points = flattenCubic(current.points, p0, p1, p2, p3, tol)
+		case opClose:
+			current.closed = true
+			start := current.points[0]
+			// keep a degenerated path so that caps are drawn
+			if len(current.points) == 1 {
+				current.points = append(current.points, start)
+			}
+			flush()
+			// a new sub-path starts at the same point
+			current.points = []point{start}
+			started = true
+		}
+	}
+	flush()
+	return out
+}
+
+// flattenCubic appends the approximation of the given Bézier curve to [dst],
+// excluding [p0].
+func flattenCubic(dst []point, p0, p1, p2, p3 point, tol float64) []point {
+	dd1 := p0.sub(p1.scale(2)).add(p2).norm()
+	dd2 := p1.sub(p2.scale(2)).add(p3).norm()
+	n := int(math.Ceil(math.Sqrt(0.75 * math.Max(dd1, dd2) / tol)))
+	if n < 1 {
+		n = 1
+	} else if n > 1000 {
+		n = 1000
+	}
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		dst = append(dst, point{
+			a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+		})
+	}
+	return dst
+}
+
+// toPolygons returns the points of the polylines, all considered closed.
+func toPolygons(lines []polyline) [][]point {
+	out := make([][]point, len(lines))
+	for i, l := range lines {
+		out[i] = l.points
+	}
+	return out
+}
+
+func transformPolygons(mt matrix.Transform, polygons [][]point) {
+	for _, poly := range polygons {
+		for i, p := range poly {
+			poly[i] = transform(mt, p)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package raster implements a [backend.Document] rendering
+// each page to an image, using only Go code.
+//
+// Drawing operations are recorded and pages are rasterized on demand
+// (see [Page.Image]), once their media box is known.
+//
+// As in PDF, the page coordinate system has its origin at the bottom-left corner,
+// and its y axis pointing up. document.Document.Write takes care of
+// this convention; when drawing y-down content (like an svg.SVGImage) directly,
+// first apply the transformation matrix.New(1, 0, 0, -1, 0, height).
+//
+// Links, attachments and metadata have no meaning for raster images and
+// are ignored.
+package raster
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"time"
+
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/font"
+
+	drawText "github.com/benoitkugler/webrender/text/draw"
+)
+
+type Fl = utils.Fl
+
+var (
+	_ backend.Document = (*Document)(nil)
+	_ backend.Page     = (*Page)(nil)
+)
+
+// Document implements [backend.Document], storing the pages
+// to be rasterized.
+type Document struct {
+	Pages []*Page
+
+	resolution Fl
+	res        *resources
+}
+
+// NewDocument returns an empty document, whose pages will be rasterized
+// using [resolution] pixels per page unit.
+// For instance, since document.Document.Write uses PDF points,
+// 96./72 produces images at the CSS pixel resolution.
+func NewDocument(resolution Fl) *Document {
+	return &Document{resolution: resolution, res: newResources()}
+}
+
+// AddPage creates a new page, whose media box is initially
+// the given rectangle.
+func (d *Document) AddPage(left, top, right, bottom Fl) backend.Page {
+	page := &Page{canvas: newCanvas(d.res, left, top, right, bottom), resolution: d.resolution}
+	page.mediaBox = [4]Fl{left, top, right, bottom}
+	page.trimBox, page.bleedBox = page.mediaBox, page.mediaBox
+	d.Pages = append(d.Pages, page)
+	return page
+}
+
+// Images rasterizes all the pages.
+func (d *Document) Images() []*image.RGBA {
+	out := make([]*image.RGBA, len(d.Pages))
+	for i, page := range d.Pages {
+		out[i] = page.Image()
+	}
+	return out
+}
+
+func (d *Document) CreateAnchors(anchors [][]backend.Anchor)      {}
+func (d *Document) SetAttachments(as []backend.Attachment)        {}
+func (d *Document) EmbedFile(fileID string, a backend.Attachment) {}
+func (d *Document) SetTitle(title string)                         {}
+func (d *Document) SetDescription(description string)             {}
+func (d *Document) SetCreator(creator string)                     {}
+func (d *Document) SetAuthors(authors []string)                   {}
+func (d *Document) SetKeywords(keywords []string)                 {}
+func (d *Document) SetProducer(producer string)                   {}
+func (d *Document) SetDateCreation(date time.Time)                {}
+func (d *Document) SetDateModification(date time.Time)            {}
+func (d *Document) SetBookmarks(root []backend.BookmarkNode)      {}
+func (d *Document) SetPageLabels(labels []backend.PageLabel)      {}
+
+// Page implements [backend.Page].
+type Page struct {
+	*canvas
+
+	mediaBox, trimBox, bleedBox [4]Fl // left, top, right, bottom
+	resolution                  Fl
+}
+
+func (p *Page) AddInternalLink(xMin, yMin, xMax, yMax Fl, anchorName string) {}
+func (p *Page) AddExternalLink(xMin, yMin, xMax, yMax Fl, url string)        {}
+func (p *Page) AddFileAnnotation(xMin, yMin, xMax, yMax Fl, fileID string)   {}
+
+// the static appearance of form fields is already drawn
+func (p *Page) AddFormField(xMin, yMin, xMax, yMax Fl, field backend.FormField) {}
+
+// the logical structure is not relevant for images
+func (p *Page) BeginStructure(element backend.StructureElement) {}
+func (p *Page) EndStructure()                                   {}
+
+func (p *Page) SetMediaBox(left, top, right, bottom Fl) {
+	p.mediaBox = [4]Fl{left, top, right, bottom}
+}
+
+func (p *Page) SetTrimBox(left, top, right, bottom Fl) {
+	p.trimBox = [4]Fl{left, top, right, bottom}
+}
+
+func (p *Page) SetBleedBox(left, top, right, bottom Fl) {
+	p.bleedBox = [4]Fl{left, top, right, bottom}
+}
+
+// TrimBox returns the page trim box, which may be used to crop
+// the image returned by [Page.Image].
+func (p *Page) TrimBox() (left, top, right, bottom Fl) {
+	return p.trimBox[0], p.trimBox[1], p.trimBox[2], p.trimBox[3]
+}
+
+// Image rasterizes the page media box, on a transparent background.
+func (p *Page) Image() *image.RGBA {
+	left, top, right, bottom := p.mediaBox[0], p.mediaBox[1], p.mediaBox[2], p.mediaBox[3]
+	width := int(math.Ceil(float64((right - left) * p.resolution)))
+	height := int(math.Ceil(float64((bottom - top) * p.resolution)))
+	if width <= 0 || height <= 0 {
+		return image.NewRGBA(image.Rectangle{})
+	}
+	// the first row of pixels is at y = bottom
+	ctm := matrix.New(p.resolution, 0, 0, -p.resolution, -left*p.resolution, bottom*p.resolution)
+	r := newRenderer(newLayer(image.Rect(0, 0, width, height)), p.res, ctm)
+	r.replay(p.canvas)
+	return r.dst.toImage()
+}
+
+// WritePNG rasterizes the page and writes it in PNG format.
+func (p *Page) WritePNG(w io.Writer) error {
+	return png.Encode(w, p.Image())
+}
+
+// resources are shared by all the pages and groups of a document.
+type resources struct {
+	faces     drawText.Faces
+	fontChars map[backend.Font]*backend.FontChars
+	images    map[int]*image.RGBA // by RasterImage.ID
+	bitmaps   map[bitmapKey]*image.RGBA
+}
+
+type bitmapKey struct {
+	face *font.Face
+	gid  font.GID
+}
+
+func newResources() *resources {
+	return &resources{
+		faces:     make(drawText.Faces),
+		fontChars: make(map[backend.Font]*backend.FontChars),
+		images:    make(map[int]*image.RGBA),
+		bitmaps:   make(map[bitmapKey]*image.RGBA),
+	}
+}
+
+func (res *resources) addFont(font backend.Font, content []byte) *backend.FontChars {
+	if chars, has := res.fontChars[font]; has {
+		return chars
+	}
+	res.faces.Load(font, content)
+	chars := &backend.FontChars{
+		Cmap:    make(map[backend.GID][]rune),
+		Extents: make(map[backend.GID]backend.GlyphExtents),
+	}
+	res.fontChars[font] = chars
+	return chars
+}
+
+// toRGBA converts to the premultiplied RGBA format
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Rect, img, out.Rect.Min, draw.Src)
+	return out
+}
+
+// decodeImage returns nil (after logging) if the image is invalid
+func (res *resources) decodeImage(img backend.RasterImage) *image.RGBA {
+	if decoded, has := res.images[img.ID]; has && img.ID != 0 {
+		return decoded
+	}
+	if seeker, ok := img.Content.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+	decoded, _, err := image.Decode(img.Content)
+	var out *image.RGBA
+	if err != nil {
+		logger.WarningLogger.Printf("invalid raster image (%s): %s", img.MimeType, err)
+	} else {
+		out = toRGBA(decoded)
+	}
+	if img.ID != 0 {
+		res.images[img.ID] = out
+	}
+	return out
+}
+
+// decodeBitmap returns nil for unsupported formats
+func (res *resources) decodeBitmap(face *font.Face, gid font.GID, data font.GlyphBitmap) *image.RGBA {
+	key := bitmapKey{face, gid}
+	if img, has := res.bitmaps[key]; has {
+		return img
+	}
+	var out *image.RGBA
+	switch data.Format {
+	case font.PNG, font.JPG:
+		img, _, err := image.Decode(bytes.NewReader(data.Data))
+		if err != nil {
+			logger.WarningLogger.Printf("invalid bitmap for glyph %d: %s", gid, err)
+		} else {
+			out = toRGBA(img)
+		}
+	}
+	res.bitmaps[key] = out
+	return out
+}
@@ -0,0 +1,293 @@
+package raster
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/svg"
+	"github.com/benoitkugler/webrender/utils/testutils/htmltest"
+)
+
+var (
+	red  = parser.RGBA{R: 1, A: 1}
+	blue = parser.RGBA{B: 1, A: 1}
+)
+
+// newTestPage returns a page of 100x100 pixels, with a y-down
+// coordinate system
+func newTestPage() (*Document, backend.Page) {
+	doc := NewDocument(1)
+	page := doc.AddPage(0, 0, 100, 100)
+	page.State().Transform(matrix.New(1, 0, 0, -1, 0, 100))
+	return doc, page
+}
+
+func assertColor(t *testing.T, img *image.RGBA, x, y int, expected color.RGBA) {
+	t.Helper()
+	got := img.RGBAAt(x, y)
+	diff := func(a, b uint8) bool { return int(a) > int(b)+5 || int(b) > int(a)+5 }
+	if diff(got.R, expected.R) || diff(got.G, expected.G) || diff(got.B, expected.B) || diff(got.A, expected.A) {
+		t.Fatalf("pixel (%d, %d): expected %v, got %v", x, y, expected, got)
+	}
+}
+
+var (
+	opaqueRed   = color.RGBA{255, 0, 0, 255}
+	opaqueBlue  = color.RGBA{0, 0, 255, 255}
+	transparent = color.RGBA{}
+)
+
+func TestFill(t *testing.T) {
+	doc, page := newTestPage()
+	page.State().SetColorRgba(red, false)
+	page.Rectangle(10, 20, 30, 40)
+	page.Paint(backend.FillNonZero)
+
+	img := doc.Images()[0]
+	if img.Bounds() != image.Rect(0, 0, 100, 100) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	assertColor(t, img, 10, 20, opaqueRed)
+	assertColor(t, img, 39, 59, opaqueRed)
+	assertColor(t, img, 9, 20, transparent)
+	assertColor(t, img, 40, 60, transparent)
+}
+
+func TestFillRules(t *testing.T) {
+	for _, evenOdd := range []bool{false, true} {
+		doc, page := newTestPage()
+		page.Rectangle(0, 0, 100, 100)
+		page.Rectangle(25, 25, 50, 50)
+		if evenOdd {
+			page.Paint(backend.FillEvenOdd)
+		} else {
+			page.Paint(backend.FillNonZero)
+		}
+		img := doc.Images()[0]
+		assertColor(t, img, 5, 5, color.RGBA{A: 255})
+		if evenOdd {
+			assertColor(t, img, 50, 50, transparent)
+		} else {
+			assertColor(t, img, 50, 50, color.RGBA{A: 255})
+		}
+	}
+}
+
+func TestStroke(t *testing.T) {
+	doc, page := newTestPage()
+	page.State().SetColorRgba(blue, true)
+	page.State().SetLineWidth(10)
+	page.State().SetDash([]Fl{20}, 0)
+	page.MoveTo(0, 50)
+	page.LineTo(100, 50)
+	page.Paint(backend.Stroke)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 10, 50, opaqueBlue)  // on
+	assertColor(t, img, 30, 50, transparent) // off
+	assertColor(t, img, 50, 50, opaqueBlue)  // on
+	assertColor(t, img, 10, 56, transparent) // outside the line width
+}
+
+func TestStrokeCaps(t *testing.T) {
+	doc, page := newTestPage()
+	page.State().SetLineWidth(10)
+	page.State().SetStrokeOptions(backend.StrokeOptions{LineCap: backend.SquareCap})
+	page.MoveTo(20, 50)
+	page.LineTo(80, 50)
+	page.Paint(backend.Stroke)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 16, 50, color.RGBA{A: 255})
+	assertColor(t, img, 83, 50, color.RGBA{A: 255})
+	assertColor(t, img, 10, 50, transparent)
+}
+
+func TestClip(t *testing.T) {
+	doc, page := newTestPage()
+	page.OnNewStack(func() {
+		page.Rectangle(0, 0, 50, 100)
+		page.State().Clip(false)
+		page.State().SetColorRgba(red, false)
+		page.Rectangle(0, 0, 100, 100)
+		page.Paint(backend.FillNonZero)
+	})
+	// the clip is restored
+	page.State().SetColorRgba(blue, false)
+	page.Rectangle(90, 90, 10, 10)
+	page.Paint(backend.FillNonZero)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 25, 50, opaqueRed)
+	assertColor(t, img, 75, 50, transparent)
+	assertColor(t, img, 95, 95, opaqueBlue)
+}
+
+func TestGroupOpacity(t *testing.T) {
+	doc, page := newTestPage()
+	group := page.NewGroup(0, 0, 50, 50)
+	group.State().SetColorRgba(red, false)
+	group.Rectangle(0, 0, 100, 100)
+	group.Paint(backend.FillNonZero)
+	page.DrawWithOpacity(0.5, group)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 25, 25, color.RGBA{128, 0, 0, 128})
+	assertColor(t, img, 75, 75, transparent) // outside the group bbox
+}
+
+func TestGroupBlur(t *testing.T) {
+	doc, page := newTestPage()
+	group := page.NewGroup(0, 0, 100, 100)
+	group.State().SetColorRgba(red, false)
+	group.Rectangle(0, 0, 50, 100)
+	group.Paint(backend.FillNonZero)
+	page.(backend.BlurCanvas).DrawWithBlur(5, group)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 10, 50, opaqueRed)
+	assertColor(t, img, 90, 50, transparent)
+	// the edge is smoothed
+	if a := img.RGBAAt(50, 50).A; a < 100 || a > 155 {
+		t.Fatalf("unexpected alpha %d", a)
+	}
+	if a := img.RGBAAt(55, 50).A; a == 0 || a > 100 {
+		t.Fatalf("unexpected alpha %d", a)
+	}
+}
+
+func TestAlphaMask(t *testing.T) {
+	doc, page := newTestPage()
+	mask := page.NewGroup(0, 0, 100, 100)
+	mask.State().SetColorRgba(parser.RGBA{R: 1, G: 1, B: 1, A: 1}, false)
+	mask.Rectangle(0, 0, 50, 100)
+	mask.Paint(backend.FillNonZero)
+	page.State().SetAlphaMask(mask)
+	page.State().SetColorRgba(red, false)
+	page.Rectangle(0, 0, 100, 100)
+	page.Paint(backend.FillNonZero)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 25, 50, opaqueRed)
+	assertColor(t, img, 75, 50, transparent)
+}
+
+func TestPattern(t *testing.T) {
+	doc, page := newTestPage()
+	pattern := page.NewGroup(0, 0, 20, 20)
+	pattern.State().SetColorRgba(red, false)
+	pattern.Rectangle(0, 0, 10, 10)
+	pattern.Paint(backend.FillNonZero)
+	page.State().SetColorPattern(pattern, 20, 20, matrix.Identity(), false)
+	page.Rectangle(0, 0, 100, 100)
+	page.Paint(backend.FillNonZero)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 5, 5, opaqueRed)
+	assertColor(t, img, 45, 65, opaqueRed)
+	assertColor(t, img, 15, 15, transparent)
+}
+
+func TestLinearGradient(t *testing.T) {
+	doc, page := newTestPage()
+	page.DrawGradient(backend.GradientLayout{
+		Positions:    []Fl{0, 1},
+		Colors:       []parser.RGBA{red, blue},
+		GradientKind: backend.GradientKind{Kind: "linear", Coords: [6]Fl{0, 0, 100, 0}},
+		ScaleY:       1,
+	}, 100, 100)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 0, 50, color.RGBA{254, 0, 1, 255})
+	assertColor(t, img, 99, 50, color.RGBA{1, 0, 254, 255})
+	assertColor(t, img, 50, 10, color.RGBA{127, 0, 128, 255})
+}
+
+func TestRadialGradient(t *testing.T) {
+	doc, page := newTestPage()
+	page.DrawGradient(backend.GradientLayout{
+		Positions:    []Fl{0, 1},
+		Colors:       []parser.RGBA{red, blue},
+		GradientKind: backend.GradientKind{Kind: "radial", Coords: [6]Fl{50, 50, 0, 50, 50, 50}},
+		ScaleY:       1,
+	}, 100, 100)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 50, 50, opaqueRed)
+	assertColor(t, img, 1, 1, opaqueBlue) // extended
+}
+
+func TestRasterImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(0, 0, opaqueRed)
+	src.SetRGBA(1, 0, opaqueBlue)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, page := newTestPage()
+	page.DrawRasterImage(backend.RasterImage{Content: &buf, MimeType: "image/png", Rendering: "pixelated", ID: 1}, 100, 50)
+
+	img := doc.Images()[0]
+	assertColor(t, img, 10, 10, opaqueRed)
+	assertColor(t, img, 90, 10, opaqueBlue)
+	assertColor(t, img, 50, 75, transparent)
+}
+
+func TestSVG(t *testing.T) {
+	const input = `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100">
+		<rect x="0" y="0" width="50" height="50" fill="red" />
+		<circle cx="75" cy="75" r="20" fill="blue" opacity="0.5" />
+	</svg>`
+	img, err := svg.Parse(strings.NewReader(input), "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, page := newTestPage()
+	img.Draw(page, 100, 100, nil)
+
+	out := doc.Images()[0]
+	assertColor(t, out, 25, 25, opaqueRed)
+	assertColor(t, out, 75, 75, color.RGBA{0, 0, 128, 128})
+	assertColor(t, out, 75, 25, transparent)
+}
+
+func TestDocument(t *testing.T) {
+	doc := htmltest.Document(t, `
+	<style>@page { size: 200px 100px; margin: 0 } body { margin: 0 }</style>
+	<div style="background: red; height: 50px"></div>
+	<p style="font-size: 30px; margin: 0">Text</p>`)
+
+	output := NewDocument(96. / 72)
+	doc.Write(output, 1, nil)
+	if len(output.Pages) != 1 {
+		t.Fatalf("expected one page, got %d", len(output.Pages))
+	}
+	img := output.Pages[0].Image()
+	if img.Bounds() != image.Rect(0, 0, 200, 100) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	assertColor(t, img, 100, 25, opaqueRed)
+
+	// some glyphs should have been drawn
+	var inked int
+	for y := 50; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if img.RGBAAt(x, y).A != 0 {
+				inked++
+			}
+		}
+	}
+	if inked == 0 {
+		t.Fatal("missing text")
+	}
+}
@@ -0,0 +1,403 @@
+package raster
+
+import (
+	"image"
+	"math"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+// tolerance used when flattening curves, in device pixels
+const tolerance = 0.1
+
+// paintState is the current fill or stroke paint
+type paintState struct {
+	color   parser.RGBA // alpha is ignored
+	alpha   Fl
+	pattern *patternSource // optional, replacing color
+}
+
+func (ps paintState) source() source {
+	if ps.pattern != nil {
+		return ps.pattern
+	}
+	c := ps.color
+	c.A = 1
+	return solid(premultiply(c))
+}
+
+type graphicState struct {
+	ctm      matrix.Transform
+	clip     *mask // nil means no clipping
+	softMask *mask // nil means no mask
+
+	fill, stroke paintState
+	line         strokeStyle
+	blend        blendMode
+	textPaint    backend.PaintOp
+}
+
+func defaultState(ctm matrix.Transform) graphicState {
+	black := paintState{color: parser.RGBA{A: 1}, alpha: 1}
+	return graphicState{
+		ctm:       ctm,
+		fill:      black,
+		stroke:    black,
+		line:      strokeStyle{width: 1},
+		textPaint: backend.FillNonZero,
+	}
+}
+
+// renderer executes the recorded operations on
+// a pixel buffer.
+type renderer struct {
+	dst   *layer
+	res   *resources
+	state graphicState
+	stack []graphicState
+	path  path
+}
+
+func newRenderer(dst *layer, res *resources, ctm matrix.Transform) *renderer {
+	return &renderer{dst: dst, res: res, state: defaultState(ctm)}
+}
+
+func (r *renderer) replay(c *canvas) {
+	for _, o := range c.ops {
+		o(r)
+	}
+}
+
+func (r *renderer) save() { r.stack = append(r.stack, r.state) }
+
+func (r *renderer) restore() {
+	if len(r.stack) == 0 {
+		return
+	}
+	r.state = r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+func (r *renderer) paintState(stroke bool) *paintState {
+	if stroke {
+		return &r.state.stroke
+	}
+	return &r.state.fill
+}
+
+// bounds returns the region which may be modified
+func (r *renderer) bounds() image.Rectangle {
+	if r.state.clip != nil {
+		return r.dst.rect.Intersect(r.state.clip.rect)
+	}
+	return r.dst.rect
+}
+
+// deviceRect returns the pixels covered by [bbox] (left, top, right, bottom),
+// mapped by the CTM.
+func (r *renderer) deviceRect(bbox [4]Fl) image.Rectangle {
+	corners := [4]point{
+		{float64(bbox[0]), float64(bbox[1])},
+		{float64(bbox[2]), float64(bbox[1])},
+		{float64(bbox[2]), float64(bbox[3])},
+		{float64(bbox[0]), float64(bbox[3])},
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range corners {
+		p := transform(r.state.ctm, c)
+		minX, maxX = math.Min(minX, p.x), math.Max(maxX, p.x)
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	if math.IsNaN(minX) || math.IsNaN(minY) {
+		return image.Rectangle{}
+	}
+	const limit = 1 << 24
+	out := image.Rect(
+		int(math.Floor(math.Max(minX, -limit))), int(math.Floor(math.Max(minY, -limit))),
+		int(math.Ceil(math.Min(maxX, limit))), int(math.Ceil(math.Min(maxY, limit))),
+	)
+	return out.Intersect(r.dst.rect)
+}
+
+// composite draws [src] with [alpha], through the coverage [cov],
+// the current clip and the current soft mask.
+// If [cov] is nil, [rect] is fully covered.
+func (r *renderer) composite(rect image.Rectangle, cov *mask, src source, alpha Fl) {
+	if cov != nil {
+		rect = cov.rect
+	}
+	rect = rect.Intersect(r.bounds())
+	clip, soft, blend := r.state.clip, r.state.softMask, r.state.blend
+	pix := r.dst.pix
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := alpha
+			if cov != nil {
+				c *= cov.at(x, y)
+			}
+			if clip != nil {
+				c *= clip.at(x, y)
+			}
+			if soft != nil {
+				c *= soft.at(x, y)
+			}
+			if c <= 0 {
+				continue
+			}
+			s := src.at(x, y)
+			s[0], s[1], s[2], s[3] = s[0]*c, s[1]*c, s[2]*c, s[3]*c
+			i := r.dst.offset(x, y)
+			d := rgba{pix[i], pix[i+1], pix[i+2], pix[i+3]}
+			d = blend.blend(d, s)
+			pix[i], pix[i+1], pix[i+2], pix[i+3] = d[0], d[1], d[2], d[3]
+		}
+	}
+}
+
+// fillPath fills the current path with [src], without clearing it
+func (r *renderer) fillPath(evenOdd bool, src source, alpha Fl) {
+	polygons := toPolygons(r.path.flatten(r.state.ctm, tolerance))
+	if cov := rasterize(polygons, evenOdd, r.bounds()); cov != nil {
+		r.composite(cov.rect, cov, src, alpha)
+	}
+}
+
+func (r *renderer) strokePath() {
+	scale := maxScale(r.state.ctm)
+	if scale == 0 {
+		return
+	}
+	tol := tolerance / scale
+	style := r.state.line
+	if style.width <= 0 { // thinnest line, one device pixel
+		style.width = 1 / scale
+	}
+	lines := r.path.flatten(matrix.Identity(), tol)
+	polygons := style.stroke(lines, tol)
+	transformPolygons(r.state.ctm, polygons)
+	if cov := rasterize(polygons, false, r.bounds()); cov != nil {
+		r.composite(cov.rect, cov, r.state.stroke.source(), r.state.stroke.alpha)
+	}
+}
+
+func (r *renderer) paint(op backend.PaintOp) {
+	if op&(backend.FillEvenOdd|backend.FillNonZero) != 0 {
+		r.fillPath(op&backend.FillEvenOdd != 0, r.state.fill.source(), r.state.fill.alpha)
+	}
+	if op&backend.Stroke != 0 {
+		r.strokePath()
+	}
+	r.path.clear()
+}
+
+func (r *renderer) clip(evenOdd bool) {
+	polygons := toPolygons(r.path.flatten(r.state.ctm, tolerance))
+	cov := rasterize(polygons, evenOdd, r.dst.rect)
+	if cov == nil { // everything is clipped
+		cov = newMask(image.Rectangle{})
+	}
+	r.state.clip = intersect(r.state.clip, cov)
+	r.path.clear()
+}
+
+// clipRect restricts the drawing to the given bounding box,
+// used for groups
+func (r *renderer) clipRect(bbox [4]Fl) {
+	r.path.rectangle(float64(bbox[0]), float64(bbox[1]), float64(bbox[2]-bbox[0]), float64(bbox[3]-bbox[1]))
+	r.clip(false)
+}
+
+// renderGroup draws [g] at the current CTM on a new layer,
+// restricted to the group bounding box.
+func (r *renderer) renderGroup(g *canvas) *layer {
+	rect := r.deviceRect(g.bbox)
+	sub := newRenderer(newLayer(rect), r.res, r.state.ctm)
+	if !rect.Empty() {
+		sub.clipRect(g.bbox)
+		sub.replay(g)
+	}
+	return sub.dst
+}
+
+func (r *renderer) drawGroup(g *canvas, opacity Fl) {
+	if r.bounds().Intersect(r.deviceRect(g.bbox)).Empty() {
+		return
+	}
+	content := r.renderGroup(g)
+	r.composite(content.rect, nil, content, opacity)
+}
+
+func (r *renderer) drawBlurredGroup(g *canvas, stdDeviation Fl) {
+	if r.bounds().Intersect(r.deviceRect(g.bbox)).Empty() {
+		return
+	}
+	content := r.renderGroup(g)
+	// the standard deviation is given in user space
+	scale := math.Sqrt(math.Abs(float64(r.state.ctm.Determinant())))
+	content.blur(float64(stdDeviation) * scale)
+	r.composite(content.rect, nil, content, 1)
+}
+
+func (r *renderer) setAlphaMask(m *canvas) {
+	r.state.softMask = r.renderGroup(m).luminosity()
+}
+
+// maximum size of a pattern tile, in pixels
+const maxTileSize = 4096
+
+func (r *renderer) setPattern(p *canvas, mat matrix.Transform, stroke bool) {
+	ps := r.paintState(stroke)
+	ps.pattern = nil
+
+	x, y := float64(p.bbox[0]), float64(p.bbox[1])
+	width, height := float64(p.bbox[2]-p.bbox[0]), float64(p.bbox[3]-p.bbox[1])
+	toDevice := matrix.Mul(r.state.ctm, mat)
+	inv := toDevice
+	if width <= 0 || height <= 0 || inv.Invert() != nil {
+		ps.color.A, ps.alpha = 0, 0 // nothing to paint
+		return
+	}
+
+	// choose the tile resolution to match the device one
+	sx := math.Hypot(float64(toDevice.A), float64(toDevice.B))
+	sy := math.Hypot(float64(toDevice.C), float64(toDevice.D))
+	tw := int(math.Ceil(math.Min(math.Max(width*sx, 1), maxTileSize)))
+	th := int(math.Ceil(math.Min(math.Max(height*sy, 1), maxTileSize)))
+	sx, sy = float64(tw)/width, float64(th)/height
+
+	ctm := matrix.Mul(matrix.Scaling(Fl(sx), Fl(sy)), matrix.Translation(Fl(-x), Fl(-y)))
+	sub := newRenderer(newLayer(image.Rect(0, 0, tw, th)), r.res, ctm)
+	sub.clipRect(p.bbox)
+	sub.replay(p)
+
+	ps.pattern = &patternSource{
+		tile: sub.dst, inv: inv,
+		x: x, y: y, width: width, height: height,
+		sx: sx, sy: sy,
+	}
+}
+
+func (r *renderer) drawGradient(gradient backend.GradientLayout, width, height Fl) {
+	if len(gradient.Colors) == 0 {
+		return
+	}
+	var src source
+	if gradient.Kind == "solid" {
+		src = solid(premultiply(gradient.Colors[0]))
+	} else {
+		inv := r.state.ctm
+		if inv.Invert() != nil {
+			return
+		}
+		src = newGradientSource(gradient, inv)
+	}
+	r.fillRect(width, height, src, 1)
+}
+
+// fillRect fills the rectangle (0, 0, width, height) with [src]
+func (r *renderer) fillRect(width, height Fl, src source, alpha Fl) {
+	saved := r.path
+	r.path = path{}
+	r.path.rectangle(0, 0, float64(width), float64(height))
+	r.fillPath(false, src, alpha)
+	r.path = saved
+}
+
+// drawImage draws [img] in the rectangle (0, 0, width, height)
+func (r *renderer) drawImage(img *image.RGBA, width, height Fl, smooth bool) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	toDevice := matrix.Mul(r.state.ctm, matrix.New(width/Fl(bounds.Dx()), 0, 0, height/Fl(bounds.Dy()), 0, 0))
+	toDevice.RightMultBy(matrix.Translation(Fl(-bounds.Min.X), Fl(-bounds.Min.Y)))
+	inv := toDevice
+	if inv.Invert() != nil {
+		return
+	}
+	r.fillRect(width, height, &imageSource{img: img, inv: inv, smooth: smooth}, r.state.fill.alpha)
+}
+
+func (r *renderer) drawText(texts []backend.TextDrawing) {
+	saved := r.path
+	r.path = path{}
+	defer func() { r.path = saved }()
+
+	for _, td := range texts {
+		r.drawTextDrawing(td)
+	}
+	// glyph outlines are painted at once
+	r.paint(r.state.textPaint)
+}
+
+// drawTextDrawing adds the glyph outlines to the current path,
+// and directly draws the bitmap glyphs
+func (r *renderer) drawTextDrawing(td backend.TextDrawing) {
+	mat := td.Matrix()
+	fontSize := td.FontSize
+	var pen Fl // in 1/1000 of font size
+	for _, run := range td.Runs {
+		face := r.res.faces.Face(run.Font)
+		chars := r.res.fontChars[run.Font]
+		for _, glyph := range run.Glyphs {
+			width := chars.GlyphWidth(face, glyph.Glyph)
+			x := (pen + glyph.Offset) / 1000 * fontSize
+			y := -glyph.Rise / 1000
+			pen += width + glyph.Offset - Fl(glyph.Kerning)
+
+			if face == nil || glyph.Glyph == backend.GID(font.EmptyGlyph) || glyph.Hidden {
+				continue
+			}
+			scale := fontSize / Fl(face.Upem())
+			glyphMat := matrix.Mul3(mat, matrix.Translation(x, y), matrix.Scaling(scale, scale))
+			r.addGlyph(face, font.GID(glyph.Glyph), glyphMat)
+		}
+	}
+}
+
+func (r *renderer) addGlyph(face *font.Face, gid font.GID, mat matrix.Transform) {
+	switch data := face.GlyphData(gid).(type) {
+	case font.GlyphOutline:
+		r.addOutline(data, mat)
+	case font.GlyphSVG:
+		r.addOutline(data.Outline, mat)
+	case font.GlyphBitmap:
+		img := r.res.decodeBitmap(face, gid, data)
+		ext, ok := face.GlyphExtents(gid)
+		if img == nil || !ok {
+			if data.Outline != nil {
+				r.addOutline(*data.Outline, mat)
+			}
+			return
+		}
+		r.save()
+		r.state.ctm = matrix.Mul3(r.state.ctm, mat, matrix.New(ext.Width, 0, 0, ext.Height, ext.XBearing, ext.YBearing))
+		r.drawImage(img, 1, 1, true)
+		r.restore()
+	}
+}
+
+func (r *renderer) addOutline(outline font.GlyphOutline, mat matrix.Transform) {
+	tr := func(p font.SegmentPoint) point { return transform(mat, point{float64(p.X), float64(p.Y)}) }
+	for i, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			if i != 0 { // contours are implicitly closed
+				r.path.close()
+			}
+			r.path.moveTo(tr(seg.Args[0]))
+		case ot.SegmentOpLineTo:
+			r.path.lineTo(tr(seg.Args[0]))
+		case ot.SegmentOpQuadTo:
+			r.path.quadTo(tr(seg.Args[0]), tr(seg.Args[1]))
+		case ot.SegmentOpCubeTo:
+			r.path.cubicTo(tr(seg.Args[0]), tr(seg.Args[1]), tr(seg.Args[2]))
+		}
+	}
+	r.path.close()
+}
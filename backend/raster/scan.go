@@ -0,0 +1,176 @@
+package raster
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// number of sub-scanlines used for vertical anti-aliasing;
+// the horizontal coverage is computed exactly
+const subsamples = 8
+
+// mask stores an alpha value in [0,1] for each pixel of [rect].
+// Pixels outside [rect] have a zero alpha.
+type mask struct {
+	rect  image.Rectangle
+	alpha []float32
+}
+
+func newMask(rect image.Rectangle) *mask {
+	return &mask{rect: rect, alpha: make([]float32, rect.Dx()*rect.Dy())}
+}
+
+// at returns the value at pixel (x, y)
+func (m *mask) at(x, y int) float32 {
+	if !(image.Point{x, y}).In(m.rect) {
+		return 0
+	}
+	return m.alpha[(y-m.rect.Min.Y)*m.rect.Dx()+x-m.rect.Min.X]
+}
+
+// intersect returns the product of the masks,
+// where nil stands for a fully opaque mask
+func intersect(m1, m2 *mask) *mask {
+	if m1 == nil {
+		return m2
+	} else if m2 == nil {
+		return m1
+	}
+	out := newMask(m1.rect.Intersect(m2.rect))
+	w := out.rect.Dx()
+	for y := out.rect.Min.Y; y < out.rect.Max.Y; y++ {
+		for x := out.rect.Min.X; x < out.rect.Max.X; x++ {
+			out.alpha[(y-out.rect.Min.Y)*w+x-out.rect.Min.X] = m1.at(x, y) * m2.at(x, y)
+		}
+	}
+	return out
+}
+
+type edge struct {
+	x0, y0, x1, y1 float64 // y0 < y1
+	slope          float64 // dx/dy
+	dir            int     // +1 if the edge goes down, -1 otherwise
+}
+
+type crossing struct {
+	x   float64
+	dir int
+}
+
+// rasterize computes the coverage of the polygons (implicitely closed),
+// restricted to [bounds].
+// It returns nil if the result is empty.
+func rasterize(polygons [][]point, evenOdd bool, bounds image.Rectangle) *mask {
+	var edges []edge
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polygons {
+		for i, p := range poly {
+			q := poly[(i+1)%len(poly)]
+			minX, maxX = math.Min(minX, p.x), math.Max(maxX, p.x)
+			minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+			if p.y == q.y || math.IsNaN(p.y) || math.IsNaN(q.y) {
+				continue
+			}
+			e := edge{x0: p.x, y0: p.y, x1: q.x, y1: q.y, dir: 1}
+			if p.y > q.y {
+				e = edge{x0: q.x, y0: q.y, x1: p.x, y1: p.y, dir: -1}
+			}
+			e.slope = (e.x1 - e.x0) / (e.y1 - e.y0)
+			edges = append(edges, e)
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	// avoid overflows when converting to int
+	const limit = 1 << 24
+	minX, minY = math.Max(minX, -limit), math.Max(minY, -limit)
+	maxX, maxY = math.Min(maxX, limit), math.Min(maxY, limit)
+	rect := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(bounds)
+	if rect.Empty() {
+		return nil
+	}
+
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	out := newMask(rect)
+	w := rect.Dx()
+	var (
+		cells     = make([]float32, w+1) // partial coverage
+		fulls     = make([]float32, w+1) // full coverage, as a difference array
+		active    []edge
+		crossings []crossing
+		next      int // next edge to activate
+	)
+	const weight = 1. / subsamples
+	addSpan := func(xa, xb float64) {
+		a := math.Max(xa-float64(rect.Min.X), 0)
+		b := math.Min(xb-float64(rect.Min.X), float64(w))
+		if b <= a {
+			return
+		}
+		ia, ib := int(a), int(b)
+		if ia == ib {
+			cells[ia] += float32((b - a) * weight)
+			return
+		}
+		cells[ia] += float32((float64(ia+1) - a) * weight)
+		fulls[ia+1] += weight
+		fulls[ib] -= weight
+		cells[ib] += float32((b - float64(ib)) * weight)
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for s := 0; s < subsamples; s++ {
+			sy := float64(y) + (float64(s)+0.5)/subsamples
+
+			// update the active edges
+			for next < len(edges) && edges[next].y0 <= sy {
+				active = append(active, edges[next])
+				next++
+			}
+			crossings = crossings[:0]
+			kept := active[:0]
+			for _, e := range active {
+				if e.y1 <= sy {
+					continue
+				}
+				kept = append(kept, e)
+				if e.y0 <= sy {
+					crossings = append(crossings, crossing{x: e.x0 + (sy-e.y0)*e.slope, dir: e.dir})
+				}
+			}
+			active = kept
+
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+			winding := 0
+			for i, c := range crossings {
+				winding += c.dir
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if inside && i+1 < len(crossings) {
+					addSpan(c.x, crossings[i+1].x)
+				}
+			}
+		}
+
+		// accumulate the row
+		row := out.alpha[(y-rect.Min.Y)*w : (y-rect.Min.Y+1)*w]
+		var full float32
+		for x := range row {
+			full += fulls[x]
+			v := full + cells[x]
+			if v > 1 {
+				v = 1
+			}
+			row[x] = v
+			cells[x], fulls[x] = 0, 0
+		}
+		cells[w], fulls[w] = 0, 0
+	}
+	return out
+}
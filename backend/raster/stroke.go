@@ -0,0 +1,264 @@
+package raster
+
+import (
+	"math"
+
+	"github.com/benoitkugler/webrender/backend"
+)
+
+// strokeStyle groups the parameters used to stroke a path
+// (in user space).
+type strokeStyle struct {
+	width      float64
+	dashes     []float64
+	dashOffset float64
+	backend.StrokeOptions
+}
+
+// stroke returns a list of polygons whose union (with the non zero rule)
+// is the outline of [lines], stroked with [style].
+// [tol] is the flattening precision used for round joins and caps.
+func (style strokeStyle) stroke(lines []polyline, tol float64) [][]point {
+	if len(style.dashes) != 0 {
+		var dashed []polyline
+		for _, line := range lines {
+			dashed = append(dashed, style.dash(line)...)
+		}
+		lines = dashed
+	}
+
+	st := stroker{style: style, hw: style.width / 2, tol: tol}
+	for _, line := range lines {
+		st.strokePolyline(line)
+	}
+	return st.out
+}
+
+// normalizeDashes returns nil if the dash pattern
+// disables dashing.
+func normalizeDashes(dashes []float64) []float64 {
+	var total float64
+	for _, d := range dashes {
+		if d < 0 {
+			return nil
+		}
+		total += d
+	}
+	if total == 0 {
+		return nil
+	}
+	if len(dashes)%2 == 1 { // repeat to have an even length
+		dashes = append(dashes, dashes...)
+	}
+	return dashes
+}
+
+// dash splits [line] into the "on" portions of the dash pattern.
+func (style strokeStyle) dash(line polyline) []polyline {
+	dashes := style.dashes
+	var total float64
+	for _, d := range dashes {
+		total += d
+	}
+
+	// find the starting state
+	index, on := 0, true
+	remaining := dashes[0]
+	offset := math.Mod(style.dashOffset, total)
+	if offset < 0 {
+		offset += total
+	}
+	for offset > 0 {
+		if offset < remaining {
+			remaining -= offset
+			break
+		}
+		offset -= remaining
+		index = (index + 1) % len(dashes)
+		on = !on
+		remaining = dashes[index]
+	}
+
+	points := line.points
+	if line.closed && !points[0].equal(points[len(points)-1]) {
+		points = append(points[:len(points):len(points)], points[0])
+	}
+
+	var (
+		out     []polyline
+		current []point
+	)
+	if on {
+		current = []point{points[0]}
+	}
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		segLength := b.sub(a).norm()
+		if segLength == 0 {
+			continue
+		}
+		dir := b.sub(a).scale(1 / segLength)
+		var pos float64 // distance already consumed on the segment
+		for segLength-pos > remaining {
+			pos += remaining
+			p := a.add(dir.scale(pos))
+			if on { // end of a dash
+				current = append(current, p)
+				out = append(out, polyline{points: current, dir: dir})
+				current = nil
+			} else { // start of a dash
+				current = []point{p}
+			}
+			on = !on
+			index = (index + 1) % len(dashes)
+			remaining = dashes[index]
+		}
+		remaining -= segLength - pos
+		if on {
+			current = append(current, b)
+		}
+	}
+	if on && len(current) >= 2 {
+		out = append(out, polyline{points: current})
+	}
+	return out
+}
+
+type stroker struct {
+	style strokeStyle
+	hw    float64 // half width
+	tol   float64
+	out   [][]point
+}
+
+// addPolygon appends the polygon, making sure it is
+// counter-clockwise oriented, so that the union may be
+// computed with the non-zero rule.
+func (st *stroker) addPolygon(poly []point) {
+	var area float64
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		area += p.cross(q)
+	}
+	if area < 0 {
+		for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+			poly[i], poly[j] = poly[j], poly[i]
+		}
+	}
+	st.out = append(st.out, poly)
+}
+
+func (st *stroker) circle(center point) {
+	r := st.hw
+	n := 8
+	if r > st.tol {
+		n = int(math.Ceil(math.Pi / math.Acos(1-st.tol/r)))
+		if n < 8 {
+			n = 8
+		} else if n > 512 {
+			n = 512
+		}
+	}
+	poly := make([]point, n)
+	for i := range poly {
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		poly[i] = point{center.x + r*math.Cos(angle), center.y + r*math.Sin(angle)}
+	}
+	st.addPolygon(poly)
+}
+
+// cap adds the cap at [p], where [dir] is the unit vector
+// pointing outside the line
+func (st *stroker) cap(p, dir point) {
+	switch st.style.LineCap {
+	case backend.RoundCap:
+		st.circle(p)
+	case backend.SquareCap:
+		n := dir.perp().scale(st.hw)
+		d := dir.scale(st.hw)
+		st.addPolygon([]point{p.add(n), p.add(n).add(d), p.sub(n).add(d), p.sub(n)})
+	}
+}
+
+// join adds the join at [p], between the directions [d1] and [d2]
+func (st *stroker) join(p, d1, d2 point) {
+	cross := d1.cross(d2)
+	dot := d1.dot(d2)
+	if math.Abs(cross) < 1e-9 && dot > 0 { // aligned
+		return
+	}
+	if st.style.LineJoin == backend.Round {
+		st.circle(p)
+		return
+	}
+	n1, n2 := d1.perp().scale(st.hw), d2.perp().scale(st.hw)
+	if cross > 0 { // turning left, the outer side is on the right
+		n1, n2 = n1.scale(-1), n2.scale(-1)
+	}
+	o1, o2 := p.add(n1), p.add(n2)
+	if st.style.LineJoin == backend.Miter && dot > -1+1e-9 {
+		limit := float64(st.style.MiterLimit)
+		if limit <= 0 {
+			limit = 10
+		}
+		ratio := math.Sqrt(2 / (1 + dot)) // 1 / sin(theta/2)
+		if ratio <= limit {
+			bisector := n1.add(n2)
+			if l := bisector.norm(); l != 0 {
+				tip := p.add(bisector.scale(st.hw * ratio / l))
+				st.addPolygon([]point{p, o1, tip, o2})
+				return
+			}
+		}
+	}
+	st.addPolygon([]point{p, o1, o2})
+}
+
+func (st *stroker) strokePolyline(line polyline) {
+	// remove duplicated points
+	points := make([]point, 0, len(line.points))
+	for _, p := range line.points {
+		if len(points) == 0 || !points[len(points)-1].equal(p) {
+			points = append(points, p)
+		}
+	}
+	if line.closed && len(points) > 1 && points[0].equal(points[len(points)-1]) {
+		points = points[:len(points)-1]
+	}
+
+	if len(points) == 1 { // zero length path
+		if st.style.LineCap == backend.ButtCap {
+			return
+		}
+		dir := line.dir
+		if dir == (point{}) {
+			dir = point{1, 0}
+		}
+		st.cap(points[0], dir)
+		st.cap(points[0], dir.scale(-1))
+		return
+	}
+
+	nbSegments := len(points) - 1
+	if line.closed {
+		nbSegments = len(points)
+	}
+	dirs := make([]point, nbSegments)
+	for i := range dirs {
+		a, b := points[i], points[(i+1)%len(points)]
+		d := b.sub(a)
+		dirs[i] = d.scale(1 / d.norm())
+		n := dirs[i].perp().scale(st.hw)
+		st.addPolygon([]point{a.add(n), b.add(n), b.sub(n), a.sub(n)})
+	}
+
+	for i := 1; i < nbSegments; i++ {
+		st.join(points[i], dirs[i-1], dirs[i])
+	}
+	if line.closed {
+		st.join(points[0], dirs[nbSegments-1], dirs[0])
+	} else {
+		st.cap(points[0], dirs[0].scale(-1))
+		st.cap(points[len(points)-1], dirs[nbSegments-1])
+	}
+}
@@ -0,0 +1,253 @@
+// Package recorder implements a [backend.Document] storing the drawing
+// calls into a [DisplayList], which may be serialized and later replayed
+// on any other [backend.Document].
+//
+// This is useful to lay out a document once and paint it several times,
+// or to write golden tests.
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/utils"
+)
+
+type Fl = utils.Fl
+
+// DisplayList is the recorded content of a document.
+//
+// Fonts, images and groups are stored once, and referenced by their index
+// in the commands.
+type DisplayList struct {
+	// Files are embedded (in order) before the pages are drawn
+	Files []File `json:",omitempty"`
+
+	Pages  []*Page
+	Groups []*Canvas `json:",omitempty"`
+	Fonts  []*Font   `json:",omitempty"`
+	Images []Image   `json:",omitempty"`
+
+	Anchors     [][]backend.Anchor     `json:",omitempty"`
+	Attachments []backend.Attachment   `json:",omitempty"`
+	Bookmarks   []backend.BookmarkNode `json:",omitempty"`
+	PageLabels  []backend.PageLabel    `json:",omitempty"`
+	Metadata    Metadata
+}
+
+// File is a file embedded with [backend.Document.EmbedFile]
+type File struct {
+	ID string
+	backend.Attachment
+}
+
+// Metadata stores the document information.
+type Metadata struct {
+	Title, Description, Creator, Producer string    `json:",omitempty"`
+	Authors, Keywords                     []string  `json:",omitempty"`
+	DateCreation, DateModification        time.Time `json:",omitempty"`
+}
+
+// Canvas is the content of a page or a group.
+type Canvas struct {
+	Commands []Command
+}
+
+// Page is a recorded page : its canvas may use
+// the page specific commands (links and boxes).
+type Page struct {
+	Box [4]Fl // left, top, right, bottom, as given to AddPage
+	Canvas
+}
+
+// Font is a font used in the document, with its content
+// and the glyphs used.
+type Font struct {
+	Origin      text.FontOrigin
+	Description backend.FontDescription
+	Content     []byte
+	Chars       *backend.FontChars
+}
+
+// Image is a raster image, stored with its (encoded) content.
+type Image struct {
+	Content  []byte
+	MimeType string
+	ID       int `json:",omitempty"`
+}
+
+// Op identifies a drawing operation.
+type Op uint8
+
+const (
+	_ Op = iota
+
+	// Canvas methods
+	OpSetBoundingBox  // Values : left, top, right, bottom
+	OpPushStack       // start of [backend.Canvas.OnNewStack]
+	OpPopStack        // end of [backend.Canvas.OnNewStack]
+	OpNewGroup        // Values : x, y, width, height, Index : group
+	OpDrawWithOpacity // Values : opacity, Index : group
+	OpPaint           // Index : [backend.PaintOp]
+	OpRectangle       // Values : x, y, width, height
+	OpMoveTo          // Values : x, y
+	OpLineTo          // Values : x, y
+	OpCubicTo         // Values : x1, y1, x2, y2, x3, y3
+	OpClosePath
+	OpAddFont          // Index : font
+	OpDrawText         // Texts
+	OpDrawRasterImage  // Values : width, height, Index : image, String : rendering
+	OpDrawGradient     // Values : width, height, Gradient
+	OpSetAlphaMask     // Index : group
+	OpClip             // Flag : even-odd
+	OpSetAlpha         // Values : alpha, Flag : stroke
+	OpSetColorRgba     // Values : R, G, B, A, Flag : stroke
+	OpSetColor         // Values : R, G, B, A, Device, Flag : stroke
+	OpSetColorPattern  // Values : content width, content height, matrix, Index : group, Flag : stroke
+	OpSetBlendingMode  // String : mode
+	OpSetLineWidth     // Values : width
+	OpSetDash          // Values : offset, dashes...
+	OpSetStrokeOptions // Values : line cap, line join, miter limit
+	OpTransform        // Values : matrix
+	OpSetTextPaint     // Index : [backend.PaintOp]
+	OpDrawWithBlur     // Values : standard deviation, Index : group
+
+	// Page methods
+	OpAddInternalLink   // Values : xMin, yMin, xMax, yMax, String : anchor
+	OpAddExternalLink   // Values : xMin, yMin, xMax, yMax, String : URL
+	OpAddFileAnnotation // Values : xMin, yMin, xMax, yMax, String : file ID
+	OpSetMediaBox       // Values : left, top, right, bottom
+	OpSetTrimBox        // Values : left, top, right, bottom
+	OpSetBleedBox       // Values : left, top, right, bottom
+	OpBeginStructure    // Structure
+	OpEndStructure
+	OpAddFormField // Values : xMin, yMin, xMax, yMax, Field
+
+	opEnd
+)
+
+// opNames is used for serialization, so that the encoded
+// display lists do not depend on the values of the constants.
+var opNames = [...]string{
+	OpSetBoundingBox:    "SetBoundingBox",
+	OpPushStack:         "PushStack",
+	OpPopStack:          "PopStack",
+	OpNewGroup:          "NewGroup",
+	OpDrawWithOpacity:   "DrawWithOpacity",
+	OpPaint:             "Paint",
+	OpRectangle:         "Rectangle",
+	OpMoveTo:            "MoveTo",
+	OpLineTo:            "LineTo",
+	OpCubicTo:           "CubicTo",
+	OpClosePath:         "ClosePath",
+	OpAddFont:           "AddFont",
+	OpDrawText:          "DrawText",
+	OpDrawRasterImage:   "DrawRasterImage",
+	OpDrawGradient:      "DrawGradient",
+	OpSetAlphaMask:      "SetAlphaMask",
+	OpClip:              "Clip",
+	OpSetAlpha:          "SetAlpha",
+	OpSetColorRgba:      "SetColorRgba",
+	OpSetColor:          "SetColor",
+	OpSetColorPattern:   "SetColorPattern",
+	OpSetBlendingMode:   "SetBlendingMode",
+	OpSetLineWidth:      "SetLineWidth",
+	OpSetDash:           "SetDash",
+	OpSetStrokeOptions:  "SetStrokeOptions",
+	OpTransform:         "Transform",
+	OpSetTextPaint:      "SetTextPaint",
+	OpDrawWithBlur:      "DrawWithBlur",
+	OpAddInternalLink:   "AddInternalLink",
+	OpAddExternalLink:   "AddExternalLink",
+	OpAddFileAnnotation: "AddFileAnnotation",
+	OpSetMediaBox:       "SetMediaBox",
+	OpSetTrimBox:        "SetTrimBox",
+	OpSetBleedBox:       "SetBleedBox",
+	OpBeginStructure:    "BeginStructure",
+	OpEndStructure:      "EndStructure",
+	OpAddFormField:      "AddFormField",
+}
+
+func (op Op) String() string {
+	if op > 0 && op < opEnd {
+		return opNames[op]
+	}
+	return fmt.Sprintf("<invalid Op %d>", uint8(op))
+}
+
+func (op Op) MarshalText() ([]byte, error) {
+	if op == 0 || op >= opEnd {
+		return nil, fmt.Errorf("invalid operation %d", op)
+	}
+	return []byte(opNames[op]), nil
+}
+
+func (op *Op) UnmarshalText(data []byte) error {
+	for i, name := range opNames {
+		if name != "" && name == string(data) {
+			*op = Op(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid operation %s", data)
+}
+
+// Command is one recorded call. The meaning of the
+// arguments depends on [Op].
+type Command struct {
+	Op        Op
+	Values    []Fl                      `json:",omitempty"`
+	Index     int                       `json:",omitempty"` // group, font or image index, or paint operation
+	Flag      bool                      `json:",omitempty"`
+	String    string                    `json:",omitempty"`
+	Texts     []Text                    `json:",omitempty"`
+	Gradient  *backend.GradientLayout   `json:",omitempty"`
+	Structure *backend.StructureElement `json:",omitempty"`
+	Field     *FormField                `json:",omitempty"`
+	Device    *parser.DeviceColor       `json:",omitempty"`
+}
+
+// FormField is a [backend.FormField], where the font is referenced
+// by index, or -1 if the field has no font.
+type FormField struct {
+	backend.FormField
+	Font int
+}
+
+// Text is a [backend.TextDrawing], where fonts are referenced by index.
+type Text struct {
+	Runs []TextRun
+
+	FontSize, ScaleX Fl
+	X, Y             Fl
+	Angle            Fl `json:",omitempty"`
+	Skew             Fl `json:",omitempty"`
+	Embolden         Fl `json:",omitempty"`
+
+	Text string
+}
+
+// TextRun is a [backend.TextRun], where the font is referenced by index.
+type TextRun struct {
+	Font   int
+	Glyphs []backend.TextGlyph
+}
+
+// Encode writes the display list in JSON format.
+func (dl *DisplayList) Encode(w io.Writer) error {
+	return json.NewEncoder(w).Encode(dl)
+}
+
+// Decode reads a display list written by [DisplayList.Encode].
+func Decode(r io.Reader) (*DisplayList, error) {
+	var dl DisplayList
+	if err := json.NewDecoder(r).Decode(&dl); err != nil {
+		return nil, fmt.Errorf("invalid display list: %s", err)
+	}
+	return &dl, nil
+}
@@ -0,0 +1,325 @@
+package recorder
+
+import (
+	"io"
+	"time"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+var (
+	_ backend.Document     = (*Document)(nil)
+	_ backend.Page         = (*page)(nil)
+	_ backend.GraphicState = (*canvas)(nil)
+	_ backend.BlurCanvas   = (*canvas)(nil)
+)
+
+// Document implements [backend.Document], recording
+// all the calls. Use [Document.DisplayList] to access the result.
+type Document struct {
+	list DisplayList
+
+	fonts  map[backend.Font]int // index in list.Fonts
+	images map[int]int          // image ID to index in list.Images
+}
+
+// NewDocument returns an empty recorder.
+func NewDocument() *Document {
+	return &Document{fonts: make(map[backend.Font]int), images: make(map[int]int)}
+}
+
+// DisplayList returns the recorded content, which is
+// still modified by subsequent calls.
+func (d *Document) DisplayList() *DisplayList { return &d.list }
+
+func (d *Document) AddPage(left, top, right, bottom Fl) backend.Page {
+	p := &Page{Box: [4]Fl{left, top, right, bottom}}
+	d.list.Pages = append(d.list.Pages, p)
+	return &page{canvas: newCanvas(d, &p.Canvas, left, top, right, bottom)}
+}
+
+func (d *Document) CreateAnchors(anchors [][]backend.Anchor) { d.list.Anchors = anchors }
+
+func (d *Document) SetAttachments(as []backend.Attachment) { d.list.Attachments = as }
+
+func (d *Document) EmbedFile(fileID string, a backend.Attachment) {
+	d.list.Files = append(d.list.Files, File{ID: fileID, Attachment: a})
+}
+
+func (d *Document) SetTitle(title string)                    { d.list.Metadata.Title = title }
+func (d *Document) SetDescription(description string)        { d.list.Metadata.Description = description }
+func (d *Document) SetCreator(creator string)                { d.list.Metadata.Creator = creator }
+func (d *Document) SetAuthors(authors []string)              { d.list.Metadata.Authors = authors }
+func (d *Document) SetKeywords(keywords []string)            { d.list.Metadata.Keywords = keywords }
+func (d *Document) SetProducer(producer string)              { d.list.Metadata.Producer = producer }
+func (d *Document) SetDateCreation(date time.Time)           { d.list.Metadata.DateCreation = date }
+func (d *Document) SetDateModification(date time.Time)       { d.list.Metadata.DateModification = date }
+func (d *Document) SetBookmarks(root []backend.BookmarkNode) { d.list.Bookmarks = root }
+func (d *Document) SetPageLabels(labels []backend.PageLabel) { d.list.PageLabels = labels }
+
+// canvas records the calls into [target].
+type canvas struct {
+	doc    *Document
+	target *Canvas
+
+	bbox [4]Fl
+	// the CTM is tracked to implement GetTransform
+	ctm   matrix.Transform
+	stack []matrix.Transform
+}
+
+func newCanvas(doc *Document, target *Canvas, left, top, right, bottom Fl) *canvas {
+	return &canvas{doc: doc, target: target, bbox: [4]Fl{left, top, right, bottom}, ctm: matrix.Identity()}
+}
+
+func (c *canvas) record(cmd Command) { c.target.Commands = append(c.target.Commands, cmd) }
+
+// group returns the index of a group created by [NewGroup]
+func (c *canvas) group(g backend.Canvas) (int, bool) {
+	rec, ok := g.(*groupCanvas)
+	if !ok || rec.doc != c.doc {
+		logger.WarningLogger.Printf("recorder: unsupported canvas %T", g)
+		return 0, false
+	}
+	return rec.index, true
+}
+
+func (c *canvas) GetBoundingBox() (left, top, right, bottom Fl) {
+	return c.bbox[0], c.bbox[1], c.bbox[2], c.bbox[3]
+}
+
+func (c *canvas) SetBoundingBox(left, top, right, bottom Fl) {
+	c.bbox = [4]Fl{left, top, right, bottom}
+	c.record(Command{Op: OpSetBoundingBox, Values: []Fl{left, top, right, bottom}})
+}
+
+func (c *canvas) OnNewStack(f func()) {
+	c.stack = append(c.stack, c.ctm)
+	c.record(Command{Op: OpPushStack})
+	f()
+	c.record(Command{Op: OpPopStack})
+	c.ctm = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+func (c *canvas) State() backend.GraphicState { return c }
+
+// groupCanvas is returned by NewGroup, and stores its
+// index in the display list
+type groupCanvas struct {
+	*canvas
+	index int
+}
+
+func (c *canvas) NewGroup(x, y, width, height Fl) backend.Canvas {
+	g := new(Canvas)
+	index := len(c.doc.list.Groups)
+	c.doc.list.Groups = append(c.doc.list.Groups, g)
+	c.record(Command{Op: OpNewGroup, Values: []Fl{x, y, width, height}, Index: index})
+	return &groupCanvas{canvas: newCanvas(c.doc, g, x, y, x+width, y+height), index: index}
+}
+
+func (c *canvas) DrawWithOpacity(opacity Fl, group backend.Canvas) {
+	if index, ok := c.group(group); ok {
+		c.record(Command{Op: OpDrawWithOpacity, Values: []Fl{opacity}, Index: index})
+	}
+}
+
+func (c *canvas) DrawWithBlur(stdDeviation Fl, group backend.Canvas) {
+	if index, ok := c.group(group); ok {
+		c.record(Command{Op: OpDrawWithBlur, Values: []Fl{stdDeviation}, Index: index})
+	}
+}
+
+func (c *canvas) Paint(op backend.PaintOp) { c.record(Command{Op: OpPaint, Index: int(op)}) }
+
+func (c *canvas) Rectangle(x, y, width, height Fl) {
+	c.record(Command{Op: OpRectangle, Values: []Fl{x, y, width, height}})
+}
+
+func (c *canvas) MoveTo(x, y Fl) { c.record(Command{Op: OpMoveTo, Values: []Fl{x, y}}) }
+
+func (c *canvas) LineTo(x, y Fl) { c.record(Command{Op: OpLineTo, Values: []Fl{x, y}}) }
+
+func (c *canvas) CubicTo(x1, y1, x2, y2, x3, y3 Fl) {
+	c.record(Command{Op: OpCubicTo, Values: []Fl{x1, y1, x2, y2, x3, y3}})
+}
+
+func (c *canvas) ClosePath() { c.record(Command{Op: OpClosePath}) }
+
+// AddFont returns the same [backend.FontChars] for a given font,
+// so that the recorded value contains all the glyphs used
+// once the drawing is done.
+func (c *canvas) AddFont(font backend.Font, content []byte) *backend.FontChars {
+	index, has := c.doc.fonts[font]
+	if !has {
+		index = len(c.doc.list.Fonts)
+		c.doc.list.Fonts = append(c.doc.list.Fonts, &Font{
+			Origin:      font.Origin(),
+			Description: font.Description(),
+			Content:     content,
+			Chars: &backend.FontChars{
+				Cmap:    make(map[backend.GID][]rune),
+				Extents: make(map[backend.GID]backend.GlyphExtents),
+			},
+		})
+		c.doc.fonts[font] = index
+	}
+	c.record(Command{Op: OpAddFont, Index: index})
+	return c.doc.list.Fonts[index].Chars
+}
+
+func (c *canvas) DrawText(texts []backend.TextDrawing) {
+	out := make([]Text, len(texts))
+	for i, td := range texts {
+		runs := make([]TextRun, 0, len(td.Runs))
+		for _, run := range td.Runs {
+			index, ok := c.doc.fonts[run.Font]
+			if !ok { // fonts are registered by AddFont
+				logger.WarningLogger.Printf("recorder: font %s not added", run.Font.Origin().File)
+				continue
+			}
+			runs = append(runs, TextRun{Font: index, Glyphs: run.Glyphs})
+		}
+		out[i] = Text{
+			Runs: runs, FontSize: td.FontSize, ScaleX: td.ScaleX,
+			X: td.X, Y: td.Y, Angle: td.Angle, Skew: td.Skew, Embolden: td.Embolden, Text: string(td.Text),
+		}
+	}
+	c.record(Command{Op: OpDrawText, Texts: out})
+}
+
+func (c *canvas) DrawRasterImage(img backend.RasterImage, width, height Fl) {
+	index, has := c.doc.images[img.ID]
+	if !has || img.ID == 0 {
+		if seeker, ok := img.Content.(io.Seeker); ok {
+			seeker.Seek(0, io.SeekStart)
+		}
+		content, err := io.ReadAll(img.Content)
+		if err != nil {
+			logger.WarningLogger.Printf("recorder: invalid raster image: %s", err)
+			return
+		}
+		index = len(c.doc.list.Images)
+		c.doc.list.Images = append(c.doc.list.Images, Image{Content: content, MimeType: img.MimeType, ID: img.ID})
+		if img.ID != 0 {
+			c.doc.images[img.ID] = index
+		}
+	}
+	c.record(Command{Op: OpDrawRasterImage, Values: []Fl{width, height}, Index: index, String: img.Rendering})
+}
+
+func (c *canvas) DrawGradient(gradient backend.GradientLayout, width, height Fl) {
+	c.record(Command{Op: OpDrawGradient, Values: []Fl{width, height}, Gradient: &gradient})
+}
+
+// GraphicState methods
+
+func (c *canvas) SetAlphaMask(mask backend.Canvas) {
+	if index, ok := c.group(mask); ok {
+		c.record(Command{Op: OpSetAlphaMask, Index: index})
+	}
+}
+
+func (c *canvas) Clip(evenOdd bool) { c.record(Command{Op: OpClip, Flag: evenOdd}) }
+
+func (c *canvas) SetAlpha(alpha Fl, stroke bool) {
+	c.record(Command{Op: OpSetAlpha, Values: []Fl{alpha}, Flag: stroke})
+}
+
+func (c *canvas) SetColorRgba(color parser.RGBA, stroke bool) {
+	c.record(Command{Op: OpSetColorRgba, Values: []Fl{color.R, color.G, color.B, color.A}, Flag: stroke})
+}
+
+func (c *canvas) SetColor(color parser.Color, stroke bool) {
+	device := color.Device
+	rgba := color.RGBA
+	c.record(Command{Op: OpSetColor, Values: []Fl{rgba.R, rgba.G, rgba.B, rgba.A}, Device: &device, Flag: stroke})
+}
+
+func (c *canvas) SetColorPattern(pattern backend.Canvas, contentWidth, contentHeight Fl, mat matrix.Transform, stroke bool) {
+	if index, ok := c.group(pattern); ok {
+		c.record(Command{
+			Op:     OpSetColorPattern,
+			Values: []Fl{contentWidth, contentHeight, mat.A, mat.B, mat.C, mat.D, mat.E, mat.F},
+			Index:  index, Flag: stroke,
+		})
+	}
+}
+
+func (c *canvas) SetBlendingMode(mode string) {
+	c.record(Command{Op: OpSetBlendingMode, String: mode})
+}
+
+func (c *canvas) SetLineWidth(width Fl) { c.record(Command{Op: OpSetLineWidth, Values: []Fl{width}}) }
+
+func (c *canvas) SetDash(dashes []Fl, offset Fl) {
+	c.record(Command{Op: OpSetDash, Values: append([]Fl{offset}, dashes...)})
+}
+
+func (c *canvas) SetStrokeOptions(opts backend.StrokeOptions) {
+	c.record(Command{Op: OpSetStrokeOptions, Values: []Fl{Fl(opts.LineCap), Fl(opts.LineJoin), opts.MiterLimit}})
+}
+
+func (c *canvas) GetTransform() matrix.Transform { return c.ctm }
+
+func (c *canvas) Transform(mt matrix.Transform) {
+	c.ctm.RightMultBy(mt)
+	c.record(Command{Op: OpTransform, Values: []Fl{mt.A, mt.B, mt.C, mt.D, mt.E, mt.F}})
+}
+
+func (c *canvas) SetTextPaint(op backend.PaintOp) {
+	c.record(Command{Op: OpSetTextPaint, Index: int(op)})
+}
+
+// page adds the page specific methods
+type page struct {
+	*canvas
+}
+
+func (p *page) AddInternalLink(xMin, yMin, xMax, yMax Fl, anchorName string) {
+	p.record(Command{Op: OpAddInternalLink, Values: []Fl{xMin, yMin, xMax, yMax}, String: anchorName})
+}
+
+func (p *page) AddExternalLink(xMin, yMin, xMax, yMax Fl, url string) {
+	p.record(Command{Op: OpAddExternalLink, Values: []Fl{xMin, yMin, xMax, yMax}, String: url})
+}
+
+func (p *page) AddFileAnnotation(xMin, yMin, xMax, yMax Fl, fileID string) {
+	p.record(Command{Op: OpAddFileAnnotation, Values: []Fl{xMin, yMin, xMax, yMax}, String: fileID})
+}
+
+func (p *page) SetMediaBox(left, top, right, bottom Fl) {
+	p.record(Command{Op: OpSetMediaBox, Values: []Fl{left, top, right, bottom}})
+}
+
+func (p *page) SetTrimBox(left, top, right, bottom Fl) {
+	p.record(Command{Op: OpSetTrimBox, Values: []Fl{left, top, right, bottom}})
+}
+
+func (p *page) SetBleedBox(left, top, right, bottom Fl) {
+	p.record(Command{Op: OpSetBleedBox, Values: []Fl{left, top, right, bottom}})
+}
+
+func (p *page) BeginStructure(element backend.StructureElement) {
+	p.record(Command{Op: OpBeginStructure, Structure: &element})
+}
+
+func (p *page) EndStructure() { p.record(Command{Op: OpEndStructure}) }
+
+func (p *page) AddFormField(xMin, yMin, xMax, yMax Fl, field backend.FormField) {
+	index := -1
+	if field.Font != nil {
+		var ok bool
+		index, ok = p.doc.fonts[field.Font]
+		if !ok { // fonts are registered by AddFont
+			logger.WarningLogger.Printf("recorder: font %s not added", field.Font.Origin().File)
+			index = -1
+		}
+	}
+	field.Font = nil
+	p.record(Command{Op: OpAddFormField, Values: []Fl{xMin, yMin, xMax, yMax}, Field: &FormField{FormField: field, Font: index}})
+}
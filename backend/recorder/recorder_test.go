@@ -0,0 +1,176 @@
+package recorder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/raster"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/utils/testutils/htmltest"
+)
+
+func roundTrip(t *testing.T, dl *DisplayList) *DisplayList {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := dl.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the encoding is stable
+	var buf2 bytes.Buffer
+	if err := out.Encode(&buf2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Fatal("unstable encoding")
+	}
+	return out
+}
+
+func TestGroups(t *testing.T) {
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	page.State().Transform(matrix.New(1, 0, 0, -1, 0, 100))
+	page.OnNewStack(func() {
+		group := page.NewGroup(0, 0, 50, 50)
+		group.State().SetColorRgba(parser.RGBA{R: 1, A: 1}, false)
+		group.Rectangle(0, 0, 100, 100)
+		group.Paint(backend.FillNonZero)
+		page.DrawWithOpacity(0.5, group)
+	})
+	if tr := page.State().GetTransform(); tr != matrix.New(1, 0, 0, -1, 0, 100) {
+		t.Fatalf("unexpected transform %v", tr)
+	}
+
+	dl := roundTrip(t, doc.DisplayList())
+	if len(dl.Groups) != 1 || len(dl.Groups[0].Commands) != 3 {
+		t.Fatalf("unexpected groups %v", dl.Groups)
+	}
+
+	out := raster.NewDocument(1)
+	if err := dl.Replay(out); err != nil {
+		t.Fatal(err)
+	}
+	img := out.Images()[0]
+	if got := img.RGBAAt(25, 25); got.R < 120 || got.R > 135 || got.A < 120 || got.A > 135 {
+		t.Fatalf("unexpected color %v", got)
+	}
+	if got := img.RGBAAt(75, 75); got.A != 0 {
+		t.Fatalf("unexpected color %v", got)
+	}
+}
+
+// noBlurDocument hides the [backend.BlurCanvas] implementation
+// of the raster pages
+type noBlurDocument struct{ *raster.Document }
+
+type noBlurPage struct{ backend.Page }
+
+func (d noBlurDocument) AddPage(left, top, right, bottom Fl) backend.Page {
+	return noBlurPage{d.Document.AddPage(left, top, right, bottom)}
+}
+
+func TestBlurFallback(t *testing.T) {
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	page.State().Transform(matrix.New(1, 0, 0, -1, 0, 100))
+	group := page.NewGroup(0, 0, 100, 100)
+	group.State().SetColorRgba(parser.RGBA{R: 1, A: 1}, false)
+	group.Rectangle(0, 0, 50, 100)
+	group.Paint(backend.FillNonZero)
+	page.(backend.BlurCanvas).DrawWithBlur(5, group)
+
+	dl := roundTrip(t, doc.DisplayList())
+
+	blurred := raster.NewDocument(1)
+	if err := dl.Replay(blurred); err != nil {
+		t.Fatal(err)
+	}
+	if got := blurred.Images()[0].RGBAAt(52, 50); got.A == 0 {
+		t.Fatalf("unexpected color %v", got)
+	}
+
+	// the content is drawn without blur
+	crisp := noBlurDocument{raster.NewDocument(1)}
+	if err := dl.Replay(crisp); err != nil {
+		t.Fatal(err)
+	}
+	if got := crisp.Images()[0].RGBAAt(52, 50); got.A != 0 {
+		t.Fatalf("unexpected color %v", got)
+	}
+	if got := crisp.Images()[0].RGBAAt(48, 50); got.A != 255 {
+		t.Fatalf("unexpected color %v", got)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, commands := range [][]Command{
+		{{Op: OpPopStack}},
+		{{Op: OpRectangle, Values: []Fl{1}}},
+		{{Op: OpDrawWithOpacity, Values: []Fl{1}, Index: 2}},
+		{{Op: OpAddFont}},
+	} {
+		dl := DisplayList{Pages: []*Page{{Canvas: Canvas{Commands: commands}}}}
+		if err := dl.Replay(NewDocument()); err == nil {
+			t.Fatalf("expected error for %v", commands)
+		}
+	}
+
+	dl := DisplayList{Groups: []*Canvas{{Commands: []Command{{Op: OpSetMediaBox, Values: []Fl{0, 0, 1, 1}}}}}}
+	dl.Pages = []*Page{{Canvas: Canvas{Commands: []Command{{Op: OpNewGroup, Values: []Fl{0, 0, 1, 1}}}}}}
+	if err := dl.Replay(NewDocument()); err == nil {
+		t.Fatal("expected error for page command in group")
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte(`{"Pages": [{"Commands": [{"Op": "Unknown"}]}]}`))); err == nil {
+		t.Fatal("expected error for unknown operation")
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := htmltest.Document(t, `
+	<title>My title</title>
+	<style>@page { size: 200px 100px; margin: 0 } body { margin: 0 }</style>
+	<div style="background: linear-gradient(red, blue); height: 30px; opacity: 0.5"></div>
+	<p style="margin: 0"><a href="#target">Internal</a> <a id="target" href="https://example.com">External</a></p>`)
+
+	direct := raster.NewDocument(1)
+	doc.Write(direct, 1, nil)
+
+	recorder := NewDocument()
+	doc.Write(recorder, 1, nil)
+	dl := roundTrip(t, recorder.DisplayList())
+	if dl.Metadata.Title != "My title" {
+		t.Fatalf("unexpected title %s", dl.Metadata.Title)
+	}
+	if len(dl.Fonts) == 0 || len(dl.Fonts[0].Chars.Cmap) == 0 {
+		t.Fatal("missing font characters")
+	}
+
+	replayed := raster.NewDocument(1)
+	if err := dl.Replay(replayed); err != nil {
+		t.Fatal(err)
+	}
+	// replaying is equivalent to drawing directly
+	exp, got := direct.Images()[0], replayed.Images()[0]
+	if exp.Bounds() != got.Bounds() || !bytes.Equal(exp.Pix, got.Pix) {
+		t.Fatal("replayed output differs")
+	}
+
+	// replaying on a recorder yields the same display list
+	again := NewDocument()
+	if err := dl.Replay(again); err != nil {
+		t.Fatal(err)
+	}
+	var b1, b2 bytes.Buffer
+	dl.Encode(&b1)
+	again.DisplayList().Encode(&b2)
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Fatal("replayed display list differs")
+	}
+}
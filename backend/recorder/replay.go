@@ -0,0 +1,331 @@
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/text"
+)
+
+// replayFont implements [backend.Font] for recorded fonts
+type replayFont struct {
+	origin      text.FontOrigin
+	description backend.FontDescription
+}
+
+func (f *replayFont) Origin() text.FontOrigin              { return f.origin }
+func (f *replayFont) Description() backend.FontDescription { return f.description }
+
+// player holds the objects created on the target
+type player struct {
+	list   *DisplayList
+	fonts  []*replayFont
+	groups []backend.Canvas // created by NewGroup
+}
+
+// Replay draws the display list on [target], calling the
+// [backend.Document] methods in the same order as the html/document package.
+// An error is returned if the list is not valid (for instance, if it
+// references an unknown group).
+func (dl *DisplayList) Replay(target backend.Document) error {
+	pl := player{
+		list:   dl,
+		fonts:  make([]*replayFont, len(dl.Fonts)),
+		groups: make([]backend.Canvas, len(dl.Groups)),
+	}
+	for i, font := range dl.Fonts {
+		pl.fonts[i] = &replayFont{origin: font.Origin, description: font.Description}
+	}
+
+	for _, file := range dl.Files {
+		target.EmbedFile(file.ID, file.Attachment)
+	}
+	for i, page := range dl.Pages {
+		p := target.AddPage(page.Box[0], page.Box[1], page.Box[2], page.Box[3])
+		if err := pl.replay(p, page.Commands); err != nil {
+			return fmt.Errorf("page %d: %s", i+1, err)
+		}
+	}
+	target.CreateAnchors(dl.Anchors)
+	target.SetAttachments(dl.Attachments)
+	target.SetBookmarks(dl.Bookmarks)
+	target.SetPageLabels(dl.PageLabels)
+
+	md := dl.Metadata
+	target.SetTitle(md.Title)
+	target.SetDescription(md.Description)
+	target.SetCreator(md.Creator)
+	target.SetAuthors(md.Authors)
+	target.SetKeywords(md.Keywords)
+	target.SetProducer(md.Producer)
+	target.SetDateCreation(md.DateCreation)
+	target.SetDateModification(md.DateModification)
+	return nil
+}
+
+// expected number of values, for each operation
+var opArity = [opEnd]int{
+	OpSetBoundingBox:    4,
+	OpNewGroup:          4,
+	OpDrawWithOpacity:   1,
+	OpDrawWithBlur:      1,
+	OpRectangle:         4,
+	OpMoveTo:            2,
+	OpLineTo:            2,
+	OpCubicTo:           6,
+	OpDrawRasterImage:   2,
+	OpDrawGradient:      2,
+	OpSetAlpha:          1,
+	OpSetColorRgba:      4,
+	OpSetColor:          4,
+	OpSetColorPattern:   8,
+	OpSetLineWidth:      1,
+	OpSetDash:           1, // at least
+	OpSetStrokeOptions:  3,
+	OpTransform:         6,
+	OpAddInternalLink:   4,
+	OpAddExternalLink:   4,
+	OpAddFileAnnotation: 4,
+	OpSetMediaBox:       4,
+	OpSetTrimBox:        4,
+	OpSetBleedBox:       4,
+	OpAddFormField:      4,
+}
+
+// check validates the arguments of [cmd]
+func (pl *player) check(cmd Command) error {
+	if cmd.Op == 0 || cmd.Op >= opEnd {
+		return fmt.Errorf("invalid operation %d", cmd.Op)
+	}
+	if len(cmd.Values) < opArity[cmd.Op] {
+		return fmt.Errorf("missing arguments for %s", cmd.Op)
+	}
+	var size int
+	switch cmd.Op {
+	case OpNewGroup, OpDrawWithOpacity, OpDrawWithBlur, OpSetAlphaMask, OpSetColorPattern:
+		size = len(pl.groups)
+	case OpAddFont:
+		size = len(pl.fonts)
+	case OpDrawRasterImage:
+		size = len(pl.list.Images)
+	case OpDrawGradient:
+		if cmd.Gradient == nil {
+			return fmt.Errorf("missing gradient for %s", cmd.Op)
+		}
+		return nil
+	case OpBeginStructure:
+		if cmd.Structure == nil {
+			return fmt.Errorf("missing structure element for %s", cmd.Op)
+		}
+		return nil
+	case OpSetColor:
+		if cmd.Device == nil {
+			return fmt.Errorf("missing device color for %s", cmd.Op)
+		}
+		return nil
+	case OpAddFormField:
+		if cmd.Field == nil {
+			return fmt.Errorf("missing field for %s", cmd.Op)
+		}
+		if cmd.Field.Font < -1 || cmd.Field.Font >= len(pl.fonts) {
+			return fmt.Errorf("invalid font index %d", cmd.Field.Font)
+		}
+		return nil
+	case OpDrawText:
+		for _, text := range cmd.Texts {
+			for _, run := range text.Runs {
+				if run.Font < 0 || run.Font >= len(pl.fonts) {
+					return fmt.Errorf("invalid font index %d", run.Font)
+				}
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+	if cmd.Index < 0 || cmd.Index >= size {
+		return fmt.Errorf("invalid index %d for %s", cmd.Index, cmd.Op)
+	}
+	return nil
+}
+
+// replay draws [commands] on [dst], which must be a
+// [backend.Page] if page specific commands are used.
+func (pl *player) replay(dst backend.Canvas, commands []Command) error {
+	_, popped, err := pl.replayStack(dst, commands)
+	if err == nil && popped {
+		return fmt.Errorf("unexpected %s", OpPopStack)
+	}
+	return err
+}
+
+// replayStack stops at the first unmatched OpPopStack, and returns
+// the number of commands processed (including the OpPopStack).
+func (pl *player) replayStack(dst backend.Canvas, commands []Command) (n int, popped bool, err error) {
+	for i := 0; i < len(commands); i++ {
+		cmd := commands[i]
+		if err := pl.check(cmd); err != nil {
+			return 0, false, err
+		}
+		v := cmd.Values
+		switch cmd.Op {
+		case OpPopStack:
+			return i + 1, true, nil
+		case OpPushStack:
+			dst.OnNewStack(func() { n, _, err = pl.replayStack(dst, commands[i+1:]) })
+			if err != nil {
+				return 0, false, err
+			}
+			i += n
+		case OpSetBoundingBox:
+			dst.SetBoundingBox(v[0], v[1], v[2], v[3])
+		case OpNewGroup:
+			group := dst.NewGroup(v[0], v[1], v[2], v[3])
+			pl.groups[cmd.Index] = group
+			if err := pl.replay(group, pl.list.Groups[cmd.Index].Commands); err != nil {
+				return 0, false, err
+			}
+		case OpDrawWithOpacity:
+			if group := pl.groups[cmd.Index]; group != nil {
+				dst.DrawWithOpacity(v[0], group)
+			}
+		case OpDrawWithBlur:
+			if group := pl.groups[cmd.Index]; group != nil {
+				if blur, ok := dst.(backend.BlurCanvas); ok {
+					blur.DrawWithBlur(v[0], group)
+				} else { // draw the content without blur
+					dst.DrawWithOpacity(1, group)
+				}
+			}
+		case OpPaint:
+			dst.Paint(backend.PaintOp(cmd.Index))
+		case OpRectangle:
+			dst.Rectangle(v[0], v[1], v[2], v[3])
+		case OpMoveTo:
+			dst.MoveTo(v[0], v[1])
+		case OpLineTo:
+			dst.LineTo(v[0], v[1])
+		case OpCubicTo:
+			dst.CubicTo(v[0], v[1], v[2], v[3], v[4], v[5])
+		case OpClosePath:
+			dst.ClosePath()
+		case OpAddFont:
+			font := pl.list.Fonts[cmd.Index]
+			chars := dst.AddFont(pl.fonts[cmd.Index], font.Content)
+			if chars != nil && font.Chars != nil {
+				copyChars(chars, font.Chars)
+			}
+		case OpDrawText:
+			dst.DrawText(pl.texts(cmd.Texts))
+		case OpDrawRasterImage:
+			img := pl.list.Images[cmd.Index]
+			dst.DrawRasterImage(backend.RasterImage{
+				Content:   bytes.NewReader(img.Content),
+				MimeType:  img.MimeType,
+				Rendering: cmd.String,
+				ID:        img.ID,
+			}, v[0], v[1])
+		case OpDrawGradient:
+			dst.DrawGradient(*cmd.Gradient, v[0], v[1])
+		case OpSetAlphaMask:
+			if group := pl.groups[cmd.Index]; group != nil {
+				dst.State().SetAlphaMask(group)
+			}
+		case OpClip:
+			dst.State().Clip(cmd.Flag)
+		case OpSetAlpha:
+			dst.State().SetAlpha(v[0], cmd.Flag)
+		case OpSetColorRgba:
+			dst.State().SetColorRgba(parser.RGBA{R: v[0], G: v[1], B: v[2], A: v[3]}, cmd.Flag)
+		case OpSetColor:
+			dst.State().SetColor(parser.Color{
+				Type:   parser.ColorRGBA,
+				RGBA:   parser.RGBA{R: v[0], G: v[1], B: v[2], A: v[3]},
+				Device: *cmd.Device,
+			}, cmd.Flag)
+		case OpSetColorPattern:
+			if group := pl.groups[cmd.Index]; group != nil {
+				dst.State().SetColorPattern(group, v[0], v[1], matrix.New(v[2], v[3], v[4], v[5], v[6], v[7]), cmd.Flag)
+			}
+		case OpSetBlendingMode:
+			dst.State().SetBlendingMode(cmd.String)
+		case OpSetLineWidth:
+			dst.State().SetLineWidth(v[0])
+		case OpSetDash:
+			dst.State().SetDash(v[1:], v[0])
+		case OpSetStrokeOptions:
+			dst.State().SetStrokeOptions(backend.StrokeOptions{
+				LineCap: backend.StrokeCapMode(v[0]), LineJoin: backend.StrokeJoinMode(v[1]), MiterLimit: v[2],
+			})
+		case OpTransform:
+			dst.State().Transform(matrix.New(v[0], v[1], v[2], v[3], v[4], v[5]))
+		case OpSetTextPaint:
+			dst.State().SetTextPaint(backend.PaintOp(cmd.Index))
+		default: // page specific methods
+			page, ok := dst.(backend.Page)
+			if !ok {
+				return 0, false, fmt.Errorf("%s is only supported on pages", cmd.Op)
+			}
+			switch cmd.Op {
+			case OpAddInternalLink:
+				page.AddInternalLink(v[0], v[1], v[2], v[3], cmd.String)
+			case OpAddExternalLink:
+				page.AddExternalLink(v[0], v[1], v[2], v[3], cmd.String)
+			case OpAddFileAnnotation:
+				page.AddFileAnnotation(v[0], v[1], v[2], v[3], cmd.String)
+			case OpSetMediaBox:
+				page.SetMediaBox(v[0], v[1], v[2], v[3])
+			case OpSetTrimBox:
+				page.SetTrimBox(v[0], v[1], v[2], v[3])
+			case OpSetBleedBox:
+				page.SetBleedBox(v[0], v[1], v[2], v[3])
+			case OpBeginStructure:
+				page.BeginStructure(*cmd.Structure)
+			case OpEndStructure:
+				page.EndStructure()
+			case OpAddFormField:
+				field := cmd.Field.FormField
+				if cmd.Field.Font != -1 {
+					field.Font = pl.fonts[cmd.Field.Font]
+				}
+				page.AddFormField(v[0], v[1], v[2], v[3], field)
+			}
+		}
+	}
+	return len(commands), false, nil
+}
+
+func (pl *player) texts(texts []Text) []backend.TextDrawing {
+	out := make([]backend.TextDrawing, len(texts))
+	for i, td := range texts {
+		runs := make([]backend.TextRun, len(td.Runs))
+		for j, run := range td.Runs {
+			runs[j] = backend.TextRun{Font: pl.fonts[run.Font], Glyphs: run.Glyphs}
+		}
+		out[i] = backend.TextDrawing{
+			Runs: runs, FontSize: td.FontSize, ScaleX: td.ScaleX,
+			X: td.X, Y: td.Y, Angle: td.Angle, Skew: td.Skew, Embolden: td.Embolden, Text: []rune(td.Text),
+		}
+	}
+	return out
+}
+
+// copyChars fills [dst], as returned by the target, with the recorded values
+func copyChars(dst, src *backend.FontChars) {
+	if dst.Cmap == nil {
+		dst.Cmap = make(map[backend.GID][]rune, len(src.Cmap))
+	}
+	for gid, runes := range src.Cmap {
+		dst.Cmap[gid] = runes
+	}
+	if dst.Extents == nil {
+		dst.Extents = make(map[backend.GID]backend.GlyphExtents, len(src.Extents))
+	}
+	for gid, ext := range src.Extents {
+		dst.Extents[gid] = ext
+	}
+	dst.Bbox = src.Bbox
+}
@@ -0,0 +1,403 @@
+package svgwriter
+
+import (
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+var (
+	_ backend.Canvas       = (*canvas)(nil)
+	_ backend.GraphicState = (*canvas)(nil)
+	_ backend.BlurCanvas   = (*canvas)(nil)
+)
+
+// paint is a fill or stroke color
+type paint struct {
+	color   parser.RGBA
+	alpha   Fl
+	pattern *pattern // optional, overrides color
+}
+
+type pattern struct {
+	content *canvas
+	mat     matrix.Transform // from pattern space to canvas space
+}
+
+// graphicState tracks the current settings, since SVG has no
+// notion of graphic state: the settings are written
+// on each drawn element.
+type graphicState struct {
+	ctm    matrix.Transform
+	clipID string // empty for no clipping
+	maskID string // empty for no mask
+	blend  string // empty for normal
+
+	fill, stroke paint
+
+	lineWidth  Fl
+	dashes     []Fl
+	dashOffset Fl
+	backend.StrokeOptions
+
+	textPaint backend.PaintOp
+}
+
+// canvas implements [backend.Canvas] by building the SVG elements.
+// The content of a group is written in its own coordinate system,
+// and wrapped in a <g> (or <mask>, <pattern>) element with the correct
+// transformation when used.
+type canvas struct {
+	res  *resources // shared by all the pages
+	defs *defs      // shared with the page and its groups
+	bbox [4]Fl      // left, top, right, bottom
+
+	content []*element
+
+	state graphicState
+	stack []graphicState
+
+	path strings.Builder // current path data, in user space
+}
+
+func newCanvas(res *resources, defs *defs, left, top, right, bottom Fl) *canvas {
+	return &canvas{
+		res:  res,
+		defs: defs,
+		bbox: [4]Fl{left, top, right, bottom},
+		state: graphicState{
+			ctm:       matrix.Identity(),
+			fill:      paint{color: parser.RGBA{A: 1}, alpha: 1},
+			stroke:    paint{color: parser.RGBA{A: 1}, alpha: 1},
+			lineWidth: 1,
+			textPaint: backend.FillNonZero,
+		},
+	}
+}
+
+// emit adds [el] to the content, applying the current
+// clip, mask and blending mode
+func (c *canvas) emit(el *element) {
+	st := c.state
+	if st.clipID == "" && st.maskID == "" && st.blend == "" {
+		c.content = append(c.content, el)
+		return
+	}
+	// these attributes are resolved in the canvas coordinate system, so
+	// they must not be set on [el], which has its own transform
+	g := newElement("g")
+	if st.clipID != "" {
+		g.set("clip-path", url(st.clipID))
+	}
+	if st.maskID != "" {
+		g.set("mask", url(st.maskID))
+	}
+	if st.blend != "" {
+		g.set("style", "mix-blend-mode:"+st.blend)
+	}
+	g.children = []*element{el}
+	c.content = append(c.content, g)
+}
+
+func (c *canvas) transformAttr() attr { return attr{"transform", fmtMatrix(c.state.ctm)} }
+
+// clipBox returns the id of a clip path restricting to the bounding box,
+// or an empty string for empty boxes
+func (c *canvas) clipBox(bbox [4]Fl) string {
+	left, top, right, bottom := bbox[0], bbox[1], bbox[2], bbox[3]
+	if right <= left || bottom <= top {
+		return ""
+	}
+	id := c.res.newID()
+	clip := newElement("clipPath", attr{"id", id}, attr{"clipPathUnits", "userSpaceOnUse"})
+	clip.children = []*element{newElement("rect",
+		attr{"x", fmtFl(left)},
+		attr{"y", fmtFl(top)},
+		attr{"width", fmtFl(right - left)},
+		attr{"height", fmtFl(bottom - top)},
+	)}
+	c.defs.add(clip)
+	return id
+}
+
+func (c *canvas) GetBoundingBox() (left, top, right, bottom Fl) {
+	return c.bbox[0], c.bbox[1], c.bbox[2], c.bbox[3]
+}
+
+func (c *canvas) SetBoundingBox(left, top, right, bottom Fl) {
+	c.bbox = [4]Fl{left, top, right, bottom}
+}
+
+func (c *canvas) OnNewStack(f func()) {
+	c.stack = append(c.stack, c.state)
+	f()
+	c.state = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+func (c *canvas) State() backend.GraphicState { return c }
+
+func (c *canvas) NewGroup(x, y, width, height Fl) backend.Canvas {
+	return newCanvas(c.res, c.defs, x, y, x+width, y+height)
+}
+
+func (c *canvas) DrawWithOpacity(opacity Fl, group backend.Canvas) {
+	g, ok := group.(*canvas)
+	if !ok {
+		return
+	}
+	el := newElement("g", c.transformAttr())
+	if opacity < 1 {
+		el.set("opacity", fmtFl(opacity))
+	}
+	if id := c.clipBox(g.bbox); id != "" {
+		el.set("clip-path", url(id))
+	}
+	el.children = g.content[:len(g.content):len(g.content)]
+	c.emit(el)
+}
+
+func (c *canvas) DrawWithBlur(stdDeviation Fl, group backend.Canvas) {
+	g, ok := group.(*canvas)
+	if !ok {
+		return
+	}
+	el := newElement("g", c.transformAttr())
+	if id := c.clipBox(g.bbox); id != "" {
+		el.set("clip-path", url(id))
+	}
+	// the filter region defaults to a margin of 10% around the content,
+	// which may be too small : use the bounding box of the group instead
+	left, top, right, bottom := g.bbox[0], g.bbox[1], g.bbox[2], g.bbox[3]
+	id := c.res.newID()
+	filter := newElement("filter", attr{"id", id}, attr{"filterUnits", "userSpaceOnUse"},
+		attr{"x", fmtFl(left)}, attr{"y", fmtFl(top)},
+		attr{"width", fmtFl(right - left)}, attr{"height", fmtFl(bottom - top)},
+	)
+	filter.children = []*element{newElement("feGaussianBlur", attr{"stdDeviation", fmtFl(stdDeviation)})}
+	c.defs.add(filter)
+	el.set("filter", url(id)) // applied before the clip path
+
+	el.children = g.content[:len(g.content):len(g.content)]
+	c.emit(el)
+}
+
+func (c *canvas) Paint(op backend.PaintOp) {
+	d := c.path.String()
+	c.path.Reset()
+	if d == "" || op == 0 {
+		return
+	}
+	el := newElement("path", attr{"d", d}, c.transformAttr())
+	c.setPaint(el, op)
+	c.emit(el)
+}
+
+// setPaint adds the fill and stroke attributes to [el]
+func (c *canvas) setPaint(el *element, op backend.PaintOp) {
+	if op&(backend.FillEvenOdd|backend.FillNonZero) != 0 {
+		el.set("fill", c.paintValue(c.state.fill))
+		if alpha := c.state.fill.alpha; alpha < 1 {
+			el.set("fill-opacity", fmtFl(alpha))
+		}
+		if op&backend.FillEvenOdd != 0 {
+			el.set("fill-rule", "evenodd")
+		}
+	} else {
+		el.set("fill", "none")
+	}
+
+	if op&backend.Stroke == 0 {
+		return
+	}
+	st := c.state
+	el.set("stroke", c.paintValue(st.stroke))
+	if st.stroke.alpha < 1 {
+		el.set("stroke-opacity", fmtFl(st.stroke.alpha))
+	}
+	if st.lineWidth != 1 {
+		el.set("stroke-width", fmtFl(st.lineWidth))
+	}
+	if len(st.dashes) != 0 {
+		chunks := make([]string, len(st.dashes))
+		for i, d := range st.dashes {
+			chunks[i] = fmtFl(d)
+		}
+		el.set("stroke-dasharray", strings.Join(chunks, " "))
+		if st.dashOffset != 0 {
+			el.set("stroke-dashoffset", fmtFl(st.dashOffset))
+		}
+	}
+	switch st.LineCap {
+	case backend.RoundCap:
+		el.set("stroke-linecap", "round")
+	case backend.SquareCap:
+		el.set("stroke-linecap", "square")
+	}
+	switch st.LineJoin {
+	case backend.Round:
+		el.set("stroke-linejoin", "round")
+	case backend.Bevel:
+		el.set("stroke-linejoin", "bevel")
+	}
+	if st.MiterLimit > 0 {
+		el.set("stroke-miterlimit", fmtFl(st.MiterLimit))
+	}
+}
+
+// paintValue returns the value of a fill or stroke attribute,
+// defining the pattern if needed.
+func (c *canvas) paintValue(p paint) string {
+	if p.pattern == nil {
+		return fmtColor(p.color)
+	}
+	content := p.pattern.content
+	left, top, right, bottom := content.GetBoundingBox()
+
+	// the pattern is resolved in the user space of the painted element,
+	// which is given by the current CTM
+	inv := c.state.ctm
+	if err := inv.Invert(); err != nil {
+		return "none"
+	}
+	id := c.res.newID()
+	el := newElement("pattern",
+		attr{"id", id},
+		attr{"patternUnits", "userSpaceOnUse"},
+		attr{"x", fmtFl(left)},
+		attr{"y", fmtFl(top)},
+		attr{"width", fmtFl(right - left)},
+		attr{"height", fmtFl(bottom - top)},
+		attr{"patternTransform", fmtMatrix(matrix.Mul(inv, p.pattern.mat))},
+	)
+	// the content origin is the top left corner of the tile
+	tile := newElement("g", attr{"transform", fmtMatrix(matrix.Translation(-left, -top))})
+	tile.children = content.content[:len(content.content):len(content.content)]
+	el.children = []*element{tile}
+	c.defs.add(el)
+	return url(id)
+}
+
+func (c *canvas) Rectangle(x, y, width, height Fl) {
+	c.path.WriteString("M" + fmtFl(x) + " " + fmtFl(y) +
+		"h" + fmtFl(width) + "v" + fmtFl(height) + "h" + fmtFl(-width) + "Z")
+}
+
+func (c *canvas) MoveTo(x, y Fl) {
+	c.path.WriteString("M" + fmtFl(x) + " " + fmtFl(y))
+}
+
+func (c *canvas) LineTo(x, y Fl) {
+	c.path.WriteString("L" + fmtFl(x) + " " + fmtFl(y))
+}
+
+func (c *canvas) CubicTo(x1, y1, x2, y2, x3, y3 Fl) {
+	c.path.WriteString("C" + fmtFl(x1) + " " + fmtFl(y1) + " " + fmtFl(x2) + " " + fmtFl(y2) +
+		" " + fmtFl(x3) + " " + fmtFl(y3))
+}
+
+func (c *canvas) ClosePath() { c.path.WriteString("Z") }
+
+func (c *canvas) AddFont(font backend.Font, content []byte) *backend.FontChars {
+	return c.res.addFont(font, content)
+}
+
+// GraphicState methods
+
+func (c *canvas) SetAlphaMask(mask backend.Canvas) {
+	m, ok := mask.(*canvas)
+	if !ok {
+		return
+	}
+	// the mask region is expressed in the canvas space
+	left, top, right, bottom := m.GetBoundingBox()
+	var xs, ys [4]Fl
+	xs[0], ys[0] = c.state.ctm.Apply(left, top)
+	xs[1], ys[1] = c.state.ctm.Apply(right, top)
+	xs[2], ys[2] = c.state.ctm.Apply(left, bottom)
+	xs[3], ys[3] = c.state.ctm.Apply(right, bottom)
+	minX, maxX := min(xs[0], xs[1], xs[2], xs[3]), max(xs[0], xs[1], xs[2], xs[3])
+	minY, maxY := min(ys[0], ys[1], ys[2], ys[3]), max(ys[0], ys[1], ys[2], ys[3])
+
+	id := c.res.newID()
+	el := newElement("mask",
+		attr{"id", id},
+		attr{"maskUnits", "userSpaceOnUse"},
+		attr{"x", fmtFl(minX)},
+		attr{"y", fmtFl(minY)},
+		attr{"width", fmtFl(maxX - minX)},
+		attr{"height", fmtFl(maxY - minY)},
+	)
+	content := newElement("g", c.transformAttr())
+	content.children = m.content[:len(m.content):len(m.content)]
+	el.children = []*element{content}
+	c.defs.add(el)
+	c.state.maskID = id
+}
+
+func (c *canvas) Clip(evenOdd bool) {
+	d := c.path.String()
+	c.path.Reset()
+
+	id := c.res.newID()
+	el := newElement("clipPath", attr{"id", id}, attr{"clipPathUnits", "userSpaceOnUse"})
+	if c.state.clipID != "" { // intersect with the current clip
+		el.set("clip-path", url(c.state.clipID))
+	}
+	path := newElement("path", attr{"d", d}, c.transformAttr())
+	if evenOdd {
+		path.set("clip-rule", "evenodd")
+	}
+	el.children = []*element{path}
+	c.defs.add(el)
+	c.state.clipID = id
+}
+
+func (c *canvas) paintState(stroke bool) *paint {
+	if stroke {
+		return &c.state.stroke
+	}
+	return &c.state.fill
+}
+
+func (c *canvas) SetAlpha(alpha Fl, stroke bool) { c.paintState(stroke).alpha = alpha }
+
+func (c *canvas) SetColorRgba(color parser.RGBA, stroke bool) {
+	*c.paintState(stroke) = paint{color: color, alpha: color.A}
+}
+
+// SetColor uses the RGB approximation of print colors, not supported by SVG.
+func (c *canvas) SetColor(color parser.Color, stroke bool) { c.SetColorRgba(color.RGBA, stroke) }
+
+func (c *canvas) SetColorPattern(pat backend.Canvas, contentWidth, contentHeight Fl, mat matrix.Transform, stroke bool) {
+	p, ok := pat.(*canvas)
+	if !ok {
+		return
+	}
+	ps := c.paintState(stroke)
+	ps.pattern = &pattern{content: p, mat: matrix.Mul(c.state.ctm, mat)}
+}
+
+func (c *canvas) SetBlendingMode(mode string) {
+	if mode == "normal" {
+		mode = ""
+	}
+	c.state.blend = mode
+}
+
+func (c *canvas) SetLineWidth(width Fl) { c.state.lineWidth = width }
+
+func (c *canvas) SetDash(dashes []Fl, offset Fl) {
+	c.state.dashes = append([]Fl(nil), dashes...)
+	c.state.dashOffset = offset
+}
+
+func (c *canvas) SetStrokeOptions(opts backend.StrokeOptions) { c.state.StrokeOptions = opts }
+
+func (c *canvas) GetTransform() matrix.Transform { return c.state.ctm }
+
+func (c *canvas) Transform(mt matrix.Transform) { c.state.ctm.RightMultBy(mt) }
+
+func (c *canvas) SetTextPaint(op backend.PaintOp) { c.state.textPaint = op }
@@ -0,0 +1,193 @@
+package svgwriter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/text"
+)
+
+func (c *canvas) DrawRasterImage(img backend.RasterImage, width, height Fl) {
+	href := c.res.imageURL(img)
+	if href == "" {
+		return
+	}
+	el := newElement("image",
+		attr{"width", fmtFl(width)},
+		attr{"height", fmtFl(height)},
+		attr{"preserveAspectRatio", "none"},
+		attr{"xlink:href", href},
+		c.transformAttr(),
+	)
+	if alpha := c.state.fill.alpha; alpha < 1 {
+		el.set("opacity", fmtFl(alpha))
+	}
+	if backend.IsPixelated(img.Rendering) {
+		el.set("style", "image-rendering:"+img.Rendering)
+	}
+	c.emit(el)
+}
+
+func (c *canvas) DrawGradient(gradient backend.GradientLayout, width, height Fl) {
+	if len(gradient.Colors) == 0 {
+		return
+	}
+	rect := newElement("rect",
+		attr{"width", fmtFl(width)},
+		attr{"height", fmtFl(height)},
+		c.transformAttr(),
+	)
+	if gradient.Kind == "solid" || len(gradient.Colors) == 1 {
+		color := gradient.Colors[0]
+		rect.set("fill", fmtColor(color))
+		if color.A < 1 {
+			rect.set("fill-opacity", fmtFl(color.A))
+		}
+		c.emit(rect)
+		return
+	}
+
+	id := c.res.newID()
+	var el *element
+	coords := gradient.Coords
+	if gradient.Kind == "linear" {
+		el = newElement("linearGradient",
+			attr{"id", id},
+			attr{"gradientUnits", "userSpaceOnUse"},
+			attr{"x1", fmtFl(coords[0])},
+			attr{"y1", fmtFl(coords[1])},
+			attr{"x2", fmtFl(coords[2])},
+			attr{"y2", fmtFl(coords[3])},
+		)
+	} else {
+		el = newElement("radialGradient",
+			attr{"id", id},
+			attr{"gradientUnits", "userSpaceOnUse"},
+			attr{"fx", fmtFl(coords[0])},
+			attr{"fy", fmtFl(coords[1])},
+			attr{"fr", fmtFl(coords[2])},
+			attr{"cx", fmtFl(coords[3])},
+			attr{"cy", fmtFl(coords[4])},
+			attr{"r", fmtFl(coords[5])},
+		)
+		if gradient.ScaleY != 1 && gradient.ScaleY != 0 {
+			el.set("gradientTransform", fmtMatrix(matrix.Scaling(1, gradient.ScaleY)))
+		}
+	}
+
+	// the coordinates are defined for the first and last positions
+	first, last := gradient.Positions[0], gradient.Positions[len(gradient.Positions)-1]
+	for i, color := range gradient.Colors {
+		var offset Fl
+		if last != first {
+			offset = (gradient.Positions[i] - first) / (last - first)
+		}
+		stop := newElement("stop",
+			attr{"offset", fmtFl(offset)},
+			attr{"stop-color", fmtColor(color)},
+		)
+		if color.A < 1 {
+			stop.set("stop-opacity", fmtFl(color.A))
+		}
+		el.children = append(el.children, stop)
+	}
+	c.defs.add(el)
+
+	rect.set("fill", url(id))
+	c.emit(rect)
+}
+
+// DrawText writes one <text> element per run, positionning each glyph
+// cluster with a <tspan>, so that the layout does not depend on the font
+// used by the SVG renderer.
+func (c *canvas) DrawText(texts []backend.TextDrawing) {
+	for _, td := range texts {
+		// text space, with y pointing down as expected by SVG
+		mat := matrix.Mul3(c.state.ctm, td.Matrix(), matrix.Scaling(1, -1))
+		var pen Fl // in 1/1000 of font size
+		for _, run := range td.Runs {
+			el := newElement("text",
+				attr{"transform", fmtMatrix(mat)},
+				attr{"xml:space", "preserve"},
+				attr{"font-size", fmtFl(td.FontSize)},
+			)
+			c.setFont(el, run.Font)
+			c.setPaint(el, c.state.textPaint)
+
+			face := c.res.faces.Face(run.Font)
+			chars := c.res.fontChars[run.Font]
+			for _, glyph := range run.Glyphs {
+				x := (pen + glyph.Offset) / 1000 * td.FontSize
+				y := glyph.Rise / 1000
+				pen += chars.GlyphWidth(face, glyph.Glyph) + glyph.Offset - Fl(glyph.Kerning)
+
+				// the other glyphs of a cluster have an empty text
+				end := min(glyph.TextOffset+glyph.TextLength, len(td.Text))
+				if glyph.TextLength <= 0 || glyph.TextOffset >= end {
+					continue
+				}
+				span := &element{
+					name:  "tspan",
+					attrs: []attr{{"x", fmtFl(x)}, {"y", fmtFl(y)}},
+					text:  string(td.Text[glyph.TextOffset:end]),
+				}
+				if glyph.Hidden {
+					span.set("visibility", "hidden")
+				}
+				el.children = append(el.children, span)
+			}
+			if len(el.children) != 0 {
+				c.emit(el)
+			}
+		}
+	}
+}
+
+// setFont adds the font attributes, registering the font
+// as used by the page.
+func (c *canvas) setFont(el *element, f backend.Font) {
+	c.defs.useFont(f)
+	desc := f.Description()
+	el.set("font-family", fmt.Sprintf("'%s'", desc.Family))
+	if weight := fontWeight(desc); weight != 400 {
+		el.set("font-weight", strconv.Itoa(weight))
+	}
+	if style := fontStyle(desc); style != "normal" {
+		el.set("font-style", style)
+	}
+	if settings := fontVariationSettings(desc); settings != "" {
+		el.set("style", "font-variation-settings:"+settings)
+	}
+}
+
+// fontVariationSettings returns the CSS value selecting
+// the instance of a variable font, or an empty string.
+func fontVariationSettings(desc backend.FontDescription) string {
+	vs := text.ParseVariations(desc.Variations)
+	chunks := make([]string, len(vs))
+	for i, v := range vs {
+		chunks[i] = fmt.Sprintf("'%s' %s", v.Tag[:], fmtFl(v.Value))
+	}
+	return strings.Join(chunks, ",")
+}
+
+func fontWeight(desc backend.FontDescription) int {
+	if desc.Weight == 0 {
+		return 400
+	}
+	return desc.Weight
+}
+
+func fontStyle(desc backend.FontDescription) string {
+	switch desc.Style {
+	case text.FSyItalic:
+		return "italic"
+	case text.FSyOblique:
+		return "oblique"
+	default:
+		return "normal"
+	}
+}
@@ -0,0 +1,393 @@
+// Package svgwriter implements a [backend.Document] producing
+// one standalone SVG file per page.
+//
+// As in PDF, the page coordinate system has its origin at the bottom-left corner,
+// and its y axis pointing up. document.Document.Write takes care of
+// this convention; when drawing y-down content (like an svg.SVGImage) directly,
+// first apply the transformation matrix.New(1, 0, 0, -1, 0, height).
+//
+// Links are written as <a> elements, and anchors as empty elements whose
+// id is the anchor name. Bookmarks and attachments have no SVG equivalent and
+// are ignored.
+package svgwriter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/utils"
+
+	drawText "github.com/benoitkugler/webrender/text/draw"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+type Fl = utils.Fl
+
+var (
+	_ backend.Document = (*Document)(nil)
+	_ backend.Page     = (*Page)(nil)
+)
+
+// Document implements [backend.Document], storing the pages
+// to be written.
+type Document struct {
+	Pages []*Page
+
+	// Unit is the CSS unit used for the width and height of the
+	// SVG files. It defaults to "pt", matching the unit used by
+	// document.Document.Write.
+	Unit string
+
+	// EmbedFonts controls whether the font files used by each page
+	// are included in the SVG file. If false, the rendering depends
+	// on the fonts installed on the system.
+	EmbedFonts bool
+
+	// PageURL, if not nil, returns the URL of the SVG file for the given (0-based) page,
+	// and is used to resolve internal links pointing to another page.
+	// If nil, such links are ignored.
+	PageURL func(pageIndex int) string
+
+	title, description string
+	anchors            map[string]int // anchor name -> page index
+
+	res *resources
+}
+
+// NewDocument returns an empty document.
+func NewDocument() *Document {
+	return &Document{Unit: "pt", res: newResources()}
+}
+
+// AddPage creates a new page, whose media box is initially
+// the given rectangle.
+func (d *Document) AddPage(left, top, right, bottom Fl) backend.Page {
+	page := &Page{canvas: newCanvas(d.res, &defs{}, left, top, right, bottom), doc: d, index: len(d.Pages)}
+	page.mediaBox = [4]Fl{left, top, right, bottom}
+	d.Pages = append(d.Pages, page)
+	return page
+}
+
+func (d *Document) CreateAnchors(anchors [][]backend.Anchor) {
+	d.anchors = make(map[string]int)
+	for i, pageAnchors := range anchors {
+		if i >= len(d.Pages) {
+			break
+		}
+		for _, anchor := range pageAnchors {
+			d.anchors[anchor.Name] = i
+		}
+		d.Pages[i].anchors = pageAnchors
+	}
+}
+
+func (d *Document) SetTitle(title string)             { d.title = title }
+func (d *Document) SetDescription(description string) { d.description = description }
+
+func (d *Document) SetAttachments(as []backend.Attachment)        {}
+func (d *Document) EmbedFile(fileID string, a backend.Attachment) {}
+func (d *Document) SetCreator(creator string)                     {}
+func (d *Document) SetAuthors(authors []string)                   {}
+func (d *Document) SetKeywords(keywords []string)                 {}
+func (d *Document) SetProducer(producer string)                   {}
+func (d *Document) SetDateCreation(date time.Time)                {}
+func (d *Document) SetDateModification(date time.Time)            {}
+func (d *Document) SetBookmarks(root []backend.BookmarkNode)      {}
+func (d *Document) SetPageLabels(labels []backend.PageLabel)      {}
+
+// Page implements [backend.Page].
+type Page struct {
+	*canvas
+
+	doc   *Document
+	index int
+
+	mediaBox [4]Fl // left, top, right, bottom
+	links    []link
+	anchors  []backend.Anchor
+}
+
+func (p *Page) AddInternalLink(xMin, yMin, xMax, yMax Fl, anchorName string) {
+	p.links = append(p.links, link{rect: [4]Fl{xMin, yMin, xMax, yMax}, anchor: anchorName})
+}
+
+func (p *Page) AddExternalLink(xMin, yMin, xMax, yMax Fl, url string) {
+	p.links = append(p.links, link{rect: [4]Fl{xMin, yMin, xMax, yMax}, url: url})
+}
+
+func (p *Page) AddFileAnnotation(xMin, yMin, xMax, yMax Fl, fileID string) {}
+
+func (p *Page) AddFormField(xMin, yMin, xMax, yMax Fl, field backend.FormField) {}
+
+func (p *Page) SetMediaBox(left, top, right, bottom Fl) {
+	p.mediaBox = [4]Fl{left, top, right, bottom}
+}
+
+func (p *Page) SetTrimBox(left, top, right, bottom Fl)  {}
+func (p *Page) SetBleedBox(left, top, right, bottom Fl) {}
+
+func (p *Page) BeginStructure(element backend.StructureElement) {}
+func (p *Page) EndStructure()                                   {}
+
+// link is either internal (with a non empty anchor)
+// or external
+type link struct {
+	rect   [4]Fl // xMin, yMin, xMax, yMax
+	url    string
+	anchor string
+}
+
+// href returns an empty string for links which can't be resolved.
+// Internal links are resolved when writing, since the anchors are only
+// known after the pages are drawn.
+func (p *Page) href(l link) string {
+	if l.anchor == "" {
+		return l.url
+	}
+	index, ok := p.doc.anchors[l.anchor]
+	if !ok {
+		return ""
+	}
+	if index == p.index {
+		return "#" + l.anchor
+	}
+	if p.doc.PageURL == nil {
+		return ""
+	}
+	return p.doc.PageURL(index) + "#" + l.anchor
+}
+
+// element returns a transparent rectangle wrapped in an <a> element.
+func (l link) element(href string) *element {
+	xMin, yMin, xMax, yMax := l.rect[0], l.rect[1], l.rect[2], l.rect[3]
+	a := newElement("a", attr{"xlink:href", href})
+	a.children = []*element{newElement("rect",
+		attr{"x", fmtFl(min(xMin, xMax))},
+		attr{"y", fmtFl(min(yMin, yMax))},
+		attr{"width", fmtFl(max(xMin, xMax) - min(xMin, xMax))},
+		attr{"height", fmtFl(max(yMin, yMax) - min(yMin, yMax))},
+		attr{"fill-opacity", "0"},
+	)}
+	return a
+}
+
+// WriteSVG writes the page as a standalone SVG file.
+func (p *Page) WriteSVG(w io.Writer) error {
+	left, top, right, bottom := p.mediaBox[0], p.mediaBox[1], p.mediaBox[2], p.mediaBox[3]
+	width, height := right-left, bottom-top
+	unit := p.doc.Unit
+
+	root := newElement("svg",
+		attr{"xmlns", "http://www.w3.org/2000/svg"},
+		attr{"xmlns:xlink", "http://www.w3.org/1999/xlink"},
+		attr{"version", "1.1"},
+		attr{"width", fmtFl(width) + unit},
+		attr{"height", fmtFl(height) + unit},
+		attr{"viewBox", fmt.Sprintf("0 0 %s %s", fmtFl(width), fmtFl(height))},
+	)
+	if p.doc.title != "" {
+		root.children = append(root.children, &element{name: "title", text: p.doc.title})
+	}
+	if p.doc.description != "" {
+		root.children = append(root.children, &element{name: "desc", text: p.doc.description})
+	}
+
+	if defs := p.defsElement(); defs != nil {
+		root.children = append(root.children, defs)
+	}
+
+	// the first row is at y = bottom
+	body := newElement("g", attr{"transform", fmt.Sprintf("matrix(1 0 0 -1 %s %s)", fmtFl(-left), fmtFl(bottom))})
+	body.children = append(body.children, p.canvas.content...)
+	for _, anchor := range p.anchors {
+		body.children = append(body.children, newElement("rect",
+			attr{"id", anchor.Name},
+			attr{"x", fmtFl(anchor.X)},
+			attr{"y", fmtFl(anchor.Y)},
+			attr{"width", "0"},
+			attr{"height", "0"},
+			attr{"fill", "none"},
+		))
+	}
+	for _, link := range p.links {
+		if href := p.href(link); href != "" {
+			body.children = append(body.children, link.element(href))
+		}
+	}
+	root.children = append(root.children, body)
+
+	buf := bufio.NewWriter(w)
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	root.write(buf)
+	buf.WriteByte('\n')
+	return buf.Flush()
+}
+
+// defsElement returns nil if the page has no definitions
+func (p *Page) defsElement() *element {
+	if len(p.defs.elements) == 0 && len(p.defs.fonts) == 0 {
+		return nil
+	}
+	out := newElement("defs")
+	if p.doc.EmbedFonts {
+		if style := p.res.fontFaces(p.defs.fonts); style != "" {
+			out.children = append(out.children, &element{name: "style", text: style})
+		}
+	}
+	out.children = append(out.children, p.defs.elements...)
+	return out
+}
+
+// defs stores the resources referenced by a page
+// (and all the groups created from it).
+type defs struct {
+	elements []*element
+	fonts    []backend.Font // used fonts, in order
+}
+
+func (ds *defs) add(el *element) { ds.elements = append(ds.elements, el) }
+
+func (ds *defs) useFont(font backend.Font) {
+	for _, f := range ds.fonts {
+		if f == font {
+			return
+		}
+	}
+	ds.fonts = append(ds.fonts, font)
+}
+
+// resources are shared by all the pages and groups of a document.
+type resources struct {
+	faces     drawText.Faces
+	fontChars map[backend.Font]*backend.FontChars
+	fonts     map[backend.Font][]byte // font file content
+	images    map[int]string          // data URL, by RasterImage.ID
+
+	lastID int // used to generate unique ids
+}
+
+func newResources() *resources {
+	return &resources{
+		faces:     make(drawText.Faces),
+		fontChars: make(map[backend.Font]*backend.FontChars),
+		fonts:     make(map[backend.Font][]byte),
+		images:    make(map[int]string),
+	}
+}
+
+// newID returns a new unique element id
+func (res *resources) newID() string {
+	res.lastID++
+	return fmt.Sprintf("wr-%d", res.lastID)
+}
+
+func (res *resources) addFont(font backend.Font, content []byte) *backend.FontChars {
+	if chars, has := res.fontChars[font]; has {
+		return chars
+	}
+	res.faces.Load(font, content)
+	res.fonts[font] = content
+	chars := &backend.FontChars{
+		Cmap:    make(map[backend.GID][]rune),
+		Extents: make(map[backend.GID]backend.GlyphExtents),
+	}
+	res.fontChars[font] = chars
+	return chars
+}
+
+// fontFaces returns the @font-face rules embedding [fonts].
+// The rules use the family, weight and style of the fonts, so that
+// they are selected by the text elements.
+// Faces from font collections are extracted, since browsers only
+// load the first face of a collection.
+func (res *resources) fontFaces(fonts []backend.Font) string {
+	type ruleKey struct {
+		origin text.FontOrigin
+		family string
+		weight int
+		style  string
+	}
+	var (
+		out   strings.Builder
+		rules = make(map[ruleKey]bool) // instances of a variable font share their rule
+	)
+	for _, font := range fonts {
+		content := res.fonts[font]
+		if len(content) == 0 {
+			continue
+		}
+		desc := font.Description()
+		key := ruleKey{font.Origin(), desc.Family, fontWeight(desc), fontStyle(desc)}
+		if rules[key] {
+			continue
+		}
+		rules[key] = true
+		if bytes.HasPrefix(content, []byte("ttcf")) {
+			var err error
+			content, err = extractFace(content, font.Origin().Index)
+			if err != nil {
+				logger.WarningLogger.Printf("can't embed font %s: %s", font.Origin().File, err)
+				continue
+			}
+		}
+		mime := "font/ttf"
+		if desc.IsOpentypeOpentype {
+			mime = "font/otf"
+		}
+		fmt.Fprintf(&out, "@font-face { font-family: %q; font-weight: %d; font-style: %s; src: url(data:%s;base64,%s); }\n",
+			desc.Family, fontWeight(desc), fontStyle(desc), mime, base64.StdEncoding.EncodeToString(content))
+	}
+	return out.String()
+}
+
+// extractFace returns a standalone font file for the face
+// at [index] in the font collection [content].
+func extractFace(content []byte, index uint16) ([]byte, error) {
+	loaders, err := ot.NewLoaders(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if int(index) >= len(loaders) {
+		return nil, fmt.Errorf("invalid index %d in font collection", index)
+	}
+	ld := loaders[index]
+	var tables []ot.Table
+	for _, tag := range ld.Tables() {
+		table, err := ld.RawTable(tag)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, ot.Table{Tag: tag, Content: table})
+	}
+	return ot.WriteTTF(tables), nil
+}
+
+// imageURL returns an empty string (after logging) if the image content
+// can't be read
+func (res *resources) imageURL(img backend.RasterImage) string {
+	if url, has := res.images[img.ID]; has && img.ID != 0 {
+		return url
+	}
+	if seeker, ok := img.Content.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+	var url string
+	content, err := io.ReadAll(img.Content)
+	if err != nil {
+		logger.WarningLogger.Printf("invalid raster image (%s): %s", img.MimeType, err)
+	} else {
+		url = "data:" + img.MimeType + ";base64," + base64.StdEncoding.EncodeToString(content)
+	}
+	if img.ID != 0 {
+		res.images[img.ID] = url
+	}
+	return url
+}
@@ -0,0 +1,263 @@
+package svgwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/raster"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/svg"
+	"github.com/benoitkugler/webrender/utils/testutils/htmltest"
+	"github.com/go-text/typesetting/font"
+)
+
+var (
+	red  = parser.RGBA{R: 1, A: 1}
+	blue = parser.RGBA{B: 1, A: 1}
+)
+
+// newTestPage returns a page of 100x100 units, with a y-down
+// coordinate system
+func newTestPage() (*Document, backend.Page) {
+	doc := NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	page.State().Transform(matrix.New(1, 0, 0, -1, 0, 100))
+	return doc, page
+}
+
+func writeSVG(t *testing.T, page *Page) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := page.WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// check the output is well formed
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid XML: %s\n%s", err, buf.String())
+		}
+	}
+	return buf.String()
+}
+
+// render rasterizes the SVG output, using the svg and raster packages
+func render(t *testing.T, page *Page) *image.RGBA {
+	t.Helper()
+	img, err := svg.Parse(strings.NewReader(writeSVG(t, page)), "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := raster.NewDocument(1)
+	out := doc.AddPage(0, 0, 100, 100)
+	out.State().Transform(matrix.New(1, 0, 0, -1, 0, 100))
+	img.Draw(out, 100, 100, nil)
+	return doc.Images()[0]
+}
+
+func assertColor(t *testing.T, img *image.RGBA, x, y int, expected color.RGBA) {
+	t.Helper()
+	got := img.RGBAAt(x, y)
+	diff := func(a, b uint8) bool { return int(a) > int(b)+5 || int(b) > int(a)+5 }
+	if diff(got.R, expected.R) || diff(got.G, expected.G) || diff(got.B, expected.B) || diff(got.A, expected.A) {
+		t.Fatalf("pixel (%d, %d): expected %v, got %v", x, y, expected, got)
+	}
+}
+
+var (
+	opaqueRed   = color.RGBA{255, 0, 0, 255}
+	opaqueBlue  = color.RGBA{0, 0, 255, 255}
+	transparent = color.RGBA{}
+)
+
+func TestFill(t *testing.T) {
+	doc, page := newTestPage()
+	page.State().SetColorRgba(red, false)
+	page.Rectangle(10, 20, 30, 40)
+	page.Paint(backend.FillNonZero)
+
+	img := render(t, doc.Pages[0])
+	assertColor(t, img, 11, 21, opaqueRed)
+	assertColor(t, img, 38, 58, opaqueRed)
+	assertColor(t, img, 8, 20, transparent)
+	assertColor(t, img, 41, 61, transparent)
+}
+
+func TestClip(t *testing.T) {
+	doc, page := newTestPage()
+	page.OnNewStack(func() {
+		page.Rectangle(0, 0, 50, 100)
+		page.State().Clip(false)
+		page.State().SetColorRgba(red, false)
+		page.Rectangle(0, 0, 100, 100)
+		page.Paint(backend.FillNonZero)
+	})
+	// the clip is restored
+	page.State().SetColorRgba(blue, false)
+	page.Rectangle(90, 90, 10, 10)
+	page.Paint(backend.FillNonZero)
+
+	img := render(t, doc.Pages[0])
+	assertColor(t, img, 25, 50, opaqueRed)
+	assertColor(t, img, 75, 50, transparent)
+	assertColor(t, img, 95, 95, opaqueBlue)
+}
+
+func TestGroupOpacity(t *testing.T) {
+	doc, page := newTestPage()
+	group := page.NewGroup(0, 0, 50, 50)
+	group.State().SetColorRgba(red, false)
+	group.Rectangle(0, 0, 100, 100)
+	group.Paint(backend.FillNonZero)
+	page.DrawWithOpacity(0.5, group)
+
+	img := render(t, doc.Pages[0])
+	assertColor(t, img, 25, 25, color.RGBA{128, 0, 0, 128})
+	assertColor(t, img, 75, 75, transparent) // outside the group bbox
+}
+
+func TestGroupBlur(t *testing.T) {
+	doc, page := newTestPage()
+	group := page.NewGroup(0, 0, 50, 50)
+	group.State().SetColorRgba(red, false)
+	group.Rectangle(10, 10, 30, 30)
+	group.Paint(backend.FillNonZero)
+	page.(backend.BlurCanvas).DrawWithBlur(2, group)
+
+	out := writeSVG(t, doc.Pages[0])
+	if !strings.Contains(out, `<feGaussianBlur stdDeviation="2"`) ||
+		!strings.Contains(out, `filterUnits="userSpaceOnUse" x="0" y="0" width="50" height="50"`) {
+		t.Fatalf("missing blur filter in %s", out)
+	}
+}
+
+func TestLinearGradient(t *testing.T) {
+	doc, page := newTestPage()
+	page.DrawGradient(backend.GradientLayout{
+		Positions:    []Fl{0, 1},
+		Colors:       []parser.RGBA{red, blue},
+		GradientKind: backend.GradientKind{Kind: "linear", Coords: [6]Fl{0, 0, 100, 0}},
+		ScaleY:       1,
+	}, 100, 100)
+
+	img := render(t, doc.Pages[0])
+	assertColor(t, img, 0, 50, color.RGBA{254, 0, 1, 255})
+	assertColor(t, img, 99, 50, color.RGBA{1, 0, 254, 255})
+}
+
+func TestRasterImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(0, 0, opaqueRed)
+	src.SetRGBA(1, 0, opaqueBlue)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, page := newTestPage()
+	page.DrawRasterImage(backend.RasterImage{Content: &buf, MimeType: "image/png", Rendering: "pixelated", ID: 1}, 100, 50)
+
+	out := writeSVG(t, doc.Pages[0])
+	if !strings.Contains(out, `xlink:href="data:image/png;base64,`) {
+		t.Fatalf("missing image data:\n%s", out)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	const input = `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100">
+		<rect x="0" y="0" width="50" height="50" fill="red" />
+		<circle cx="75" cy="75" r="20" fill="blue" opacity="0.5" />
+	</svg>`
+	img, err := svg.Parse(strings.NewReader(input), "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, page := newTestPage()
+	img.Draw(page, 100, 100, nil)
+
+	out := render(t, doc.Pages[0])
+	assertColor(t, out, 25, 25, opaqueRed)
+	assertColor(t, out, 75, 75, color.RGBA{0, 0, 128, 128})
+	assertColor(t, out, 75, 25, transparent)
+}
+
+func TestDocument(t *testing.T) {
+	doc := htmltest.Document(t, `
+	<title>My title</title>
+	<style>@page { size: 200px 100px; margin: 0 } body { margin: 0 }</style>
+	<div style="background: red; height: 50px"></div>
+	<p style="margin: 0"><a href="#target">Internal</a> <a id="target" href="https://example.com">External</a></p>`)
+
+	output := NewDocument()
+	output.EmbedFonts = true
+	doc.Write(output, 1, nil)
+	if len(output.Pages) != 1 {
+		t.Fatalf("expected one page, got %d", len(output.Pages))
+	}
+	out := writeSVG(t, output.Pages[0])
+	for _, expected := range []string{
+		`width="150pt" height="75pt" viewBox="0 0 150 75"`,
+		`<title>My title</title>`,
+		`<tspan`,
+		`xlink:href="#target"`,
+		`xlink:href="https://example.com"`,
+		`id="target"`,
+		`@font-face { font-family: "DejaVu Serif"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %s in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestExtractFace(t *testing.T) {
+	ahem, err := os.ReadFile("../../resources_test/AHEM____.TTF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a collection with two copies of the same face
+	collection := []byte("ttcf\x00\x01\x00\x00\x00\x00\x00\x02\x00\x00\x00\x14\x00\x00\x00\x14")
+	collection = append(collection, ahem...)
+	numTables := int(binary.BigEndian.Uint16(ahem[4:]))
+	for i := 0; i < numTables; i++ { // table offsets are relative to the collection
+		record := collection[20+12+16*i+8:]
+		binary.BigEndian.PutUint32(record, binary.BigEndian.Uint32(record)+20)
+	}
+
+	face, err := extractFace(collection, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(face, []byte("ttcf")) {
+		t.Fatal("expected a standalone font")
+	}
+	if _, err = font.ParseTTF(bytes.NewReader(face)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = extractFace(collection, 2); err == nil {
+		t.Fatal("expected error for invalid index")
+	}
+}
+
+func TestFontVariationSettings(t *testing.T) {
+	if s := fontVariationSettings(backend.FontDescription{}); s != "" {
+		t.Fatalf("unexpected settings %q", s)
+	}
+	if s := fontVariationSettings(backend.FontDescription{Variations: "wght=700,wdth=87.5"}); s != "'wght' 700,'wdth' 87.5" {
+		t.Fatalf("unexpected settings %q", s)
+	}
+}
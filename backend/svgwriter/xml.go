@@ -0,0 +1,88 @@
+package svgwriter
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/matrix"
+)
+
+// element is a node of the SVG tree being built
+type element struct {
+	name     string
+	attrs    []attr
+	text     string // character data, escaped when written
+	children []*element
+}
+
+type attr struct{ key, value string }
+
+func newElement(name string, attrs ...attr) *element {
+	return &element{name: name, attrs: attrs}
+}
+
+func (e *element) set(key, value string) { e.attrs = append(e.attrs, attr{key, value}) }
+
+// write serializes the element. Whitespaces are only
+// added between children of container elements, since they
+// would be significant in text elements.
+func (e *element) write(w *bufio.Writer) {
+	w.WriteByte('<')
+	w.WriteString(e.name)
+	for _, a := range e.attrs {
+		w.WriteByte(' ')
+		w.WriteString(a.key)
+		w.WriteString(`="`)
+		xml.EscapeText(w, []byte(a.value))
+		w.WriteByte('"')
+	}
+	if e.text == "" && len(e.children) == 0 {
+		w.WriteString("/>")
+		return
+	}
+	w.WriteByte('>')
+	textEscaper.WriteString(w, e.text)
+	isText := e.name == "text" || e.name == "tspan"
+	for _, child := range e.children {
+		if !isText {
+			w.WriteByte('\n')
+		}
+		child.write(w)
+	}
+	if len(e.children) != 0 && !isText {
+		w.WriteByte('\n')
+	}
+	w.WriteString("</")
+	w.WriteString(e.name)
+	w.WriteByte('>')
+}
+
+// quotes are valid in character data
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func fmtFl(v Fl) string { return strconv.FormatFloat(float64(v), 'f', -1, 32) }
+
+func fmtMatrix(mt matrix.Transform) string {
+	return fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+		fmtFl(mt.A), fmtFl(mt.B), fmtFl(mt.C), fmtFl(mt.D), fmtFl(mt.E), fmtFl(mt.F))
+}
+
+// fmtColor returns the color, ignoring its alpha channel
+func fmtColor(c parser.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", to8bit(c.R), to8bit(c.G), to8bit(c.B))
+}
+
+func to8bit(v Fl) uint8 {
+	if v <= 0 {
+		return 0
+	} else if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
+
+func url(id string) string { return "url(#" + id + ")" }
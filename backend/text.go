@@ -3,6 +3,7 @@ package backend
 import (
 	"github.com/benoitkugler/webrender/matrix"
 	"github.com/benoitkugler/webrender/text"
+	"github.com/go-text/typesetting/font"
 )
 
 // TextDrawing exposes the positionned text glyphs to draw
@@ -14,16 +15,31 @@ type TextDrawing struct {
 	X, Y             Fl // origin
 	Angle            Fl // (optional) rotation
 
+	// Skew is the (optional) horizontal shear of the glyphs,
+	// used to synthesize an oblique font.
+	Skew Fl
+
+	// Embolden is the (optional) width of the stroke which should
+	// be added to the glyph outlines to synthesize a bold font,
+	// in the same unit as [FontSize].
+	// Canvas implementations do not apply it : it is the responsability
+	// of the caller of [Canvas.DrawText] to setup the stroke.
+	Embolden Fl
+
 	Text []rune
 }
 
 // Matrix return the transformation scaling the text by [FontSize],
 // translating if to (X, Y)  and applying the [Angle] rotation
+// and the [Skew] shear
 func (td TextDrawing) Matrix() matrix.Transform {
 	mat := matrix.New(td.ScaleX, 0, 0, -1, td.X, td.Y)
 	if td.Angle != 0 { // avoid useless multiplication if angle == 0
 		mat.RightMultBy(matrix.Rotation(td.Angle))
 	}
+	if td.Skew != 0 {
+		mat.RightMultBy(matrix.New(1, 0, td.Skew, 1, 0, 0))
+	}
 	return mat
 }
 
@@ -43,6 +59,17 @@ type TextGlyph struct {
 	Rise     Fl
 	XAdvance Fl // how much to move before drawing, used for emojis
 
+	// Hidden is true for glyphs already painted on the output,
+	// like color glyphs. They should not be shown by [Canvas.DrawText],
+	// but are kept for text extraction.
+	Hidden bool
+
+	// Upright is true for the glyphs of vertical text which are displayed
+	// upright. Their [Offset] and [Rise] are given in the frame of the glyph,
+	// relative to the pen position on the central baseline, and they must be
+	// painted separately, rotated back from the logical frame of the line.
+	Upright bool
+
 	// TextDrawing.Text[TextOffset:TextOffset+TextLength]
 	// gives the runes yielding the glyph.
 	TextOffset, TextLength int
@@ -78,6 +105,21 @@ func (f *FontChars) IsFixedPitch() bool {
 	return true
 }
 
+// GlyphWidth returns the advance of [gid], in 1/1000 of font size.
+// The extents stored in [f] are used if present, falling back to
+// the metrics of [face], which may be nil.
+func (f *FontChars) GlyphWidth(face *font.Face, gid GID) Fl {
+	if f != nil {
+		if ext, ok := f.Extents[gid]; ok {
+			return Fl(ext.Width)
+		}
+	}
+	if face == nil || gid == GID(font.EmptyGlyph) {
+		return 0
+	}
+	return face.HorizontalAdvance(font.GID(gid)) * 1000 / Fl(face.Upem())
+}
+
 type FontDescription struct {
 	Family string
 	Style  text.FontStyle
@@ -88,6 +130,11 @@ type FontDescription struct {
 
 	Size int // the font size used with this font
 
+	// Variations are the design coordinates of a variable font,
+	// as formatted by [text.FormatVariations], like "wght=700,wdth=100".
+	// It is empty for the default instance.
+	Variations string
+
 	IsOpentype bool
 	// IsOpentype is true for an OpenType file containing a PostScript Type 2 font
 	IsOpentypeOpentype bool
@@ -259,11 +259,41 @@ const (
 	ColorCurrentColor
 	// ColorRGBA is a standard rgba color.
 	ColorRGBA
+	// ColorSpot is a reference to a spot color, whose alternate
+	// depends on the document and is resolved with [SpotColors.Resolve].
+	// Only [Color.Device.Spot] and [Color.Device.Tint] are set.
+	ColorSpot
 )
 
 type Color struct {
 	Type ColorType
+	// RGBA is the color value, or, for colors in
+	// a print color space, its RGB approximation.
 	RGBA RGBA
+	// Device is only used for colors in a print color space,
+	// and is the zero value for RGB colors.
+	Device DeviceColor
+}
+
+// ColorSpace is the color space in which a color is painted.
+type ColorSpace uint8
+
+const (
+	SpaceRGB        ColorSpace = iota // the default color space
+	SpaceCMYK                         // the device CMYK color space
+	SpaceSeparation                   // a spot color, with a CMYK alternate
+)
+
+// DeviceColor is a color defined in a print color space.
+type DeviceColor struct {
+	Space ColorSpace
+	// CMYK are the components, in [0, 1], of CMYK colors.
+	// For spot colors, they are the alternate values at full tint.
+	CMYK [4]utils.Fl
+	// Spot is the name of a spot color.
+	Spot string
+	// Tint is the tint of a spot color, in [0, 1]
+	Tint utils.Fl
 }
 
 func (c Color) IsNone() bool {
@@ -296,6 +326,11 @@ func ParseColorString(color string) Color {
 //   - RGBA color for every other values (including keywords, HSL && HSLA.)
 //     The alpha channel is clipped to [0, 1] but red, green, or blue can be out of range
 //     (eg. “rgb(-10%, 120%, 0%)“ is represented as “(-0.1, 1.2, 0, 1)“.
+//
+// The device-cmyk() function is also supported : the returned color has then a
+// [DeviceColor] and an RGB fallback.
+// The spot() function returns a [ColorSpot] reference, which must be resolved
+// against the spot colors declared by the document.
 func ParseColor(token Token) Color {
 	switch token := token.(type) {
 	case Ident:
@@ -311,6 +346,19 @@ func ParseColor(token Token) Color {
 			}
 		}
 	case FunctionBlock:
+		// print colors are not always comma separated
+		switch utils.AsciiLower(token.Name) {
+		case "device-cmyk":
+			if color, ok := parseDeviceCmyk(token.Arguments); ok {
+				return color
+			}
+			return Color{}
+		case "spot":
+			if color, ok := parseSpot(token.Arguments); ok {
+				return color
+			}
+			return Color{}
+		}
 		args := parseCommaSeparated(token.Arguments)
 		if len(args) != 0 {
 			switch utils.AsciiLower(token.Name) {
@@ -352,6 +400,229 @@ func ParseColor(token Token) Color {
 	return Color{}
 }
 
+// NewCMYKColor returns an opaque color in the CMYK space,
+// whose components are clamped to [0, 1].
+// The RGB fallback is computed with the naive conversion.
+func NewCMYKColor(c, m, y, k utils.Fl) Color {
+	cmyk := [4]utils.Fl{clamp(c), clamp(m), clamp(y), clamp(k)}
+	return Color{
+		Type:   ColorRGBA,
+		RGBA:   CMYKToRGB(cmyk, 1),
+		Device: DeviceColor{Space: SpaceCMYK, CMYK: cmyk},
+	}
+}
+
+// CMYKToRGB returns the naive RGB approximation of the
+// given CMYK components, ignoring any color profile.
+func CMYKToRGB(cmyk [4]utils.Fl, alpha utils.Fl) RGBA {
+	k := 1 - cmyk[3]
+	return RGBA{R: (1 - cmyk[0]) * k, G: (1 - cmyk[1]) * k, B: (1 - cmyk[2]) * k, A: alpha}
+}
+
+// RGBToCMYK is the inverse of [CMYKToRGB]
+func RGBToCMYK(color RGBA) [4]utils.Fl {
+	r, g, b := clamp(color.R), clamp(color.G), clamp(color.B)
+	max := utils.MaxF(r, utils.MaxF(g, b))
+	if max == 0 {
+		return [4]utils.Fl{0, 0, 0, 1}
+	}
+	return [4]utils.Fl{(max - r) / max, (max - g) / max, (max - b) / max, 1 - max}
+}
+
+// parseDeviceCmyk parses the arguments of a device-cmyk() function, either
+// with the modern syntax "c m y k [/ alpha] [, fallback]" or with the legacy one
+// "c, m, y, k [, alpha]". Components are numbers or percentages.
+func parseDeviceCmyk(arguments []Token) (Color, bool) {
+	var tokens []Token
+	for _, token := range arguments {
+		if token.Kind() != KWhitespace && token.Kind() != KComment {
+			tokens = append(tokens, token)
+		}
+	}
+	component := func(token Token) (utils.Fl, bool) {
+		switch token := token.(type) {
+		case Number:
+			return token.ValueF, true
+		case Percentage:
+			return token.ValueF / 100, true
+		}
+		return 0, false
+	}
+	isLiteral := func(token Token, value string) bool {
+		lit, ok := token.(Literal)
+		return ok && lit.Value == value
+	}
+
+	var (
+		cmyk     [4]utils.Fl
+		alpha    utils.Fl = 1
+		fallback Color
+	)
+	if args := parseCommaSeparated(tokens); len(args) == 4 || len(args) == 5 { // legacy syntax
+		for i := range cmyk {
+			v, ok := component(args[i])
+			if !ok {
+				return Color{}, false
+			}
+			cmyk[i] = v
+		}
+		if len(args) == 5 {
+			var ok bool
+			if alpha, ok = component(args[4]); !ok {
+				return Color{}, false
+			}
+		}
+	} else {
+		if len(tokens) < 4 {
+			return Color{}, false
+		}
+		for i := range cmyk {
+			v, ok := component(tokens[i])
+			if !ok {
+				return Color{}, false
+			}
+			cmyk[i] = v
+		}
+		tokens = tokens[4:]
+		if len(tokens) >= 2 && isLiteral(tokens[0], "/") {
+			var ok bool
+			if alpha, ok = component(tokens[1]); !ok {
+				return Color{}, false
+			}
+			tokens = tokens[2:]
+		}
+		if len(tokens) == 2 && isLiteral(tokens[0], ",") {
+			if fallback = ParseColor(tokens[1]); fallback.Type != ColorRGBA {
+				return Color{}, false
+			}
+			tokens = nil
+		}
+		if len(tokens) != 0 {
+			return Color{}, false
+		}
+	}
+
+	out := NewCMYKColor(cmyk[0], cmyk[1], cmyk[2], cmyk[3])
+	out.RGBA.A = clamp(alpha)
+	if fallback.Type == ColorRGBA {
+		out.RGBA = fallback.RGBA
+		out.RGBA.A *= clamp(alpha)
+	}
+	return out, true
+}
+
+// AverageDeviceColor returns the average of the print colors of [colors],
+// weighted by [weights] (which should sum to 1).
+// It returns false if the colors are not all in the same print color space,
+// or, for spot colors, do not share the same name.
+func AverageDeviceColor(colors []Color, weights []utils.Fl) (DeviceColor, bool) {
+	if len(colors) == 0 || colors[0].Device.Space == SpaceRGB {
+		return DeviceColor{}, false
+	}
+	out := DeviceColor{Space: colors[0].Device.Space, Spot: colors[0].Device.Spot}
+	for i, color := range colors {
+		if color.Device.Space != out.Space || color.Device.Spot != out.Spot {
+			return DeviceColor{}, false
+		}
+		if out.Space == SpaceSeparation {
+			out.CMYK = color.Device.CMYK // the alternate is shared
+		} else {
+			for c := range out.CMYK {
+				out.CMYK[c] += weights[i] * color.Device.CMYK[c]
+			}
+		}
+		out.Tint += weights[i] * color.Device.Tint
+	}
+	return out, true
+}
+
+// SpotColors stores the CMYK alternates of the spot colors
+// declared for a document, by name.
+//
+// It is filled by the "@spot-color" rules of the style sheets, and
+// may also be provided by the caller of the HTML renderer.
+type SpotColors map[string][4]utils.Fl
+
+// Register declares the spot color [name], which may then be used
+// in style sheets with the "spot(<name> [<tint>])" function.
+// The [alternate] color is used by output devices not supporting the spot color.
+// A new declaration overrides the previous one.
+func (sc SpotColors) Register(name string, alternate Color) {
+	cmyk := alternate.Device.CMYK
+	if alternate.Device.Space == SpaceRGB {
+		cmyk = RGBToCMYK(alternate.RGBA)
+	}
+	sc[name] = cmyk
+}
+
+// Color returns the spot color [name], with the given tint
+// in [0, 1], or false if it has not been registered.
+func (sc SpotColors) Color(name string, tint utils.Fl) (Color, bool) {
+	alternate, ok := sc[name]
+	if !ok {
+		return Color{}, false
+	}
+	return NewSpotColor(name, alternate, tint), true
+}
+
+// Resolve returns [c] with its spot color reference, if any, replaced
+// by the registered color. It returns false for an undeclared spot color.
+func (sc SpotColors) Resolve(c Color) (Color, bool) {
+	if c.Type != ColorSpot {
+		return c, true
+	}
+	return sc.Color(c.Device.Spot, c.Device.Tint)
+}
+
+// NewSpotColor returns the spot color [name] with the given
+// CMYK [alternate] at full tint. Its RGB approximation is obtained by
+// mixing the alternate with white.
+func NewSpotColor(name string, alternate [4]utils.Fl, tint utils.Fl) Color {
+	tint = clamp(tint)
+	rgb := CMYKToRGB(alternate, 1)
+	rgb.R, rgb.G, rgb.B = 1-tint*(1-rgb.R), 1-tint*(1-rgb.G), 1-tint*(1-rgb.B)
+	return Color{
+		Type:   ColorRGBA,
+		RGBA:   rgb,
+		Device: DeviceColor{Space: SpaceSeparation, CMYK: alternate, Spot: name, Tint: tint},
+	}
+}
+
+// parseSpot parses the arguments of a spot() function :
+// a name (identifier or string) and an optional tint, as a number or a percentage
+func parseSpot(arguments []Token) (Color, bool) {
+	var tokens []Token
+	for _, token := range arguments {
+		if lit, ok := token.(Literal); token.Kind() != KWhitespace && token.Kind() != KComment && !(ok && lit.Value == ",") {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 || len(tokens) > 2 {
+		return Color{}, false
+	}
+	var name string
+	switch token := tokens[0].(type) {
+	case Ident:
+		name = token.Value
+	case String:
+		name = token.Value
+	default:
+		return Color{}, false
+	}
+	var tint utils.Fl = 1
+	if len(tokens) == 2 {
+		switch token := tokens[1].(type) {
+		case Number:
+			tint = token.ValueF
+		case Percentage:
+			tint = token.ValueF / 100
+		default:
+			return Color{}, false
+		}
+	}
+	return Color{Type: ColorSpot, Device: DeviceColor{Space: SpaceSeparation, Spot: name, Tint: clamp(tint)}}, true
+}
+
 // If args is a list of a single  NUMBER token,
 // return its value clipped to the 0..1 range
 func parseAlpha(args []Token) (utils.Fl, bool) {
@@ -151,8 +151,6 @@ func parseDeclaration(firstToken Token, tokens *TokensIter, nested bool) Compoun
 		return ParseError{pos: colon.Pos(), kind: errInvalid, Message: "Declaration contains {} block"}
 	}
 
-	// TODO: Handle unicode-range
-
 	return Declaration{
 		pos:       name.pos,
 		Name:      name.Value,
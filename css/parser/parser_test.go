@@ -141,3 +141,51 @@ func TestNilContent(t *testing.T) {
 	rule = parseOneRule(tokenizeString("@font-face", true)).(AtRule)
 	testutils.AssertEqual(t, rule.Content == nil, true)
 }
+
+func TestDeviceColors(t *testing.T) {
+	for _, test := range []struct {
+		input  string
+		cmyk   [4]utils.Fl
+		rgba   RGBA
+		parsed bool
+	}{
+		{"device-cmyk(0 0 0 1)", [4]utils.Fl{0, 0, 0, 1}, RGBA{A: 1}, true},
+		{"device-cmyk(100% 0 0 0 / 0.5)", [4]utils.Fl{1, 0, 0, 0}, RGBA{G: 1, B: 1, A: 0.5}, true},
+		{"device-cmyk(0 1 1 0, green)", [4]utils.Fl{0, 1, 1, 0}, ColorKeywords["green"].RGBA, true},
+		{"device-cmyk(0, 0, 0, 0, 1)", [4]utils.Fl{0, 0, 0, 0}, RGBA{R: 1, G: 1, B: 1, A: 1}, true},
+		{"device-cmyk(0 0 0)", [4]utils.Fl{}, RGBA{}, false},
+		{"device-cmyk(0 0 0 0 1)", [4]utils.Fl{}, RGBA{}, false},
+	} {
+		color := ParseColorString(test.input)
+		if !test.parsed {
+			testutils.AssertEqual(t, color.IsNone(), true)
+			continue
+		}
+		testutils.AssertEqual(t, color.Device, DeviceColor{Space: SpaceCMYK, CMYK: test.cmyk})
+		testutils.AssertEqual(t, color.RGBA, test.rgba)
+	}
+
+	testutils.AssertEqual(t, ParseColorString("spot(test-gold 50% 1)").IsNone(), true)
+
+	ref := ParseColorString("spot(test-gold 50%)")
+	testutils.AssertEqual(t, ref, Color{Type: ColorSpot, Device: DeviceColor{Space: SpaceSeparation, Spot: "test-gold", Tint: 0.5}})
+	testutils.AssertEqual(t, ParseColorString(`spot("test-gold")`).Device.Tint, utils.Fl(1))
+
+	spots := SpotColors{}
+	_, ok := spots.Resolve(ref)
+	testutils.AssertEqual(t, ok, false)
+
+	spots.Register("test-gold", NewCMYKColor(0, 0.2, 0.8, 0))
+	color, ok := spots.Resolve(ref)
+	testutils.AssertEqual(t, ok, true)
+	testutils.AssertEqual(t, color.Device, DeviceColor{
+		Space: SpaceSeparation, CMYK: [4]utils.Fl{0, 0.2, 0.8, 0}, Spot: "test-gold", Tint: 0.5,
+	})
+	testutils.AssertEqual(t, color.RGBA, RGBA{R: 1, G: 0.9, B: 0.6, A: 1})
+
+	// other colors are left unchanged
+	red := ColorKeywords["red"]
+	color, ok = spots.Resolve(red)
+	testutils.AssertEqual(t, ok, true)
+	testutils.AssertEqual(t, color, red)
+}
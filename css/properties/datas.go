@@ -58,6 +58,22 @@ var (
 	}
 	FontSizeKeywordsOrder = []string{"xx-small", "x-small", "small", "medium", "large", "x-large", "xx-large"}
 
+	// EmphasisMarks maps the fill and shape keywords of
+	// 'text-emphasis-style' to the mark characters.
+	// https://www.w3.org/TR/css-text-decor-3/#text-emphasis-style-property
+	EmphasisMarks = map[[2]string]string{
+		{"filled", "dot"}:           "\u2022",
+		{"open", "dot"}:             "\u25e6",
+		{"filled", "circle"}:        "\u25cf",
+		{"open", "circle"}:          "\u25cb",
+		{"filled", "double-circle"}: "\u25c9",
+		{"open", "double-circle"}:   "\u25ce",
+		{"filled", "triangle"}:      "\u25b2",
+		{"open", "triangle"}:        "\u25b3",
+		{"filled", "sesame"}:        "\ufe45",
+		{"open", "sesame"}:          "\ufe46",
+	}
+
 	// http://www.w3.org/TR/css3-page/#size
 	PageSizes = map[string]Point{
 		"a10":     {Dimension{Value: 26, Unit: Mm}, Dimension{Value: 37, Unit: Mm}},
@@ -136,6 +152,7 @@ var (
 		PFontSize,
 		PFontStyle,
 		PFontStretch,
+		PFontSynthesis,
 		PFontVariant,
 		PFontVariantAlternates,
 		PFontVariantCaps,
@@ -161,16 +178,24 @@ var (
 		POrphans,
 		POverflowWrap,
 		PQuotes,
+		PRubyAlign,
+		PRubyPosition,
 		PTabSize,
 		PTextAlignAll,
 		PTextAlignLast,
+		PTextEmphasisColor,
+		PTextEmphasisPosition,
+		PTextEmphasisStyle,
 		PTextIndent,
+		PTextOrientation,
+		PTextShadow,
 		PTextTransform,
 		PVisibility,
 		PWhiteSpace,
 		PWidows,
 		PWordSpacing,
 		PWordBreak,
+		PWritingMode,
 	)
 
 	// http://www.w3.org/TR/CSS21/tables.html#model
@@ -98,6 +98,7 @@ const (
 
 	PFontWeight
 	PFontVariationSettings
+	PFontSynthesis
 
 	PHyphenateCharacter
 	PHyphenateLimitChars
@@ -238,6 +239,17 @@ const (
 
 	PHyphenateLimitZone
 
+	PWritingMode
+	PTextOrientation
+
+	PRubyPosition
+	PRubyAlign
+
+	PTextEmphasisStyle
+	PTextEmphasisColor
+	PTextEmphasisPosition
+	PTextShadow
+
 	NbProperties
 )
 
@@ -335,7 +347,7 @@ var InitialValues = Properties{
 	PFontKerning:           String("auto"),
 	PFontLanguageOverride:  String("normal"),
 	PFontSize:              FToV(16), // actually medium, but we define medium from this
-	PFontStretch:           String("normal"),
+	PFontStretch:           Float(100),
 	PFontStyle:             String("normal"),
 	PFontVariant:           String("normal"),
 	PFontVariantAlternates: String("normal"),
@@ -345,6 +357,7 @@ var InitialValues = Properties{
 	PFontVariantNumeric:    SStrings{String: "normal"},
 	PFontVariantPosition:   String("normal"),
 	PFontWeight:            IntString{Int: 400},
+	PFontSynthesis:         SStrings{Strings: []string{"weight", "style"}},
 
 	// Fonts 4 (WD): https://www.w3.org/TR/css-fonts-4/
 	PFontVariationSettings: SFloatStrings{String: "normal"},
@@ -455,6 +468,11 @@ var InitialValues = Properties{
 	PTextDecorationColor: CurrentColor,
 	PTextDecorationStyle: String("solid"),
 
+	PTextEmphasisStyle:    TaggedString{Tag: None},
+	PTextEmphasisColor:    CurrentColor,
+	PTextEmphasisPosition: Strings{"over", "right"},
+	PTextShadow:           Shadows{}, // computed value for "none"
+
 	// Overflow Module 3 (WD): https://www.w3.org/TR/css-overflow-3/
 	PBlockEllipsis: TaggedString{Tag: None},
 	PContinue:      String("auto"),
@@ -463,6 +481,14 @@ var InitialValues = Properties{
 	POverflowWrap:  String("normal"),
 	PTextOverflow:  String("clip"),
 
+	// Writing Modes 3 (CR): https://www.w3.org/TR/css-writing-modes-3/
+	PWritingMode:     String("horizontal-tb"),
+	PTextOrientation: String("mixed"),
+
+	// Ruby Layout 1 (WD): https://www.w3.org/TR/css-ruby-1/
+	PRubyPosition: String("over"),
+	PRubyAlign:    String("space-around"),
+
 	// Lists Module 3 (WD): https://drafts.csswg.org/css-lists-3/
 	// Means "none", but allow `display: list-item` to increment the
 	// list-item counter. If we ever have a way for authors to query
@@ -524,6 +550,7 @@ const (
 	SGridArea
 	SGridTemplate
 	SGrid
+	STextEmphasis
 )
 
 // NewShortand return the tag for 's' or 0 if not supported
@@ -597,6 +624,8 @@ func NewShortand(s string) Shortand {
 		return SGridTemplate
 	case "grid":
 		return SGrid
+	case "text-emphasis":
+		return STextEmphasis
 	default:
 		return 0
 	}
@@ -673,6 +702,8 @@ func (sh Shortand) String() string {
 		return "grid-template"
 	case SGrid:
 		return "grid"
+	case STextEmphasis:
+		return "text-emphasis"
 	default:
 		return ""
 	}
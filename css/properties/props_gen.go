@@ -249,12 +249,15 @@ func (s Properties) SetFontLanguageOverride(v String) { s[PFontLanguageOverride]
 func (s Properties) GetFontSize() DimOrS  { return s[PFontSize].(DimOrS) }
 func (s Properties) SetFontSize(v DimOrS) { s[PFontSize] = v }
 
-func (s Properties) GetFontStretch() String  { return s[PFontStretch].(String) }
-func (s Properties) SetFontStretch(v String) { s[PFontStretch] = v }
+func (s Properties) GetFontStretch() Float  { return s[PFontStretch].(Float) }
+func (s Properties) SetFontStretch(v Float) { s[PFontStretch] = v }
 
 func (s Properties) GetFontStyle() String  { return s[PFontStyle].(String) }
 func (s Properties) SetFontStyle(v String) { s[PFontStyle] = v }
 
+func (s Properties) GetFontSynthesis() SStrings  { return s[PFontSynthesis].(SStrings) }
+func (s Properties) SetFontSynthesis(v SStrings) { s[PFontSynthesis] = v }
+
 func (s Properties) GetFontVariant() String  { return s[PFontVariant].(String) }
 func (s Properties) SetFontVariant(v String) { s[PFontVariant] = v }
 
@@ -471,6 +474,12 @@ func (s Properties) SetRight(v DimOrS) { s[PRight] = v }
 func (s Properties) GetRowGap() DimOrS  { return s[PRowGap].(DimOrS) }
 func (s Properties) SetRowGap(v DimOrS) { s[PRowGap] = v }
 
+func (s Properties) GetRubyAlign() String  { return s[PRubyAlign].(String) }
+func (s Properties) SetRubyAlign(v String) { s[PRubyAlign] = v }
+
+func (s Properties) GetRubyPosition() String  { return s[PRubyPosition].(String) }
+func (s Properties) SetRubyPosition(v String) { s[PRubyPosition] = v }
+
 func (s Properties) GetSize() Point  { return s[PSize].(Point) }
 func (s Properties) SetSize(v Point) { s[PSize] = v }
 
@@ -498,12 +507,27 @@ func (s Properties) SetTextDecorationLine(v Decorations) { s[PTextDecorationLine
 func (s Properties) GetTextDecorationStyle() String  { return s[PTextDecorationStyle].(String) }
 func (s Properties) SetTextDecorationStyle(v String) { s[PTextDecorationStyle] = v }
 
+func (s Properties) GetTextEmphasisColor() Color  { return s[PTextEmphasisColor].(Color) }
+func (s Properties) SetTextEmphasisColor(v Color) { s[PTextEmphasisColor] = v }
+
+func (s Properties) GetTextEmphasisPosition() Strings  { return s[PTextEmphasisPosition].(Strings) }
+func (s Properties) SetTextEmphasisPosition(v Strings) { s[PTextEmphasisPosition] = v }
+
+func (s Properties) GetTextEmphasisStyle() TaggedString  { return s[PTextEmphasisStyle].(TaggedString) }
+func (s Properties) SetTextEmphasisStyle(v TaggedString) { s[PTextEmphasisStyle] = v }
+
 func (s Properties) GetTextIndent() DimOrS  { return s[PTextIndent].(DimOrS) }
 func (s Properties) SetTextIndent(v DimOrS) { s[PTextIndent] = v }
 
+func (s Properties) GetTextOrientation() String  { return s[PTextOrientation].(String) }
+func (s Properties) SetTextOrientation(v String) { s[PTextOrientation] = v }
+
 func (s Properties) GetTextOverflow() String  { return s[PTextOverflow].(String) }
 func (s Properties) SetTextOverflow(v String) { s[PTextOverflow] = v }
 
+func (s Properties) GetTextShadow() Shadows  { return s[PTextShadow].(Shadows) }
+func (s Properties) SetTextShadow(v Shadows) { s[PTextShadow] = v }
+
 func (s Properties) GetTextTransform() String  { return s[PTextTransform].(String) }
 func (s Properties) SetTextTransform(v String) { s[PTextTransform] = v }
 
@@ -540,6 +564,9 @@ func (s Properties) SetWordBreak(v String) { s[PWordBreak] = v }
 func (s Properties) GetWordSpacing() DimOrS  { return s[PWordSpacing].(DimOrS) }
 func (s Properties) SetWordSpacing(v DimOrS) { s[PWordSpacing] = v }
 
+func (s Properties) GetWritingMode() String  { return s[PWritingMode].(String) }
+func (s Properties) SetWritingMode(v String) { s[PWritingMode] = v }
+
 func (s Properties) GetZIndex() IntString  { return s[PZIndex].(IntString) }
 func (s Properties) SetZIndex(v IntString) { s[PZIndex] = v }
 
@@ -787,12 +814,15 @@ type StyleAccessor interface {
 	GetFontSize() DimOrS
 	SetFontSize(v DimOrS)
 
-	GetFontStretch() String
-	SetFontStretch(v String)
+	GetFontStretch() Float
+	SetFontStretch(v Float)
 
 	GetFontStyle() String
 	SetFontStyle(v String)
 
+	GetFontSynthesis() SStrings
+	SetFontSynthesis(v SStrings)
+
 	GetFontVariant() String
 	SetFontVariant(v String)
 
@@ -1003,6 +1033,12 @@ type StyleAccessor interface {
 	GetRowGap() DimOrS
 	SetRowGap(v DimOrS)
 
+	GetRubyAlign() String
+	SetRubyAlign(v String)
+
+	GetRubyPosition() String
+	SetRubyPosition(v String)
+
 	GetSize() Point
 	SetSize(v Point)
 
@@ -1030,12 +1066,27 @@ type StyleAccessor interface {
 	GetTextDecorationStyle() String
 	SetTextDecorationStyle(v String)
 
+	GetTextEmphasisColor() Color
+	SetTextEmphasisColor(v Color)
+
+	GetTextEmphasisPosition() Strings
+	SetTextEmphasisPosition(v Strings)
+
+	GetTextEmphasisStyle() TaggedString
+	SetTextEmphasisStyle(v TaggedString)
+
 	GetTextIndent() DimOrS
 	SetTextIndent(v DimOrS)
 
+	GetTextOrientation() String
+	SetTextOrientation(v String)
+
 	GetTextOverflow() String
 	SetTextOverflow(v String)
 
+	GetTextShadow() Shadows
+	SetTextShadow(v Shadows)
+
 	GetTextTransform() String
 	SetTextTransform(v String)
 
@@ -1072,6 +1123,9 @@ type StyleAccessor interface {
 	GetWordSpacing() DimOrS
 	SetWordSpacing(v DimOrS)
 
+	GetWritingMode() String
+	SetWritingMode(v String)
+
 	GetZIndex() IntString
 	SetZIndex(v IntString)
 }
@@ -1160,6 +1214,7 @@ var propsNames = [...]string{
 	PFontSize:                "font-size",
 	PFontStretch:             "font-stretch",
 	PFontStyle:               "font-style",
+	PFontSynthesis:           "font-synthesis",
 	PFontVariant:             "font-variant",
 	PFontVariantAlternates:   "font-variant-alternates",
 	PFontVariantCaps:         "font-variant-caps",
@@ -1230,6 +1285,8 @@ var propsNames = [...]string{
 	PQuotes:                  "quotes",
 	PRight:                   "right",
 	PRowGap:                  "row-gap",
+	PRubyAlign:               "ruby-align",
+	PRubyPosition:            "ruby-position",
 	PSize:                    "size",
 	PStringSet:               "string-set",
 	PTabSize:                 "tab-size",
@@ -1239,8 +1296,13 @@ var propsNames = [...]string{
 	PTextDecorationColor:     "text-decoration-color",
 	PTextDecorationLine:      "text-decoration-line",
 	PTextDecorationStyle:     "text-decoration-style",
+	PTextEmphasisColor:       "text-emphasis-color",
+	PTextEmphasisPosition:    "text-emphasis-position",
+	PTextEmphasisStyle:       "text-emphasis-style",
 	PTextIndent:              "text-indent",
+	PTextOrientation:         "text-orientation",
 	PTextOverflow:            "text-overflow",
+	PTextShadow:              "text-shadow",
 	PTextTransform:           "text-transform",
 	PTop:                     "top",
 	PTransform:               "transform",
@@ -1253,6 +1315,7 @@ var propsNames = [...]string{
 	PWidth:                   "width",
 	PWordBreak:               "word-break",
 	PWordSpacing:             "word-spacing",
+	PWritingMode:             "writing-mode",
 	PZIndex:                  "z-index",
 }
 
@@ -1341,6 +1404,7 @@ var PropsFromNames = map[string]KnownProp{
 	"font-size":                  PFontSize,
 	"font-stretch":               PFontStretch,
 	"font-style":                 PFontStyle,
+	"font-synthesis":             PFontSynthesis,
 	"font-variant":               PFontVariant,
 	"font-variant-alternates":    PFontVariantAlternates,
 	"font-variant-caps":          PFontVariantCaps,
@@ -1411,6 +1475,8 @@ var PropsFromNames = map[string]KnownProp{
 	"quotes":                     PQuotes,
 	"right":                      PRight,
 	"row-gap":                    PRowGap,
+	"ruby-align":                 PRubyAlign,
+	"ruby-position":              PRubyPosition,
 	"size":                       PSize,
 	"string-set":                 PStringSet,
 	"tab-size":                   PTabSize,
@@ -1420,8 +1486,13 @@ var PropsFromNames = map[string]KnownProp{
 	"text-decoration-color":      PTextDecorationColor,
 	"text-decoration-line":       PTextDecorationLine,
 	"text-decoration-style":      PTextDecorationStyle,
+	"text-emphasis-color":        PTextEmphasisColor,
+	"text-emphasis-position":     PTextEmphasisPosition,
+	"text-emphasis-style":        PTextEmphasisStyle,
 	"text-indent":                PTextIndent,
+	"text-orientation":           PTextOrientation,
 	"text-overflow":              PTextOverflow,
+	"text-shadow":                PTextShadow,
 	"text-transform":             PTextTransform,
 	"top":                        PTop,
 	"transform":                  PTransform,
@@ -1434,5 +1505,6 @@ var PropsFromNames = map[string]KnownProp{
 	"width":                      PWidth,
 	"word-break":                 PWordBreak,
 	"word-spacing":               PWordSpacing,
+	"writing-mode":               PWritingMode,
 	"z-index":                    PZIndex,
 }
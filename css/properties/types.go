@@ -59,6 +59,16 @@ func (dec Decorations) Union(other Decorations) Decorations { return dec | other
 
 type Transforms []SDimensions
 
+// Shadow is one layer of a 'text-shadow' value.
+// The color defaults to 'currentcolor'.
+type Shadow struct {
+	Color      Color
+	X, Y, Blur Dimension
+}
+
+// An empty slice means 'none'
+type Shadows []Shadow
+
 type Values []DimOrS
 
 type SIntStrings struct {
@@ -639,6 +649,7 @@ func (String) isCssProperty()            {}
 func (StringSet) isCssProperty()         {}
 func (Strings) isCssProperty()           {}
 func (Transforms) isCssProperty()        {}
+func (Shadows) isCssProperty()           {}
 func (DimOrS) isCssProperty()            {}
 func (Values) isCssProperty()            {}
 func (DimOrS4) isCssProperty()           {}
@@ -686,6 +697,7 @@ func (String) isDeclaredValue()            {}
 func (StringSet) isDeclaredValue()         {}
 func (Strings) isDeclaredValue()           {}
 func (Transforms) isDeclaredValue()        {}
+func (Shadows) isDeclaredValue()           {}
 func (DimOrS) isDeclaredValue()            {}
 func (Values) isDeclaredValue()            {}
 func (DimOrS4) isDeclaredValue()           {}
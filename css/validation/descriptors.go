@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"unicode"
 
 	"github.com/benoitkugler/webrender/css/counters"
 	"github.com/benoitkugler/webrender/logger"
@@ -29,6 +30,7 @@ var (
 		"font-stretch":          fontStretchDescriptor,
 		"font-feature-settings": fontFeatureSettingsDescriptor,
 		"font-variant":          fontVariant,
+		"unicode-range":         unicodeRange,
 	}
 
 	counterStyleDescriptors = map[string]counterStyleDescriptorParser{
@@ -54,9 +56,34 @@ type FontFaceDescriptors struct {
 	FontFamily          pr.String
 	FontStyle           pr.String
 	FontWeight          pr.IntString
-	FontStretch         pr.String
+	FontWeightEnd       pr.IntString // end of a 'font-weight' range, zero for a single value
+	FontStretch         pr.Float     // a percentage, zero if the descriptor is missing
+	FontStretchEnd      pr.Float     // end of a 'font-stretch' range, zero for a single value
 	FontFeatureSettings pr.FontFeatures
 	FontVariant         []NamedProp
+	UnicodeRange        UnicodeRanges // empty means all the code points
+}
+
+// UnicodeRange is an inclusive range of code points.
+type UnicodeRange struct {
+	Start, End rune
+}
+
+// UnicodeRanges is the value of the 'unicode-range' descriptor.
+type UnicodeRanges []UnicodeRange
+
+// Contains returns true if [r] is in one of the ranges,
+// or if [urs] is empty.
+func (urs UnicodeRanges) Contains(r rune) bool {
+	if len(urs) == 0 {
+		return true
+	}
+	for _, ur := range urs {
+		if ur.Start <= r && r <= ur.End {
+			return true
+		}
+	}
+	return false
 }
 
 type fontFaceDescriptorParser = func(tokens []Token, baseUrl string, out *FontFaceDescriptors) error
@@ -154,43 +181,55 @@ func fontStyleDescriptor(tokens []Token, _ string, out *FontFaceDescriptors) err
 }
 
 // @descriptor()
-// @singleToken
-// “font-weight“ descriptor validation.
+// “font-weight“ descriptor validation, accepting
+// a single weight or a range, like "100 900".
 func fontWeightDescriptor(tokens []Token, _ string, out *FontFaceDescriptors) error {
-	if len(tokens) != 1 {
+	if len(tokens) != 1 && len(tokens) != 2 {
 		return ErrInvalidValue
 	}
-	token := tokens[0]
-	keyword := getKeyword(token)
-	if keyword == "normal" || keyword == "bold" {
-		out.FontWeight = pr.IntString{String: keyword}
-		return nil
+	start, ok := fontWeightValue(tokens[0])
+	if !ok {
+		return ErrInvalidValue
 	}
-	if number, ok := token.(pa.Number); ok && number.IsInt() {
-		v := number.Int()
-		switch v {
-		case 100, 200, 300, 400, 500, 600, 700, 800, 900:
-			out.FontWeight = pr.IntString{Int: v}
-			return nil
+	var end pr.IntString
+	if len(tokens) == 2 {
+		end, ok = fontWeightValue(tokens[1])
+		if !ok {
+			return ErrInvalidValue
 		}
 	}
-	return ErrInvalidValue
+	out.FontWeight, out.FontWeightEnd = start, end
+	return nil
+}
+
+func fontWeightValue(token Token) (pr.IntString, bool) {
+	keyword := getKeyword(token)
+	if keyword == "normal" || keyword == "bold" {
+		return pr.IntString{String: keyword}, true
+	}
+	return fontWeightNumber(token)
 }
 
 // @descriptor()
-// @singleKeyword
-// Validation for the “font-stretch“ descriptor.
+// “font-stretch“ descriptor validation, accepting
+// a single width or a range, like "75% 125%".
 func fontStretchDescriptor(tokens []Token, _ string, out *FontFaceDescriptors) error {
-	keyword := getSingleKeyword(tokens)
-	switch keyword {
-	case "ultra-condensed", "extra-condensed", "condensed", "semi-condensed",
-		"normal",
-		"semi-expanded", "expanded", "extra-expanded", "ultra-expanded":
-		out.FontStretch = pr.String(keyword)
-		return nil
-	default:
-		return fmt.Errorf("unsupported font-stretch descriptor: %s", keyword)
+	if len(tokens) != 1 && len(tokens) != 2 {
+		return ErrInvalidValue
+	}
+	start, ok := fontStretchValue(tokens[0])
+	if !ok {
+		return fmt.Errorf("unsupported font-stretch descriptor: %s", pa.Serialize(tokens))
 	}
+	var end pr.Float
+	if len(tokens) == 2 {
+		end, ok = fontStretchValue(tokens[1])
+		if !ok {
+			return fmt.Errorf("unsupported font-stretch descriptor: %s", pa.Serialize(tokens))
+		}
+	}
+	out.FontStretch, out.FontStretchEnd = start, end
+	return nil
 }
 
 // @descriptor("font-feature-settings")
@@ -229,6 +268,26 @@ func fontVariant(tokens []Token, _ string, out *FontFaceDescriptors) error {
 	return nil
 }
 
+// @descriptor()
+// @commaSeparatedList
+// “unicode-range“ descriptor validation.
+func unicodeRange(tokens []Token, _ string, out *FontFaceDescriptors) error {
+	var l UnicodeRanges
+	for _, part := range pa.SplitOnComma(tokens) {
+		part = pa.RemoveWhitespace(part)
+		if len(part) != 1 {
+			return ErrInvalidValue
+		}
+		ur, ok := part[0].(pa.UnicodeRange)
+		if !ok || ur.Start > ur.End || ur.End > unicode.MaxRune {
+			return ErrInvalidValue
+		}
+		l = append(l, UnicodeRange{Start: rune(ur.Start), End: rune(ur.End)})
+	}
+	out.UnicodeRange = l
+	return nil
+}
+
 func PreprocessFontFaceDescriptors(baseUrl string, descriptors []pa.Compound) FontFaceDescriptors {
 	var out FontFaceDescriptors
 	preprocessDescriptors(baseUrl, descriptors, &out)
@@ -480,6 +539,33 @@ func PreprocessCounterStyleDescriptors(baseUrl string, descriptors []pa.Compound
 	return out
 }
 
+// spot-color
+
+// SpotColorDescriptors are the descriptors of a "@spot-color" rule.
+type SpotColorDescriptors struct {
+	// AlternateColor is used when the spot color is not supported
+	AlternateColor pa.Color
+}
+
+// “alternate-color“ descriptor validation.
+func alternateColor(tokens []Token, _ string, out *SpotColorDescriptors) error {
+	if len(tokens) != 1 {
+		return ErrInvalidValue
+	}
+	color := pa.ParseColor(tokens[0])
+	if color.Type != pa.ColorRGBA {
+		return ErrInvalidValue
+	}
+	out.AlternateColor = color
+	return nil
+}
+
+func PreprocessSpotColorDescriptors(baseUrl string, descriptors []pa.Compound) SpotColorDescriptors {
+	var out SpotColorDescriptors
+	preprocessDescriptors(baseUrl, descriptors, &out)
+	return out
+}
+
 type parsedDescriptor interface {
 	validateDescriptor(baseUrl, name string, tokens []Token) error
 }
@@ -505,6 +591,13 @@ func (d *csDescriptors) validateDescriptor(baseUrl, name string, tokens []Token)
 	return err
 }
 
+func (d *SpotColorDescriptors) validateDescriptor(baseUrl, name string, tokens []Token) error {
+	if name != "alternate-color" {
+		return errors.New("descriptor not supported")
+	}
+	return alternateColor(tokens, baseUrl, d)
+}
+
 // Filter unsupported names and values for descriptors.
 // Log a warning for every ignored descriptor.
 func preprocessDescriptors(baseUrl string, descriptors []pa.Compound, out parsedDescriptor) {
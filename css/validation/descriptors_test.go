@@ -50,7 +50,7 @@ func TestFontFace(t *testing.T) {
 	checkNameDescriptor([]pr.NamedString{{Name: "external", String: "https://weasyprint.org/foo/Fonty-Smiley.woff"}}, l.Src, t)
 	checkNameDescriptor(pr.String("italic"), l.FontStyle, t)
 	checkNameDescriptor(pr.IntString{Int: 200}, l.FontWeight, t)
-	checkNameDescriptor(pr.String("condensed"), l.FontStretch, t)
+	checkNameDescriptor(pr.Float(75), l.FontStretch, t)
 
 	l = processFontFace(`@font-face {
 		font-family: Gentium Hard;
@@ -96,7 +96,7 @@ func TestBadFontFace(t *testing.T) {
 
 	checkNameDescriptor(pr.String("Bad Font"), l.FontFamily, t)
 	checkNameDescriptor([]pr.NamedString{{Name: "external", String: "https://weasyprint.org/foo/BadFont.woff"}}, l.Src, t)
-	checkNameDescriptor(pr.String("expanded"), l.FontStretch, t)
+	checkNameDescriptor(pr.Float(125), l.FontStretch, t)
 
 	logs.CheckEqual([]string{
 		"Ignored `font-style: wrong` at 1:91, unsupported font-style descriptor: wrong.",
@@ -105,4 +105,70 @@ func TestBadFontFace(t *testing.T) {
 	}, t)
 }
 
+func TestFontFaceUnicodeRange(t *testing.T) {
+	l := processFontFace(`@font-face {
+		font-family: Gentium Hard;
+		src: url(Gentium.woff);
+		unicode-range: U+0025-00FF, u+4??, U+A5;
+	  }`, t)
+	checkNameDescriptor(UnicodeRanges{{0x25, 0xFF}, {0x400, 0x4FF}, {0xA5, 0xA5}}, l.UnicodeRange, t)
+	tu.AssertEqual(t, l.UnicodeRange.Contains('a'), true)
+	tu.AssertEqual(t, l.UnicodeRange.Contains('Ж'), true)
+	tu.AssertEqual(t, l.UnicodeRange.Contains('中'), false)
+	tu.AssertEqual(t, UnicodeRanges(nil).Contains('中'), true)
+
+	logs := tu.CaptureLogs()
+	for _, value := range []string{"U+00FF-0025", "U+0025-00FF U+A5", "U+110000", "a-z", "U+0025,"} {
+		l = processFontFace(`@font-face { src: url(Gentium.woff); unicode-range: `+value+` }`, t)
+		tu.AssertEqual(t, len(l.UnicodeRange), 0)
+	}
+	tu.AssertEqual(t, len(logs.Logs()), 5)
+}
+
+func TestFontFaceWeightRange(t *testing.T) {
+	l := processFontFace(`@font-face { src: url(Gentium.woff); font-weight: 100 900 }`, t)
+	checkNameDescriptor(pr.IntString{Int: 100}, l.FontWeight, t)
+	checkNameDescriptor(pr.IntString{Int: 900}, l.FontWeightEnd, t)
+
+	l = processFontFace(`@font-face { src: url(Gentium.woff); font-weight: normal bold }`, t)
+	checkNameDescriptor(pr.IntString{String: "normal"}, l.FontWeight, t)
+	checkNameDescriptor(pr.IntString{String: "bold"}, l.FontWeightEnd, t)
+
+	l = processFontFace(`@font-face { src: url(Gentium.woff); font-weight: 300 }`, t)
+	checkNameDescriptor(pr.IntString{}, l.FontWeightEnd, t)
+
+	// any number in [1, 1000] is a valid weight
+	l = processFontFace(`@font-face { src: url(Gentium.woff); font-weight: 150 850.4 }`, t)
+	checkNameDescriptor(pr.IntString{Int: 150}, l.FontWeight, t)
+	checkNameDescriptor(pr.IntString{Int: 850}, l.FontWeightEnd, t)
+
+	logs := tu.CaptureLogs()
+	for _, value := range []string{"100 900 700", "100 bolder", "0 900", "100 1001"} {
+		l = processFontFace(`@font-face { src: url(Gentium.woff); font-weight: `+value+` }`, t)
+		checkNameDescriptor(pr.IntString{}, l.FontWeight, t)
+	}
+	tu.AssertEqual(t, len(logs.Logs()), 4)
+}
+
+func TestFontFaceStretchRange(t *testing.T) {
+	l := processFontFace(`@font-face { src: url(Gentium.woff); font-stretch: 75% 112.5% }`, t)
+	checkNameDescriptor(pr.Float(75), l.FontStretch, t)
+	checkNameDescriptor(pr.Float(112.5), l.FontStretchEnd, t)
+
+	l = processFontFace(`@font-face { src: url(Gentium.woff); font-stretch: condensed expanded }`, t)
+	checkNameDescriptor(pr.Float(75), l.FontStretch, t)
+	checkNameDescriptor(pr.Float(125), l.FontStretchEnd, t)
+
+	l = processFontFace(`@font-face { src: url(Gentium.woff); font-stretch: 80% }`, t)
+	checkNameDescriptor(pr.Float(80), l.FontStretch, t)
+	checkNameDescriptor(pr.Float(0), l.FontStretchEnd, t)
+
+	logs := tu.CaptureLogs()
+	for _, value := range []string{"50% 100% 200%", "-10%", "100", "condensed wide"} {
+		l = processFontFace(`@font-face { src: url(Gentium.woff); font-stretch: `+value+` }`, t)
+		checkNameDescriptor(pr.Float(0), l.FontStretch, t)
+	}
+	tu.AssertEqual(t, len(logs.Logs()), 4)
+}
+
 // see style/style_test.go for other font face tests
@@ -52,6 +52,7 @@ var expanders = [...]expander{
 	pr.SGridArea:       genericExpander(pr.PGridRowStart, pr.PGridRowEnd, pr.PGridColumnStart, pr.PGridColumnEnd)(_expandGridArea),
 	pr.SGridTemplate:   genericExpander(pr.PGridTemplateColumns, pr.PGridTemplateRows, pr.PGridTemplateAreas)(_expandGridTemplate),
 	pr.SGrid:           genericExpander(pr.PGridTemplateColumns, pr.PGridTemplateRows, pr.PGridTemplateAreas, pr.PGridAutoColumns, pr.PGridAutoRows, pr.PGridAutoFlow)(_expandGrid),
+	pr.STextEmphasis:   genericExpander(pr.PTextEmphasisStyle, pr.PTextEmphasisColor)(_expandTextEmphasis),
 }
 
 var borderExpanders = [...]expander{
@@ -797,6 +798,41 @@ func _expandTextDecoration(_ string, _ pr.Shortand, tokens []Token) (out []named
 	return out, nil
 }
 
+// @expander("text-emphasis")
+func _expandTextEmphasis(_ string, _ pr.Shortand, tokens []Token) (out []namedTokens, err error) {
+	var textEmphasisStyle, textEmphasisColor []Token
+	for _, token := range tokens {
+		if textEmphasisStyle_(token) {
+			textEmphasisStyle = append(textEmphasisStyle, token)
+		} else if color := pa.ParseColor(token); !color.IsNone() && len(textEmphasisColor) == 0 {
+			textEmphasisColor = append(textEmphasisColor, token)
+		} else {
+			return nil, ErrInvalidValue
+		}
+	}
+
+	if len(textEmphasisStyle) != 0 {
+		out = append(out, namedTokens{name: pr.PTextEmphasisStyle, tokens: textEmphasisStyle})
+	}
+	if len(textEmphasisColor) != 0 {
+		out = append(out, namedTokens{name: pr.PTextEmphasisColor, tokens: textEmphasisColor})
+	}
+	return out, nil
+}
+
+// textEmphasisStyle_ returns true if [token] may be part of
+// a “text-emphasis-style“ value.
+func textEmphasisStyle_(token Token) bool {
+	if _, ok := token.(pa.String); ok {
+		return true
+	}
+	switch getKeyword(token) {
+	case "none", "filled", "open", "dot", "circle", "double-circle", "triangle", "sesame":
+		return true
+	}
+	return false
+}
+
 // Expand legacy “page-break-before“ && “page-break-after“ pr.
 // See https://www.w3.org/TR/css-break-3/#page-break-properties
 func _expandPageBreakBeforeAfter(_ string, name pr.Shortand, tokens []Token) (out []namedTokens, err error) {
@@ -982,7 +1018,8 @@ func _expandFont(_ string, _ pr.Shortand, tokens []Token) ([]namedTokens, error)
 			suffix = pr.PFontVariantCaps
 		} else if fontWeight([]Token{token}, "") != nil {
 			suffix = pr.PFontWeight
-		} else if fontStretch([]Token{token}, "") != nil {
+		} else if _, isKeyword := fontStretchKeywords[kw]; isKeyword {
+			// percentages are not allowed in the shorthand
 			suffix = pr.PFontStretch
 		} else {
 			// We’re done with these four, continue with font-size
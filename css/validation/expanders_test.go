@@ -52,6 +52,25 @@ func TestTextDecoration(t *testing.T) {
 	assertInvalid(t, "text-decoration: none none", "invalid")
 }
 
+func TestExpandTextEmphasis(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	assertValidDict(t, "text-emphasis: none", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{Tag: pr.None},
+	}))
+	assertValidDict(t, "text-emphasis: filled red", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{Tag: pr.Auto, S: "filled"},
+		pr.PTextEmphasisColor: pr.NewColor(1, 0, 0, 1),
+	}))
+	assertValidDict(t, "text-emphasis: blue open triangle", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{S: "\u25b3"},
+		pr.PTextEmphasisColor: pr.NewColor(0, 0, 1, 1),
+	}))
+
+	assertInvalid(t, "text-emphasis: red blue", "invalid")
+	assertInvalid(t, "text-emphasis: dot 1px", "invalid")
+}
+
 // Test the 4-value pr.
 func TestFourSides(t *testing.T) {
 	capt := tu.CaptureLogs()
@@ -435,7 +454,7 @@ func TestFont(t *testing.T) {
 	}))
 	assertValidDict(t, "font: small-caps condensed normal 700 large serif", toValidated(pr.Properties{
 		// "font_style": String("normal"),  XXX shouldn’t this be here?
-		pr.PFontStretch:     pr.String("condensed"),
+		pr.PFontStretch:     pr.Float(75),
 		pr.PFontVariantCaps: pr.String("small-caps"),
 		pr.PFontWeight:      pr.IntString{Int: 700},
 		pr.PFontSize:        pr.SToV("large"),
@@ -446,6 +465,12 @@ func TestFont(t *testing.T) {
 		pr.PFontSize:   pr.FToPx(13),
 		pr.PFontFamily: pr.Strings{"sans-serif"},
 	}))
+	// percentages are font sizes, not widths
+	assertValidDict(t, "font: 450 80% serif", toValidated(pr.Properties{
+		pr.PFontWeight: pr.IntString{Int: 450},
+		pr.PFontSize:   pr.Dimension{Value: 80, Unit: pr.Perc}.ToValue(),
+		pr.PFontFamily: pr.Strings{"serif"},
+	}))
 	capt.AssertNoLogs(t)
 	assertInvalid(t, `font-family: "My" Font, serif`, "invalid")
 	assertInvalid(t, `font-family: "My" "Font", serif`, "invalid")
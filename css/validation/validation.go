@@ -177,6 +177,7 @@ var (
 		pr.PFontVariantAlternates:   fontVariantAlternates,
 		pr.PFontVariantEastAsian:    fontVariantEastAsian,
 		pr.PFontVariationSettings:   fontVariationSettings,
+		pr.PFontSynthesis:           fontSynthesis,
 		pr.PFontStyle:               fontStyle,
 		pr.PFontStretch:             fontStretch,
 		pr.PFontWeight:              fontWeight,
@@ -209,8 +210,13 @@ var (
 		pr.PTextAlignLast:           textAlignLast,
 		pr.PTextDecorationLine:      textDecorationLine,
 		pr.PTextDecorationStyle:     textDecorationStyle,
+		pr.PTextEmphasisColor:       otherColors,
+		pr.PTextEmphasisStyle:       textEmphasisStyle,
+		pr.PTextEmphasisPosition:    textEmphasisPosition,
+		pr.PTextShadow:              textShadow,
 		pr.PTextIndent:              textIndent,
 		pr.PTextTransform:           textTransform,
+		pr.PUnicodeBidi:             unicodeBidi,
 		pr.PVerticalAlign:           verticalAlign,
 		pr.PVisibility:              visibility,
 		pr.PWhiteSpace:              whiteSpace,
@@ -257,6 +263,10 @@ var (
 		pr.PGridColumnStart:         gridLine,
 		pr.PGridRowEnd:              gridLine,
 		pr.PGridColumnEnd:           gridLine,
+		pr.PWritingMode:             writingMode,
+		pr.PTextOrientation:         textOrientation,
+		pr.PRubyPosition:            rubyPosition,
+		pr.PRubyAlign:               rubyAlign,
 	}
 	validatorsError = map[pr.KnownProp]validatorError{
 		pr.PBackgroundImage:   backgroundImage,
@@ -1729,7 +1739,7 @@ func display(tokens []Token, _ string) pr.CssProperty {
 	switch keyword {
 	case "none", "table-caption", "table-row-group", "table-cell",
 		"table-header-group", "table-footer-group", "table-row",
-		"table-column-group", "table-column":
+		"table-column-group", "table-column", "ruby-base", "ruby-text":
 		return pr.Display{keyword}
 	case "inline-table", "inline-flex", "inline-grid":
 		return pr.Display{"inline", keyword[7:]}
@@ -1750,7 +1760,7 @@ func display(tokens []Token, _ string) pr.CssProperty {
 				return nil
 			}
 			outside = value
-		case "flow", "flow-root", "table", "flex", "grid":
+		case "flow", "flow-root", "table", "flex", "grid", "ruby":
 			if inside != "" {
 				return nil
 			}
@@ -1765,6 +1775,13 @@ func display(tokens []Token, _ string) pr.CssProperty {
 		}
 	}
 
+	if inside == "ruby" {
+		// only inline ruby containers are supported
+		if outside == "block" || listItem != "" {
+			return nil
+		}
+		return pr.Display{"inline", "ruby"}
+	}
 	if outside == "" {
 		outside = "block"
 	}
@@ -2075,6 +2092,31 @@ func fontVariationSettings(tokens []Token, _ string) pr.CssProperty {
 	return out
 }
 
+// @validator()
+// “font-synthesis“ property validation.
+func fontSynthesis(tokens []Token, _ string) pr.CssProperty {
+	if len(tokens) == 1 && getKeyword(tokens[0]) == "none" {
+		return pr.SStrings{String: "none"}
+	}
+	var out pr.SStrings
+	for _, token := range tokens {
+		keyword := getKeyword(token)
+		if keyword != "weight" && keyword != "style" {
+			return nil
+		}
+		for _, v := range out.Strings {
+			if v == keyword {
+				return nil
+			}
+		}
+		out.Strings = append(out.Strings, keyword)
+	}
+	if len(out.Strings) == 0 {
+		return nil
+	}
+	return out
+}
+
 // @validator()
 // @singleToken
 // “font-size“ property validation.
@@ -2107,18 +2149,51 @@ func fontStyle(tokens []Token, _ string) pr.CssProperty {
 	}
 }
 
+// fontStretchKeywords are the percentages of the
+// 'font-stretch' keywords
+var fontStretchKeywords = map[string]pr.Float{
+	"ultra-condensed": 50,
+	"extra-condensed": 62.5,
+	"condensed":       75,
+	"semi-condensed":  87.5,
+	"normal":          100,
+	"semi-expanded":   112.5,
+	"expanded":        125,
+	"extra-expanded":  150,
+	"ultra-expanded":  200,
+}
+
+// fontStretchValue returns the percentage of a 'font-stretch' keyword,
+// or a positive percentage.
+func fontStretchValue(token Token) (pr.Float, bool) {
+	if percentage, ok := token.(pa.Percentage); ok && percentage.ValueF >= 0 {
+		return pr.Float(percentage.ValueF), true
+	}
+	value, ok := fontStretchKeywords[getKeyword(token)]
+	return value, ok
+}
+
 // @validator()
-// @singleKeyword
+// @singleToken
 // Validation for the “font-stretch“ property.
+// Keywords are converted to their percentage.
 func fontStretch(tokens []Token, _ string) pr.CssProperty {
-	keyword := getSingleKeyword(tokens)
-	switch keyword {
-	case "ultra-condensed", "extra-condensed", "condensed", "semi-condensed",
-		"normal", "semi-expanded", "expanded", "extra-expanded", "ultra-expanded":
-		return pr.String(keyword)
-	default:
+	if len(tokens) != 1 {
 		return nil
 	}
+	if value, ok := fontStretchValue(tokens[0]); ok {
+		return value
+	}
+	return nil
+}
+
+// fontWeightNumber returns the weight of a number in [1, 1000],
+// rounded since the text engines only support integer weights.
+func fontWeightNumber(token Token) (pr.IntString, bool) {
+	if number, ok := token.(pa.Number); ok && 1 <= number.ValueF && number.ValueF <= 1000 {
+		return pr.IntString{Int: int(math.Round(float64(number.ValueF)))}, true
+	}
+	return pr.IntString{}, false
 }
 
 // @validator()
@@ -2133,11 +2208,8 @@ func fontWeight(tokens []Token, _ string) pr.CssProperty {
 	if keyword == "normal" || keyword == "bold" || keyword == "bolder" || keyword == "lighter" {
 		return pr.IntString{String: keyword}
 	}
-	if number, ok := token.(pa.Number); ok {
-		intValue := number.Int()
-		if number.IsInt() && (intValue == 100 || intValue == 200 || intValue == 300 || intValue == 400 || intValue == 500 || intValue == 600 || intValue == 700 || intValue == 800 || intValue == 900) {
-			return pr.IntString{Int: intValue}
-		}
+	if weight, ok := fontWeightNumber(token); ok {
+		return weight
 	}
 	return nil
 }
@@ -2452,6 +2524,51 @@ func wordBreak(tokens []Token, _ string) pr.CssProperty {
 	}
 }
 
+// Validation for the “writing-mode“ property.
+func writingMode(tokens []Token, _ string) pr.CssProperty {
+	keyword := getSingleKeyword(tokens)
+	switch keyword {
+	case "horizontal-tb", "vertical-rl", "vertical-lr":
+		return pr.String(keyword)
+	default:
+		return nil
+	}
+}
+
+// Validation for the “text-orientation“ property.
+func textOrientation(tokens []Token, _ string) pr.CssProperty {
+	keyword := getSingleKeyword(tokens)
+	switch keyword {
+	case "mixed", "upright", "sideways":
+		return pr.String(keyword)
+	default:
+		return nil
+	}
+}
+
+// Validation for the “ruby-position“ property.
+// Only the 'over' and 'under' positions are supported.
+func rubyPosition(tokens []Token, _ string) pr.CssProperty {
+	keyword := getSingleKeyword(tokens)
+	switch keyword {
+	case "over", "under":
+		return pr.String(keyword)
+	default:
+		return nil
+	}
+}
+
+// Validation for the “ruby-align“ property.
+func rubyAlign(tokens []Token, _ string) pr.CssProperty {
+	keyword := getSingleKeyword(tokens)
+	switch keyword {
+	case "start", "center", "space-between", "space-around":
+		return pr.String(keyword)
+	default:
+		return nil
+	}
+}
+
 // @validator()
 // @single_keyword
 // Validation for the “text-overflow“ property.
@@ -2601,6 +2718,117 @@ func textDecorationStyle(tokens []Token, _ string) pr.CssProperty {
 	}
 }
 
+// “text-emphasis-style“ property validation.
+// Keywords are resolved to the mark string, except when only the fill
+// is given : the shape then depends on the writing mode, and the
+// fill is returned with an [pr.Auto] tag.
+func textEmphasisStyle(tokens []Token, _ string) pr.CssProperty {
+	if len(tokens) == 1 {
+		if str, ok := tokens[0].(pa.String); ok {
+			return pr.TaggedString{S: str.Value}
+		}
+		if getKeyword(tokens[0]) == "none" {
+			return pr.TaggedString{Tag: pr.None}
+		}
+	}
+	var fill, shape string
+	for _, token := range tokens {
+		switch keyword := getKeyword(token); keyword {
+		case "filled", "open":
+			if fill != "" {
+				return nil
+			}
+			fill = keyword
+		case "dot", "circle", "double-circle", "triangle", "sesame":
+			if shape != "" {
+				return nil
+			}
+			shape = keyword
+		default:
+			return nil
+		}
+	}
+	if shape == "" {
+		if fill == "" {
+			return nil
+		}
+		return pr.TaggedString{Tag: pr.Auto, S: fill}
+	}
+	if fill == "" {
+		fill = "filled"
+	}
+	return pr.TaggedString{S: pr.EmphasisMarks[[2]string{fill, shape}]}
+}
+
+// “text-emphasis-position“ property validation.
+func textEmphasisPosition(tokens []Token, _ string) pr.CssProperty {
+	var over, right string
+	for _, token := range tokens {
+		switch keyword := getKeyword(token); keyword {
+		case "over", "under":
+			if over != "" {
+				return nil
+			}
+			over = keyword
+		case "right", "left":
+			if right != "" {
+				return nil
+			}
+			right = keyword
+		default:
+			return nil
+		}
+	}
+	if over == "" {
+		return nil
+	}
+	if right == "" {
+		right = "right"
+	}
+	return pr.Strings{over, right}
+}
+
+// “text-shadow“ property validation.
+func textShadow(tokens []Token, _ string) pr.CssProperty {
+	if getSingleKeyword(tokens) == "none" {
+		return pr.Shadows{}
+	}
+	var out pr.Shadows
+	for _, part := range pa.SplitOnComma(tokens) {
+		shadow, ok := textShadow_(pa.RemoveWhitespace(part))
+		if !ok {
+			return nil
+		}
+		out = append(out, shadow)
+	}
+	return out
+}
+
+// textShadow_ parses one shadow : 2 or 3 lengths (the blur radius can't
+// be negative) and an optional color, either before or after the lengths.
+func textShadow_(tokens []Token) (pr.Shadow, bool) {
+	out := pr.Shadow{Color: pr.CurrentColor}
+	if n := len(tokens); n != 0 {
+		if color := pa.ParseColor(tokens[0]); !color.IsNone() {
+			out.Color, tokens = pr.Color(color), tokens[1:]
+		} else if color := pa.ParseColor(tokens[n-1]); !color.IsNone() {
+			out.Color, tokens = pr.Color(color), tokens[:n-1]
+		}
+	}
+	if len(tokens) != 2 && len(tokens) != 3 {
+		return out, false
+	}
+	lengths := [3]*pr.Dimension{&out.X, &out.Y, &out.Blur}
+	for i, token := range tokens {
+		length := getLength(token, i != 2, false)
+		if length.IsNone() {
+			return out, false
+		}
+		*lengths[i] = length
+	}
+	return out, true
+}
+
 // @validator()
 // @singleToken
 // “text-indent“ property validation.
@@ -2629,6 +2857,19 @@ func textTransform(tokens []Token, _ string) pr.CssProperty {
 	}
 }
 
+// @validator()
+// @singleKeyword
+// “unicode-bidi“ property validation.
+func unicodeBidi(tokens []Token, _ string) pr.CssProperty {
+	keyword := getSingleKeyword(tokens)
+	switch keyword {
+	case "normal", "embed", "isolate", "bidi-override", "isolate-override", "plaintext":
+		return pr.String(keyword)
+	default:
+		return nil
+	}
+}
+
 // @validator()
 // @singleToken
 // Validation for the “vertical-align“ property
@@ -183,6 +183,23 @@ func TestSpacing(t *testing.T) {
 	assertInvalid(t, "word-spacing: 3", "invalid")
 }
 
+func TestFontWeightStretch(t *testing.T) {
+	capt := tu.CaptureLogs()
+	assertValidDict(t, "font-weight: 1", toValidated(pr.Properties{pr.PFontWeight: pr.IntString{Int: 1}}))
+	assertValidDict(t, "font-weight: 450", toValidated(pr.Properties{pr.PFontWeight: pr.IntString{Int: 450}}))
+	assertValidDict(t, "font-weight: 1000", toValidated(pr.Properties{pr.PFontWeight: pr.IntString{Int: 1000}}))
+	assertValidDict(t, "font-weight: bolder", toValidated(pr.Properties{pr.PFontWeight: pr.IntString{String: "bolder"}}))
+	assertValidDict(t, "font-stretch: 80%", toValidated(pr.Properties{pr.PFontStretch: pr.Float(80)}))
+	assertValidDict(t, "font-stretch: semi-condensed", toValidated(pr.Properties{pr.PFontStretch: pr.Float(87.5)}))
+	capt.AssertNoLogs(t)
+
+	assertInvalid(t, "font-weight: 0", "invalid")
+	assertInvalid(t, "font-weight: 1001", "invalid")
+	assertInvalid(t, "font-weight: 400px", "invalid")
+	assertInvalid(t, "font-stretch: -50%", "invalid")
+	assertInvalid(t, "font-stretch: 100", "invalid")
+}
+
 func TestDecoration(t *testing.T) {
 	capt := tu.CaptureLogs()
 	assertValidDict(t, "text-decoration-line: none", toValidated(pr.Properties{
@@ -398,6 +415,116 @@ func TestFontFamily(t *testing.T) {
 	assertInvalid(t, `font-family: "My", 12pt, serif`, "invalid")
 }
 
+func TestFontSynthesis(t *testing.T) {
+	assertValidDict(t, "font-synthesis: none", toValidated(pr.Properties{
+		pr.PFontSynthesis: pr.SStrings{String: "none"},
+	}))
+	assertValidDict(t, "font-synthesis: style weight", toValidated(pr.Properties{
+		pr.PFontSynthesis: pr.SStrings{Strings: []string{"style", "weight"}},
+	}))
+	assertInvalid(t, "font-synthesis: weight weight", "invalid")
+	assertInvalid(t, "font-synthesis: none style", "invalid")
+	assertInvalid(t, "font-synthesis: bold", "invalid")
+}
+
+func TestUnicodeBidi(t *testing.T) {
+	for _, keyword := range []string{"normal", "embed", "isolate", "bidi-override", "isolate-override", "plaintext"} {
+		assertValidDict(t, "unicode-bidi: "+keyword, toValidated(pr.Properties{
+			pr.PUnicodeBidi: pr.String(keyword),
+		}))
+	}
+	assertInvalid(t, "unicode-bidi: override", "invalid")
+	assertInvalid(t, "unicode-bidi: embed isolate", "invalid")
+}
+
+func TestWritingMode(t *testing.T) {
+	for _, keyword := range []string{"horizontal-tb", "vertical-rl", "vertical-lr"} {
+		assertValidDict(t, "writing-mode: "+keyword, toValidated(pr.Properties{
+			pr.PWritingMode: pr.String(keyword),
+		}))
+	}
+	for _, keyword := range []string{"mixed", "upright", "sideways"} {
+		assertValidDict(t, "text-orientation: "+keyword, toValidated(pr.Properties{
+			pr.PTextOrientation: pr.String(keyword),
+		}))
+	}
+	assertInvalid(t, "writing-mode: tb-rl", "invalid")
+	assertInvalid(t, "text-orientation: sideways-right", "invalid")
+}
+
+func TestRuby(t *testing.T) {
+	for _, keyword := range []string{"over", "under"} {
+		assertValidDict(t, "ruby-position: "+keyword, toValidated(pr.Properties{
+			pr.PRubyPosition: pr.String(keyword),
+		}))
+	}
+	for _, keyword := range []string{"start", "center", "space-between", "space-around"} {
+		assertValidDict(t, "ruby-align: "+keyword, toValidated(pr.Properties{
+			pr.PRubyAlign: pr.String(keyword),
+		}))
+	}
+	assertValidDict(t, "display: ruby", toValidated(pr.Properties{pr.PDisplay: pr.Display{"inline", "ruby"}}))
+	assertValidDict(t, "display: inline ruby", toValidated(pr.Properties{pr.PDisplay: pr.Display{"inline", "ruby"}}))
+	assertValidDict(t, "display: ruby-text", toValidated(pr.Properties{pr.PDisplay: pr.Display{"ruby-text"}}))
+	assertInvalid(t, "ruby-position: inter-character", "invalid")
+	assertInvalid(t, "ruby-align: end", "invalid")
+	assertInvalid(t, "display: block ruby", "invalid")
+}
+
+func TestTextEmphasis(t *testing.T) {
+	assertValidDict(t, "text-emphasis-style: none", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{Tag: pr.None},
+	}))
+	assertValidDict(t, "text-emphasis-style: open", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{Tag: pr.Auto, S: "open"},
+	}))
+	assertValidDict(t, "text-emphasis-style: sesame", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{S: "\ufe45"},
+	}))
+	assertValidDict(t, "text-emphasis-style: circle open", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{S: "\u25cb"},
+	}))
+	assertValidDict(t, "text-emphasis-style: 'x'", toValidated(pr.Properties{
+		pr.PTextEmphasisStyle: pr.TaggedString{S: "x"},
+	}))
+	assertValidDict(t, "text-emphasis-color: red", toValidated(pr.Properties{
+		pr.PTextEmphasisColor: pr.NewColor(1, 0, 0, 1),
+	}))
+	assertValidDict(t, "text-emphasis-position: under", toValidated(pr.Properties{
+		pr.PTextEmphasisPosition: pr.Strings{"under", "right"},
+	}))
+	assertValidDict(t, "text-emphasis-position: left over", toValidated(pr.Properties{
+		pr.PTextEmphasisPosition: pr.Strings{"over", "left"},
+	}))
+	assertInvalid(t, "text-emphasis-style: filled open", "invalid")
+	assertInvalid(t, "text-emphasis-style: dot circle", "invalid")
+	assertInvalid(t, "text-emphasis-style: none dot", "invalid")
+	assertInvalid(t, "text-emphasis-position: left", "invalid")
+	assertInvalid(t, "text-emphasis-position: over under", "invalid")
+}
+
+func TestTextShadow(t *testing.T) {
+	px := func(v pr.Float) pr.Dimension { return pr.Dimension{Value: v, Unit: pr.Px} }
+	assertValidDict(t, "text-shadow: none", toValidated(pr.Properties{
+		pr.PTextShadow: pr.Shadows{},
+	}))
+	assertValidDict(t, "text-shadow: 1px -2px", toValidated(pr.Properties{
+		pr.PTextShadow: pr.Shadows{{Color: pr.CurrentColor, X: px(1), Y: px(-2)}},
+	}))
+	assertValidDict(t, "text-shadow: red 1px 2px 3px, 0 1em blue", toValidated(pr.Properties{
+		pr.PTextShadow: pr.Shadows{
+			{Color: pr.NewColor(1, 0, 0, 1), X: px(1), Y: px(2), Blur: px(3)},
+			{Color: pr.NewColor(0, 0, 1, 1), X: pr.Dimension{Unit: pr.Scalar}, Y: pr.Dimension{Value: 1, Unit: pr.Em}},
+		},
+	}))
+	assertInvalid(t, "text-shadow: 1px", "invalid")
+	assertInvalid(t, "text-shadow: 1px 2px -3px", "invalid")
+	assertInvalid(t, "text-shadow: 1px 2px 3px 4px", "invalid")
+	assertInvalid(t, "text-shadow: 1px red 2px", "invalid")
+	assertInvalid(t, "text-shadow: 1px 2px, none", "invalid")
+	assertInvalid(t, "text-shadow: 1px 2px,", "invalid")
+}
+
 // Test the “line-height“ property.
 func TestLineHeight(t *testing.T) {
 	capt := tu.CaptureLogs()
@@ -9,6 +9,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/benoitkugler/webrender/images"
+	"github.com/benoitkugler/webrender/text"
 	"github.com/benoitkugler/webrender/utils"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -120,7 +121,7 @@ const (
 type Background struct {
 	ImageRendering pr.String
 	Layers         []BackgroundLayer
-	Color          parser.RGBA
+	Color          parser.Color
 }
 
 type Area struct {
@@ -149,6 +150,23 @@ type BackgroundLayer struct {
 	Unbounded       bool
 }
 
+// VerticalFlow describes a page laid out with a vertical writing mode.
+// The content of the page is laid out in a logical frame, where lines
+// are horizontal and blocks are stacked downward, starting at the origin
+// of the page content area. It is then mapped to the page by
+// a rotation (for 'vertical-rl') or a reflection (for 'vertical-lr').
+//
+// Only the principal writing mode, given by the root element, is supported :
+// the mapping applies to the whole page, and there are no orthogonal flows.
+type VerticalFlow struct {
+	Mode pr.String // "vertical-rl" or "vertical-lr"
+
+	// X, Y is the origin of the page content area,
+	// and Width its physical width, that is
+	// the logical height of the content.
+	X, Y, Width pr.Float
+}
+
 // BoxFields is an abstract base class for all boxes.
 type BoxFields struct {
 	// Original html node, needed for post-processing.
@@ -206,6 +224,10 @@ type BoxFields struct {
 	ContentHeight  pr.Float
 	VerticalAlign  string
 
+	// For inline-level boxes, the bidi embedding level resolved
+	// with the paragraph, see [ResolveBidiLevels]
+	BidiLevel text.BidiLevel
+
 	// For table cells, [true] when no children is either floated or in normal flow
 	Empty bool
 
@@ -227,6 +249,10 @@ type BoxFields struct {
 	RemoveDecorationSides [4]bool
 
 	BorderImage images.Image
+
+	// VerticalFlow is set on the root box and the footnote area
+	// of pages with a vertical writing mode, and nil otherwise.
+	VerticalFlow *VerticalFlow
 }
 
 func newBoxFields(style pr.ElementStyle, element *html.Node, pseudoType string, children []Box) BoxFields {
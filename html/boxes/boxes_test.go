@@ -14,6 +14,7 @@ import (
 	pr "github.com/benoitkugler/webrender/css/properties"
 	"github.com/benoitkugler/webrender/html/tree"
 	"github.com/benoitkugler/webrender/images"
+	"github.com/benoitkugler/webrender/text"
 	"github.com/benoitkugler/webrender/utils"
 	tu "github.com/benoitkugler/webrender/utils/testutils"
 )
@@ -430,6 +431,31 @@ func TestBlockInInline(t *testing.T) {
 	})
 }
 
+func TestRubyBoxes(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	box := parseAndBuild(t, `<p><ruby>漢<rp>(</rp><rt>kan</rt> <rb>字</rb><rb>a</rb><rt>ji</rt></ruby><rt>x</rt></p>`)
+	assertTree(t, box, []SerBox{
+		{"p", BlockT, BC{C: []SerBox{
+			{"p", LineT, BC{C: []SerBox{
+				{"ruby", RubyT, BC{C: []SerBox{
+					{"ruby", RubyBaseT, BC{C: []SerBox{{"ruby", TextT, BC{Text: "漢"}}}}},
+					{"rt", RubyTextT, BC{C: []SerBox{{"rt", TextT, BC{Text: "kan"}}}}},
+					{"rb", RubyBaseT, BC{C: []SerBox{{"rb", TextT, BC{Text: "字"}}}}},
+					{"ruby", RubyTextT, BC{C: []SerBox{}}},
+					{"rb", RubyBaseT, BC{C: []SerBox{{"rb", TextT, BC{Text: "a"}}}}},
+					{"rt", RubyTextT, BC{C: []SerBox{{"rt", TextT, BC{Text: "ji"}}}}},
+				}}},
+				// misparented annotation
+				{"p", RubyT, BC{C: []SerBox{
+					{"p", RubyBaseT, BC{C: []SerBox{}}},
+					{"rt", RubyTextT, BC{C: []SerBox{{"rt", TextT, BC{Text: "x"}}}}},
+				}}},
+			}}},
+		}}},
+	})
+}
+
 func TestStyles(t *testing.T) {
 	defer tu.CaptureLogs().AssertNoLogs(t)
 
@@ -495,6 +521,29 @@ func TestWhitespaces(t *testing.T) {
 	})
 }
 
+func TestBidiLevels(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	box := parseAndBuild(t, `<p style="direction: rtl">abc אבג <b>def</b></p>`+
+		`<p>abc <b style="direction: rtl; unicode-bidi: isolate">!</b> def</p>`)
+	type level struct {
+		text  string
+		level text.BidiLevel
+	}
+	var levels []level
+	for _, child := range Descendants(box) {
+		if textBox, ok := child.(*TextBox); ok {
+			levels = append(levels, level{textBox.TextS(), textBox.BidiLevel})
+		} else if InlineT.IsInstance(child) {
+			levels = append(levels, level{"<" + child.Box().ElementTag() + ">", child.Box().BidiLevel})
+		}
+	}
+	tu.AssertEqual(t, levels, []level{
+		{"abc", 2}, {" אבג ", 1}, {"<b>", 2}, {"def", 2},
+		{"abc ", 0}, {"<b>", 1}, {"!", 1}, {" def", 0},
+	})
+}
+
 type pageStyleData struct {
 	type_                    utils.PageElement
 	top, right, bottom, left pr.Float
@@ -38,6 +38,18 @@ type InlineBox struct {
 	BoxFields
 }
 
+type RubyBox struct {
+	InlineBox
+}
+
+type RubyBaseBox struct {
+	InlineBox
+}
+
+type RubyTextBox struct {
+	InlineBox
+}
+
 type TextBox struct {
 	InlineLevelBox
 	BoxFields
@@ -123,6 +135,13 @@ type PageBox struct {
 	CanvasBackground *Background
 	FixedBoxes       []Box
 	PageType         utils.PageElement
+
+	// PageCounter is the value of the "page" counter, printed
+	// in the margin boxes with PageCounterStyle, as PageLabel.
+	// The style defaults to decimal when the page number is not printed.
+	PageCounter      int
+	PageCounterStyle pr.CounterStyleID
+	PageLabel        string
 }
 
 func (pb *PageBox) Bleed() Bleed {
@@ -234,6 +253,21 @@ func NewInlineBox(style pr.ElementStyle, element *html.Node, pseudoType string,
 	return &out
 }
 
+func NewRubyBox(style pr.ElementStyle, element *html.Node, pseudoType string, children []Box) *RubyBox {
+	out := RubyBox{InlineBox: *NewInlineBox(style, element, pseudoType, children)}
+	return &out
+}
+
+func NewRubyBaseBox(style pr.ElementStyle, element *html.Node, pseudoType string, children []Box) *RubyBaseBox {
+	out := RubyBaseBox{InlineBox: *NewInlineBox(style, element, pseudoType, children)}
+	return &out
+}
+
+func NewRubyTextBox(style pr.ElementStyle, element *html.Node, pseudoType string, children []Box) *RubyTextBox {
+	out := RubyTextBox{InlineBox: *NewInlineBox(style, element, pseudoType, children)}
+	return &out
+}
+
 func NewTextBox(style pr.ElementStyle, element *html.Node, pseudoType string, text []rune) *TextBox {
 	if len(text) == 0 {
 		panic("NewTextBox called with empty text")
@@ -268,6 +302,11 @@ func (u InlineBox) RemoveDecoration(b *BoxFields, start, end bool) {
 
 func NewReplacedBox(style pr.ElementStyle, element *html.Node, pseudoType string, replacement images.Image) ReplacedBox {
 	out := ReplacedBox{BoxFields: newBoxFields(style, element, pseudoType, nil)}
+	if mode := style.GetWritingMode(); mode != "horizontal-tb" {
+		// the replaced content is laid out in the logical frame of the flow,
+		// but is displayed upright
+		replacement = images.NewVerticalImage(replacement, mode == "vertical-rl")
+	}
 	out.Replacement = replacement
 	return out
 }
@@ -93,8 +93,10 @@ func CreateAnonymousBox(box Box) Box {
 	box = AnonymousTableBoxes(box)
 	box = FlexBoxes(box)
 	box = GridBoxes(box)
+	box = RubyBoxes(box)
 	box = InlineInBlock(box)
 	box = BlockInInline(box)
+	box = ResolveBidiLevels(box)
 	return box
 }
 
@@ -146,6 +148,12 @@ func makeBox(style pr.ElementStyle, content []Box, element *utils.HTMLNode, pseu
 		b = NewGridBox(style, (*html.Node)(element), pseudoType, content)
 	case [2]string{"inline", "grid"}:
 		b = NewInlineGridBox(style, (*html.Node)(element), pseudoType, content)
+	case [2]string{"inline", "ruby"}:
+		b = NewRubyBox(style, (*html.Node)(element), pseudoType, content)
+	case [2]string{"ruby-base"}:
+		b = NewRubyBaseBox(style, (*html.Node)(element), pseudoType, content)
+	case [2]string{"ruby-text"}:
+		b = NewRubyTextBox(style, (*html.Node)(element), pseudoType, content)
 	case [2]string{"table-row"}:
 		b = NewTableRowBox(style, (*html.Node)(element), pseudoType, content)
 	case [2]string{"table-row-group"}:
@@ -1617,6 +1625,92 @@ func gridChildren(box Box, children []Box) []Box {
 	return children
 }
 
+// Remove and add boxes according to the ruby model.
+// See https://www.w3.org/TR/css-ruby-1/#box-fixup
+//
+// Once done, the children of a [RubyBox] alternate between a [RubyBaseBox]
+// and its [RubyTextBox], which may be empty.
+func RubyBoxes(box Box) Box {
+	if !ParentT.IsInstance(box) || box.Box().IsRunning() {
+		return box
+	}
+	// Do recursion.
+	children := make([]Box, len(box.Box().Children))
+	for i, child := range box.Box().Children {
+		children[i] = RubyBoxes(child)
+	}
+	box.Box().Children = rubyChildren(box, children)
+	return box
+}
+
+func isRubyInternal(box Box) bool { return RubyBaseT.IsInstance(box) || RubyTextT.IsInstance(box) }
+
+func rubyChildren(box Box, children []Box) []Box {
+	if !RubyT.IsInstance(box) {
+		// wrap misparented bases and annotations in anonymous ruby containers
+		var out, run []Box
+		flush := func() {
+			if len(run) != 0 {
+				ruby := RubyBoxAnonymousFrom(box, nil)
+				ruby.Children = rubyChildren(ruby, run)
+				out = append(out, ruby)
+				run = nil
+			}
+		}
+		for _, child := range children {
+			if isRubyInternal(child) {
+				run = append(run, child)
+			} else {
+				flush()
+				out = append(out, child)
+			}
+		}
+		flush()
+		return out
+	}
+
+	var (
+		out         []Box
+		pendingBase Box   // a base waiting for its annotation
+		anonymous   []Box // the content of an anonymous base
+	)
+	addBase := func(base Box) {
+		if pendingBase != nil {
+			out = append(out, pendingBase, RubyTextBoxAnonymousFrom(box, nil))
+		}
+		pendingBase = base
+	}
+	flushAnonymous := func() {
+		if len(anonymous) != 0 {
+			addBase(RubyBaseBoxAnonymousFrom(box, anonymous))
+			anonymous = nil
+		}
+	}
+	for _, child := range children {
+		switch {
+		case RubyTextT.IsInstance(child):
+			flushAnonymous()
+			if pendingBase == nil {
+				pendingBase = RubyBaseBoxAnonymousFrom(box, nil)
+			}
+			out = append(out, pendingBase, child)
+			pendingBase = nil
+		case RubyBaseT.IsInstance(child):
+			flushAnonymous()
+			addBase(child)
+		case len(anonymous) == 0 && isWhitespace(child, nil):
+			// white space between ruby segments is removed
+		default:
+			anonymous = append(anonymous, child)
+		}
+	}
+	flushAnonymous()
+	if pendingBase != nil {
+		out = append(out, pendingBase, RubyTextBoxAnonymousFrom(box, nil))
+	}
+	return out
+}
+
 // ProcessWhitespace executes the first part of "The 'white-space' processing model".
 // See https://www.w3.org/TR/CSS21/text.html#white-space-model
 // and https://drafts.csswg.org/css-text-3/#white-space-rules
@@ -2025,6 +2119,148 @@ func innerBlockInInline(box Box, skipStack tree.ResumeStack) (Box, Box, tree.Res
 	return box, blockLevelBox, resumeAt
 }
 
+// ResolveBidiLevels applies the Unicode Bidirectional Algorithm to the
+// inline content of each line box of [box], that is to each paragraph.
+//
+// The resolved levels are stored in the [BoxFields.BidiLevel] of the inline-level boxes :
+// text boxes are split so that their level is uniform, atomic inlines use the level of
+// U+FFFC, and inline boxes the lowest level of their content.
+// The boxes are then reordered line by line, during layout.
+//
+// See https://www.w3.org/TR/css-writing-modes-3/#text-direction
+func ResolveBidiLevels(box Box) Box {
+	for _, child := range box.Box().Children {
+		if line, ok := child.(*LineBox); ok {
+			resolveParagraphLevels(box, line)
+		}
+		ResolveBidiLevels(child)
+	}
+	return box
+}
+
+// objectReplacement stands for atomic inlines in a bidi paragraph
+const objectReplacement = '\ufffc'
+
+type bidiParagraph struct {
+	text   []rune
+	starts []int // for each in-flow inline-level box, in tree order
+	levels []text.BidiLevel
+
+	index int // when setting the levels
+}
+
+func resolveParagraphLevels(block Box, line *LineBox) {
+	style := block.Box().Style
+	rtl := style.GetDirection() == "rtl"
+	var p bidiParagraph
+	if ub := style.GetUnicodeBidi(); ub == "bidi-override" || ub == "isolate-override" {
+		// the override applies to the inline content of the block container
+		open, _ := text.BidiControls("bidi-override", rtl)
+		p.text = open
+	}
+	p.collect(line)
+
+	p.levels = text.ResolveBidiLevels(p.text, rtl, style.GetUnicodeBidi() == "plaintext")
+	for _, level := range p.levels {
+		if level != 0 {
+			p.setLevels(line)
+			return
+		}
+	}
+	// left to right paragraph : all the levels are zero
+}
+
+func (p *bidiParagraph) collect(box Box) {
+	for _, child := range box.Box().Children {
+		if !child.Box().IsInNormalFlow() {
+			continue
+		}
+		p.starts = append(p.starts, len(p.text))
+		switch child := child.(type) {
+		case *TextBox:
+			p.text = append(p.text, child.Text...)
+		case *InlineBox:
+			open, close := text.BidiControls(child.Style.GetUnicodeBidi(), child.Style.GetDirection() == "rtl")
+			p.text = append(p.text, open...)
+			p.starts[len(p.starts)-1] = len(p.text)
+			p.collect(child)
+			p.text = append(p.text, close...)
+		default: // atomic inlines, including ruby
+			p.text = append(p.text, objectReplacement)
+		}
+	}
+}
+
+// levelAt returns the level of an empty box, inserted at [start]
+func (p *bidiParagraph) levelAt(start int) text.BidiLevel {
+	if start < len(p.levels) {
+		if start > 0 {
+			return min(p.levels[start-1], p.levels[start])
+		}
+		return p.levels[start]
+	} else if start > 0 {
+		return p.levels[start-1]
+	}
+	return 0
+}
+
+// setLevels sets the levels of the children of [box], splitting the text boxes
+// at level changes, and returns the lowest level of its content.
+func (p *bidiParagraph) setLevels(box Box) (lowest text.BidiLevel, ok bool) {
+	var newChildren []Box
+	for _, child := range box.Box().Children {
+		if !child.Box().IsInNormalFlow() {
+			newChildren = append(newChildren, child)
+			continue
+		}
+		start, first := p.starts[p.index], len(newChildren)
+		p.index++
+		switch child := child.(type) {
+		case *TextBox:
+			levels := p.levels[start : start+len(child.Text)]
+			if len(levels) == 0 {
+				child.BidiLevel = p.levelAt(start)
+				newChildren = append(newChildren, child)
+				break
+			}
+			child.BidiLevel = levels[0]
+			runStart := 0
+			for i := 1; i <= len(levels); i++ {
+				if i < len(levels) && levels[i] == levels[runStart] {
+					continue
+				}
+				part := child
+				if runStart != 0 || i != len(levels) {
+					part = child.CopyWithText(child.Text[runStart:i])
+					part.BidiLevel = levels[runStart]
+					// only the extremities of the original box keep the collapsed spaces
+					part.LeadingCollapsibleSpace = runStart == 0 && child.LeadingCollapsibleSpace
+					part.TrailingCollapsibleSpace = i == len(levels) && child.TrailingCollapsibleSpace
+				}
+				newChildren = append(newChildren, part)
+				runStart = i
+			}
+		case *InlineBox:
+			var hasContent bool
+			child.BidiLevel, hasContent = p.setLevels(child)
+			if !hasContent {
+				child.BidiLevel = p.levelAt(start)
+			}
+			newChildren = append(newChildren, child)
+		default:
+			child.Box().BidiLevel = p.levels[start]
+			newChildren = append(newChildren, child)
+		}
+		for _, newChild := range newChildren[first:] {
+			if level := newChild.Box().BidiLevel; !ok || level < lowest {
+				lowest, ok = level, true
+			}
+		}
+	}
+	box.Box().Children = newChildren
+	return lowest, ok
+}
+
 // Set a “ViewportOverflow“ attribute on the box for the root element.
 //
 //	Like backgrounds, ``overflow`` on the root element must be propagated
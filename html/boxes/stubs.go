@@ -345,6 +345,68 @@ func (b *ReplacedBox) Box() *BoxFields { return &b.BoxFields }
 func (b ReplacedBox) Copy() Box        { return &b }
 func (ReplacedBox) isReplacedBox()     {}
 
+// Box for elements with ``display: ruby-base``
+type RubyBaseBoxITF interface {
+	InlineBoxITF
+	isRubyBaseBox()
+}
+
+func (RubyBaseBox) Type() BoxType      { return RubyBaseT }
+func (b *RubyBaseBox) Box() *BoxFields { return &b.BoxFields }
+func (b RubyBaseBox) Copy() Box        { return &b }
+func (RubyBaseBox) isRubyBaseBox()     {}
+func (RubyBaseBox) isInlineBox()       {}
+func (RubyBaseBox) isInlineLevelBox()  {}
+func (RubyBaseBox) isParentBox()       {}
+
+func RubyBaseBoxAnonymousFrom(parent Box, children []Box) *RubyBaseBox {
+	style := tree.ComputedFromCascaded(nil, nil, parent.Box().Style, nil)
+	out := NewRubyBaseBox(style, parent.Box().Element, parent.Box().PseudoType, children)
+	return out
+}
+
+// Box for elements with ``display: ruby``.
+// Its children are ruby bases, each one followed by its annotation,
+// and are laid out as a single unbreakable unit.
+type RubyBoxITF interface {
+	InlineBoxITF
+	isRubyBox()
+}
+
+func (RubyBox) Type() BoxType      { return RubyT }
+func (b *RubyBox) Box() *BoxFields { return &b.BoxFields }
+func (b RubyBox) Copy() Box        { return &b }
+func (RubyBox) isRubyBox()         {}
+func (RubyBox) isInlineBox()       {}
+func (RubyBox) isInlineLevelBox()  {}
+func (RubyBox) isParentBox()       {}
+
+func RubyBoxAnonymousFrom(parent Box, children []Box) *RubyBox {
+	style := tree.ComputedFromCascaded(nil, nil, parent.Box().Style, nil)
+	out := NewRubyBox(style, parent.Box().Element, parent.Box().PseudoType, children)
+	return out
+}
+
+// Box for elements with ``display: ruby-text``
+type RubyTextBoxITF interface {
+	InlineBoxITF
+	isRubyTextBox()
+}
+
+func (RubyTextBox) Type() BoxType      { return RubyTextT }
+func (b *RubyTextBox) Box() *BoxFields { return &b.BoxFields }
+func (b RubyTextBox) Copy() Box        { return &b }
+func (RubyTextBox) isRubyTextBox()     {}
+func (RubyTextBox) isInlineBox()       {}
+func (RubyTextBox) isInlineLevelBox()  {}
+func (RubyTextBox) isParentBox()       {}
+
+func RubyTextBoxAnonymousFrom(parent Box, children []Box) *RubyTextBox {
+	style := tree.ComputedFromCascaded(nil, nil, parent.Box().Style, nil)
+	out := NewRubyTextBox(style, parent.Box().Element, parent.Box().PseudoType, children)
+	return out
+}
+
 // Box for elements with ``display: table``
 type TableBoxITF interface {
 	BlockLevelBoxITF
@@ -517,6 +579,9 @@ const (
 	PageT
 	ParentT
 	ReplacedT
+	RubyBaseT
+	RubyT
+	RubyTextT
 	TableT
 	TableCaptionT
 	TableCellT
@@ -577,6 +642,12 @@ func (t BoxType) IsInstance(box BoxITF) bool {
 		_, isInstance = box.(ParentBoxITF)
 	case ReplacedT:
 		_, isInstance = box.(ReplacedBoxITF)
+	case RubyBaseT:
+		_, isInstance = box.(RubyBaseBoxITF)
+	case RubyT:
+		_, isInstance = box.(RubyBoxITF)
+	case RubyTextT:
+		_, isInstance = box.(RubyTextBoxITF)
 	case TableT:
 		_, isInstance = box.(TableBoxITF)
 	case TableCaptionT:
@@ -645,6 +716,12 @@ func (t BoxType) String() string {
 		return "ParentBox"
 	case ReplacedT:
 		return "ReplacedBox"
+	case RubyBaseT:
+		return "RubyBaseBox"
+	case RubyT:
+		return "RubyBox"
+	case RubyTextT:
+		return "RubyTextBox"
 	case TableT:
 		return "TableBox"
 	case TableCaptionT:
@@ -686,6 +763,9 @@ var (
 	_ MarginBoxITF           = (*MarginBox)(nil)
 	_ PageBoxITF             = (*PageBox)(nil)
 	_ ReplacedBoxITF         = (*ReplacedBox)(nil)
+	_ RubyBaseBoxITF         = (*RubyBaseBox)(nil)
+	_ RubyBoxITF             = (*RubyBox)(nil)
+	_ RubyTextBoxITF         = (*RubyTextBox)(nil)
 	_ TableBoxITF            = (*TableBox)(nil)
 	_ TableCaptionBoxITF     = (*TableCaptionBox)(nil)
 	_ TableCellBoxITF        = (*TableCellBox)(nil)
@@ -714,6 +794,12 @@ func (t BoxType) AnonymousFrom(parent Box, children []Box) Box {
 		return InlineGridBoxAnonymousFrom(parent, children)
 	case InlineTableT:
 		return InlineTableBoxAnonymousFrom(parent, children)
+	case RubyBaseT:
+		return RubyBaseBoxAnonymousFrom(parent, children)
+	case RubyT:
+		return RubyBoxAnonymousFrom(parent, children)
+	case RubyTextT:
+		return RubyTextBoxAnonymousFrom(parent, children)
 	case TableT:
 		return TableBoxAnonymousFrom(parent, children)
 	case TableCaptionT:
@@ -0,0 +1,113 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend/recorder"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/html/tree"
+	"github.com/benoitkugler/webrender/utils"
+)
+
+// printColors returns the print colors used to paint [doc]
+func printColors(doc Document) []parser.DeviceColor {
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+
+	var out []parser.DeviceColor
+	for _, page := range rec.DisplayList().Pages {
+		for _, cmd := range page.Commands {
+			if cmd.Op == recorder.OpSetColor && cmd.Device.Space != parser.SpaceRGB {
+				out = append(out, *cmd.Device)
+			}
+		}
+	}
+	return out
+}
+
+// printColorsOf returns the print colors used to paint [html]
+func printColorsOf(t *testing.T, html string) []parser.DeviceColor {
+	t.Helper()
+	return printColors(renderHTML(t, html, baseUrl, false))
+}
+
+func TestPrintColors(t *testing.T) {
+	colors := printColorsOf(t, `
+	<style>
+		div { width: 10px; height: 10px }
+		@spot-color test-document-gold { alternate-color: device-cmyk(0 0.2 0.8 0) }
+	</style>
+	<div style="background: device-cmyk(0.1 0.2 0.3 0.4)"></div>
+	<div style="background: spot(test-document-gold 40%)"></div>`)
+	if len(colors) != 2 {
+		t.Fatalf("expected 2 print colors, got %v", colors)
+	}
+	if c := colors[0]; c.Space != parser.SpaceCMYK || c.CMYK != [4]fl{0.1, 0.2, 0.3, 0.4} {
+		t.Fatalf("unexpected CMYK color %v", c)
+	}
+	if c := colors[1]; c.Space != parser.SpaceSeparation || c.Spot != "test-document-gold" || c.Tint != 0.4 {
+		t.Fatalf("unexpected spot color %v", c)
+	}
+}
+
+func TestSpotColorsScope(t *testing.T) {
+	const html = `<div style="width: 10px; height: 10px; background: spot(test-scoped)"></div>`
+
+	// the declaration of a previous document is not visible
+	printColorsOf(t, `<style>@spot-color test-scoped { alternate-color: red }</style>`+html)
+	if colors := printColorsOf(t, html); len(colors) != 0 {
+		t.Fatalf("unexpected print colors %v", colors)
+	}
+
+	// spot colors may be provided by the caller
+	doc, err := tree.NewHTML(utils.InputString(html), baseUrl, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.UAStyleSheet = tree.TestUAStylesheet
+	doc.SpotColors = parser.SpotColors{"test-scoped": {0, 1, 0, 0}}
+	colors := printColors(Render(doc, nil, false, fc))
+	if len(colors) != 1 || colors[0].Spot != "test-scoped" || colors[0].CMYK != [4]fl{0, 1, 0, 0} {
+		t.Fatalf("unexpected print colors %v", colors)
+	}
+}
+
+func TestShadePrintColors(t *testing.T) {
+	cmyk := parser.NewCMYKColor(0.2, 0, 0, 0.5)
+	for _, shaded := range []Color{darken(Color(cmyk)), lighten(Color(cmyk))} {
+		if shaded.Device.Space != parser.SpaceCMYK {
+			t.Fatalf("expected a CMYK color, got %v", shaded.Device)
+		}
+		// the approximation stays consistent with the device components
+		if rgb := parser.CMYKToRGB(shaded.Device.CMYK, 1); !colorNear(rgb, shaded.RGBA) {
+			t.Fatalf("inconsistent colors %v %v", rgb, shaded.RGBA)
+		}
+	}
+	if dark, light := darken(Color(cmyk)), lighten(Color(cmyk)); dark.Device.CMYK[3] <= 0.5 || light.Device.CMYK[3] >= 0.5 {
+		t.Fatalf("unexpected black components %v %v", dark.Device.CMYK, light.Device.CMYK)
+	}
+
+	spot := parser.NewSpotColor("test-gold", [4]fl{0, 0.2, 0.8, 0}, 0.75)
+	if light := lighten(Color(spot)); light.Device.Space != parser.SpaceSeparation || light.Device.Tint != 0.5 {
+		t.Fatalf("unexpected lighter spot color %v", light.Device)
+	}
+	if dark := darken(Color(spot)); dark.Device.Space != parser.SpaceCMYK {
+		t.Fatalf("unexpected darker spot color %v", dark.Device)
+	}
+
+	colors := printColorsOf(t, `<div style="width: 10px; height: 10px;
+		border: 4px inset device-cmyk(0.2 0 0 0.5)"></div>`)
+	if len(colors) != 4 {
+		t.Fatalf("expected 4 border colors, got %v", colors)
+	}
+	for _, c := range colors {
+		if c.Space != parser.SpaceCMYK {
+			t.Fatalf("unexpected border color %v", c)
+		}
+	}
+}
+
+func colorNear(c1, c2 parser.RGBA) bool {
+	near := func(a, b fl) bool { return a-b < 1e-3 && b-a < 1e-3 }
+	return near(c1.R, c2.R) && near(c1.G, c2.G) && near(c1.B, c2.B)
+}
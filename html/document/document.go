@@ -5,12 +5,14 @@ package document
 
 import (
 	"fmt"
+	"math"
 	"net/url"
 	"path"
 
 	"github.com/benoitkugler/webrender/logger"
 	mt "github.com/benoitkugler/webrender/matrix"
 	"github.com/benoitkugler/webrender/text"
+	drawText "github.com/benoitkugler/webrender/text/draw"
 	"github.com/benoitkugler/webrender/text/hyphen"
 
 	"github.com/benoitkugler/webrender/backend"
@@ -20,10 +22,11 @@ import (
 	"github.com/benoitkugler/webrender/html/layout"
 	"github.com/benoitkugler/webrender/html/tree"
 	"github.com/benoitkugler/webrender/utils"
+	"golang.org/x/net/html"
 )
 
 type (
-	Color = parser.RGBA
+	Color = parser.Color
 	Box   = bo.Box
 )
 
@@ -31,8 +34,34 @@ type fl = utils.Fl
 
 func toF(v pr.Dimension) fl { return fl(v.Value) }
 
-// Return the matrix for the CSS transform properties on this box (possibly nil).
+// Return the matrix for the CSS transform properties on this box (possibly nil),
+// combined with the mapping of the vertical writing modes.
 func getMatrix(box_ Box) (mt.Transform, bool) {
+	matrix, hasTransform := transformMatrix(box_)
+	flow := box_.Box().VerticalFlow
+	if flow == nil {
+		return matrix, hasTransform
+	}
+	out := verticalFlowMatrix(*flow)
+	if hasTransform {
+		out.RightMultBy(matrix)
+	}
+	return out, true
+}
+
+// verticalFlowMatrix maps the logical coordinates of [flow] to the page.
+func verticalFlowMatrix(flow bo.VerticalFlow) mt.Transform {
+	x, y, width := fl(flow.X), fl(flow.Y), fl(flow.Width)
+	if flow.Mode == "vertical-lr" {
+		// lines go from top to bottom, and blocks from left to right
+		return mt.New(0, 1, 1, 0, x-y, y-x)
+	}
+	// lines go from top to bottom, and blocks from right to left
+	return mt.New(0, 1, -1, 0, x+width+y, y-x)
+}
+
+// Return the matrix for the CSS transform properties on this box (possibly nil).
+func transformMatrix(box_ Box) (mt.Transform, bool) {
 	// "Transforms apply to block-level and atomic inline-level elements,
 	//  but do not apply to elements which may be split into
 	//  multiple inline-level boxes."
@@ -114,6 +143,8 @@ type Link struct {
 	// [x_min, y_min, x_max, y_max] in CSS
 	// pixels from the top-left of the page.
 	Rectangle [4]fl
+
+	element *html.Node // the element of the link, for the logical structure
 }
 
 type bookmarkData struct {
@@ -156,7 +187,7 @@ func gatherLinksAndBookmarks(box_ bo.Box, bookmarks *[]bookmarkData, links *[]Li
 			if linkType == "external" && isAttachment {
 				linkType = "attachment"
 			}
-			linkS := Link{Type: linkType, Target: target}
+			linkS := Link{Type: linkType, Target: target, element: box.Element}
 			if matrix != nil {
 				linkS.Rectangle = rectangleAabb(*matrix, posX, posY, width, height)
 			} else {
@@ -189,6 +220,9 @@ type anchors = map[string][2]fl
 type Page struct {
 	pageBox *bo.PageBox
 
+	// shared by the pages of a document
+	structureIDs structureIDs
+
 	// The `dict` mapping each anchor name to its target, an
 	// `(x, y)` point in CSS pixels from the top-left of the page.
 	anchors anchors
@@ -208,6 +242,14 @@ type Page struct {
 
 	// The page height, including margins, in CSS pixels.
 	Height fl
+
+	// the configuration used during layout, which may
+	// differ between the pages of merged documents
+	fontconfig text.FontConfiguration
+
+	// the font report of the page, saved
+	// when its boxes are released
+	fonts *FontReport
 }
 
 // newPage post-process a laid out `PageBox`.
@@ -239,11 +281,18 @@ func (d Page) Paint(dst backend.Page, fc text.FontConfiguration, leftX, topY, sc
 			dst.Rectangle(0, 0, d.Width, d.Height)
 			dst.State().Clip(false)
 		}
+		ids := d.structureIDs
+		if ids == nil {
+			ids = make(structureIDs)
+		}
 		ctx := drawContext{
 			dst:               dst,
+			page:              dst,
 			fonts:             fc,
+			structure:         &structure{page: dst, ids: ids},
 			hyphenCache:       make(map[text.HyphenDictKey]hyphen.Hyphener),
 			strutLayoutsCache: make(map[text.StrutLayoutKey][2]pr.Float),
+			colorFonts:        make(drawText.ColorFonts),
 		}
 		ctx.drawPage(d.pageBox)
 	})
@@ -276,32 +325,134 @@ type Document struct {
 func Render(html *tree.HTML, stylesheets []tree.CSS, presentationalHints bool, fontConfig text.FontConfiguration) Document {
 	pageBoxes := layout.Layout(html, stylesheets, presentationalHints, fontConfig)
 	pages := make([]Page, len(pageBoxes))
+	ids := make(structureIDs)
 	for i, pageBox := range pageBoxes {
 		pages[i] = newPage(pageBox)
+		pages[i].structureIDs = ids
+		pages[i].fontconfig = fontConfig
 	}
 	return Document{Pages: pages, Metadata: html.GetMetadata(), urlFetcher: html.UrlFetcher, fontconfig: fontConfig}
 }
 
-// Take a subset of the pages.
+// SelectPages returns a document made of the pages at the given
+// 0-based indexes, in the given order. Invalid indexes are ignored.
+//
+// Links to anchors defined on pages not selected are dropped with a warning,
+// as are the bookmarks of these pages.
+//
+// For instance, the odd-numbered pages are selected with
+//
+//	var indexes []int
+//	for i := 0; i < len(doc.Pages); i += 2 {
+//		indexes = append(indexes, i)
+//	}
+//	oddPages := doc.SelectPages(indexes)
+func (d Document) SelectPages(indexes []int) Document {
+	out := d
+	out.Pages = nil
+	for _, index := range indexes {
+		if index >= 0 && index < len(d.Pages) {
+			out.Pages = append(out.Pages, d.Pages[index])
+		}
+	}
+	return out
+}
+
+// PageRange returns a document made of the pages with 0-based indexes
+// in [start, end). The range is clamped to the valid indexes.
+func (d Document) PageRange(start, end int) Document {
+	start, end = max(start, 0), min(end, len(d.Pages))
+	out := d
+	out.Pages = nil
+	if start < end {
+		out.Pages = append([]Page(nil), d.Pages[start:end]...)
+	}
+	return out
+}
+
+// Merge concatenates the pages of the given documents.
+// The metadata of the first document is used, except for the
+// attachments, which are merged.
 //
-// Examples:
-// Write two PDF files for odd-numbered and even-numbered pages:
-//     document.Copy(document.pages[::2]).writePdf("oddPages.pdf")
-//     document.Copy(document.pages[1::2]).writePdf("evenPages.pdf")
-// Combine multiple documents into one PDF file, using metadata from the first:
-//		var allPages []Page
-// 		for _, doc := range documents {
-//		 	for _, p := range doc.pages {
-//		 		allPages = append(allPages, p)
-//		 	}
-//		 }
-//		documents[0].Copy(allPages).writePdf("combined.pdf")
-// func (d Document) Copy(pages []Page, all bool) Document {
-// 	if all {
-// 		pages = d.Pages
-// 	}
-// 	return Document{Pages: pages, Metadata: d.Metadata, urlFetcher: d.urlFetcher}
-// }
+// Anchors defined by several documents are renamed, so that
+// the internal links of each document still point to its own anchors.
+// Internal links to an anchor missing in their document are removed with a warning.
+func Merge(documents ...Document) Document {
+	if len(documents) == 0 {
+		return Document{}
+	}
+	out := documents[0]
+	out.Pages = nil
+	out.Metadata.Attachments = nil
+
+	ids := make(structureIDs) // share the IDs between all the pages
+	defined := utils.NewSet() // anchors defined by the previous documents
+	for i, doc := range documents {
+		// rename the anchors already used
+		renames := make(map[string]string)
+		own := utils.NewSet() // anchors defined by this document
+		for _, page := range doc.Pages {
+			for name := range page.anchors {
+				own.Add(name)
+				if _, has := renames[name]; has || !defined.Has(name) {
+					continue
+				}
+				newName := fmt.Sprintf("%s-%d", name, i+1)
+				for j := 2; defined.Has(newName); j++ {
+					newName = fmt.Sprintf("%s-%d-%d", name, i+1, j)
+				}
+				renames[name] = newName
+			}
+		}
+
+		for _, page := range doc.Pages {
+			if page.fontconfig == nil {
+				page.fontconfig = doc.fontconfig
+			}
+			page.structureIDs = ids
+			page.anchors, page.links = renameAnchors(page.anchors, page.links, own, renames)
+			out.Pages = append(out.Pages, page)
+		}
+		for _, page := range doc.Pages {
+			for name := range page.anchors {
+				if newName, has := renames[name]; has {
+					name = newName
+				}
+				defined.Add(name)
+			}
+		}
+
+		out.Metadata.Attachments = append(out.Metadata.Attachments, doc.Metadata.Attachments...)
+	}
+	return out
+}
+
+// renameAnchors returns copies of [anchors] and [links], where the
+// anchor names and the internal link targets in [renames] are replaced.
+// Internal links to an anchor not in [defined] are removed.
+func renameAnchors(anchors_ anchors, links []Link, defined utils.Set, renames map[string]string) (anchors, []Link) {
+	newAnchors := make(anchors, len(anchors_))
+	for name, pos := range anchors_ {
+		if newName, has := renames[name]; has {
+			name = newName
+		}
+		newAnchors[name] = pos
+	}
+	newLinks := make([]Link, 0, len(links))
+	for _, link := range links {
+		if link.Type == "internal" {
+			if !defined.Has(link.Target) {
+				logger.WarningLogger.Printf("No anchor #%s for internal URI reference\n", link.Target)
+				continue
+			}
+			if newTarget, has := renames[link.Target]; has {
+				link.Target = newTarget
+			}
+		}
+		newLinks = append(newLinks, link)
+	}
+	return newAnchors, newLinks
+}
 
 // Resolve internal hyperlinks.
 // Links to a missing anchor are removed with a warning.
@@ -396,12 +547,18 @@ func (d Document) makeBookmarkTree() []backend.BookmarkNode {
 	return root
 }
 
-// Include hyperlinks in current PDF page.
-func (d Document) addHyperlinks(links []Link, context backend.Page, scale mt.Transform) {
+// Include hyperlinks in current PDF page, in the structure
+// elements of their links.
+func (d Document) addHyperlinks(links []Link, context backend.Page, scale mt.Transform, ids structureIDs) {
+	if ids == nil {
+		ids = make(structureIDs)
+	}
+	structure := &structure{page: context, ids: ids}
 	for _, link := range links {
 		linkType, linkTarget, rectangle := link.Type, link.Target, link.Rectangle
 		xMin, yMin := scale.Apply(rectangle[0], rectangle[1])
 		xMax, yMax := scale.Apply(rectangle[2], rectangle[3])
+		structure.enter(link.element)
 		if linkType == "external" {
 			context.AddExternalLink(xMin, yMin, xMax, yMax, linkTarget)
 		} else if linkType == "internal" {
@@ -411,6 +568,7 @@ func (d Document) addHyperlinks(links []Link, context backend.Page, scale mt.Tra
 			context.AddFileAnnotation(xMin, yMin, xMax, yMax, linkTarget)
 		}
 	}
+	structure.enter(nil)
 }
 
 func (d *Document) scaleAnchors(anchors []backend.Anchor, matrix mt.Transform) {
@@ -484,27 +642,95 @@ func (d *Document) Write(target backend.Document, zoom pr.Fl, attachments []back
 	logger.ProgressLogger.Println("Step 6 - Drawing pages")
 
 	for i, page := range d.Pages {
-		pageWidth := scale * (page.Width + fl(page.Bleed.Left) + fl(page.Bleed.Right))
-		pageHeight := scale * (page.Height + fl(page.Bleed.Top) + fl(page.Bleed.Bottom))
-		left := -scale * fl(page.Bleed.Left)
-		top := -scale * fl(page.Bleed.Top)
-		right := left + pageWidth
-		bottom := top + pageHeight
-
-		outputPage := target.AddPage(left/scale, top/scale, (right-left)/scale, (bottom-top)/scale)
-		outputPage.State().Transform(mt.New(1, 0, 0, -1, 0, page.Height*scale))
-		page.Paint(outputPage, d.fontconfig, 0, 0, scale, false)
+		outputPage := d.paintPage(target, page, scale)
 
 		// Draw from the top-left corner
 		matrix := mt.New(scale, 0, 0, -scale, 0, page.Height*scale)
 
-		d.addHyperlinks(pagedLinks[i], outputPage, matrix)
+		d.addHyperlinks(pagedLinks[i], outputPage, matrix, page.structureIDs)
+		d.scaleAnchors(pagedAnchors[i], matrix)
+	}
+
+	target.CreateAnchors(pagedAnchors)
+
+	d.writeMetadata(target, attachments)
+}
+
+// WriteStream is the same as calling [Render] and [Document.Write], except that each page
+// is painted as soon as it is final, and its boxes released, so that
+// only the pages waiting to be painted are kept in memory.
+// See [layout.LayoutStream] for the pages whose painting is delayed.
+//
+// The links, anchors, bookmarks and meta-data are added to the target
+// once every page has been painted.
+//
+// Since the boxes are not available afterwards, the report of the fonts
+// used, as described in [Document.FontReport], is returned.
+func WriteStream(html *tree.HTML, stylesheets []tree.CSS, presentationalHints bool, fontConfig text.FontConfiguration,
+	target backend.Document, zoom pr.Fl, attachments []backend.Attachment,
+) FontReport {
+	// 0.75 = 72 PDF point per inch / 96 CSS pixel per inch
+	scale := zoom * 0.75
+
+	d := Document{Metadata: html.GetMetadata(), urlFetcher: html.UrlFetcher, fontconfig: fontConfig}
+	ids := make(structureIDs)
+	var outputPages []backend.Page
+	layout.LayoutStream(html, stylesheets, presentationalHints, fontConfig, func(pageBox *bo.PageBox) {
+		page := newPage(pageBox)
+		page.structureIDs = ids
+		page.fontconfig = fontConfig
+		outputPages = append(outputPages, d.paintPage(target, page, scale))
+
+		// release the boxes, only keeping what is needed for the page labels
+		// and the font report
+		fonts := page.fontReport(len(d.Pages))
+		page.fonts = &fonts
+		page.pageBox = &bo.PageBox{PageCounter: pageBox.PageCounter, PageCounterStyle: pageBox.PageCounterStyle, PageLabel: pageBox.PageLabel}
+		d.Pages = append(d.Pages, page)
+	})
+
+	// Links and anchors
+	pagedLinks, pagedAnchors := d.resolveLinks()
+
+	d.embedFileAnnotations(pagedLinks, target)
+
+	for i, page := range d.Pages {
+		matrix := mt.New(scale, 0, 0, -scale, 0, page.Height*scale)
+		d.addHyperlinks(pagedLinks[i], outputPages[i], matrix, page.structureIDs)
 		d.scaleAnchors(pagedAnchors[i], matrix)
-		setMediaBoxes(page.Bleed, [4]fl{left, top, right, bottom}, outputPage)
 	}
 
 	target.CreateAnchors(pagedAnchors)
 
+	d.writeMetadata(target, attachments)
+
+	return d.FontReport()
+}
+
+// paintPage adds a new page to [target], with its content and media boxes.
+func (d *Document) paintPage(target backend.Document, page Page, scale fl) backend.Page {
+	pageWidth := scale * (page.Width + fl(page.Bleed.Left) + fl(page.Bleed.Right))
+	pageHeight := scale * (page.Height + fl(page.Bleed.Top) + fl(page.Bleed.Bottom))
+	left := -scale * fl(page.Bleed.Left)
+	top := -scale * fl(page.Bleed.Top)
+	right := left + pageWidth
+	bottom := top + pageHeight
+
+	outputPage := target.AddPage(left/scale, top/scale, (right-left)/scale, (bottom-top)/scale)
+	outputPage.State().Transform(mt.New(1, 0, 0, -1, 0, page.Height*scale))
+	fc := page.fontconfig
+	if fc == nil {
+		fc = d.fontconfig
+	}
+	page.Paint(outputPage, fc, 0, 0, scale, false)
+
+	setMediaBoxes(page.Bleed, [4]fl{left, top, right, bottom}, outputPage)
+	return outputPage
+}
+
+// writeMetadata adds the attachments, bookmarks, page labels
+// and document information.
+func (d *Document) writeMetadata(target backend.Document, attachments []backend.Attachment) {
 	logger.ProgressLogger.Println("Step 7 - Adding PDF metadata")
 
 	// embedded files
@@ -520,6 +746,8 @@ func (d *Document) Write(target backend.Document, zoom pr.Fl, attachments []back
 	// Set bookmarks
 	target.SetBookmarks(d.makeBookmarkTree())
 
+	target.SetPageLabels(d.pageLabels())
+
 	// Set document information
 	target.SetTitle(d.Metadata.Title)
 	target.SetDescription(d.Metadata.Description)
@@ -531,6 +759,45 @@ func (d *Document) Write(target backend.Document, zoom pr.Fl, attachments []back
 	target.SetDateModification(d.Metadata.Modified)
 }
 
+// nativeLabelStyles are the counter styles supported by [backend.PageLabel],
+// with the maximum value for which the numbering is the same.
+var nativeLabelStyles = map[string]struct {
+	style backend.PageLabelStyle
+	max   int
+}{
+	"decimal":     {backend.LabelDecimal, math.MaxInt},
+	"lower-roman": {backend.LabelLowerRoman, 3999},
+	"upper-roman": {backend.LabelUpperRoman, 3999},
+	"lower-alpha": {backend.LabelLowerAlpha, 26},
+	"lower-latin": {backend.LabelLowerAlpha, 26},
+	"upper-alpha": {backend.LabelUpperAlpha, 26},
+	"upper-latin": {backend.LabelUpperAlpha, 26},
+}
+
+// pageLabels returns the labels matching the page numbers printed
+// on the pages, grouping the consecutive pages with the same numbering.
+// The pages numbered with other counter styles are labeled with
+// their printed value.
+func (d Document) pageLabels() []backend.PageLabel {
+	var out []backend.PageLabel
+	for i, page := range d.Pages {
+		box := page.pageBox
+		native, ok := nativeLabelStyles[box.PageCounterStyle.Name]
+		if !ok || box.PageCounterStyle.Type != "" || box.PageCounter < 1 || box.PageCounter > native.max {
+			out = append(out, backend.PageLabel{PageIndex: i, Prefix: box.PageLabel})
+			continue
+		}
+		if L := len(out); L != 0 {
+			last := out[L-1]
+			if last.Style == native.style && last.Start+i-last.PageIndex == box.PageCounter {
+				continue // same range
+			}
+		}
+		out = append(out, backend.PageLabel{PageIndex: i, Style: native.style, Start: box.PageCounter})
+	}
+	return out
+}
+
 func (d *Document) embedFileAnnotations(pagedLinks [][]Link, context backend.Document) {
 	// A single link can be split in multiple regions.
 	for _, rl := range pagedLinks {
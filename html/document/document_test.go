@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
 	"github.com/benoitkugler/webrender/html/tree"
 	"github.com/benoitkugler/webrender/utils"
 	tu "github.com/benoitkugler/webrender/utils/testutils"
@@ -321,6 +322,12 @@ func TestLinks(t *testing.T) {
 			gotLinksByPage = append(gotLinksByPage, p.links)
 			gotAnchorsByPage = append(gotAnchorsByPage, p.anchors)
 		}
+		// the elements are only used for the logical structure
+		for _, links := range append(gotLinksByPage, resolvedLinks...) {
+			for i := range links {
+				links[i].element = nil
+			}
+		}
 		if !reflect.DeepEqual(gotLinksByPage, expectedLinksByPage) {
 			t.Fatalf("unexpected gotLinksByPage: %v", gotLinksByPage)
 		}
@@ -537,3 +544,101 @@ func TestLinks(t *testing.T) {
 		[][]backend.Anchor{nil},
 		"", nil, false)
 }
+
+func TestSelectAndMerge(t *testing.T) {
+	source := `
+	<style>@page { size: 100px } body { margin: 0 } h1 { break-before: page; font-size: 10px; margin: 0 }</style>
+	<h1 id="first">First</h1><a href="#second">To second</a>
+	<h1 id="second">Second</h1><a href="#first">To first</a>`
+	doc := renderHTML(t, source, baseUrl, false)
+	if len(doc.Pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(doc.Pages))
+	}
+
+	// the link on the first page points to a page not selected
+	capt := tu.CaptureLogs()
+	first := doc.PageRange(0, 1)
+	links, anchors := first.resolveLinks()
+	if len(first.Pages) != 1 || len(links[0]) != 0 || len(anchors[0]) != 1 {
+		t.Fatalf("unexpected links %v and anchors %v", links, anchors)
+	}
+	if logs := capt.Logs(); len(logs) != 1 || !strings.Contains(logs[0], "No anchor #second") {
+		t.Fatalf("unexpected logs %v", logs)
+	}
+
+	reversed := doc.SelectPages([]int{1, 0, 5})
+	if len(reversed.Pages) != 2 || len(reversed.makeBookmarkTree()) != 2 {
+		t.Fatal("unexpected selection")
+	}
+
+	merged := Merge(doc, renderHTML(t, source, baseUrl, false))
+	links, anchors = merged.resolveLinks()
+	if len(merged.Pages) != 4 {
+		t.Fatalf("expected 4 pages, got %d", len(merged.Pages))
+	}
+	var names []string
+	for _, pageAnchors := range anchors {
+		for _, anchor := range pageAnchors {
+			names = append(names, anchor.Name)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"first", "second", "first-2", "second-2"}) {
+		t.Fatalf("unexpected anchors %v", names)
+	}
+	var targets []string
+	for _, pageLinks := range links {
+		for _, link := range pageLinks {
+			targets = append(targets, link.Target)
+		}
+	}
+	if !reflect.DeepEqual(targets, []string{"second", "first", "second-2", "first-2"}) {
+		t.Fatalf("unexpected links %v", targets)
+	}
+	// the source document is not modified
+	if _, has := doc.Pages[0].anchors["first"]; !has {
+		t.Fatal("source document modified")
+	}
+	if len(merged.makeBookmarkTree()) != 4 {
+		t.Fatal("unexpected bookmarks")
+	}
+
+	// a link to an anchor only defined by another document is removed
+	other := renderHTML(t, `<a href="#first">To first</a>`, baseUrl, false)
+	capt = tu.CaptureLogs()
+	merged = Merge(doc, other)
+	if logs := capt.Logs(); len(logs) != 1 || !strings.Contains(logs[0], "No anchor #first") {
+		t.Fatalf("unexpected logs %v", logs)
+	}
+	links, _ = merged.resolveLinks()
+	if len(links[2]) != 0 {
+		t.Fatalf("unexpected links %v", links[2])
+	}
+}
+
+func TestWriteStream(t *testing.T) {
+	for _, source := range []string{
+		`<style>@page { size: 100px } h1 { break-before: page; font-size: 10px }</style>
+		<h1 id="first">First</h1><a href="#second">To second</a>
+		<h1 id="second">Second</h1><a href="#first">To first</a><a href="#missing">Missing</a>`,
+		// the total number of pages delays the painting
+		`<style>@page { size: 100px; @bottom-center { content: counter(page) "/" counter(pages) } }
+		div { height: 90px }</style>
+		<div></div><div></div><div></div>`,
+		// the font report is returned
+		`<style>@page { size: 100px } p { break-after: page }</style><p>Text</p><p>Missing 中</p>`,
+	} {
+		doc, err := tree.NewHTML(utils.InputString(source), baseUrl, nil, "")
+		tu.AssertNoErr(t, err)
+		doc.UAStyleSheet = tree.TestUAStylesheet // fakeHTML
+
+		expected := recorder.NewDocument()
+		document := Render(doc, nil, false, fc)
+		document.Write(expected, 1, nil)
+
+		got := recorder.NewDocument()
+		report := WriteStream(doc, nil, false, fc, got, 1, nil)
+
+		tu.AssertEqual(t, got.DisplayList(), expected.DisplayList())
+		tu.AssertEqual(t, report, document.FontReport())
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"unicode"
 
 	"github.com/benoitkugler/webrender/backend"
 	"github.com/benoitkugler/webrender/css/parser"
 	"github.com/benoitkugler/webrender/html/tree"
 	"github.com/benoitkugler/webrender/logger"
 	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/svg"
 	"github.com/benoitkugler/webrender/text"
 	drawText "github.com/benoitkugler/webrender/text/draw"
 	"github.com/benoitkugler/webrender/text/hyphen"
@@ -155,23 +157,58 @@ func rgb2hsv(red, green, blue fl) (h, s, c fl) {
 }
 
 // Return a darker color.
+// Spot colors can't be darker than their full tint, so that
+// they are darkened as their CMYK equivalent.
 func darken(color Color) Color {
-	hue, saturation, value := rgb2hsv(color.R, color.G, color.B)
-	value /= 1.5
-	saturation /= 1.25
-	r, g, b := hsv2rgb(hue, saturation, value)
-	return Color{R: r, G: g, B: b, A: color.A}
+	if color.Device.Space == parser.SpaceSeparation {
+		color = spotToCMYK(color)
+	}
+	return shade(color, func(hue, saturation, value fl) (fl, fl, fl) {
+		return hue, saturation / 1.25, value / 1.5
+	})
 }
 
 // Return a lighter color.
+// Spot colors are lightened by reducing their tint.
 func lighten(color Color) Color {
-	hue, saturation, value := rgb2hsv(color.R, color.G, color.B)
-	value = 1 - (1-value)/1.5
-	if saturation != 0 {
-		saturation = 1 - (1-saturation)/1.25
+	if device := color.Device; device.Space == parser.SpaceSeparation {
+		out := parser.NewSpotColor(device.Spot, device.CMYK, device.Tint/1.5)
+		out.RGBA.A = color.RGBA.A
+		return out
+	}
+	return shade(color, func(hue, saturation, value fl) (fl, fl, fl) {
+		value = 1 - (1-value)/1.5
+		if saturation != 0 {
+			saturation = 1 - (1-saturation)/1.25
+		}
+		return hue, saturation, value
+	})
+}
+
+// shade applies [fn] to the HSV components of [color].
+// The CMYK components are transformed through their own RGB approximation,
+// and converted back so that the color stays in the CMYK color space.
+func shade(color Color, fn func(hue, saturation, value fl) (fl, fl, fl)) Color {
+	apply := func(rgb parser.RGBA) parser.RGBA {
+		r, g, b := hsv2rgb(fn(rgb2hsv(rgb.R, rgb.G, rgb.B)))
+		return parser.RGBA{R: r, G: g, B: b, A: rgb.A}
+	}
+	out := Color{Type: parser.ColorRGBA, RGBA: apply(color.RGBA)}
+	if color.Device.Space == parser.SpaceCMYK {
+		cmyk := parser.RGBToCMYK(apply(parser.CMYKToRGB(color.Device.CMYK, 1)))
+		out.Device = parser.DeviceColor{Space: parser.SpaceCMYK, CMYK: cmyk}
 	}
-	r, g, b := hsv2rgb(hue, saturation, value)
-	return Color{R: r, G: g, B: b, A: color.A}
+	return out
+}
+
+// spotToCMYK returns the CMYK color obtained by
+// applying the tint of [color] to its alternate.
+func spotToCMYK(color Color) Color {
+	device := color.Device
+	out := parser.NewCMYKColor(device.CMYK[0]*device.Tint, device.CMYK[1]*device.Tint,
+		device.CMYK[2]*device.Tint, device.CMYK[3]*device.Tint)
+	out.RGBA.A = color.RGBA.A
+	return out
 }
 
 // text layout is needed by SVG images
@@ -179,10 +216,15 @@ var _ text.TextLayoutContext = drawContext{}
 
 type drawContext struct {
 	dst   backend.Canvas
+	page  backend.Page // the page containing [dst]
 	fonts text.FontConfiguration
 
+	// structure is nil when the logical structure is not needed
+	structure *structure
+
 	hyphenCache       map[text.HyphenDictKey]hyphen.Hyphener
 	strutLayoutsCache map[text.StrutLayoutKey][2]pr.Float
+	colorFonts        drawText.ColorFonts
 }
 
 func (ctx drawContext) Fonts() text.FontConfiguration { return ctx.fonts }
@@ -199,10 +241,13 @@ func (ctx drawContext) StrutLayoutsCache() map[text.StrutLayoutKey][2]pr.Float {
 func (ctx drawContext) drawPage(page *bo.PageBox) {
 	marks := page.Style.GetMarks()
 	stackingContext := NewStackingContextFromPage(page)
+	ctx.structure.beginArtifact()
 	ctx.drawBackground(stackingContext.box.Box().Background, false, page.Bleed(), marks)
 	ctx.drawBackground(page.CanvasBackground, false, bo.Bleed{}, pr.Marks{})
 	ctx.drawBorder(page)
+	ctx.structure.endArtifact()
 	ctx.drawStackingContext(stackingContext)
+	ctx.structure.enter(nil)
 }
 
 // Draw a “stackingContext“ on “context“.
@@ -212,6 +257,13 @@ func (ctx drawContext) drawStackingContext(stackingContext StackingContext) {
 		box_ := stackingContext.box
 		box := box_.Box()
 
+		// page margin boxes are not part of the logical structure
+		if bo.MarginT.IsInstance(box_) {
+			ctx.structure.enter(nil)
+			ctx.structure.beginArtifact()
+			defer ctx.structure.endArtifact()
+		}
+
 		// apply the viewport_overflow to the html box, see #35
 		if box.IsForRootElement && (stackingContext.page.Style.GetOverflow() != "visible") {
 			roundedBoxPath(
@@ -245,8 +297,8 @@ func (ctx drawContext) drawStackingContext(stackingContext StackingContext) {
 		originalDst := ctx.dst
 		opacity := fl(box.Style.GetOpacity())
 		if opacity < 1 { // we draw all the following to a separate group
-			ctx.dst = ctx.dst.NewGroup(pr.Fl(box.BorderBoxX()), pr.Fl(box.BorderBoxY()),
-				pr.Fl(box.BorderWidth()), pr.Fl(box.BorderHeight()))
+			ink := inkOverflow(box_)
+			ctx.dst = ctx.dst.NewGroup(ink[0], ink[1], ink[2], ink[3])
 		}
 
 		if mat, ok := getMatrix(box_); ok {
@@ -265,8 +317,7 @@ func (ctx drawContext) drawStackingContext(stackingContext StackingContext) {
 			bo.InlineBlockT.IsInstance(box_) || bo.TableCellT.IsInstance(box_) ||
 			bo.FlexContainerT.IsInstance(box_) || bo.ReplacedT.IsInstance(box_) {
 			// The canvas background was removed by layoutBackgrounds
-			ctx.drawBackgroundDefaut(box_.Box().Background)
-			ctx.drawBorder(box_)
+			ctx.drawDecoration(box_)
 		}
 
 		ctx.dst.OnNewStack(func() {
@@ -287,10 +338,11 @@ func (ctx drawContext) drawStackingContext(stackingContext StackingContext) {
 			// Point 4
 			for _, block := range stackingContext.blockLevelBoxes {
 				if box_, ok := block.(bo.TableBoxITF); ok {
+					ctx.structure.beginArtifact()
 					ctx.drawTable(box_.Table())
+					ctx.structure.endArtifact()
 				} else {
-					ctx.drawBackgroundDefaut(block.Box().Background)
-					ctx.drawBorder(block)
+					ctx.drawDecoration(block)
 				}
 			}
 
@@ -301,11 +353,13 @@ func (ctx drawContext) drawStackingContext(stackingContext StackingContext) {
 
 			// Point 6
 			if bo.InlineT.IsInstance(box_) {
+				ctx.structure.enter(box.Element)
 				ctx.drawInlineLevel(stackingContext.page, box_, 0, "clip", pr.TaggedString{Tag: pr.None})
 			}
 
 			// Point 7
 			for _, block := range append([]Box{box_}, stackingContext.blocksAndCells...) {
+				ctx.structure.enter(block.Box().Element)
 				if blockRep, ok := block.(bo.ReplacedBoxITF); ok {
 					ctx.drawReplacedbox(blockRep)
 				} else if children := block.Box().Children; len(children) != 0 {
@@ -393,6 +447,22 @@ func reversed(in []bo.BackgroundLayer) []bo.BackgroundLayer {
 	return out
 }
 
+// drawDecoration draws the background and the borders of [box_],
+// which are not part of the logical structure, and adds its form field.
+func (ctx drawContext) drawDecoration(box_ Box) {
+	box := box_.Box()
+	hasBorders := box.BorderTopWidth != 0 || box.BorderRightWidth != 0 || box.BorderBottomWidth != 0 || box.BorderLeftWidth != 0
+	// see drawBorder for the column rules
+	hasColumns := box.Style.GetColumnWidth().S != "auto" || box.Style.GetColumnCount().String != "auto"
+	if box.Background != nil || hasBorders || hasColumns {
+		ctx.structure.beginArtifact()
+		ctx.drawBackgroundDefaut(box.Background)
+		ctx.drawBorder(box_)
+		ctx.structure.endArtifact()
+	}
+	ctx.drawFormField(box_)
+}
+
 func (ctx drawContext) drawBackgroundDefaut(bg *bo.Background) {
 	ctx.drawBackground(bg, true, bo.Bleed{}, pr.Marks{})
 }
@@ -415,9 +485,9 @@ func (ctx drawContext) drawBackground(bg *bo.Background, clipBox bool, bleed bo.
 		}
 
 		// Background color
-		if bg.Color.A > 0 {
+		if bg.Color.RGBA.A > 0 {
 			ctx.dst.OnNewStack(func() {
-				ctx.dst.State().SetColorRgba(bg.Color, false)
+				ctx.dst.State().SetColor(bg.Color, false)
 				paintingArea := bg.Layers[len(bg.Layers)-1].PaintingArea
 				ctx.dst.Rectangle(paintingArea.Unpack())
 				ctx.dst.State().Clip(false)
@@ -604,7 +674,7 @@ func (ctx drawContext) drawBorder(box_ Box) {
 					ctx.drawRectBorder(borderBox, borderWidths,
 						box.Style.GetColumnRuleStyle(), styledColor(
 							box.Style.GetColumnRuleStyle(),
-							tree.ResolveColor(box.Style, pr.PColumnRuleColor).RGBA, left))
+							parser.Color(tree.ResolveColor(box.Style, pr.PColumnRuleColor)), left))
 				})
 			}
 		}
@@ -637,9 +707,9 @@ func (ctx drawContext) drawBorder(box_ Box) {
 		stylesSet = utils.NewSet()
 	)
 	for i, side := range sides {
-		colors[i] = tree.ResolveColor(box.Style, pr.PBorderBottomColor+side*5).RGBA
+		colors[i] = parser.Color(tree.ResolveColor(box.Style, pr.PBorderBottomColor+side*5))
 		colorsSet[colors[i]] = true
-		if colors[i].A != 0 {
+		if colors[i].RGBA.A != 0 {
 			styles[i] = box.Style.Get((pr.PBorderBottomStyle + side*5).Key()).(pr.String)
 		}
 		stylesSet.Add(string(styles[i]))
@@ -1122,18 +1192,18 @@ func clipBorderSegment(context backend.Canvas, style pr.String, width fl, side p
 
 func (ctx drawContext) drawRoundedBorder(box *bo.BoxFields, style pr.String, colors [2]Color) {
 	if style == "ridge" || style == "groove" {
-		ctx.dst.State().SetColorRgba(colors[0], false)
+		ctx.dst.State().SetColor(colors[0], false)
 		roundedBoxPath(ctx.dst, box.RoundedPaddingBox())
 		roundedBoxPath(ctx.dst, box.RoundedBoxRatio(1./2))
 		ctx.dst.Paint(backend.FillEvenOdd)
-		ctx.dst.State().SetColorRgba(colors[1], false)
+		ctx.dst.State().SetColor(colors[1], false)
 		roundedBoxPath(ctx.dst, box.RoundedBoxRatio(1./2))
 		roundedBoxPath(ctx.dst, box.RoundedBorderBox())
 		ctx.dst.Paint(backend.FillEvenOdd)
 		return
 	}
 
-	ctx.dst.State().SetColorRgba(colors[0], false)
+	ctx.dst.State().SetColor(colors[0], false)
 	roundedBoxPath(ctx.dst, box.RoundedPaddingBox())
 	if style == "double" {
 		roundedBoxPath(ctx.dst, box.RoundedBoxRatio(1./3))
@@ -1147,17 +1217,17 @@ func (ctx drawContext) drawRectBorder(box, widths pr.Rectangle, style pr.String,
 	bbx, bby, bbw, bbh := box.Unpack()
 	bt, br, bb, bl := widths.Unpack()
 	if style == "ridge" || style == "groove" {
-		ctx.dst.State().SetColorRgba(color[0], false)
+		ctx.dst.State().SetColor(color[0], false)
 		ctx.dst.Rectangle(box.Unpack())
 		ctx.dst.Rectangle(bbx+bl/2, bby+bt/2, bbw-(bl+br)/2, bbh-(bt+bb)/2)
 		ctx.dst.Paint(backend.FillEvenOdd)
 		ctx.dst.Rectangle(bbx+bl/2, bby+bt/2, bbw-(bl+br)/2, bbh-(bt+bb)/2)
 		ctx.dst.Rectangle(bbx+bl, bby+bt, bbw-bl-br, bbh-bt-bb)
-		ctx.dst.State().SetColorRgba(color[1], false)
+		ctx.dst.State().SetColor(color[1], false)
 		ctx.dst.Paint(backend.FillEvenOdd)
 		return
 	}
-	ctx.dst.State().SetColorRgba(color[0], false)
+	ctx.dst.State().SetColor(color[0], false)
 	ctx.dst.Rectangle(box.Unpack())
 	if style == "double" {
 		ctx.dst.Rectangle(bbx+bl/3, bby+bt/3, bbw-(bl+br)/3, bbh-(bt+bb)/3)
@@ -1171,7 +1241,7 @@ func (ctx drawContext) drawRectBorder(box, widths pr.Rectangle, style pr.String,
 func (ctx drawContext) drawLine(x1, y1, x2, y2, thickness pr.Fl, style pr.String, colors [2]Color, offset fl) {
 	ctx.dst.OnNewStack(func() {
 		if !(style == "ridge" || style == "groove") {
-			ctx.dst.State().SetColorRgba(colors[0], true)
+			ctx.dst.State().SetColor(colors[0], true)
 		}
 
 		if style == "dashed" {
@@ -1195,7 +1265,7 @@ func (ctx drawContext) drawLine(x1, y1, x2, y2, thickness pr.Fl, style pr.String
 			}
 		} else if style == "ridge" || style == "groove" {
 			ctx.dst.State().SetLineWidth(thickness / 2)
-			ctx.dst.State().SetColorRgba(colors[0], true)
+			ctx.dst.State().SetColor(colors[0], true)
 			if x1 == x2 {
 				ctx.dst.MoveTo(x1+thickness/4, y1)
 				ctx.dst.LineTo(x2+thickness/4, y2)
@@ -1204,7 +1274,7 @@ func (ctx drawContext) drawLine(x1, y1, x2, y2, thickness pr.Fl, style pr.String
 				ctx.dst.LineTo(x2, y2+thickness/4)
 			}
 			ctx.dst.Paint(backend.Stroke)
-			ctx.dst.State().SetColorRgba(colors[1], true)
+			ctx.dst.State().SetColor(colors[1], true)
 			if x1 == x2 {
 				ctx.dst.MoveTo(x1-thickness/4, y1)
 				ctx.dst.LineTo(x2-thickness/4, y2)
@@ -1243,9 +1313,9 @@ func (ctx drawContext) drawLine(x1, y1, x2, y2, thickness pr.Fl, style pr.String
 func (ctx drawContext) drawOutlines(box_ Box) {
 	box := box_.Box()
 	width_ := box.Style.GetOutlineWidth()
-	color := tree.ResolveColor(box.Style, pr.POutlineColor).RGBA
+	color := parser.Color(tree.ResolveColor(box.Style, pr.POutlineColor))
 	style := box.Style.GetOutlineStyle()
-	if box.Style.GetVisibility() == "visible" && width_.Value != 0 && color.A != 0 {
+	if box.Style.GetVisibility() == "visible" && width_.Value != 0 && color.RGBA.A != 0 {
 		width := width_.Value
 		outlineBox := pr.Rectangle{
 			box.BorderBoxX() - width, box.BorderBoxY() - width,
@@ -1481,7 +1551,7 @@ func (ctx drawContext) drawCollapsedBorders(table *bo.TableBox) {
 		ctx.dst.OnNewStack(func() {
 			bx, by, bw, bh := segment.borderBox.Unpack()
 			ctx.drawLine(bx, by, bx+bw, by+bh, segment.Width, segment.Style,
-				styledColor(segment.Style, segment.Color.RGBA, segment.side), 0)
+				styledColor(segment.Style, parser.Color(segment.Color), segment.side), 0)
 		})
 	}
 }
@@ -1516,8 +1586,7 @@ func (ctx drawContext) drawInlineLevel(page *bo.PageBox, box_ Box, offsetX fl, t
 		ctx.drawStackingContext(stackingContext)
 	} else {
 		box := box_.Box()
-		ctx.drawBackgroundDefaut(box.Background)
-		ctx.drawBorder(box_)
+		ctx.drawDecoration(box_)
 		textBox, isTextBox := box_.(*bo.TextBox)
 		replacedBox, isReplacedBox := box_.(bo.ReplacedBoxITF)
 		if layout.IsLine(box_) {
@@ -1527,7 +1596,9 @@ func (ctx drawContext) drawInlineLevel(page *bo.PageBox, box_ Box, offsetX fl, t
 			}
 			for _, child := range box.Children {
 				childOffsetX := offsetX
+				// stacking contexts start their own element
 				if _, ok := child.(StackingContext); !ok {
+					ctx.structure.enter(child.Box().Element)
 					childOffsetX = offsetX + fl(child.Box().PositionX) - fl(box.PositionX)
 				}
 				if childT, ok := child.(*bo.TextBox); ok {
@@ -1553,10 +1624,29 @@ func (ctx drawContext) drawText(textbox *bo.TextBox, offsetX fl, textOverflow st
 		return
 	}
 
+	textbox.TextLayout.ApplyJustification()
+
+	// the first shadow is on top
+	if shadows := textbox.Style.GetTextShadow(); len(shadows) != 0 {
+		ctx.structure.beginArtifact()
+		for i := len(shadows) - 1; i >= 0; i-- {
+			ctx.drawTextShadow(textbox, shadows[i], offsetX, textOverflow, blockEllipsis)
+		}
+		ctx.structure.endArtifact()
+	}
+
+	ctx.drawTextLayers(textbox, offsetX, textOverflow, blockEllipsis, func(color pr.Color) pr.Color { return color })
+}
+
+// drawTextLayers draws the text of [textbox], its decorations and emphasis marks,
+// using [paint] to select the colors.
+func (ctx drawContext) drawTextLayers(textbox *bo.TextBox, offsetX fl, textOverflow string, blockEllipsis pr.TaggedString,
+	paint func(pr.Color) pr.Color,
+) {
 	// Draw text decoration
 
 	decoration := textbox.Style.GetTextDecorationLine()
-	color := tree.ResolveColor(textbox.Style, pr.PTextDecorationColor)
+	color := paint(tree.ResolveColor(textbox.Style, pr.PTextDecorationColor))
 
 	var offsetY pr.Float
 
@@ -1565,28 +1655,92 @@ func (ctx drawContext) drawText(textbox *bo.TextBox, offsetX fl, textOverflow st
 	if decoration&pr.Overline != 0 {
 		thickness := metrics.UnderlineThickness
 		offsetY = textbox.Baseline.V() - pr.Float(metrics.Ascent) + pr.Float(thickness)/2
-		ctx.drawTextDecoration(textbox, offsetX, pr.Fl(offsetY), thickness, color.RGBA)
+		ctx.drawTextDecoration(textbox, offsetX, pr.Fl(offsetY), thickness, parser.Color(color))
 	}
 	if decoration&pr.Underline != 0 {
 		thickness := metrics.UnderlineThickness
 		offsetY = textbox.Baseline.V() - pr.Float(metrics.UnderlinePosition) + pr.Float(thickness)/2
-		ctx.drawTextDecoration(textbox, offsetX, pr.Fl(offsetY), thickness, color.RGBA)
+		ctx.drawTextDecoration(textbox, offsetX, pr.Fl(offsetY), thickness, parser.Color(color))
 	}
 
 	x, y := pr.Fl(textbox.PositionX), pr.Fl(textbox.PositionY+textbox.Baseline.V())
-	ctx.dst.State().SetColorRgba(textbox.Style.GetColor().RGBA, false)
+	textColor := paint(textbox.Style.GetColor())
+	ctx.dst.State().SetColor(parser.Color(textColor), false)
 
-	textbox.TextLayout.ApplyJustification()
-	ctx.drawFirstLine(textbox, textOverflow, blockEllipsis, x, y)
+	ctx.drawFirstLine(textbox, textOverflow, blockEllipsis, x, y, textColor)
+	ctx.drawEmphasisMarks(textbox, textOverflow, blockEllipsis, x, y, paint(tree.ResolveColor(textbox.Style, pr.PTextEmphasisColor)))
 
 	if decoration&pr.LineThrough != 0 {
 		thickness := metrics.StrikethroughThickness
 		offsetY = textbox.Baseline.V() - pr.Float(metrics.StrikethroughPosition)
-		ctx.drawTextDecoration(textbox, offsetX, pr.Fl(offsetY), thickness, color.RGBA)
+		ctx.drawTextDecoration(textbox, offsetX, pr.Fl(offsetY), thickness, parser.Color(color))
+	}
+}
+
+// drawTextShadow paints [shadow] for the text of [textbox].
+// Blurred shadows are drawn in a separate group, when the output supports it,
+// and the blur is ignored otherwise.
+func (ctx drawContext) drawTextShadow(textbox *bo.TextBox, shadow pr.Shadow, offsetX fl, textOverflow string, blockEllipsis pr.TaggedString) {
+	color := shadow.Color
+	if color.Type == parser.ColorCurrentColor {
+		color = textbox.Style.GetColor()
+	}
+	paint := func(pr.Color) pr.Color { return color }
+	offset := matrix.Translation(fl(shadow.X.Value), fl(shadow.Y.Value))
+
+	ctx.dst.OnNewStack(func() {
+		blur, canBlur := ctx.dst.(backend.BlurCanvas)
+		if shadow.Blur.Value == 0 || !canBlur {
+			ctx.dst.State().Transform(offset)
+			ctx.drawTextLayers(textbox, offsetX, textOverflow, blockEllipsis, paint)
+			return
+		}
+		rect := shadowRect(textbox, shadow)
+		group := ctx.dst.NewGroup(rect[0], rect[1], rect[2], rect[3])
+		group.State().Transform(offset)
+		groupCtx := ctx
+		groupCtx.dst = group
+		groupCtx.drawTextLayers(textbox, offsetX, textOverflow, blockEllipsis, paint)
+		// the blur radius is twice the standard deviation
+		blur.DrawWithBlur(fl(shadow.Blur.Value)/2, group)
+	})
+}
+
+// inkOverflow returns the area (x, y, width, height) painted by [box_]
+// and its descendants, that is its border box, extended by the text shadows.
+func inkOverflow(box_ Box) [4]fl {
+	box := box_.Box()
+	left, top := fl(box.BorderBoxX()), fl(box.BorderBoxY())
+	right, bottom := left+fl(box.BorderWidth()), top+fl(box.BorderHeight())
+	var visit func(Box)
+	visit = func(child Box) {
+		if textBox, ok := child.(*bo.TextBox); ok {
+			for _, shadow := range textBox.Style.GetTextShadow() {
+				rect := shadowRect(textBox, shadow)
+				left, top = min(left, rect[0]), min(top, rect[1])
+				right, bottom = max(right, rect[0]+rect[2]), max(bottom, rect[1]+rect[3])
+			}
+		}
+		for _, c := range child.AllChildren() {
+			visit(c)
+		}
 	}
+	visit(box_)
+	return [4]fl{left, top, right - left, bottom - top}
 }
 
-func (ctx drawContext) drawFirstLine(textbox *bo.TextBox, textOverflow string, blockEllipsis pr.TaggedString, x, y pr.Fl) {
+// shadowRect returns the area (x, y, width, height) painted by [shadow]
+// for [textbox]. The glyphs, decorations and emphasis marks may overflow
+// the content area of the text : it is extended by the font size.
+func shadowRect(textbox *bo.TextBox, shadow pr.Shadow) [4]fl {
+	// the gaussian blur is negligible beyond three times its standard deviation
+	margin := fl(textbox.Style.GetFontSize().Value + 1.5*shadow.Blur.Value)
+	x := fl(textbox.PositionX+shadow.X.Value) - margin
+	y := fl(textbox.PositionY+textbox.MarginTop.V()+shadow.Y.Value) - margin
+	return [4]fl{x, y, fl(textbox.Width.V()) + 2*margin, fl(textbox.Height.V()) + 2*margin}
+}
+
+func (ctx drawContext) drawFirstLine(textbox *bo.TextBox, textOverflow string, blockEllipsis pr.TaggedString, x, y pr.Fl, color pr.Color) {
 	// Don’t draw lines with only invisible characters
 	if strings.TrimSpace(textbox.TextS()) == "" {
 		return
@@ -1597,13 +1751,101 @@ func (ctx drawContext) drawFirstLine(textbox *bo.TextBox, textOverflow string, b
 		return
 	}
 
-	textContext := drawText.Context{Output: ctx.dst, Fonts: ctx.fonts}
+	textContext := drawText.Context{Output: ctx.dst, Fonts: ctx.fonts, ColorFonts: ctx.colorFonts, SVGGlyphs: svg.DrawGlyph}
 	text := textContext.CreateFirstLine(textbox.TextLayout, textOverflow, blockEllipsis, 1, x, y, 0)
-	ctx.dst.DrawText([]backend.TextDrawing{text})
+	ctx.paintText(textContext, text, textbox.Style, color,
+		2*fl(textbox.PositionY)+fl(textbox.Height.V()))
+}
+
+// paintText draws the glyphs of [text] with the given color, which is expected
+// to be the current fill color. In 'vertical-lr' writing mode, the sideways glyphs
+// are reflected around the horizontal line `y = mirror / 2`.
+func (ctx drawContext) paintText(textContext drawText.Context, text backend.TextDrawing, style pr.StyleAccessor, color pr.Color, mirror fl) {
+	foreground := color.RGBA
+	withStroke := func(paint func()) {
+		if text.Embolden == 0 {
+			paint()
+			return
+		}
+		// synthetic bold : also stroke the glyphs, with the text color
+		ctx.dst.OnNewStack(func() {
+			ctx.dst.State().SetColor(parser.Color(color), true)
+			ctx.dst.State().SetLineWidth(text.Embolden)
+			ctx.dst.State().SetTextPaint(backend.FillNonZero | backend.Stroke)
+			paint()
+		})
+	}
+
+	// the glyphs are drawn in the logical frame of the line,
+	// which is rotated or reflected in vertical writing modes
+	switch style.GetWritingMode() {
+	case "vertical-rl":
+		withStroke(func() { textContext.DrawUprightGlyphs(&text, matrix.New(0, -1, 1, 0, 0, 0), foreground) })
+	case "vertical-lr":
+		withStroke(func() { textContext.DrawUprightGlyphs(&text, matrix.New(0, 1, 1, 0, 0, 0), foreground) })
+		// mirror the sideways glyphs around the middle of the line,
+		// so that their top faces the right of the page
+		ctx.dst.OnNewStack(func() {
+			ctx.dst.State().Transform(matrix.New(1, 0, 0, -1, 0, mirror))
+			textContext.DrawColorGlyphs(&text, foreground)
+			withStroke(func() { ctx.dst.DrawText([]backend.TextDrawing{text}) })
+		})
+		return
+	}
+	textContext.DrawColorGlyphs(&text, foreground)
+	withStroke(func() { ctx.dst.DrawText([]backend.TextDrawing{text}) })
+}
+
+// drawEmphasisMarks draws the marks defined by 'text-emphasis-style' over
+// or under each character of [textbox], centered on its glyphs.
+// Spaces, punctuation and control characters are not emphasized.
+func (ctx drawContext) drawEmphasisMarks(textbox *bo.TextBox, textOverflow string, blockEllipsis pr.TaggedString, x, y pr.Fl, color pr.Color) {
+	mark, over, ok := layout.EmphasisMark(textbox.Style, ctx)
+	if !ok {
+		return
+	}
+	textContext := drawText.Context{Output: ctx.dst, Fonts: ctx.fonts, ColorFonts: ctx.colorFonts, SVGGlyphs: svg.DrawGlyph}
+	text := textContext.CreateFirstLine(textbox.TextLayout, textOverflow, blockEllipsis, 1, x, y, 0)
+
+	// the marks are placed against the content area of the text
+	markTop := fl(textbox.PositionY + textbox.MarginTop.V() - mark.Height)
+	if !over {
+		markTop = fl(textbox.PositionY + textbox.MarginTop.V() + textbox.Height.V())
+	}
+	markY := markTop + fl(mark.Baseline)
+
+	// the clusters start at their first glyph, and end at the next cluster
+	var (
+		starts     []fl
+		emphasized []bool
+	)
+	for _, run := range text.Runs {
+		for _, glyph := range run.Glyphs {
+			if glyph.TextLength == 0 || glyph.TextOffset >= len(text.Text) { // not the first glyph of its cluster
+				continue
+			}
+			r := text.Text[glyph.TextOffset]
+			starts = append(starts, glyph.XAdvance/1000*text.FontSize)
+			emphasized = append(emphasized, !(unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsControl(r)))
+		}
+	}
+	starts = append(starts, fl(textbox.Width.V()))
+
+	ctx.dst.OnNewStack(func() {
+		ctx.dst.State().SetColor(parser.Color(color), false)
+		for i, ok := range emphasized {
+			if !ok {
+				continue
+			}
+			markX := x + (starts[i]+starts[i+1]-fl(mark.Width))/2
+			drawing := textContext.CreateFirstLine(mark.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, markX, markY, 0)
+			ctx.paintText(textContext, drawing, textbox.Style, color, 2*markTop+fl(mark.Height))
+		}
+	})
 }
 
 // Draw text-decoration of “textbox“ to a “context“.
 func (ctx drawContext) drawTextDecoration(textbox *bo.TextBox, offsetX, offsetY, thickness pr.Fl, color Color) {
 	ctx.drawLine(fl(textbox.PositionX), fl(textbox.PositionY)+offsetY, fl(textbox.PositionX)+fl(textbox.Width.V()), fl(textbox.PositionY)+offsetY,
-		thickness, textbox.Style.GetTextDecorationStyle(), [2]parser.RGBA{color}, offsetX)
+		thickness, textbox.Style.GetTextDecorationStyle(), [2]Color{color}, offsetX)
 }
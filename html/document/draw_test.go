@@ -4,17 +4,23 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"testing"
 	"text/template"
 	"time"
 
 	"github.com/benoitkugler/textprocessing/fontconfig"
 	"github.com/benoitkugler/textprocessing/pango/fcfonts"
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
+	pr "github.com/benoitkugler/webrender/css/properties"
+	bo "github.com/benoitkugler/webrender/html/boxes"
 	"github.com/benoitkugler/webrender/html/tree"
 	"github.com/benoitkugler/webrender/logger"
 	"github.com/benoitkugler/webrender/text"
 	"github.com/benoitkugler/webrender/utils"
 	"github.com/benoitkugler/webrender/utils/testutils/tracer"
+	"github.com/go-text/typesetting/di"
 	"github.com/go-text/typesetting/fontscan"
 )
 
@@ -267,6 +273,246 @@ func TestLayoutTime(t *testing.T) {
 	fmt.Println(time.Since(ti))
 }
 
+func TestFontSynthesis(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@font-face { src: url(weasyprint.otf); font-family: weasyprint }
+		p { margin: 0; font-size: 24px }
+	</style>
+	<p style="font-family: DejaVu Sans; font-style: italic">a</p>
+	<p style="font-family: weasyprint; font-weight: bold">b</p>
+	<p style="font-family: weasyprint; font-weight: bold; font-synthesis: none">c</p>`, baseUrl, false)
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+
+	var (
+		texts    []recorder.Text
+		stroking []bool
+		paints   = []backend.PaintOp{backend.FillNonZero} // the stack of states
+	)
+	for _, cmd := range rec.DisplayList().Pages[0].Commands {
+		switch cmd.Op {
+		case recorder.OpPushStack:
+			paints = append(paints, paints[len(paints)-1])
+		case recorder.OpPopStack:
+			paints = paints[:len(paints)-1]
+		case recorder.OpSetTextPaint:
+			paints[len(paints)-1] = backend.PaintOp(cmd.Index)
+		case recorder.OpDrawText:
+			texts = append(texts, cmd.Texts...)
+			stroking = append(stroking, paints[len(paints)-1]&backend.Stroke != 0)
+		}
+	}
+	if len(texts) != 3 {
+		t.Fatalf("unexpected texts %v", texts)
+	}
+	if texts[0].Skew == 0 || texts[0].Embolden != 0 || stroking[0] {
+		t.Fatalf("expected synthetic oblique, got %v", texts[0])
+	}
+	if texts[1].Skew != 0 || texts[1].Embolden != 1 || !stroking[1] {
+		t.Fatalf("expected synthetic bold, got %v", texts[1])
+	}
+	if texts[2].Skew != 0 || texts[2].Embolden != 0 || stroking[2] {
+		t.Fatalf("expected no synthesis, got %v", texts[2])
+	}
+}
+
+func TestTextEmphasis(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@font-face { src: url(weasyprint.otf); font-family: weasyprint }
+		@page { margin: 0 }
+		p { margin: 0; font: 20px/1 weasyprint; text-emphasis: 'x' }
+	</style>
+	<p>a b.</p>`, baseUrl, false)
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+
+	var texts []recorder.Text
+	for _, cmd := range rec.DisplayList().Pages[0].Commands {
+		if cmd.Op == recorder.OpDrawText {
+			texts = append(texts, cmd.Texts...)
+		}
+	}
+	near := func(a, b fl) bool { return a-b < 0.01 && b-a < 0.01 }
+	// the text, then one mark by letter : spaces and punctuation are skipped
+	if len(texts) != 3 {
+		t.Fatalf("unexpected texts %v", texts)
+	}
+	if texts[0].Text != "a b." || !near(texts[0].Y, 26) {
+		t.Fatalf("unexpected text %v", texts[0])
+	}
+	for i, x := range []fl{5, 45} {
+		mark := texts[i+1]
+		// centered over the letter, with half the font size
+		if mark.Text != "x" || mark.FontSize != 10 || !near(mark.X, x) || !near(mark.Y, 8) {
+			t.Fatalf("unexpected mark %v", mark)
+		}
+	}
+}
+
+func TestTextShadow(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@font-face { src: url(weasyprint.otf); font-family: weasyprint }
+		@page { margin: 0 }
+		p { margin: 0; font: 20px/1 weasyprint; color: black; opacity: 0.5 }
+	</style>
+	<p style="text-shadow: 2px 3px red, 0 0 4px blue">a</p>`, baseUrl, false)
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+	list := rec.DisplayList()
+
+	// the text color of each drawn text
+	textColors := func(commands []recorder.Command) (out [][]fl) {
+		var color []fl
+		for _, cmd := range commands {
+			switch cmd.Op {
+			case recorder.OpSetColor, recorder.OpSetColorRgba:
+				color = cmd.Values[:4]
+			case recorder.OpDrawText:
+				out = append(out, color)
+			}
+		}
+		return out
+	}
+	var blurs, opacities []recorder.Command
+	groupBoxes := map[int][]fl{}
+	for _, group := range append([]*recorder.Canvas{&list.Pages[0].Canvas}, list.Groups...) {
+		for _, cmd := range group.Commands {
+			switch cmd.Op {
+			case recorder.OpDrawWithBlur:
+				blurs = append(blurs, cmd)
+			case recorder.OpDrawWithOpacity:
+				opacities = append(opacities, cmd)
+			case recorder.OpNewGroup:
+				groupBoxes[cmd.Index] = cmd.Values
+			}
+		}
+	}
+
+	// the blurred shadow is drawn in its own group, with half the blur radius as standard deviation
+	if len(blurs) != 1 || blurs[0].Values[0] != 2 {
+		t.Fatalf("unexpected blurs %v", blurs)
+	}
+	if colors := textColors(list.Groups[blurs[0].Index].Commands); len(colors) != 1 || colors[0][2] != 1 {
+		t.Fatalf("unexpected blurred shadow %v", colors)
+	}
+
+	// the blurred shadow, then the red one, then the text
+	if len(opacities) != 1 {
+		t.Fatalf("unexpected opacity groups %v", opacities)
+	}
+	colors := textColors(list.Groups[opacities[0].Index].Commands)
+	if len(colors) != 2 || colors[0][0] != 1 || colors[1][0] != 0 {
+		t.Fatalf("unexpected texts %v", colors)
+	}
+	// the opacity group includes the shadows
+	if box := groupBoxes[opacities[0].Index]; box[0] >= 0 || box[1] >= 0 {
+		t.Fatalf("unexpected opacity group %v", box)
+	}
+}
+
+func TestBidiInline(t *testing.T) {
+	fm := fontscan.NewFontMap(nil)
+	if err := fm.UseSystemFonts(t.TempDir()); err != nil {
+		t.Skip(err)
+	}
+	fcGotext := text.NewFontConfigurationGotext(fm)
+
+	doc, err := tree.NewHTML(utils.InputString(`
+	<p style="direction: rtl; font-family: DejaVu Sans">abc אבג</p>
+	<p style="direction: rtl; font-family: DejaVu Sans"><span style="unicode-bidi: embed; direction: ltr">abc אבג</span></p>
+	<p style="font-family: DejaVu Sans"><bdo dir="rtl">abc</bdo></p>
+	<p style="font-family: DejaVu Sans">abc <b>שלום</b> <i>עולם</i></p>
+	<p style="direction: rtl; font-family: DejaVu Sans">שלום <b>abc</b> <i>def</i></p>
+	`), baseUrl, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := Render(doc, nil, false, fcGotext)
+
+	// the text boxes of each line, in visual order,
+	// with '<' marking right to left runs
+	var lines [][]string
+	for _, box := range bo.Descendants(document.Pages[0].pageBox) {
+		if _, ok := box.(*bo.LineBox); !ok {
+			continue
+		}
+		var textBoxes []*bo.TextBox
+		for _, child := range bo.Descendants(box) {
+			if textBox, ok := child.(*bo.TextBox); ok {
+				textBoxes = append(textBoxes, textBox)
+			}
+		}
+		// compare the middles, since trailing spaces may be empty
+		middle := func(box *bo.TextBox) pr.Float { return box.PositionX + box.Width.V()/2 }
+		sort.Slice(textBoxes, func(i, j int) bool { return middle(textBoxes[i]) < middle(textBoxes[j]) })
+		var runs []string
+		for _, textBox := range textBoxes {
+			line, _ := textBox.TextLayout.(*text.TextLayoutGotext).GetFirstLine()
+			for _, run := range line {
+				if run.Direction.Progression() == di.TowardTopLeft {
+					runs = append(runs, "<"+textBox.TextS())
+				} else {
+					runs = append(runs, textBox.TextS())
+				}
+			}
+		}
+		lines = append(lines, runs)
+	}
+	expected := [][]string{
+		{"< אבג", "abc"},
+		{"abc ", "<אבג"},
+		{"<abc"}, // overriden
+		{"abc ", "<עולם", "< ", "<שלום"},
+		{"abc", " ", "def", "<שלום "},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	for i, exp := range expected {
+		if fmt.Sprint(lines[i]) != fmt.Sprint(exp) {
+			t.Fatalf("unexpected runs for paragraph %d: %q", i, lines[i])
+		}
+	}
+}
+
+func TestVerticalWritingMode(t *testing.T) {
+	for _, test := range []struct {
+		mode             string
+		originX, originY fl // physical position of the logical origin
+	}{
+		{"vertical-rl", 200, 0},
+		{"vertical-lr", 0, 0},
+	} {
+		doc, err := tree.NewHTML(utils.InputString(fmt.Sprintf(`
+		<style>@page { size: 200px 100px; margin: 0 }</style>
+		<html style="writing-mode: %s"><p>abc</p></html>`, test.mode)), baseUrl, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		document := Render(doc, nil, false, fc)
+
+		root := document.Pages[0].pageBox.Children[0]
+		// lines are laid out along the page height
+		if w := root.Box().Width.V(); w != 100 {
+			t.Fatalf("%s: unexpected root width %v", test.mode, w)
+		}
+		matrix, ok := getMatrix(root)
+		if !ok {
+			t.Fatalf("%s: missing page frame", test.mode)
+		}
+		if x, y := matrix.Apply(0, 0); x != test.originX || y != test.originY {
+			t.Fatalf("%s: unexpected origin (%v, %v)", test.mode, x, y)
+		}
+		// the inline direction goes downward
+		if x, y := matrix.Apply(10, 0); x != test.originX || y != 10 {
+			t.Fatalf("%s: unexpected inline direction (%v, %v)", test.mode, x, y)
+		}
+	}
+}
+
 func Benchmark(b *testing.B) {
 	logger.ProgressLogger.SetOutput(io.Discard)
 	logger.WarningLogger.SetOutput(io.Discard)
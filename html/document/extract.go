@@ -0,0 +1,231 @@
+package document
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+
+	bo "github.com/benoitkugler/webrender/html/boxes"
+	mt "github.com/benoitkugler/webrender/matrix"
+	"golang.org/x/net/html"
+)
+
+// TextRun is a piece of text laid out on a page,
+// as returned by [Document.TextRuns].
+type TextRun struct {
+	Text string
+
+	// PageIndex is the 0-based index of the page containing the run.
+	PageIndex int
+
+	// Line is the index of the line box containing the run, counted
+	// from the start of the document. Runs on the same line share
+	// the same index, including the runs of the inline blocks
+	// laid out in the line.
+	Line int
+
+	// Rectangle is the bounding box (xMin, yMin, xMax, yMax) of the run,
+	// in CSS pixels from the top-left of the page.
+	Rectangle [4]fl
+
+	FontSize fl
+
+	// Element is the HTML element the text belongs to.
+	Element *html.Node
+
+	// Words are the words of the run, that is the sequences of
+	// non space characters, measured from their glyphs.
+	Words []TextWord
+}
+
+// TextWord is a word of a [TextRun].
+type TextWord struct {
+	Text string
+
+	// Rectangle is the bounding box (xMin, yMin, xMax, yMax) of the word,
+	// in CSS pixels from the top-left of the page.
+	Rectangle [4]fl
+}
+
+// TextRuns walks the laid out pages and returns their visible text, in reading
+// order, that is the order of the document (margin boxes come last).
+// No rendering is needed.
+func (d Document) TextRuns() []TextRun {
+	var (
+		out  []TextRun
+		line = -1
+	)
+	for i, page := range d.Pages {
+		gatherTextRuns(page.pageBox, i, &line, false, &out, nil)
+	}
+	return out
+}
+
+// gatherTextRuns is similar to [gatherLinksAndBookmarks]
+// [inLine] is true for the descendants of a line box, whose own
+// line boxes are not counted.
+func gatherTextRuns(box_ Box, pageIndex int, line *int, inLine bool, runs *[]TextRun, matrix *mt.Transform) {
+	if transform, hasTransform := getMatrix(box_); hasTransform {
+		if matrix != nil {
+			t := mt.Mul(*matrix, transform)
+			matrix = &t
+		} else {
+			matrix = &transform
+		}
+	}
+	if bo.LineT.IsInstance(box_) && !inLine {
+		*line++
+		inLine = true
+	}
+	if textBox, isText := box_.(*bo.TextBox); isText {
+		box := textBox.Box()
+		if len(textBox.Text) != 0 && box.Style.GetVisibility() == "visible" {
+			posX, posY, width, height := fl(box.PositionX), fl(box.PositionY), fl(box.Width.V()), fl(box.Height.V())
+			run := TextRun{
+				Text:      string(textBox.Text),
+				PageIndex: pageIndex,
+				Line:      *line,
+				Rectangle: [4]fl{posX, posY, posX + width, posY + height},
+				FontSize:  fl(box.Style.GetFontSize().Value),
+				Element:   box.Element,
+			}
+			run.Words = splitWords(textBox, matrix)
+			if matrix != nil {
+				run.Rectangle = rectangleAabb(*matrix, posX, posY, width, height)
+			}
+			*runs = append(*runs, run)
+		}
+	}
+
+	for _, child := range box_.AllChildren() {
+		gatherTextRuns(child, pageIndex, line, inLine, runs, matrix)
+	}
+}
+
+// splitWords returns the words of [textBox], positioned using
+// the advances of their glyphs.
+func splitWords(textBox *bo.TextBox, matrix *mt.Transform) []TextWord {
+	box := textBox.Box()
+	var bounds [][2]fl
+	if textBox.TextLayout != nil {
+		bounds = textBox.TextLayout.ClusterBounds()
+	}
+	posX, posY, height := fl(box.PositionX), fl(box.PositionY), fl(box.Height.V())
+
+	var out []TextWord
+	addWord := func(start, end int) {
+		word := TextWord{Text: string(textBox.Text[start:end])}
+		// fall back to the whole box for the runes not in the layout
+		xMin, xMax := fl(box.Width.V()), fl(0)
+		if end > len(bounds) {
+			xMin, xMax = 0, fl(box.Width.V())
+		}
+		for _, b := range bounds[min(start, len(bounds)):min(end, len(bounds))] {
+			xMin, xMax = min(xMin, b[0]), max(xMax, b[1])
+		}
+		word.Rectangle = [4]fl{posX + xMin, posY, posX + xMax, posY + height}
+		if matrix != nil {
+			word.Rectangle = rectangleAabb(*matrix, posX+xMin, posY, xMax-xMin, height)
+		}
+		out = append(out, word)
+	}
+	start := -1
+	for i, r := range textBox.Text {
+		if isSpace := unicode.IsSpace(r); isSpace && start != -1 {
+			addWord(start, i)
+			start = -1
+		} else if !isSpace && start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		addWord(start, len(textBox.Text))
+	}
+	return out
+}
+
+// PlainText returns the text of the document, in reading order.
+// Lines are separated by a new line, and pages by a form feed.
+func (d Document) PlainText() string {
+	var out strings.Builder
+	runs := d.TextRuns()
+	for i, run := range runs {
+		if i > 0 {
+			if previous := runs[i-1]; previous.PageIndex != run.PageIndex {
+				out.WriteString("\f")
+			} else if previous.Line != run.Line {
+				out.WriteString("\n")
+			}
+		}
+		out.WriteString(run.Text)
+	}
+	return out.String()
+}
+
+// WriteHOCR writes the text of the document, with its position, using the hOCR format.
+// Each page is an "ocr_page", containing "ocr_line" elements, which contain
+// one "ocrx_word" element by word.
+// The coordinates are in CSS pixels.
+func (d Document) WriteHOCR(w io.Writer) error {
+	var out strings.Builder
+	out.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="ocr-system" content="webrender">
+<meta name="ocr-capabilities" content="ocr_page ocr_line ocrx_word">
+`)
+	if d.Metadata.Title != "" {
+		fmt.Fprintf(&out, "<title>%s</title>\n", html.EscapeString(d.Metadata.Title))
+	}
+	out.WriteString("</head>\n<body>\n")
+
+	runs := d.TextRuns()
+	for pageIndex, page := range d.Pages {
+		fmt.Fprintf(&out, "<div class=\"ocr_page\" id=\"page_%d\" title=\"bbox 0 0 %d %d; ppageno %d\">\n",
+			pageIndex+1, roundPixel(page.Width), roundPixel(page.Height), pageIndex)
+		var pageRuns []TextRun
+		for len(runs) != 0 && runs[0].PageIndex == pageIndex {
+			pageRuns, runs = append(pageRuns, runs[0]), runs[1:]
+		}
+		for len(pageRuns) != 0 {
+			end := 1
+			for end < len(pageRuns) && pageRuns[end].Line == pageRuns[0].Line {
+				end++
+			}
+			writeHOCRLine(&out, pageRuns[:end])
+			pageRuns = pageRuns[end:]
+		}
+		out.WriteString("</div>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// writeHOCRLine writes the runs sharing the same line
+func writeHOCRLine(out *strings.Builder, runs []TextRun) {
+	bbox := runs[0].Rectangle
+	for _, run := range runs[1:] {
+		bbox[0], bbox[1] = min(bbox[0], run.Rectangle[0]), min(bbox[1], run.Rectangle[1])
+		bbox[2], bbox[3] = max(bbox[2], run.Rectangle[2]), max(bbox[3], run.Rectangle[3])
+	}
+	fmt.Fprintf(out, "<span class=\"ocr_line\" id=\"line_%d\" title=\"%s\">", runs[0].Line+1, hocrBbox(bbox))
+	for _, run := range runs {
+		for _, word := range run.Words {
+			// x_fsize is in points
+			fmt.Fprintf(out, "<span class=\"ocrx_word\" title=\"%s; x_fsize %d\">%s</span> ",
+				hocrBbox(word.Rectangle), roundPixel(run.FontSize*0.75), html.EscapeString(word.Text))
+		}
+	}
+	out.WriteString("</span>\n")
+}
+
+func hocrBbox(rect [4]fl) string {
+	return fmt.Sprintf("bbox %d %d %d %d", roundPixel(rect[0]), roundPixel(rect[1]), roundPixel(rect[2]), roundPixel(rect[3]))
+}
+
+func roundPixel(v fl) int { return int(math.Round(float64(v))) }
@@ -0,0 +1,156 @@
+package document
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html/atom"
+)
+
+func TestTextRuns(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@page { size: 200px 100px; margin: 10px }
+		body { margin: 0; font-size: 10px }
+		h1 { font-size: 20px; margin: 0 }
+		p { margin: 0 }
+	</style>
+	<h1>Title</h1>
+	<p>Some <em>emphasized</em> text<br>New line</p>
+	<p style="visibility: hidden">Hidden</p>
+	<p style="break-before: page">Second page &lt;3</p>`, baseUrl, false)
+
+	runs := doc.TextRuns()
+	if len(runs) != 6 {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+	title := runs[0]
+	if title.Text != "Title" || title.PageIndex != 0 || title.FontSize != 20 || title.Element.DataAtom != atom.H1 {
+		t.Fatalf("unexpected title run %v", title)
+	}
+	if r := title.Rectangle; r[0] != 10 || r[1] != 10 || r[2] <= r[0] || r[3] <= r[1] {
+		t.Fatalf("unexpected title position %v", title.Rectangle)
+	}
+	if em := runs[2]; em.Text != "emphasized" || em.Element.DataAtom != atom.Em || em.Line != runs[1].Line {
+		t.Fatalf("unexpected run %v", em)
+	}
+	if last := runs[5]; last.PageIndex != 1 || last.Rectangle[1] != 10 {
+		t.Fatalf("unexpected run on second page %v", last)
+	}
+
+	if text := doc.PlainText(); text != "Title\nSome emphasized text\nNew line\fSecond page <3" {
+		t.Fatalf("unexpected text %q", text)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.WriteHOCR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	hocr := buf.String()
+	for _, expected := range []string{
+		`<div class="ocr_page" id="page_1" title="bbox 0 0 200 100; ppageno 0">`,
+		`<span class="ocr_line" id="line_1" title="bbox 10 10 `,
+		`; x_fsize 15">Title</span> </span>`,
+		`ppageno 1`,
+		`>page</span> <span class="ocrx_word" title="bbox `,
+		`>&lt;3</span>`,
+	} {
+		if !strings.Contains(hocr, expected) {
+			t.Fatalf("missing %s in\n%s", expected, hocr)
+		}
+	}
+}
+
+func TestTextRunsInlineBlock(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@page { size: 200px 100px }
+		p { margin: 0 }
+	</style>
+	<p>Before <span style="display: inline-block">in<br>block</span> after</p>
+	<p>Next</p>`, baseUrl, false)
+
+	runs := doc.TextRuns()
+	if len(runs) != 5 {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+	// the lines of the inline block belong to the enclosing line
+	for _, run := range runs[:4] {
+		if run.Line != runs[0].Line {
+			t.Fatalf("unexpected line for run %v", run)
+		}
+	}
+	if next := runs[4]; next.Text != "Next" || next.Line != runs[0].Line+1 {
+		t.Fatalf("unexpected run %v", next)
+	}
+}
+
+func TestHOCRWords(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@font-face {src: url(weasyprint.otf); font-family: weasyprint}
+		@page { size: 200px 100px; margin: 0 }
+		body { margin: 0; font: 10px/20px weasyprint }
+	</style>
+	<p style="margin: 0">ab cde <em>fg h</em></p>`, baseUrl, false)
+
+	runs := doc.TextRuns()
+	if len(runs) != 2 {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+	// each glyph is 1em wide
+	expected := []TextWord{
+		{Text: "ab", Rectangle: [4]fl{0, 0, 20, 10}},
+		{Text: "cde", Rectangle: [4]fl{30, 0, 60, 10}},
+		{Text: "fg", Rectangle: [4]fl{70, 0, 90, 10}},
+		{Text: "h", Rectangle: [4]fl{100, 0, 110, 10}},
+	}
+	near := func(a, b fl) bool { return a-b < 0.01 && b-a < 0.01 }
+	words := append(runs[0].Words, runs[1].Words...)
+	if len(words) != len(expected) {
+		t.Fatalf("unexpected words %v", words)
+	}
+	for i, word := range words {
+		exp := expected[i]
+		if word.Text != exp.Text || !near(word.Rectangle[0], exp.Rectangle[0]) || !near(word.Rectangle[2], exp.Rectangle[2]) ||
+			!near(word.Rectangle[1], exp.Rectangle[1]) || !near(word.Rectangle[3], exp.Rectangle[3]) {
+			t.Fatalf("expected %v, got %v", exp, word)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := doc.WriteHOCR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	hocr := buf.String()
+	if n := strings.Count(hocr, `class="ocrx_word"`); n != 4 {
+		t.Fatalf("expected 4 words, got %d in\n%s", n, hocr)
+	}
+	if !strings.Contains(hocr, `<span class="ocrx_word" title="bbox 30 0 60 10; x_fsize 8">cde</span>`) {
+		t.Fatalf("unexpected word box in\n%s", hocr)
+	}
+}
+
+func TestFontReport(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@page { size: 200px 100px }
+		p { font-family: serif }
+	</style>
+	<p>Text</p>
+	<p style="break-before: page">Missing <em>中</em></p>
+	<p style="visibility: hidden">文</p>`, baseUrl, false)
+
+	report := doc.FontReport()
+	if len(report.Missing) != 1 {
+		t.Fatalf("unexpected missing glyphs %v", report.Missing)
+	}
+	if m := report.Missing[0]; m.Rune != '中' || m.PageIndex != 1 || m.Element.DataAtom != atom.Em ||
+		len(m.Families) != 1 || m.Families[0] != "serif" {
+		t.Fatalf("unexpected missing glyph %v", m)
+	}
+	if len(report.Usages) != 1 || len(report.Usages[0].Fonts) == 0 || report.Usages[0].Fonts[0].File == "" {
+		t.Fatalf("unexpected font usages %v", report.Usages)
+	}
+}
@@ -0,0 +1,116 @@
+package document
+
+import (
+	"slices"
+
+	bo "github.com/benoitkugler/webrender/html/boxes"
+	"github.com/benoitkugler/webrender/text"
+	"golang.org/x/net/html"
+)
+
+// MissingGlyph is a character not supported by any of the available fonts,
+// and thus displayed with a .notdef glyph.
+type MissingGlyph struct {
+	Rune rune
+
+	// Families is the value of the 'font-family' property
+	// of the text.
+	Families []string
+
+	// PageIndex is the 0-based index of the page containing the character.
+	PageIndex int
+
+	// Element is the HTML element the text belongs to.
+	Element *html.Node
+}
+
+// FontUsage lists the font files used to display the text
+// requested with a given 'font-family' value, including the fallback fonts.
+type FontUsage struct {
+	Families []string
+	Fonts    []text.FontOrigin
+}
+
+// FontReport summarizes how the fonts have been resolved,
+// as returned by [Document.FontReport].
+type FontReport struct {
+	// Missing are the characters without glyph, in the order of the document,
+	// reported once by text box.
+	Missing []MissingGlyph
+
+	// Usages are sorted by first use in the document.
+	Usages []FontUsage
+}
+
+// FontReport walks the laid out pages and returns the characters
+// not supported by the fonts, and the font files used for each
+// font family, which may be used to check the output or to
+// audit the embedded fonts.
+// Only visible text is considered. No rendering is needed.
+//
+// With [WriteStream], whose pages are released once painted,
+// the report is returned by [WriteStream] itself.
+func (d Document) FontReport() FontReport {
+	var out FontReport
+	for i, page := range d.Pages {
+		out.merge(page.fontReport(i))
+	}
+	return out
+}
+
+// fontReport returns the report of the page at [pageIndex],
+// which is computed when the page is released.
+func (p Page) fontReport(pageIndex int) FontReport {
+	if p.fonts != nil {
+		return *p.fonts
+	}
+	var out FontReport
+	gatherFontCoverage(p.pageBox, pageIndex, &out)
+	return out
+}
+
+// merge appends the report of a following page
+func (report *FontReport) merge(other FontReport) {
+	report.Missing = append(report.Missing, other.Missing...)
+	for _, usage := range other.Usages {
+		report.addUsage(usage.Families, usage.Fonts)
+	}
+}
+
+// gatherFontCoverage is similar to [gatherTextRuns]
+func gatherFontCoverage(box_ Box, pageIndex int, report *FontReport) {
+	if textBox, isText := box_.(*bo.TextBox); isText {
+		box := textBox.Box()
+		if len(textBox.Text) != 0 && textBox.TextLayout != nil && box.Style.GetVisibility() == "visible" {
+			families := []string(box.Style.GetFontFamily())
+			coverage := textBox.TextLayout.Coverage()
+			for _, r := range coverage.Missing {
+				report.Missing = append(report.Missing, MissingGlyph{
+					Rune:      r,
+					Families:  families,
+					PageIndex: pageIndex,
+					Element:   box.Element,
+				})
+			}
+			report.addUsage(families, coverage.Fonts)
+		}
+	}
+
+	for _, child := range box_.AllChildren() {
+		gatherFontCoverage(child, pageIndex, report)
+	}
+}
+
+func (report *FontReport) addUsage(families []string, fonts []text.FontOrigin) {
+	index := slices.IndexFunc(report.Usages, func(u FontUsage) bool { return slices.Equal(u.Families, families) })
+	if index == -1 {
+		report.Usages = append(report.Usages, FontUsage{Families: families})
+		index = len(report.Usages) - 1
+	}
+	usage := &report.Usages[index]
+	for _, origin := range fonts {
+		if !slices.Contains(usage.Fonts, origin) {
+			usage.Fonts = append(usage.Fonts, origin)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package document
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+	pr "github.com/benoitkugler/webrender/css/properties"
+	bo "github.com/benoitkugler/webrender/html/boxes"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/text"
+	drawText "github.com/benoitkugler/webrender/text/draw"
+	"github.com/benoitkugler/webrender/utils"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// textContent returns the text of the descendants of [node]
+func textContent(node *html.Node) string {
+	var out strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.TextNode {
+				out.WriteString(child.Data)
+			} else {
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return out.String()
+}
+
+// newFormField returns the field described by [element],
+// or false if the element is not exported as an interactive field.
+func newFormField(element *html.Node) (backend.FormField, bool) {
+	node := (*utils.HTMLNode)(element)
+	field := backend.FormField{
+		Name:     node.Get("name"),
+		Value:    node.Get("value"),
+		ReadOnly: node.HasAttr("readonly") || node.HasAttr("disabled"),
+	}
+	switch element.DataAtom {
+	case atom.Input:
+		switch strings.ToLower(node.Get("type")) {
+		case "checkbox", "radio":
+			field.Kind = backend.FieldCheckbox
+			if strings.ToLower(node.Get("type")) == "radio" {
+				field.Kind = backend.FieldRadio
+			}
+			field.Checked = node.HasAttr("checked")
+			if !node.HasAttr("value") {
+				field.Value = "on"
+			}
+		case "submit", "reset", "button":
+			field.Kind = backend.FieldButton
+			if !node.HasAttr("value") {
+				switch strings.ToLower(node.Get("type")) {
+				case "submit":
+					field.Value = "Submit"
+				case "reset":
+					field.Value = "Reset"
+				}
+			}
+		case "image", "hidden":
+			return field, false
+		default:
+			field.Kind = backend.FieldText
+			field.Password = strings.ToLower(node.Get("type")) == "password"
+			field.MaxLength, _ = strconv.Atoi(node.Get("maxlength"))
+		}
+	case atom.Textarea:
+		field.Kind = backend.FieldText
+		field.Multiline = true
+		field.Value = textContent(element)
+		field.MaxLength, _ = strconv.Atoi(node.Get("maxlength"))
+	case atom.Button:
+		field.Kind = backend.FieldButton
+		field.Value = strings.TrimSpace(textContent(element))
+	case atom.Select:
+		field.Kind = backend.FieldSelect
+		field.Multiple = node.HasAttr("multiple")
+		for iter := node.Iter(atom.Option); iter.HasNext(); {
+			option := iter.Next()
+			label := strings.TrimSpace(textContent(option.AsHtmlNode()))
+			if option.HasAttr("label") {
+				label = option.Get("label")
+			}
+			value := label
+			if option.HasAttr("value") {
+				value = option.Get("value")
+			}
+			field.Options = append(field.Options, backend.FormOption{Value: value, Label: label})
+			if option.HasAttr("selected") {
+				field.Selected = append(field.Selected, value)
+			}
+		}
+		// a drop-down list always displays an option
+		if !field.Multiple && len(field.Selected) == 0 && len(field.Options) != 0 {
+			field.Selected = []string{field.Options[0].Value}
+		}
+		if len(field.Selected) != 0 {
+			field.Value = field.Selected[0]
+		}
+	default:
+		return field, false
+	}
+	return field, true
+}
+
+// drawFormField exports [box_] as an interactive field, if
+// it is a form input, that is if [tree.Html5UAFormsStylesheet] is used.
+func (ctx drawContext) drawFormField(box_ Box) {
+	if !bo.IsInput(box_) {
+		return
+	}
+	box := box_.Box()
+	if box.Style.GetVisibility() != "visible" {
+		return
+	}
+	field, ok := newFormField(box.Element)
+	if !ok {
+		return
+	}
+
+	// the fields are positioned in the page coordinates, which
+	// differ from the groups ones
+	mat := ctx.dst.State().GetTransform()
+	if ctx.dst != ctx.page {
+		mat = matrix.Mul(ctx.page.State().GetTransform(), mat)
+	}
+	x, y, w, h := fl(box.BorderBoxX()), fl(box.BorderBoxY()), fl(box.BorderWidth()), fl(box.BorderHeight())
+	xMin, yMin := fl(math.Inf(1)), fl(math.Inf(1))
+	xMax, yMax := fl(math.Inf(-1)), fl(math.Inf(-1))
+	for _, corner := range [4][2]fl{{x, y}, {x + w, y}, {x, y + h}, {x + w, y + h}} {
+		cx, cy := mat.Apply(corner[0], corner[1])
+		xMin, yMin = min(xMin, cx), min(yMin, cy)
+		xMax, yMax = max(xMax, cx), max(yMax, cy)
+	}
+
+	fontSize := fl(box.Style.GetFontSize().Value)
+	field.FontSize = fontSize * fl(math.Sqrt(math.Abs(float64(mat.Determinant()))))
+	field.Color = box.Style.GetColor().RGBA
+	if fontSize >= 1e-6 {
+		field.Font = ctx.formFieldFont(box.Style, field.Value)
+	}
+	ctx.page.AddFormField(xMin, yMin, xMax, yMax, field)
+}
+
+// formFieldFont returns the font used to display [sample]
+// with [style], or nil if no font is found.
+func (ctx drawContext) formFieldFont(style pr.StyleAccessor, sample string) backend.Font {
+	if strings.TrimSpace(sample) == "" {
+		sample = "0"
+	}
+	line := text.SplitFirstLine([]rune(sample), style, ctx, nil, false, true)
+	textContext := drawText.Context{Output: ctx.dst, Fonts: ctx.fonts}
+	drawing := textContext.CreateFirstLine(line.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, 0, 0, 0)
+	if len(drawing.Runs) == 0 {
+		return nil
+	}
+	return drawing.Runs[0].Font
+}
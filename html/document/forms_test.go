@@ -0,0 +1,79 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
+	"github.com/benoitkugler/webrender/html/tree"
+	"github.com/benoitkugler/webrender/utils"
+)
+
+// formFieldsOf returns the AddFormField commands, by field name
+func formFieldsOf(t *testing.T, html string) map[string]recorder.Command {
+	t.Helper()
+	page, err := tree.NewHTML(utils.InputString(html), baseUrl, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := Render(page, []tree.CSS{tree.Html5UAFormsStylesheet}, false, fc)
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+	out := make(map[string]recorder.Command)
+	for _, page := range rec.DisplayList().Pages {
+		for _, cmd := range page.Commands {
+			if cmd.Op == recorder.OpAddFormField {
+				out[cmd.Field.Name+cmd.Field.Value] = cmd
+			}
+		}
+	}
+	return out
+}
+
+func TestFormFields(t *testing.T) {
+	cmds := formFieldsOf(t, `
+	<style>@page { size: 200px 400px; margin: 0 } body { margin: 0 }</style>
+	<input name="first" value="Jane" style="font-size: 16px">
+	<input type="checkbox" name="agree" checked>
+	<input type="radio" name="choice" value="a"><input type="radio" name="choice" value="b" checked>
+	<select name="color"><option>Red</option><option value="b" selected>Blue</option></select>
+	<textarea name="comment">Hello</textarea>
+	<button name="send">Send</button>
+	<input type="hidden" name="secret" value="1">`)
+	if len(cmds) != 7 {
+		t.Fatalf("expected 7 fields, got %d", len(cmds))
+	}
+
+	text := cmds["firstJane"]
+	if f := text.Field; f.Kind != backend.FieldText || f.Name != "first" || f.Value != "Jane" || f.FontSize != 12 {
+		t.Fatalf("unexpected text field %v", f.FormField)
+	}
+	if text.Field.Font == -1 {
+		t.Fatal("missing font")
+	}
+	// the rectangle is in page coordinates, with y going up
+	if v := text.Values; v[0] != 0 || v[3] != 300 || v[1] >= v[3] {
+		t.Fatalf("unexpected rectangle %v", v)
+	}
+
+	if f := cmds["agreeon"].Field; f.Kind != backend.FieldCheckbox || !f.Checked || f.Value != "on" {
+		t.Fatalf("unexpected checkbox %v", f.FormField)
+	}
+	if f := cmds["choicea"].Field; f.Kind != backend.FieldRadio || f.Checked || f.Name != "choice" {
+		t.Fatalf("unexpected radio %v", f.FormField)
+	}
+	if f := cmds["choiceb"].Field; f.Kind != backend.FieldRadio || !f.Checked || f.Value != "b" {
+		t.Fatalf("unexpected radio %v", f.FormField)
+	}
+	select_ := cmds["colorb"].Field
+	if select_.Kind != backend.FieldSelect || len(select_.Options) != 2 || select_.Value != "b" ||
+		select_.Options[0] != (backend.FormOption{Value: "Red", Label: "Red"}) {
+		t.Fatalf("unexpected select %v", select_.FormField)
+	}
+	if f := cmds["commentHello"].Field; f.Kind != backend.FieldText || !f.Multiline || f.Value != "Hello" {
+		t.Fatalf("unexpected textarea %v", f.FormField)
+	}
+	if f := cmds["sendSend"].Field; f.Kind != backend.FieldButton || f.Value != "Send" {
+		t.Fatalf("unexpected button %v", f.FormField)
+	}
+}
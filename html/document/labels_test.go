@@ -0,0 +1,36 @@
+package document
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
+)
+
+func TestPageLabels(t *testing.T) {
+	doc := renderHTML(t, `
+	<style>
+		@page { size: 100px; margin: 20px; @bottom-center { content: counter(page) } }
+		@page front { @bottom-center { content: counter(page, lower-roman) } }
+		@page :nth(3) { counter-reset: page 1 }
+		@page :nth(5) { @bottom-center { content: counter(page, cjk-decimal) } }
+		div { page: front }
+		section { page: main }
+		p { break-before: page; margin: 0 }
+	</style>
+	<div><p>i</p><p>ii</p></div>
+	<section><p>1</p><p>2</p><p>3</p><p>4</p></section>`, baseUrl, false)
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+
+	expected := []backend.PageLabel{
+		{PageIndex: 0, Style: backend.LabelLowerRoman, Start: 1},
+		{PageIndex: 2, Style: backend.LabelDecimal, Start: 1},
+		{PageIndex: 4, Prefix: "三"},
+		{PageIndex: 5, Style: backend.LabelDecimal, Start: 4},
+	}
+	if got := rec.DisplayList().PageLabels; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
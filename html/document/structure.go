@@ -0,0 +1,155 @@
+package document
+
+import (
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/utils"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// structureIDs identifies the HTML elements, so that
+// an element split across pages is recognized by the backend.
+type structureIDs map[*html.Node]int
+
+// structure emits the logical structure of the document,
+// deduced from the HTML elements, while drawing a page.
+//
+// Since the content is not drawn in the document order,
+// the elements are started and ended as needed, following
+// the HTML tree from the root to the element being drawn.
+type structure struct {
+	page backend.Page
+	ids  structureIDs
+
+	open     []*html.Node // the elements currently started
+	artifact int          // depth of nested artifacts
+}
+
+// structureTags maps the HTML elements to their structure role.
+// Elements without a role (like <div>) are transparent.
+var structureTags = map[atom.Atom]backend.StructureTag{
+	atom.Article:    backend.TagSection,
+	atom.Aside:      backend.TagSection,
+	atom.Main:       backend.TagSection,
+	atom.Nav:        backend.TagSection,
+	atom.Section:    backend.TagSection,
+	atom.Blockquote: backend.TagBlockQuote,
+	atom.P:          backend.TagParagraph,
+	atom.H1:         backend.TagHeading1,
+	atom.H2:         backend.TagHeading2,
+	atom.H3:         backend.TagHeading3,
+	atom.H4:         backend.TagHeading4,
+	atom.H5:         backend.TagHeading5,
+	atom.H6:         backend.TagHeading6,
+	atom.Ul:         backend.TagList,
+	atom.Ol:         backend.TagList,
+	atom.Dl:         backend.TagList,
+	atom.Li:         backend.TagListItem,
+	atom.Dt:         backend.TagListItem,
+	atom.Dd:         backend.TagListItem,
+	atom.Table:      backend.TagTable,
+	atom.Thead:      backend.TagTableHead,
+	atom.Tbody:      backend.TagTableBody,
+	atom.Tfoot:      backend.TagTableFoot,
+	atom.Tr:         backend.TagTableRow,
+	atom.Th:         backend.TagTableHeaderCell,
+	atom.Td:         backend.TagTableDataCell,
+	atom.Caption:    backend.TagCaption,
+	atom.Figcaption: backend.TagCaption,
+	atom.Figure:     backend.TagFigure,
+	atom.Img:        backend.TagFigure,
+	atom.Svg:        backend.TagFigure,
+	atom.Object:     backend.TagFigure,
+	atom.A:          backend.TagLink,
+}
+
+func structureTag(element *html.Node) (backend.StructureTag, bool) {
+	if element.Type != html.ElementNode {
+		return "", false
+	}
+	if element.DataAtom == atom.A && !(*utils.HTMLNode)(element).HasAttr("href") {
+		return "", false
+	}
+	tag, ok := structureTags[element.DataAtom]
+	return tag, ok
+}
+
+// path returns the ancestors of [element] (including itself)
+// with a structure role, starting from the root.
+func structurePath(element *html.Node) (out []*html.Node) {
+	for node := element; node != nil; node = node.Parent {
+		if _, ok := structureTag(node); ok {
+			out = append(out, node)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func (s *structure) element(node *html.Node, isTopLevel bool) backend.StructureElement {
+	tag, _ := structureTag(node)
+	id, has := s.ids[node]
+	if !has {
+		id = len(s.ids) + 1
+		s.ids[node] = id
+	}
+	elem := backend.StructureElement{Tag: tag, ID: id}
+	if tag == backend.TagFigure {
+		elem.Alt = (*utils.HTMLNode)(node).Get("alt")
+	}
+	// the language of top level elements is inherited from the document
+	for n := node; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if lang := (*utils.HTMLNode)(n).Get("lang"); lang != "" || !isTopLevel {
+			elem.Lang = lang
+			break
+		}
+	}
+	return elem
+}
+
+// enter starts the structure elements containing [element],
+// ending the ones which do not.
+func (s *structure) enter(element *html.Node) {
+	if s == nil || s.artifact > 0 {
+		return
+	}
+	path := structurePath(element)
+	common := 0
+	for common < len(path) && common < len(s.open) && path[common] == s.open[common] {
+		common++
+	}
+	for len(s.open) > common {
+		s.page.EndStructure()
+		s.open = s.open[:len(s.open)-1]
+	}
+	for _, node := range path[common:] {
+		s.page.BeginStructure(s.element(node, len(s.open) == 0))
+		s.open = append(s.open, node)
+	}
+}
+
+// beginArtifact starts a content which is not part of the
+// logical structure, like a background or a page margin box.
+// The elements currently started are kept, so that the content
+// following the artifact is still in them.
+func (s *structure) beginArtifact() {
+	if s == nil {
+		return
+	}
+	if s.artifact == 0 {
+		s.page.BeginStructure(backend.StructureElement{Tag: backend.TagArtifact})
+	}
+	s.artifact++
+}
+
+func (s *structure) endArtifact() {
+	if s == nil {
+		return
+	}
+	s.artifact--
+	if s.artifact == 0 {
+		s.page.EndStructure()
+	}
+}
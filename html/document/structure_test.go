@@ -0,0 +1,71 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
+)
+
+// structureOf returns the structure calls on each page, as a string
+// like "H1()P(Link())"
+func structureOf(t *testing.T, html string) []string {
+	t.Helper()
+	doc := renderHTML(t, html, baseUrl, false)
+	rec := recorder.NewDocument()
+	doc.Write(rec, 1, nil)
+
+	var out []string
+	for _, page := range rec.DisplayList().Pages {
+		var (
+			chunks []string
+			stack  []backend.StructureElement
+		)
+		for _, cmd := range page.Commands {
+			switch cmd.Op {
+			case recorder.OpBeginStructure:
+				elem := *cmd.Structure
+				label := string(elem.Tag)
+				if elem.Alt != "" || elem.Lang != "" {
+					label += fmt.Sprintf("[%s%s]", elem.Alt, elem.Lang)
+				}
+				chunks = append(chunks, label+"(")
+				stack = append(stack, elem)
+			case recorder.OpEndStructure:
+				if len(stack) == 0 {
+					t.Fatal("unbalanced EndStructure")
+				}
+				stack = stack[:len(stack)-1]
+				chunks = append(chunks, ")")
+			}
+		}
+		if len(stack) != 0 {
+			t.Fatal("unbalanced BeginStructure")
+		}
+		out = append(out, strings.Join(chunks, ""))
+	}
+	return out
+}
+
+func TestStructure(t *testing.T) {
+	for _, test := range []struct {
+		html     string
+		expected string
+	}{
+		// the link annotation is added in its element, after the content
+		{`<h1>Title</h1><p>Some <a href="https://example.com">link</a></p>`, "Artifact()H1()P(Link())P(Link())"},
+		{`<ul><li>One</li></ul>`, "Artifact()L(LI())"},
+		{`<p lang="fr">Texte</p>`, "Artifact()P[fr]()"},
+		{`<img alt="An image" src="pattern.png">`, "Artifact()Figure[An image]()"},
+		{`<style>@page { @top-center { content: "Header" } }</style><p>Text</p>`, "Artifact()P()Artifact()"},
+		// backgrounds and borders are artifacts
+		{`<p style="border: 1px solid">Some <em style="background: red">text</em></p>`, "Artifact()Artifact()P(Artifact())"},
+	} {
+		got := structureOf(t, test.html)
+		if len(got) != 1 || got[0] != test.expected {
+			t.Fatalf("for %s, expected %s, got %v", test.html, test.expected, got)
+		}
+	}
+}
@@ -81,7 +81,7 @@ func layoutBoxBackgrounds(page *bo.PageBox, box_ Box, getImageFromUri bo.ImageFe
 	box.BorderImage = resolveImage(style.GetBorderImageSource(), pr.SBoolFloat{}, getImageFromUri)
 
 	var (
-		color     parser.RGBA // transparent
+		color     parser.Color // transparent
 		images_   []images.Image
 		anyImages = false
 	)
@@ -95,10 +95,10 @@ func layoutBoxBackgrounds(page *bo.PageBox, box_ Box, getImageFromUri bo.ImageFe
 				anyImages = true
 			}
 		}
-		color = tree.ResolveColor(style, pr.PBackgroundColor).RGBA
+		color = parser.Color(tree.ResolveColor(style, pr.PBackgroundColor))
 	}
 
-	if color.A == 0 && !anyImages {
+	if color.RGBA.A == 0 && !anyImages {
 		if page != box_ { // Pages need a background for bleed box
 			box.Background = nil
 			return
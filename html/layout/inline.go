@@ -276,7 +276,8 @@ func skipFirstWhitespace(box Box, skipStack tree.ResumeStack) (tree.ResumeStack,
 		return nil, false
 	}
 
-	if IsLine(box) {
+	// the content of ruby containers is laid out independently of the line
+	if IsLine(box) && !bo.RubyT.IsInstance(box) {
 		children := box.Box().Children
 		if index == 0 && len(children) == 0 {
 			return nil, false
@@ -306,7 +307,7 @@ func skipFirstWhitespace(box Box, skipStack tree.ResumeStack) (tree.ResumeStack,
 // This also reduces the width of the inline parents of the modified text.
 func removeLastWhitespace(context *layoutContext, box Box) {
 	var ancestors []Box
-	for IsLine(box) {
+	for IsLine(box) && !bo.RubyT.IsInstance(box) {
 		ancestors = append(ancestors, box)
 		ch := box.Box().Children
 		if len(ch) == 0 {
@@ -633,6 +634,14 @@ func splitInlineLevel(context *layoutContext, box_ Box, positionX, maxX, bottomS
 				lastLetter = text[skip-1]
 			}
 		}
+	} else if bo.RubyT.IsInstance(box_) {
+		resolveMarginAuto(box)
+		newBox = rubyBoxLayout(context, box_, positionX, bottomSpace, containingBlock,
+			absoluteBoxes, fixedBoxes, linePlaceholders, waitingFloats)
+		// The ruby container is not split across lines : like atomic inlines,
+		// it behaves as an ideographic character for line breaking.
+		firstLetter = '\u2e80'
+		lastLetter = '\u2e80'
 	} else if bo.InlineT.IsInstance(box_) {
 		if box.MarginLeft == pr.AutoF {
 			box.MarginLeft = pr.Float(0)
@@ -949,16 +958,16 @@ func splitInlineBox(context *layoutContext, box_ Box, positionX, maxX, bottomSpa
 		resumeAt = nil
 	}
 
-	// Reorder inline blocks when direction is rtl
-	if box.Style.GetDirection() == "rtl" && len(children) > 1 {
-		var inFlowChildren []Box
-		for _, child := range children {
-			if child.box.Box().IsInNormalFlow() {
-				inFlowChildren = append(inFlowChildren, child.box)
-			}
+	// Reorder the children in visual order, from their bidi levels
+	var inFlowChildren []Box
+	for _, child := range children {
+		if child.box.Box().IsInNormalFlow() {
+			inFlowChildren = append(inFlowChildren, child.box)
 		}
+	}
+	if visual := reorderLevels(inFlowChildren); visual != nil {
 		posX := inFlowChildren[0].Box().PositionX
-		for _, child := range reversedBoxes(inFlowChildren) {
+		for _, child := range visual {
 			child.Translate(child, (posX - child.Box().PositionX), 0, true)
 			posX += child.Box().MarginWidth()
 		}
@@ -977,14 +986,9 @@ func splitInlineBox(context *layoutContext, box_ Box, positionX, maxX, bottomSpa
 	if bo.LineT.IsInstance(box_) {
 		// We must reset line box width according to its new children
 		newBox.Width = pr.Float(0)
-		children := newBox.Children
-		if newBox.Style.GetDirection() == "ltr" {
-			children = reversedBoxes(children)
-		}
-		for _, boxChild := range children {
+		for _, boxChild := range newBox.Children {
 			if boxChild.Box().IsInNormalFlow() {
-				newBox.Width = boxChild.Box().PositionX + boxChild.Box().MarginWidth() - newBox.PositionX
-				break
+				newBox.Width = pr.Max(newBox.Width.V(), boxChild.Box().PositionX+boxChild.Box().MarginWidth()-newBox.PositionX)
 			}
 		}
 	} else {
@@ -1005,15 +1009,7 @@ func splitInlineBox(context *layoutContext, box_ Box, positionX, maxX, bottomSpa
 		newBox.Width = positionX - contentBoxLeft
 		newBox_.Translate(newBox_, floatWidths.left, 0, true)
 	}
-	stl := text.StrutLayout(box.Style, context)
-	lineHeight := stl[0]
-	newBox.Baseline = stl[1]
-	newBox.Height = box.Style.GetFontSize().ToMaybeFloat()
-	halfLeading := (lineHeight - newBox.Height.V()) / 2.
-	// Set margins to the half leading but also compensate for borders and
-	// paddings. We want marginHeight() == lineHeight
-	newBox.MarginTop = halfLeading - newBox.BorderTopWidth.V() - newBox.PaddingTop.V()
-	newBox.MarginBottom = halfLeading - newBox.BorderBottomWidth.V() - newBox.PaddingBottom.V()
+	setInlineStrut(context, newBox)
 
 	if newBox.Style.GetPosition().String == "relative" {
 		for _, absoluteBox := range *absoluteBoxes {
@@ -1043,6 +1039,136 @@ func splitInlineBox(context *layoutContext, box_ Box, positionX, maxX, bottomSpa
 	}
 }
 
+// reorderLevels returns the inline-level [boxes], given in logical order, sorted
+// by visual order, or nil if no reordering is needed.
+// It applies the rule L2 of the Unicode Bidirectional Algorithm, using the
+// levels resolved during the box tree construction : from the highest level
+// to the lowest odd level, any contiguous sequence of boxes at that level
+// or higher is reversed.
+//
+// See https://www.unicode.org/reports/tr9/#L2
+func reorderLevels(boxes []Box) []Box {
+	var highest, lowestOdd text.BidiLevel = 0, 127
+	for _, box := range boxes {
+		level := box.Box().BidiLevel
+		highest = max(highest, level)
+		if level%2 == 1 {
+			lowestOdd = min(lowestOdd, level)
+		}
+	}
+	if len(boxes) < 2 || lowestOdd > highest {
+		return nil
+	}
+
+	out := append([]Box(nil), boxes...)
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(out); {
+			if out[i].Box().BidiLevel < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(out) && out[j].Box().BidiLevel >= level {
+				j++
+			}
+			reverseB(out[i:j])
+			i = j
+		}
+	}
+	return out
+}
+
+// setInlineStrut sets the height, the baseline and the vertical margins
+// of the inline [box] from its strut.
+func setInlineStrut(context *layoutContext, box *bo.BoxFields) {
+	stl := text.StrutLayout(box.Style, context)
+	lineHeight := stl[0]
+	box.Baseline = stl[1]
+	box.Height = box.Style.GetFontSize().ToMaybeFloat()
+	halfLeading := (lineHeight - box.Height.V()) / 2.
+	// Set margins to the half leading but also compensate for borders and
+	// paddings. We want marginHeight() == lineHeight
+	box.MarginTop = halfLeading - box.BorderTopWidth.V() - box.PaddingTop.V()
+	box.MarginBottom = halfLeading - box.BorderBottomWidth.V() - box.PaddingBottom.V()
+}
+
+// rubyBoxLayout lays out the ruby container [box_] on a single line :
+// each base is laid out in a column with its annotation, the narrower
+// of the two being aligned according to 'ruby-align'.
+// The annotations are then placed above or below their base by
+// [rubyBoxVerticality].
+// See https://www.w3.org/TR/css-ruby-1/#ruby-layout
+func rubyBoxLayout(context *layoutContext, box_ Box, positionX, bottomSpace pr.Float, containingBlock Box,
+	absoluteBoxes, fixedBoxes, linePlaceholders *[]*AbsolutePlaceholder, waitingFloats *[]Box,
+) Box {
+	box := box_.Box()
+	leftSpacing := box.PaddingLeft.V() + box.MarginLeft.V() + box.BorderLeftWidth.V()
+	contentBoxLeft := positionX + leftSpacing
+
+	// the children alternate between bases and annotations, see [bo.RubyBoxes]
+	var children []Box
+	x := contentBoxLeft
+	for i := 0; i+1 < len(box.Children); i += 2 {
+		var (
+			column [2]Box
+			widths [2]pr.Float
+		)
+		for j, child := range box.Children[i : i+2] {
+			v := splitInlineLevel(context, child, 0, pr.Inf, bottomSpace, nil, containingBlock,
+				absoluteBoxes, fixedBoxes, linePlaceholders, waitingFloats, nil)
+			column[j] = v.newBox
+			widths[j] = v.newBox.Box().MarginWidth()
+		}
+		columnWidth := pr.Max(widths[0], widths[1])
+		for j, child := range column {
+			offset := rubyAlign(context, child, columnWidth-widths[j])
+			child.Translate(child, x+offset, 0, false)
+		}
+		children = append(children, column[:]...)
+		x += columnWidth
+	}
+
+	newBox_ := bo.CopyWithChildren(box_, children)
+	newBox := newBox_.Box()
+	newBox.PositionX = positionX
+	newBox.Width = x - contentBoxLeft
+	setInlineStrut(context, newBox)
+	return newBox_
+}
+
+// rubyAlign distributes the [extra] space of a ruby column in the base
+// or annotation [box], according to its 'ruby-align' property,
+// and returns the offset of [box] in the column.
+// Justification opportunities are the spaces, as for the word justification of [justifyLine].
+func rubyAlign(context *layoutContext, box Box, extra pr.Float) pr.Float {
+	if extra <= 0 {
+		return 0
+	}
+	style := box.Box().Style
+	nbSpaces := pr.Float(countSpaces(box))
+	switch style.GetRubyAlign() {
+	case "start":
+		if style.GetDirection() == "rtl" {
+			return extra
+		}
+		return 0
+	case "space-between":
+		if nbSpaces > 0 {
+			addWordSpacing(context, box, extra/nbSpaces, 0)
+			return 0
+		}
+	case "space-around":
+		if nbSpaces > 0 {
+			// half a justification space at each end
+			spacing := extra / (nbSpaces + 1)
+			addWordSpacing(context, box, spacing, 0)
+			return spacing / 2
+		}
+	}
+	// "center", or no justification opportunity
+	return extra / 2
+}
+
 func (context *layoutContext) addRunning(child_ bo.Box) {
 	runningName := child_.Box().Style.GetPosition().String
 	currentRE, has := context.runningElements[runningName]
@@ -1163,7 +1289,7 @@ func splitTextBox(context *layoutContext, box *bo.TextBox, availableWidth pr.May
 	if fontSize == pr.FToV(0) || len(text_) == 0 {
 		return nil, -1, false, false
 	}
-	v := text.SplitFirstLine(text_, box.Style, context, availableWidth, false, isLineStart)
+	v := text.SplitFirstLineLevel(text_, box.Style, box.BidiLevel, context, availableWidth, false, isLineStart)
 	layout, length, resumeIndex, width, height, baseline := v.Layout, v.Length, v.ResumeAt, v.Width, v.Height, v.Baseline
 	if resumeIndex == 0 {
 		panic("resumeAt should not be 0 here")
@@ -1302,6 +1428,9 @@ func inlineBoxVerticality(context *layoutContext, box_ Box, topBottomSubtrees *[
 	if !IsLine(box_) {
 		return maxY, minY
 	}
+	if bo.RubyT.IsInstance(box_) {
+		return rubyBoxVerticality(context, box_, topBottomSubtrees, baselineY)
+	}
 	box := box_.Box()
 	for _, child_ := range box_.Box().Children {
 		child := child_.Box()
@@ -1365,6 +1494,16 @@ func inlineBoxVerticality(context *layoutContext, box_ Box, topBottomSubtrees *[
 		if maxY == nil || bottom > maxY.V() {
 			maxY = bottom
 		}
+		if textBox, ok := child_.(*bo.TextBox); ok {
+			// emphasis marks are drawn outside of the content area
+			if mark, over, ok := EmphasisMark(textBox.Style, context); ok {
+				if contentTop := top + child.MarginTop.V(); over {
+					minY = min(minY.V(), contentTop-mark.Height)
+				} else {
+					maxY = max(maxY.V(), contentTop+child.Height.V()+mark.Height)
+				}
+			}
+		}
 		if bo.InlineT.IsInstance(child_) {
 			childrenMaxY, childrenMinY := inlineBoxVerticality(context, child_, topBottomSubtrees, childBaselineY)
 			if childrenMinY != nil && childrenMinY.V() < minY.V() {
@@ -1378,6 +1517,75 @@ func inlineBoxVerticality(context *layoutContext, box_ Box, topBottomSubtrees *[
 	return maxY, minY
 }
 
+// EmphasisMark returns the layout of the emphasis mark defined by the
+// 'text-emphasis-style' property of [style], with half the font size.
+// [over] is true if the marks are placed on the top of the line,
+// in the logical frame used for layout, and false if they are placed below it.
+// [ok] is false when no marks should be drawn.
+func EmphasisMark(style pr.ElementStyle, context text.TextLayoutContext) (mark text.FirstLine, over, ok bool) {
+	emphasis := style.GetTextEmphasisStyle()
+	if emphasis.Tag == pr.None || emphasis.S == "" {
+		return mark, false, false
+	}
+	fontSize := style.GetFontSize().Value
+	if fontSize == 0 {
+		return mark, false, false
+	}
+	markStyle := style.Copy()
+	markStyle.SetFontSize(pr.FToPx(fontSize / 2))
+	mark = text.SplitFirstLine([]rune(emphasis.S), markStyle, context, nil, false, true)
+
+	// in vertical writing modes, the logical top of the line
+	// is on the right for 'vertical-rl' and on the left for 'vertical-lr'
+	position := style.GetTextEmphasisPosition()
+	switch style.GetWritingMode() {
+	case "vertical-rl":
+		over = position[1] == "right"
+	case "vertical-lr":
+		over = position[1] == "left"
+	default:
+		over = position[0] == "over"
+	}
+	return mark, over, true
+}
+
+// rubyBoxVerticality aligns the bases of the ruby container [box_] on its
+// baseline, at `y = baselineY`, and places each annotation against
+// the border box of its base, according to 'ruby-position'.
+// As for [inlineBoxVerticality], the annotations are included in the
+// returned extents, so that they are taken into account in the line height.
+func rubyBoxVerticality(context *layoutContext, box_ Box, topBottomSubtrees *[]Box, baselineY pr.Float) (maxY, minY pr.MaybeFloat) {
+	extend := func(childMaxY, childMinY pr.MaybeFloat) {
+		if childMinY != nil && (minY == nil || childMinY.V() < minY.V()) {
+			minY = childMinY
+		}
+		if childMaxY != nil && (maxY == nil || childMaxY.V() > maxY.V()) {
+			maxY = childMaxY
+		}
+	}
+	children := box_.Box().Children
+	for i := 0; i+1 < len(children); i += 2 {
+		base, annotation := children[i].Box(), children[i+1].Box()
+		base.PositionY = baselineY - base.Baseline.V()
+		extend(base.PositionY+base.MarginHeight(), base.PositionY)
+		extend(inlineBoxVerticality(context, children[i], topBottomSubtrees, baselineY))
+
+		if len(annotation.Children) == 0 { // nothing to display
+			annotation.PositionY = base.PositionY
+			continue
+		}
+		baseTop := base.PositionY + base.MarginTop.V()
+		if annotation.Style.GetRubyPosition() == "under" {
+			annotation.PositionY = baseTop + base.BorderHeight()
+		} else {
+			annotation.PositionY = baseTop - annotation.MarginHeight()
+		}
+		extend(annotation.PositionY+annotation.MarginHeight(), annotation.PositionY)
+		extend(inlineBoxVerticality(context, children[i+1], topBottomSubtrees, annotation.PositionY+annotation.Baseline.V()))
+	}
+	return maxY, minY
+}
+
 // Return how much the line should be moved horizontally according to
 // the `text-align` property.
 func textAlign(context *layoutContext, line_ Box, availableWidth pr.Float, last bool) pr.Float {
@@ -1437,16 +1645,89 @@ func justifyLine(context *layoutContext, line Box, extraWidth pr.Float) {
 	// https://www.w3.org/TR/css-text-3/#justify-algos
 	nbSpaces := countSpaces(line)
 	if nbSpaces == 0 {
+		// lines without word separators, as in Chinese or Thai,
+		// are justified between characters
+		nbCharacters := countCharacters(line)
+		if nbCharacters <= 1 || !hasUnspacedScript(line) {
+			return
+		}
+		addCharacterSpacing(line, extraWidth/pr.Float(nbCharacters-1), 0, &nbCharacters)
 		return
 	}
 	addWordSpacing(context, line, extraWidth/pr.Float(nbSpaces), 0)
 }
 
+// countCharacters returns the number of inter-character
+// justification opportunities in [box], plus one for the last character.
+func countCharacters(box Box) int {
+	if textBox, isTextBox := box.(*bo.TextBox); isTextBox {
+		return text.CharacterOpportunities(textBox.Text)
+	} else if IsLine(box) && !bo.RubyT.IsInstance(box) {
+		var sum int
+		for _, child := range box.Box().Children {
+			sum += countCharacters(child)
+		}
+		return sum
+	} else {
+		return 0
+	}
+}
+
+// hasUnspacedScript returns true if the text of [box]
+// is written in a script without word separators.
+func hasUnspacedScript(box Box) bool {
+	if textBox, isTextBox := box.(*bo.TextBox); isTextBox {
+		return text.HasUnspacedScript(textBox.Text)
+	} else if IsLine(box) && !bo.RubyT.IsInstance(box) {
+		for _, child := range box.Box().Children {
+			if hasUnspacedScript(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addCharacterSpacing is the same as [addWordSpacing] for inter-character
+// justification. [remaining] is the number of characters not visited yet :
+// the last one of the line is not spaced.
+func addCharacterSpacing(box_ Box, justificationSpacing, xAdvance pr.Float, remaining *int) pr.Float {
+	if textBox, isTextBox := box_.(*bo.TextBox); isTextBox {
+		textBox.PositionX += xAdvance
+		nbCharacters := countCharacters(box_)
+		if nbCharacters > 0 {
+			*remaining -= nbCharacters
+			trailing := *remaining > 0
+			if !trailing {
+				nbCharacters--
+			}
+			textBox.TextLayout.SetCharacterJustification(justificationSpacing, trailing)
+			extraSpace := justificationSpacing * pr.Float(nbCharacters)
+			xAdvance += extraSpace
+			textBox.Width = textBox.Width.V() + extraSpace
+		}
+	} else if IsLine(box_) && !bo.RubyT.IsInstance(box_) {
+		box := box_.Box()
+		box.PositionX += xAdvance
+		previousXAdvance := xAdvance
+		for _, child := range box.Children {
+			if child.Box().IsInNormalFlow() {
+				xAdvance = addCharacterSpacing(child, justificationSpacing, xAdvance, remaining)
+			}
+		}
+		box.Width = box.Width.V() + xAdvance - previousXAdvance
+	} else {
+		// Atomic inline-level box or ruby container
+		box_.Translate(box_, xAdvance, 0, false)
+	}
+	return xAdvance
+}
+
 func countSpaces(box Box) int {
 	if textBox, isTextBox := box.(*bo.TextBox); isTextBox {
 		// TODO: remove trailing spaces correctly
 		return strings.Count(textBox.TextS(), " ")
-	} else if IsLine(box) {
+	} else if IsLine(box) && !bo.RubyT.IsInstance(box) {
 		var sum int
 		for _, child := range box.Box().Children {
 			sum += countSpaces(child)
@@ -1468,7 +1749,7 @@ func addWordSpacing(context *layoutContext, box_ Box, justificationSpacing, xAdv
 			xAdvance += extraSpace
 			textBox.Width = textBox.Width.V() + extraSpace
 		}
-	} else if IsLine(box_) {
+	} else if IsLine(box_) && !bo.RubyT.IsInstance(box_) {
 		box := box_.Box()
 		box.PositionX += xAdvance
 		previousXAdvance := xAdvance
@@ -1479,7 +1760,7 @@ func addWordSpacing(context *layoutContext, box_ Box, justificationSpacing, xAdv
 		}
 		box.Width = box.Width.V() + xAdvance - previousXAdvance
 	} else {
-		// Atomic inline-level box
+		// Atomic inline-level box or ruby container
 		box_.Translate(box_, xAdvance, 0, false)
 	}
 	return xAdvance
@@ -1513,7 +1794,7 @@ func canBreakInside(ctx *layoutContext, box Box) pr.MaybeBool {
 	ws := box.Box().Style.GetWhiteSpace()
 	textWrap := ws == "normal" || ws == "pre-wrap" || ws == "pre-line"
 	textBox, isTextBox := box.(*bo.TextBox)
-	if bo.AtomicInlineLevelT.IsInstance(box) {
+	if bo.AtomicInlineLevelT.IsInstance(box) || bo.RubyT.IsInstance(box) {
 		return pr.False
 	} else if textWrap && isTextBox {
 		return ctx.Fonts().CanBreakText(textBox.Text)
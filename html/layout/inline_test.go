@@ -1231,3 +1231,126 @@ func TestBidiPositionXInvariant(t *testing.T) {
 	tu.AssertEqual(t, line_ltr.Box().PositionX, line_rtl.Box().PositionX)
 	tu.AssertEqual(t, text_ltr.Box().PositionX, text_rtl.Box().PositionX)
 }
+
+func TestBidiReorderBoxes(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+	page := renderOnePage(t, `
+      <style>
+        @font-face {src: url(weasyprint.otf); font-family: weasyprint}
+        p { font: 10px weasyprint }
+      </style>
+      <p>abc <b>שלום</b> <i>עולם</i></p>
+      <p style="direction: rtl"><b>abc</b> <i>def</i></p>
+      <p style="direction: rtl">abc <b>שלום</b> <i>עולם</i></p>`)
+	html := unpack1(page)
+	body := unpack1(html)
+	p1, p2, p3 := unpack3(body)
+
+	// the right to left words are reversed, in a left to right paragraph
+	text, b, space, i := unpack4(unpack1(p1))
+	tu.AssertEqual(t, text.Box().PositionX, Fl(0))
+	tu.AssertEqual(t, i.Box().PositionX, text.Box().PositionX+text.Box().Width.V())
+	tu.AssertEqual(t, space.Box().PositionX, i.Box().PositionX+i.Box().Width.V())
+	tu.AssertEqual(t, b.Box().PositionX, space.Box().PositionX+space.Box().Width.V())
+
+	// the left to right words keep their order, in a right to left paragraph
+	b, space, i = unpack3(unpack1(p2))
+	tu.AssertEqual(t, space.Box().PositionX, b.Box().PositionX+b.Box().Width.V())
+	tu.AssertEqual(t, i.Box().PositionX, space.Box().PositionX+space.Box().Width.V())
+
+	// "abc " is split between its left to right word and its right to left space
+	line := unpack1(p3)
+	children := line.Box().Children
+	tu.AssertEqual(t, len(children), 5)
+	for j, index := range []int{4, 3, 2, 1, 0} { // visual order
+		child := children[index].Box()
+		if j == 0 {
+			tu.AssertEqual(t, child.PositionX, line.Box().PositionX)
+		} else {
+			previous := children[index+1].Box()
+			tu.AssertEqual(t, child.PositionX, previous.PositionX+previous.Width.V())
+		}
+	}
+	tu.AssertEqual(t, children[0].(*bo.TextBox).TextS(), "abc")
+}
+
+func TestRuby(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+	for _, test := range []struct {
+		style                     string
+		baseX, annotationY, baseY Fl
+	}{
+		{"", 10, 0, 10},                     // centered, over the base
+		{"ruby-position: under", 10, 20, 0}, // below the base
+		{"ruby-align: start", 0, 0, 10},     // base at the start of the column
+	} {
+		page := renderOnePage(t, fmt.Sprintf(`
+      <style>
+        @font-face {src: url(weasyprint.otf); font-family: weasyprint}
+        p { font: 20px/1 weasyprint }
+        rt { font-size: 10px; line-height: 1 }
+      </style>
+      <p><ruby style="%s">ab<rt>cdefgh</rt></ruby>i</p>`, test.style))
+		html := unpack1(page)
+		body := unpack1(html)
+		paragraph := unpack1(body)
+		line := unpack1(paragraph)
+		ruby, text := unpack2(line)
+		base, annotation := unpack2(ruby)
+
+		// the column is as wide as the annotation
+		tu.AssertEqual(t, ruby.Box().Width, Fl(60))
+		tu.AssertEqual(t, text.Box().PositionX, Fl(60))
+		tu.AssertEqual(t, annotation.Box().PositionX, Fl(0))
+		tu.AssertEqual(t, base.Box().PositionX, test.baseX)
+
+		// the annotation is included in the line height
+		tu.AssertEqual(t, line.Box().Height, Fl(30))
+		tu.AssertEqual(t, annotation.Box().PositionY, test.annotationY)
+		tu.AssertEqual(t, base.Box().PositionY, test.baseY)
+	}
+}
+
+func TestRubyShrinkToFit(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+	page := renderOnePage(t, `
+      <style>
+        @font-face {src: url(weasyprint.otf); font-family: weasyprint}
+        div { float: left; font: 20px/1 weasyprint }
+        rt { font-size: 10px }
+      </style>
+      <div><ruby>ab<rt>cdefgh</rt>c<rt>d</rt></ruby></div>`)
+	html := unpack1(page)
+	body := unpack1(html)
+	div := unpack1(body)
+	tu.AssertEqual(t, div.Box().Width, Fl(80))
+}
+
+func TestTextEmphasisLineHeight(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+	for _, test := range []struct {
+		style         string
+		height, textY Fl
+	}{
+		{"text-emphasis: none", 20, 0},
+		{"text-emphasis: 'x'", 30, 10},                               // half the font size above the text
+		{"text-emphasis: 'x'; text-emphasis-position: under", 30, 0}, // below the text
+	} {
+		page := renderOnePage(t, fmt.Sprintf(`
+      <style>
+        @font-face {src: url(weasyprint.otf); font-family: weasyprint}
+        p { font: 20px/1 weasyprint }
+      </style>
+      <p>ab<span style="%s">cd</span></p>`, test.style))
+		html := unpack1(page)
+		body := unpack1(html)
+		paragraph := unpack1(body)
+		line := unpack1(paragraph)
+		text1, span := unpack2(line)
+		text2 := unpack1(span)
+
+		tu.AssertEqual(t, line.Box().Height, test.height)
+		tu.AssertEqual(t, text1.Box().PositionY, test.textY)
+		tu.AssertEqual(t, text2.Box().PositionY, test.textY)
+	}
+}
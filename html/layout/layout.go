@@ -47,6 +47,20 @@ type Box = bo.Box
 // This includes line breaks, page breaks, absolute size and position for all
 // boxes.
 func Layout(html *tree.HTML, stylesheets []tree.CSS, presentationalHints bool, fontConfig text.FontConfiguration) []*bo.PageBox {
+	var pages []*bo.PageBox
+	LayoutStream(html, stylesheets, presentationalHints, fontConfig, func(page *bo.PageBox) { pages = append(pages, page) })
+	return pages
+}
+
+// LayoutStream is the same as [Layout], but calls [emit] with each page, in order,
+// as soon as it is final, so that it may be rendered before the layout
+// of the whole document is done. The emitted pages are not retained by the layout.
+//
+// Pages depending on information only known later in the layout, like the
+// total number of pages or the page of a forward target, are buffered until it is resolved.
+// Note that fixed boxes are repeated on every page, so that documents using
+// them are only emitted at the end of the layout.
+func LayoutStream(html *tree.HTML, stylesheets []tree.CSS, presentationalHints bool, fontConfig text.FontConfiguration, emit func(*bo.PageBox)) {
 	counterStyle := make(counters.CounterStyle)
 	context := newLayoutContext(html, stylesheets, presentationalHints, fontConfig, counterStyle)
 
@@ -55,7 +69,22 @@ func Layout(html *tree.HTML, stylesheets []tree.CSS, presentationalHints bool, f
 	rootBox := bo.BuildFormattingStructure(html.Root, context.styleFor, context.resolver,
 		html.BaseUrl, &context.TargetCollector, counterStyle, &context.footnotes)
 
-	return layoutDocument(html, rootBox, context, -1)
+	layoutDocument(html, rootBox, context, -1, emit)
+}
+
+// pageProgressionLtr returns true if the pages of a document
+// whose root element has the given style progress from left to right,
+// so that its first page is a right page.
+// See https://www.w3.org/TR/css-page-3/#progression
+func pageProgressionLtr(rootStyle pr.StyleAccessor) bool {
+	switch rootStyle.GetWritingMode() {
+	case "vertical-rl":
+		return false
+	case "vertical-lr":
+		return true
+	default:
+		return rootStyle.GetDirection() == "ltr"
+	}
 }
 
 // Initialize “context.pageMaker“.
@@ -66,20 +95,17 @@ func initializePageMaker(context *layoutContext, rootBox bo.BoxFields) {
 	// Special case the root box
 	pageBreak := rootBox.Style.GetBreakBefore()
 
-	// TODO: take care of text direction and writing mode
-	// https://www.w3.org/TR/css3-page/#progression
+	ltr := pageProgressionLtr(rootBox.Style)
 	var rightPage bool
 	switch pageBreak {
 	case "right":
 		rightPage = true
 	case "left":
 		rightPage = false
-	case "recto":
-		rightPage = rootBox.Style.GetDirection() == "ltr"
 	case "verso":
-		rightPage = rootBox.Style.GetDirection() == "rtl"
-	default:
-		rightPage = rootBox.Style.GetDirection() == "ltr"
+		rightPage = !ltr
+	default: // "recto" and "auto"
+		rightPage = ltr
 	}
 	pv, _ := rootBox.PageValues()
 	nextPage := tree.PageBreak{Break: "any", Page: pv}
@@ -108,9 +134,13 @@ func initializePageMaker(context *layoutContext, rootBox bo.BoxFields) {
 }
 
 // Lay out and yield the fixed boxes of “pages“.
+// Released pages are nil, and have no fixed boxes.
 func layoutFixedBoxes(context *layoutContext, pages []*bo.PageBox, containingPage *bo.PageBox) []Box {
 	var out []Box
 	for _, page := range pages {
+		if page == nil {
+			continue
+		}
 		for _, box := range page.FixedBoxes {
 			// As replaced boxes are never copied during layout, ensure that we
 			// have different boxes (with a possibly different layout) for
@@ -135,7 +165,7 @@ func layoutFixedBoxes(context *layoutContext, pages []*bo.PageBox, containingPag
 	return out
 }
 
-func layoutDocument(doc *tree.HTML, rootBox bo.BlockLevelBoxITF, context *layoutContext, maxLoops int) []*bo.PageBox {
+func layoutDocument(doc *tree.HTML, rootBox bo.BlockLevelBoxITF, context *layoutContext, maxLoops int, emit func(*bo.PageBox)) {
 	initializePageMaker(context, *rootBox.Box())
 	if maxLoops == -1 {
 		maxLoops = 8 // default value
@@ -146,23 +176,42 @@ func layoutDocument(doc *tree.HTML, rootBox bo.BlockLevelBoxITF, context *layout
 	)
 	actualTotalPages := 0
 
+	// Prevent repetition of bookmarks (see #1145).
+	finalizer := newPageFinalizer()
+
+	// Fixed boxes are repeated on every page, including the ones before them,
+	// so that no page is final before the end of the layout.
+	streaming := !hasFixedBoxes(rootBox)
+	queue := pageQueue{context: context, finalizer: finalizer, emit: emit}
+
 	for loop := 0; loop < maxLoops; loop += 1 {
 		if loop > 0 {
 			logger.ProgressLogger.Printf("Step 5 - Creating layout - Repagination #%d \n", loop)
 			context.footnotes = append([]Box(nil), originalFootnotes...)
 		}
 
+		var made func(pages []*bo.PageBox)
+		if loop == 0 && streaming {
+			made = queue.made
+		}
+
 		initialTotalPages := actualTotalPages
-		pages = context.makeAllPages(rootBox, doc, pages)
+		pages = context.makeAllPages(rootBox, doc, pages, made)
 		actualTotalPages = len(pages)
 
 		// Check whether another round is required
 		reloopContent := false
 		reloopPages := false
-		for _, pageData := range context.pageMaker {
+		for i, pageData := range context.pageMaker {
 			// Update pages
 			pageCounterValues := pageData.InitialPageState.CounterValues
 			pageCounterValues["pages"] = []int{actualTotalPages}
+			if i < queue.emitted {
+				// emitted pages are final : never remake them
+				context.pageMaker[i].RemakeState.ContentChanged = false
+				context.pageMaker[i].RemakeState.PagesWanted = false
+				continue
+			}
 			if pageData.RemakeState.ContentChanged {
 				reloopContent = true
 			}
@@ -177,95 +226,240 @@ func layoutDocument(doc *tree.HTML, rootBox bo.BlockLevelBoxITF, context *layout
 		}
 	}
 
-	// Calculate string-sets and bookmark-label containing page based counters
-	// when pagination is finished. No need to do that (maybe multiple times) in
-	// makePage because they dont create boxes, only appear in MarginBoxes and
-	// in the final PDF.
+	for i := queue.emitted; i < len(pages); i++ {
+		emit(finalizer.finalize(context, pages, i))
+	}
+}
 
-	// Prevent repetition of bookmarks (see #1145).
-	watchElements, watchElementsBefore, watchElementsAfter := map[*html.Node]bool{}, map[*html.Node]bool{}, map[*html.Node]bool{}
-
-	for i, page := range pages {
-		// We need the updated pageCounterValues
-		pageCounterValues := context.pageMaker[i+1].InitialPageState.CounterValues
-
-		for _, child := range bo.Descendants(page) {
-			childBox := child.Box()
-			// Only one bookmark per original box
-			if childBox.BookmarkLabel != "" {
-				var checklist map[*html.Node]bool
-				if childBox.PseudoType == "before" {
-					checklist = watchElementsBefore
-				} else if childBox.PseudoType == "after" {
-					checklist = watchElementsAfter
-				} else {
-					checklist = watchElements
-				}
+// pageQueue emits the pages made during the first layout loop
+// as soon as they are final, in order : the pages following
+// a page which is not final yet are queued until it is.
+type pageQueue struct {
+	context   *layoutContext
+	finalizer pageFinalizer
+	emit      func(*bo.PageBox)
 
-				if checklist[childBox.Element] {
-					childBox.BookmarkLabel = ""
-				} else {
-					checklist[childBox.Element] = true
-				}
+	emitted int // pages before this index are final, and have been emitted
+}
+
+// made is called each time a page is made, with the pages made so far,
+// and emits the oldest queued pages which are now final.
+func (q *pageQueue) made(pages []*bo.PageBox) {
+	for q.emitted < len(pages) && q.context.isPageFinal(pages, q.emitted) {
+		q.emit(q.finalizer.finalize(q.context, pages, q.emitted))
+		// release the boxes of the page : the next pages only
+		// need the states stored in [layoutContext.pageMaker]
+		pages[q.emitted] = nil
+		q.emitted++
+	}
+}
+
+// hasFixedBoxes returns true if one of the boxes in the tree
+// has a fixed position.
+func hasFixedBoxes(rootBox Box) bool {
+	for _, child := range bo.Descendants(rootBox) {
+		if child.Box().Style.GetPosition().String == "fixed" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPageFinal returns true if the page at [index] in [pages], the pages made so far,
+// will not change when laying out the following pages, so that it may be emitted.
+// The previous pages are supposed to be final.
+//
+// This is the case if its content does not depend on the total number of pages,
+// or on targets not laid out yet.
+func (context *layoutContext) isPageFinal(pages []*bo.PageBox, index int) bool {
+	page := pages[index]
+	remakeState := context.pageMaker[index].RemakeState
+	if remakeState.ContentChanged || remakeState.PagesWanted {
+		return false
+	}
+
+	cachedAnchors := utils.NewSet()
+	for _, v := range context.pageMaker[:len(pages)] {
+		cachedAnchors.Extend(v.RemakeState.Anchors)
+	}
+	isResolved := func(item *tree.CounterLookupItem) bool {
+		if item.MissingCounters.Has("pages") {
+			return false
+		}
+		for anchorName := range item.MissingTargetCounters {
+			if !cachedAnchors.Has(anchorName) {
+				return false
 			}
+		}
+		return true
+	}
 
-			if mLink := child.MissingLink(); mLink != nil {
-				for key, item := range context.TargetCollector.CounterLookupItems {
-					box, cssToken := key.SourceBox, key.CssToken
-					if mLink == box && cssToken != "content" {
-						if cssToken == "bookmark-label" && childBox.BookmarkLabel == "" {
-							// don't refill it!
-							continue
-						}
+	for _, item := range remakeState.ContentLookups {
+		if !isResolved(item) {
+			return false
+		}
+	}
+	// check the lookups of [box] and its descendants,
+	// restricted to string-set and bookmark-label if [onlyDeferred] is true
+	hasPendingLookups := func(box Box, onlyDeferred bool) bool {
+		for _, child := range bo.Descendants(box) {
+			mLink := child.MissingLink()
+			if mLink == nil {
+				continue
+			}
+			for key, item := range context.TargetCollector.CounterLookupItems {
+				if key.SourceBox != mLink || (onlyDeferred && key.CssToken == "content") {
+					continue
+				}
+				if !isResolved(item) {
+					return true
+				}
+			}
+		}
+		return false
+	}
 
-						item.ParseAgain(pageCounterValues)
+	// string-set and bookmark-label are only computed at the end
+	if hasPendingLookups(page, true) {
+		return false
+	}
 
-						if cssToken == "bookmark-label" {
-							childBox.BookmarkLabel = box.GetBookmarkLabel()
+	// margin boxes may require the total number of pages
+	// or targets on the following pages
+	var keywords []string
+	for _, prefix := range [4]string{"top", "bottom", "left", "right"} {
+		for _, suffix := range [6]string{"left", "center", "right", "top", "middle", "bottom"} {
+			keywords = append(keywords, fmt.Sprintf("@%s-%s", prefix, suffix))
+		}
+	}
+	keywords = append(keywords, "@top-left-corner", "@top-right-corner", "@bottom-left-corner", "@bottom-right-corner")
+	for _, keyword := range keywords {
+		style := context.styleFor.Get(page.PageType, keyword)
+		if style == nil {
+			continue
+		}
+		for _, content := range style.GetContent().Contents {
+			switch content.Type {
+			case "counter()":
+				if name, _ := content.AsCounter(); name == "pages" {
+					return false
+				}
+			case "counters()":
+				if name, _, _ := content.AsCounters(); name == "pages" {
+					return false
+				}
+			case "target-counter()", "target-counters()", "target-text()":
+				return false
+			case "element()":
+				// running elements are not laid out with their page
+				for _, boxes := range context.runningElements[content.AsStrings()[0]] {
+					for _, box := range boxes {
+						if hasPendingLookups(box, false) {
+							return false
 						}
 					}
 				}
 			}
-			// Collect the stringSets in the LayoutContext
-			stringSets := childBox.StringSet
-			for _, stringSet := range stringSets {
-				stringName, text := stringSet.Type, string(stringSet.Content.(pr.String))
-				dict := context.stringSet[stringName]
-				if dict == nil {
-					dict = make(map[int][]string)
+		}
+	}
+
+	return true
+}
+
+// pageFinalizer stores the state needed to finalize
+// the pages, which must be done in order.
+type pageFinalizer struct {
+	watchElements, watchElementsBefore, watchElementsAfter map[*html.Node]bool
+}
+
+func newPageFinalizer() pageFinalizer {
+	return pageFinalizer{watchElements: map[*html.Node]bool{}, watchElementsBefore: map[*html.Node]bool{}, watchElementsAfter: map[*html.Node]bool{}}
+}
+
+// finalize adds the margin boxes to the page at index [i], and
+// resolves the string-sets and bookmark-labels containing page based counters.
+// No need to do that (maybe multiple times) in
+// makePage because they dont create boxes, only appear in MarginBoxes and
+// in the final PDF.
+func (pf pageFinalizer) finalize(context *layoutContext, pages []*bo.PageBox, i int) *bo.PageBox {
+	page := pages[i]
+	// We need the updated pageCounterValues
+	pageCounterValues := context.pageMaker[i+1].InitialPageState.CounterValues
+
+	for _, child := range bo.Descendants(page) {
+		childBox := child.Box()
+		// Only one bookmark per original box
+		if childBox.BookmarkLabel != "" {
+			var checklist map[*html.Node]bool
+			if childBox.PseudoType == "before" {
+				checklist = pf.watchElementsBefore
+			} else if childBox.PseudoType == "after" {
+				checklist = pf.watchElementsAfter
+			} else {
+				checklist = pf.watchElements
+			}
+
+			if checklist[childBox.Element] {
+				childBox.BookmarkLabel = ""
+			} else {
+				checklist[childBox.Element] = true
+			}
+		}
+
+		if mLink := child.MissingLink(); mLink != nil {
+			for key, item := range context.TargetCollector.CounterLookupItems {
+				box, cssToken := key.SourceBox, key.CssToken
+				if mLink == box && cssToken != "content" {
+					if cssToken == "bookmark-label" && childBox.BookmarkLabel == "" {
+						// don't refill it!
+						continue
+					}
+
+					item.ParseAgain(pageCounterValues)
+
+					if cssToken == "bookmark-label" {
+						childBox.BookmarkLabel = box.GetBookmarkLabel()
+					}
 				}
-				dict[i+1] = append(dict[i+1], text)
-				context.stringSet[stringName] = dict
 			}
 		}
+		// Collect the stringSets in the LayoutContext
+		stringSets := childBox.StringSet
+		for _, stringSet := range stringSets {
+			stringName, text := stringSet.Type, string(stringSet.Content.(pr.String))
+			dict := context.stringSet[stringName]
+			if dict == nil {
+				dict = make(map[int][]string)
+			}
+			dict[i+1] = append(dict[i+1], text)
+			context.stringSet[stringName] = dict
+		}
 	}
 
-	out := make([]*bo.PageBox, len(pages))
 	// Add margin boxes
-	for i, page := range pages {
-		var rootChildren []Box
-		root, footnoteArea := page.Box().Children[0], page.Box().Children[1]
-		rootChildren = append(rootChildren, layoutFixedBoxes(context, pages[:i], page)...)
-		rootChildren = append(rootChildren, root.Box().Children...)
-		rootChildren = append(rootChildren, layoutFixedBoxes(context, pages[i+1:], page)...)
-		root.Box().Children = rootChildren
-		context.currentPage = i + 1 // pageNumber starts at 1
-
-		// pageMaker's pageState is ready for the MarginBoxes
-		state := context.pageMaker[context.currentPage].InitialPageState
-		page.Children = []Box{root}
-		if len(footnoteArea.Box().Children) != 0 {
-			page.Children = append(page.Children, footnoteArea)
-		}
-		page.Children = append(page.Children, makeMarginBoxes(context, page, state)...)
-		layoutBackgrounds(page, context.resolver.FetchImage)
-		out[i] = page
+	var rootChildren []Box
+	root, footnoteArea := page.Box().Children[0], page.Box().Children[1]
+	rootChildren = append(rootChildren, layoutFixedBoxes(context, pages[:i], page)...)
+	rootChildren = append(rootChildren, root.Box().Children...)
+	rootChildren = append(rootChildren, layoutFixedBoxes(context, pages[i+1:], page)...)
+	root.Box().Children = rootChildren
+	context.currentPage = i + 1 // pageNumber starts at 1
+
+	// pageMaker's pageState is ready for the MarginBoxes
+	state := context.pageMaker[context.currentPage].InitialPageState
+	page.Children = []Box{root}
+	if len(footnoteArea.Box().Children) != 0 {
+		page.Children = append(page.Children, footnoteArea)
+	}
+	page.Children = append(page.Children, makeMarginBoxes(context, page, state)...)
+	page.PageCounter, page.PageCounterStyle = pageNumbering(page, state)
+	page.PageLabel = context.counterStyle.RenderValueStyle(page.PageCounter, page.PageCounterStyle)
+	layoutBackgrounds(page, context.resolver.FetchImage)
 
-		if traceMode {
-			traceLogger.DumpTree(page, fmt.Sprintf("Final page %d", i))
-		}
+	if traceMode {
+		traceLogger.DumpTree(page, fmt.Sprintf("Final page %d", i))
 	}
-	return out
+	return page
 }
 
 var _ text.TextLayoutContext = (*layoutContext)(nil)
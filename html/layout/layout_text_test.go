@@ -5,8 +5,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/benoitkugler/textprocessing/pango"
 	pr "github.com/benoitkugler/webrender/css/properties"
 	bo "github.com/benoitkugler/webrender/html/boxes"
+	"github.com/benoitkugler/webrender/text"
 	tu "github.com/benoitkugler/webrender/utils/testutils"
 )
 
@@ -1309,3 +1311,42 @@ func TestContinue(t *testing.T) {
 	assertText(t, text1, "abcd")
 	assertText(t, text2, "efgh")
 }
+
+func TestTextAlignJustifyCharacters(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	// Lines without word separators are justified between characters.
+	page := renderOnePage(t, `
+      <style>
+        @font-face {src: url(weasyprint.otf); font-family: weasyprint}
+        p { text-align: justify; font-family: weasyprint; width: 5.5em }
+      </style>
+      <p><span>中文字</span>體驗&#8203;測試</p>
+      <p>ooooo&#8203;oooo</p>
+    `)
+	html := unpack1(page)
+	body := unpack1(html)
+	paragraph, latin := unpack2(body)
+	line1 := unpack1(paragraph)
+	span, text2 := unpack2(line1)
+	text1 := unpack1(span)
+	// 4 spacings of 0.125em between 5 characters,
+	// the zero width space is skipped
+	tu.AssertEqual(t, span.Box().Width, Fl(3*16+3*2))
+	tu.AssertEqual(t, text2.Box().PositionX, Fl(3*16+3*2))
+	tu.AssertEqual(t, text2.Box().Width, Fl(2*16+2))
+	tu.AssertEqual(t, line1.Box().Width, Fl(5.5*16))
+
+	// the drawn glyphs match the width of the boxes
+	for _, box := range []Box{text1, text2} {
+		layout := box.(*bo.TextBox).TextLayout.(*text.TextLayoutPango)
+		layout.ApplyJustification()
+		line, _ := layout.GetFirstLine()
+		var logicalExtents pango.Rectangle
+		line.GetExtents(nil, &logicalExtents)
+		tu.AssertEqual(t, Fl(text.PangoUnitsToFloat(logicalExtents.Width)), box.Box().Width)
+	}
+
+	// ... but not latin words
+	tu.AssertEqual(t, unpack1(unpack1(latin)).Box().Width, Fl(5*16))
+}
@@ -414,6 +414,37 @@ func standardizePageBasedCounters(style pr.ElementStyle, pseudoType string) {
 	}
 }
 
+// pageNumbering returns the value of the "page" counter in [state],
+// and the counter style used to print it in the margin boxes of [page].
+func pageNumbering(page *bo.PageBox, state tree.PageState) (int, pr.CounterStyleID) {
+	value := 0
+	if values := state.CounterValues["page"]; len(values) != 0 {
+		value = values[len(values)-1]
+	}
+	for _, child := range page.Children {
+		margin, ok := child.(*bo.MarginBox)
+		if !ok || !margin.IsGenerated {
+			continue
+		}
+		for _, content := range margin.Style.GetContent().Contents {
+			var name string
+			var style pr.CounterStyleID
+			switch content.Type {
+			case "counter()":
+				name, style = content.AsCounter()
+			case "counters()":
+				name, _, style = content.AsCounters()
+			default:
+				continue
+			}
+			if name == "page" {
+				return value, style
+			}
+		}
+	}
+	return value, pr.CounterStyleID{Name: "decimal"}
+}
+
 // Yield laid-out margin boxes for this page.
 // “state“ is the actual, up-to-date page-state from
 // “context.pageMaker[context.currentPage]“.
@@ -675,14 +706,24 @@ func (context *layoutContext) makePage(rootBox bo.BlockLevelBoxITF, pageType uti
 	pageWidth(page, context, block{Width: cbWidth})
 	pageHeight(page, context, block{Height: cbHeight})
 
+	// with a vertical writing mode, the content is laid out in
+	// a logical page, whose width and height are swapped
+	initialContainingBlock := page
+	var verticalFlow *bo.VerticalFlow
+	if mode := rootBox.Box().Style.GetWritingMode(); mode != "horizontal-tb" {
+		verticalFlow = &bo.VerticalFlow{Mode: mode, X: page.ContentBoxX(), Y: page.ContentBoxY(), Width: page.Width.V()}
+		initialContainingBlock = new(bo.PageBox)
+		*initialContainingBlock = *page
+		initialContainingBlock.Width, initialContainingBlock.Height = page.Height, page.Width
+	}
+
 	rootBox.Box().PositionX = page.ContentBoxX()
 	rootBox.Box().PositionY = page.ContentBoxY()
-	context.pageBottom = rootBox.Box().PositionY + page.Height.V()
-	initialContainingBlock := page
+	context.pageBottom = rootBox.Box().PositionY + initialContainingBlock.Height.V()
 
 	footnoteAreaStyle := context.styleFor.Get(pageType, "@footnote")
-	footnoteArea := bo.NewFootnoteAreaBox(page, footnoteAreaStyle)
-	resolvePercentages(footnoteArea, bo.MaybePoint{page.Width, page.Height}, 0)
+	footnoteArea := bo.NewFootnoteAreaBox(initialContainingBlock, footnoteAreaStyle)
+	resolvePercentages(footnoteArea, bo.MaybePoint{initialContainingBlock.Width, initialContainingBlock.Height}, 0)
 	footnoteArea.PositionX = page.ContentBoxX()
 	footnoteArea.PositionY = context.pageBottom
 
@@ -768,11 +809,13 @@ func (context *layoutContext) makePage(rootBox bo.BlockLevelBoxITF, pageType uti
 		}
 	}
 	for i := 0; i < len(positionedBoxes); i++ { // note that positionedBoxes may grow over the loop
-		absoluteLayout(context, positionedBoxes[i], page, &positionedBoxes, 0, nil)
+		absoluteLayout(context, positionedBoxes[i], initialContainingBlock, &positionedBoxes, 0, nil)
 	}
 
 	context.finishBlockFormattingContext(rootBox)
 
+	rootBox.Box().VerticalFlow = verticalFlow
+	footnoteArea.VerticalFlow = verticalFlow
 	page.Children = []Box{rootBox, footnoteArea}
 
 	// Update page counter values
@@ -919,10 +962,10 @@ func (context *layoutContext) remakePage(index int, rootBox bo.BlockLevelBoxITF,
 	case "left", "right":
 		nextPageSide = tmp.InitialNextPage.Break
 	case "recto", "verso":
-		directionLtr := rootBox.Box().Style.GetDirection() == "ltr"
+		ltr := pageProgressionLtr(rootBox.Box().Style)
 		breakVerso := tmp.InitialNextPage.Break == "verso"
 		nextPageSide = "left"
-		if directionLtr != breakVerso {
+		if ltr != breakVerso {
 			nextPageSide = "right"
 		}
 	}
@@ -994,7 +1037,9 @@ func (context *layoutContext) remakePage(index int, rootBox bo.BlockLevelBoxITF,
 
 // Return a list of laid out pages without margin boxes.
 // Re-make pages only if necessary.
-func (context *layoutContext) makeAllPages(rootBox bo.BlockLevelBoxITF, html *tree.HTML, pages []*bo.PageBox) []*bo.PageBox {
+// If not nil, “made“ is called each time a page is (re)made, with the pages
+// made so far.
+func (context *layoutContext) makeAllPages(rootBox bo.BlockLevelBoxITF, html *tree.HTML, pages []*bo.PageBox, made func(pages []*bo.PageBox)) []*bo.PageBox {
 	var (
 		out               []*bo.PageBox
 		reportedFootnotes []Box
@@ -1013,6 +1058,9 @@ func (context *layoutContext) makeAllPages(rootBox bo.BlockLevelBoxITF, html *tr
 			page, resumeAt = context.remakePage(i, rootBox, html)
 			reportedFootnotes = context.reportedFootnotes
 			out = append(out, page)
+			if made != nil {
+				made(out)
+			}
 		} else {
 			logger.ProgressLogger.Printf("Step 5 - Creating layout - Page %d (up-to-date)", i+1)
 			resumeAt = context.pageMaker[i+1].InitialResumeAt
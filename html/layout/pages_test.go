@@ -6,8 +6,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/benoitkugler/webrender/css/counters"
 	pr "github.com/benoitkugler/webrender/css/properties"
 	bo "github.com/benoitkugler/webrender/html/boxes"
+	"github.com/benoitkugler/webrender/html/tree"
+	"github.com/benoitkugler/webrender/utils"
 	tu "github.com/benoitkugler/webrender/utils/testutils"
 )
 
@@ -1789,3 +1792,91 @@ func TestRunningFloat(t *testing.T) {
       </footer>
     `)
 }
+
+func TestLayoutStream(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	// for each emitted page, returns the number of pages
+	// laid out when it was emitted
+	layoutStream := func(htmlContent string) (out []int) {
+		doc, err := tree.NewHTML(utils.InputString(htmlContent), baseUrl, nil, "")
+		tu.AssertNoErr(t, err)
+		doc = fakeHTML(doc)
+		counterStyle := make(counters.CounterStyle)
+		context := newLayoutContext(doc, nil, false, fontconfig, counterStyle)
+		rootBox := bo.BuildFormattingStructure(doc.Root, context.styleFor, context.resolver,
+			doc.BaseUrl, &context.TargetCollector, counterStyle, &context.footnotes)
+		layoutDocument(doc, rootBox, context, -1, func(page *bo.PageBox) {
+			out = append(out, len(context.pageMaker)-1)
+		})
+		return out
+	}
+
+	for _, test := range []struct {
+		css      string
+		expected []int
+	}{
+		// each page is emitted as soon as it is laid out
+		{"", []int{1, 2, 3, 4}},
+		{"@page { @bottom-center { content: counter(page) } }", []int{1, 2, 3, 4}},
+		// the total number of pages is needed
+		{"@page { @bottom-center { content: counter(page) ' of ' counter(pages) } }", []int{4, 4, 4, 4}},
+		{"@page :nth(2) { @bottom-center { content: counter(pages) } }", []int{1, 4, 4, 4}},
+		{"#p3:after { content: counter(pages) }", []int{1, 2, 4, 4}},
+		// forward reference
+		{"#p2:after { content: target-counter('#p3', page) }", []int{1, 4, 4, 4}},
+		// the bookmark label is resolved with the next page, which is queued until then
+		{"#p2 { bookmark-level: 1; bookmark-label: target-counter('#p3', page) }", []int{1, 3, 3, 4}},
+		// fixed boxes are repeated on each page
+		{"#p1 span { position: fixed }", []int{4, 4, 4, 4}},
+	} {
+		got := layoutStream(fmt.Sprintf(`
+		<style>
+			@page { size: 100px }
+			div { height: 90px }
+			%s
+		</style>
+		<div id="p1"><span>1</span></div><div id="p2"></div><div id="p3"></div><div id="p4"></div>
+		`, test.css))
+		tu.AssertEqual(t, got, test.expected)
+	}
+}
+
+func TestLayoutStreamReleasesPages(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	doc, err := tree.NewHTML(utils.InputString(`
+		<style>
+			@page { size: 100px; @bottom-center { content: counter(page) } }
+			div { height: 90px }
+			#p2 { bookmark-level: 1; bookmark-label: target-counter('#p4', page) }
+		</style>
+		<div></div><div id="p2"></div><div></div><div id="p4"></div><div></div>
+		`), baseUrl, nil, "")
+	tu.AssertNoErr(t, err)
+	doc = fakeHTML(doc)
+	counterStyle := make(counters.CounterStyle)
+	context := newLayoutContext(doc, nil, false, fontconfig, counterStyle)
+	rootBox := bo.BuildFormattingStructure(doc.Root, context.styleFor, context.resolver,
+		doc.BaseUrl, &context.TargetCollector, counterStyle, &context.footnotes)
+	initializePageMaker(context, *rootBox.Box())
+
+	// for each page made, the number of pages emitted and released
+	var emitted, released []int
+	queue := pageQueue{context: context, finalizer: newPageFinalizer(), emit: func(*bo.PageBox) {}}
+	pages := context.makeAllPages(rootBox, doc, nil, func(pages []*bo.PageBox) {
+		queue.made(pages)
+		nbReleased := 0
+		for _, page := range pages {
+			if page == nil {
+				nbReleased++
+			}
+		}
+		emitted = append(emitted, queue.emitted)
+		released = append(released, nbReleased)
+	})
+	tu.AssertEqual(t, len(pages), 5)
+	// the second page waits for the fourth one
+	tu.AssertEqual(t, emitted, []int{1, 1, 1, 4, 5})
+	tu.AssertEqual(t, released, emitted)
+}
@@ -331,7 +331,15 @@ func inlineLineWidths(context *layoutContext, box_ Box, outer, isLineStart,
 		}
 		textBox, isTextBox := child.(*bo.TextBox)
 		var lines []pr.Float
-		if bo.InlineT.IsInstance(child) {
+		if bo.RubyT.IsInstance(child) {
+			// ruby containers are not split, see [rubyBoxLayout]
+			width := adjust(child, outer, rubyContentWidth(context, child), true, true)
+			if minimum {
+				lines = []pr.Float{0, width, 0}
+			} else {
+				lines = []pr.Float{width}
+			}
+		} else if bo.InlineT.IsInstance(child) {
 			lines = inlineLineWidths(context, child, outer, isLineStart, minimum,
 				skipStack, firstLine)
 			if firstLine {
@@ -370,7 +378,7 @@ func inlineLineWidths(context *layoutContext, box_ Box, outer, isLineStart,
 				textRunes := childText
 				for newResumeIndex != -1 {
 					resumeIndex += newResumeIndex
-					tmp := text.SplitFirstLine(textRunes[resumeIndex:], textBox.Style,
+					tmp := text.SplitFirstLineLevel(textRunes[resumeIndex:], textBox.Style, textBox.BidiLevel,
 						context, maxWidth, true, isLineStart)
 					newResumeIndex = tmp.ResumeAt
 					lines = append(lines, tmp.Width)
@@ -415,6 +423,17 @@ func inlineLineWidths(context *layoutContext, box_ Box, outer, isLineStart,
 	return out
 }
 
+// rubyContentWidth returns the width of the ruby container [box],
+// made of columns as wide as the widest of the base and its annotation.
+func rubyContentWidth(context *layoutContext, box Box) pr.Float {
+	var width pr.Float
+	children := box.Box().Children
+	for i := 0; i+1 < len(children); i += 2 {
+		width += pr.Max(maxContentWidth(context, children[i], true), maxContentWidth(context, children[i+1], true))
+	}
+	return width
+}
+
 // Return the percentage contribution of a cell, column or column group.
 // https://dbaron.org/css/intrinsic/#pct-contrib
 func percentageContribution(box bo.BoxFields) pr.Float {
@@ -926,7 +945,7 @@ func trailingWhitespaceSize(context *layoutContext, box Box) pr.Float {
 			return oldBox.Box().Width.V() - strippedBox.Box().Width.V()
 		}
 	} else {
-		spli := text.SplitFirstLine(textBox.Text, textBox.Style, context, nil, false, true)
+		spli := text.SplitFirstLineLevel(textBox.Text, textBox.Style, textBox.BidiLevel, context, nil, false, true)
 		return spli.Width
 	}
 }
@@ -1138,17 +1138,17 @@ func (s *AnonymousStyle) SetFontSize(v pr.DimOrS) {
 	s.propsCache.known[pr.PFontSize] = v
 }
 
-func (s *ComputedStyle) GetFontStretch() pr.String {
-	return s.Get(pr.PFontStretch.Key()).(pr.String)
+func (s *ComputedStyle) GetFontStretch() pr.Float {
+	return s.Get(pr.PFontStretch.Key()).(pr.Float)
 }
-func (s *ComputedStyle) SetFontStretch(v pr.String) {
+func (s *ComputedStyle) SetFontStretch(v pr.Float) {
 	s.propsCache.known[pr.PFontStretch] = v
 }
 
-func (s *AnonymousStyle) GetFontStretch() pr.String {
-	return s.Get(pr.PFontStretch.Key()).(pr.String)
+func (s *AnonymousStyle) GetFontStretch() pr.Float {
+	return s.Get(pr.PFontStretch.Key()).(pr.Float)
 }
-func (s *AnonymousStyle) SetFontStretch(v pr.String) {
+func (s *AnonymousStyle) SetFontStretch(v pr.Float) {
 	s.propsCache.known[pr.PFontStretch] = v
 }
 
@@ -1166,6 +1166,20 @@ func (s *AnonymousStyle) SetFontStyle(v pr.String) {
 	s.propsCache.known[pr.PFontStyle] = v
 }
 
+func (s *ComputedStyle) GetFontSynthesis() pr.SStrings {
+	return s.Get(pr.PFontSynthesis.Key()).(pr.SStrings)
+}
+func (s *ComputedStyle) SetFontSynthesis(v pr.SStrings) {
+	s.propsCache.known[pr.PFontSynthesis] = v
+}
+
+func (s *AnonymousStyle) GetFontSynthesis() pr.SStrings {
+	return s.Get(pr.PFontSynthesis.Key()).(pr.SStrings)
+}
+func (s *AnonymousStyle) SetFontSynthesis(v pr.SStrings) {
+	s.propsCache.known[pr.PFontSynthesis] = v
+}
+
 func (s *ComputedStyle) GetFontVariant() pr.String {
 	return s.Get(pr.PFontVariant.Key()).(pr.String)
 }
@@ -2146,6 +2160,34 @@ func (s *AnonymousStyle) SetRowGap(v pr.DimOrS) {
 	s.propsCache.known[pr.PRowGap] = v
 }
 
+func (s *ComputedStyle) GetRubyAlign() pr.String {
+	return s.Get(pr.PRubyAlign.Key()).(pr.String)
+}
+func (s *ComputedStyle) SetRubyAlign(v pr.String) {
+	s.propsCache.known[pr.PRubyAlign] = v
+}
+
+func (s *AnonymousStyle) GetRubyAlign() pr.String {
+	return s.Get(pr.PRubyAlign.Key()).(pr.String)
+}
+func (s *AnonymousStyle) SetRubyAlign(v pr.String) {
+	s.propsCache.known[pr.PRubyAlign] = v
+}
+
+func (s *ComputedStyle) GetRubyPosition() pr.String {
+	return s.Get(pr.PRubyPosition.Key()).(pr.String)
+}
+func (s *ComputedStyle) SetRubyPosition(v pr.String) {
+	s.propsCache.known[pr.PRubyPosition] = v
+}
+
+func (s *AnonymousStyle) GetRubyPosition() pr.String {
+	return s.Get(pr.PRubyPosition.Key()).(pr.String)
+}
+func (s *AnonymousStyle) SetRubyPosition(v pr.String) {
+	s.propsCache.known[pr.PRubyPosition] = v
+}
+
 func (s *ComputedStyle) GetSize() pr.Point {
 	return s.Get(pr.PSize.Key()).(pr.Point)
 }
@@ -2272,6 +2314,48 @@ func (s *AnonymousStyle) SetTextDecorationStyle(v pr.String) {
 	s.propsCache.known[pr.PTextDecorationStyle] = v
 }
 
+func (s *ComputedStyle) GetTextEmphasisColor() pr.Color {
+	return s.Get(pr.PTextEmphasisColor.Key()).(pr.Color)
+}
+func (s *ComputedStyle) SetTextEmphasisColor(v pr.Color) {
+	s.propsCache.known[pr.PTextEmphasisColor] = v
+}
+
+func (s *AnonymousStyle) GetTextEmphasisColor() pr.Color {
+	return s.Get(pr.PTextEmphasisColor.Key()).(pr.Color)
+}
+func (s *AnonymousStyle) SetTextEmphasisColor(v pr.Color) {
+	s.propsCache.known[pr.PTextEmphasisColor] = v
+}
+
+func (s *ComputedStyle) GetTextEmphasisPosition() pr.Strings {
+	return s.Get(pr.PTextEmphasisPosition.Key()).(pr.Strings)
+}
+func (s *ComputedStyle) SetTextEmphasisPosition(v pr.Strings) {
+	s.propsCache.known[pr.PTextEmphasisPosition] = v
+}
+
+func (s *AnonymousStyle) GetTextEmphasisPosition() pr.Strings {
+	return s.Get(pr.PTextEmphasisPosition.Key()).(pr.Strings)
+}
+func (s *AnonymousStyle) SetTextEmphasisPosition(v pr.Strings) {
+	s.propsCache.known[pr.PTextEmphasisPosition] = v
+}
+
+func (s *ComputedStyle) GetTextEmphasisStyle() pr.TaggedString {
+	return s.Get(pr.PTextEmphasisStyle.Key()).(pr.TaggedString)
+}
+func (s *ComputedStyle) SetTextEmphasisStyle(v pr.TaggedString) {
+	s.propsCache.known[pr.PTextEmphasisStyle] = v
+}
+
+func (s *AnonymousStyle) GetTextEmphasisStyle() pr.TaggedString {
+	return s.Get(pr.PTextEmphasisStyle.Key()).(pr.TaggedString)
+}
+func (s *AnonymousStyle) SetTextEmphasisStyle(v pr.TaggedString) {
+	s.propsCache.known[pr.PTextEmphasisStyle] = v
+}
+
 func (s *ComputedStyle) GetTextIndent() pr.DimOrS {
 	return s.Get(pr.PTextIndent.Key()).(pr.DimOrS)
 }
@@ -2286,6 +2370,20 @@ func (s *AnonymousStyle) SetTextIndent(v pr.DimOrS) {
 	s.propsCache.known[pr.PTextIndent] = v
 }
 
+func (s *ComputedStyle) GetTextOrientation() pr.String {
+	return s.Get(pr.PTextOrientation.Key()).(pr.String)
+}
+func (s *ComputedStyle) SetTextOrientation(v pr.String) {
+	s.propsCache.known[pr.PTextOrientation] = v
+}
+
+func (s *AnonymousStyle) GetTextOrientation() pr.String {
+	return s.Get(pr.PTextOrientation.Key()).(pr.String)
+}
+func (s *AnonymousStyle) SetTextOrientation(v pr.String) {
+	s.propsCache.known[pr.PTextOrientation] = v
+}
+
 func (s *ComputedStyle) GetTextOverflow() pr.String {
 	return s.Get(pr.PTextOverflow.Key()).(pr.String)
 }
@@ -2300,6 +2398,20 @@ func (s *AnonymousStyle) SetTextOverflow(v pr.String) {
 	s.propsCache.known[pr.PTextOverflow] = v
 }
 
+func (s *ComputedStyle) GetTextShadow() pr.Shadows {
+	return s.Get(pr.PTextShadow.Key()).(pr.Shadows)
+}
+func (s *ComputedStyle) SetTextShadow(v pr.Shadows) {
+	s.propsCache.known[pr.PTextShadow] = v
+}
+
+func (s *AnonymousStyle) GetTextShadow() pr.Shadows {
+	return s.Get(pr.PTextShadow.Key()).(pr.Shadows)
+}
+func (s *AnonymousStyle) SetTextShadow(v pr.Shadows) {
+	s.propsCache.known[pr.PTextShadow] = v
+}
+
 func (s *ComputedStyle) GetTextTransform() pr.String {
 	return s.Get(pr.PTextTransform.Key()).(pr.String)
 }
@@ -2468,6 +2580,20 @@ func (s *AnonymousStyle) SetWordSpacing(v pr.DimOrS) {
 	s.propsCache.known[pr.PWordSpacing] = v
 }
 
+func (s *ComputedStyle) GetWritingMode() pr.String {
+	return s.Get(pr.PWritingMode.Key()).(pr.String)
+}
+func (s *ComputedStyle) SetWritingMode(v pr.String) {
+	s.propsCache.known[pr.PWritingMode] = v
+}
+
+func (s *AnonymousStyle) GetWritingMode() pr.String {
+	return s.Get(pr.PWritingMode.Key()).(pr.String)
+}
+func (s *AnonymousStyle) SetWritingMode(v pr.String) {
+	s.propsCache.known[pr.PWritingMode] = v
+}
+
 func (s *ComputedStyle) GetZIndex() pr.IntString {
 	return s.Get(pr.PZIndex.Key()).(pr.IntString)
 }
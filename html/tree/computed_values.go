@@ -30,34 +30,6 @@ var (
 		"thick":  5,
 	}
 
-	// http://www.w3.org/TR/CSS21/fonts.html#propdef-font-weight
-	fontWeightRelative = struct {
-		bolder, lighter map[int]int
-	}{
-		bolder: map[int]int{
-			100: 400,
-			200: 400,
-			300: 400,
-			400: 700,
-			500: 700,
-			600: 900,
-			700: 900,
-			800: 900,
-			900: 900,
-		},
-		lighter: map[int]int{
-			100: 100,
-			200: 100,
-			300: 100,
-			400: 100,
-			500: 100,
-			600: 400,
-			700: 400,
-			800: 700,
-			900: 700,
-		},
-	}
-
 	// Maps property names to functions returning the computed values
 	computerFunctions = [pr.NbProperties]computerFunc{}
 
@@ -143,6 +115,10 @@ var (
 		pr.PBookmarkLabel: bookmarkLabel,
 		pr.PStringSet:     stringSet,
 		pr.PLink:          link,
+		pr.PWritingMode:   writingMode,
+
+		pr.PTextEmphasisStyle: textEmphasisStyle,
+		pr.PTextShadow:        textShadow,
 	}
 
 	keywordsValues []pr.Float
@@ -689,7 +665,7 @@ func display(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty) pr.
 	if (!position.Bool && (position.String == "absolute" || position.String == "fixed")) || float_ != "none" || computer.isRootElement() {
 		if value == (pr.Display{"inline-table"}) {
 			return pr.Display{"block", "table"}
-		} else if d := value[0]; value[1] == "" && value[2] == "" && strings.HasPrefix(d, "table-") {
+		} else if d := value[0]; value[1] == "" && value[2] == "" && (strings.HasPrefix(d, "table-") || strings.HasPrefix(d, "ruby-")) {
 			return pr.Display{"block", "flow"}
 		} else if d == "inline" {
 			if value.Has("list-item") {
@@ -702,6 +678,35 @@ func display(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty) pr.
 	return value
 }
 
+// Compute the “writing-mode“ property.
+// Only the principal writing mode, set on the root element, is supported :
+// orthogonal flows are not, and the other elements use the value of their parent.
+func writingMode(computer *ComputedStyle, _ pr.KnownProp, value pr.CssProperty) pr.CssProperty {
+	if computer.parentStyle == nil {
+		return value
+	}
+	parent := computer.parentStyle.GetWritingMode()
+	if value != parent {
+		logger.WarningLogger.Printf("writing-mode: %s is only supported on the root element, ignored\n", value)
+	}
+	return parent
+}
+
+// Compute the “text-emphasis-style“ property : when only the fill
+// is specified, the shape is 'circle' in horizontal writing mode and 'sesame'
+// in vertical writing modes.
+func textEmphasisStyle(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty) pr.CssProperty {
+	value := _value.(pr.TaggedString)
+	if value.Tag != pr.Auto {
+		return value
+	}
+	shape := "circle"
+	if computer.GetWritingMode() != "horizontal-tb" {
+		shape = "sesame"
+	}
+	return pr.TaggedString{S: pr.EmphasisMarks[[2]string{value.S, shape}]}
+}
+
 // Compute the “float“ property.
 // See http://www.w3.org/TR/CSS21/visuren.html#dis-pos-flo
 func floating(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty) pr.CssProperty {
@@ -756,17 +761,45 @@ func fontWeight(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty)
 	case "bold":
 		out = 700
 	case "bolder":
-		parentValue := computer.parentStyle.GetFontWeight().Int
-		out = fontWeightRelative.bolder[parentValue]
+		out = bolderWeight(computer.parentStyle.GetFontWeight().Int)
 	case "lighter":
-		parentValue := computer.parentStyle.GetFontWeight().Int
-		out = fontWeightRelative.lighter[parentValue]
+		out = lighterWeight(computer.parentStyle.GetFontWeight().Int)
 	default:
 		out = value.Int
 	}
 	return pr.IntString{Int: out}
 }
 
+// bolderWeight returns the 'bolder' weight relative to [parent].
+// See https://drafts.csswg.org/css-fonts/#relative-weights
+func bolderWeight(parent int) int {
+	switch {
+	case parent < 350:
+		return 400
+	case parent < 550:
+		return 700
+	case parent < 900:
+		return 900
+	default:
+		return parent
+	}
+}
+
+// lighterWeight returns the 'lighter' weight relative to [parent].
+// See https://drafts.csswg.org/css-fonts/#relative-weights
+func lighterWeight(parent int) int {
+	switch {
+	case parent < 100:
+		return parent
+	case parent < 550:
+		return 100
+	case parent < 750:
+		return 400
+	default:
+		return 700
+	}
+}
+
 // Compute track breadth.
 func computeTrackBreadth(computer *ComputedStyle, value pr.DimOrS) pr.DimOrS {
 	if value.S == "auto" || value.S == "min-content" || value.S == "max-content" {
@@ -925,6 +958,18 @@ func transforms(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty)
 	return result
 }
 
+// Compute the “text-shadow“ property.
+func textShadow(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty) pr.CssProperty {
+	value := _value.(pr.Shadows)
+	result := make(pr.Shadows, len(value))
+	for index, shadow := range value {
+		lengths := _lengthOrPercentageTuple2(computer, []pr.Dimension{shadow.X, shadow.Y, shadow.Blur})
+		shadow.X, shadow.Y, shadow.Blur = lengths[0], lengths[1], lengths[2]
+		result[index] = shadow
+	}
+	return result
+}
+
 // Compute the “vertical-align“ property.
 func verticalAlign(computer *ComputedStyle, _ pr.KnownProp, _value pr.CssProperty) pr.CssProperty {
 	value := _value.(pr.DimOrS)
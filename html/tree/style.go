@@ -45,6 +45,7 @@ type StyleFor struct {
 	computedStyles map[utils.ElementKey]pr.ElementStyle
 	textContext    text.TextLayoutContext
 	sheets         []sheet
+	spotColors     pa.SpotColors
 }
 
 func newStyleFor(html *HTML, sheets []sheet, presentationalHints bool,
@@ -55,6 +56,16 @@ func newStyleFor(html *HTML, sheets []sheet, presentationalHints bool,
 		computedStyles: map[utils.ElementKey]pr.ElementStyle{},
 		sheets:         sheets,
 		textContext:    textContext,
+		spotColors:     make(pa.SpotColors),
+	}
+	// spot colors provided by the caller may be overridden by the style sheets
+	for name, alternate := range html.SpotColors {
+		out.spotColors[name] = alternate
+	}
+	for _, sh := range sheets {
+		for name, alternate := range sh.sheet.spotColors {
+			out.spotColors[name] = alternate
+		}
 	}
 
 	logger.ProgressLogger.Printf("Step 3 - Applying CSS - %d sheet(s)\n", len(sheets))
@@ -181,7 +192,7 @@ func (sf *StyleFor) setComputedStyles(element, parent Element,
 		cascaded = cascadedStyle{}
 	}
 	sf.computedStyles[key] = computedFromCascaded(element, cascaded, parentStyle,
-		rootStyle_, pseudoType, baseUrl, targetCollector, sf.textContext)
+		rootStyle_, pseudoType, baseUrl, targetCollector, sf.textContext, sf.spotColors)
 }
 
 func (s StyleFor) Get(element Element, pseudoType string) pr.ElementStyle {
@@ -335,10 +346,13 @@ type ComputedStyle struct {
 	pseudoType string
 	baseUrl    string
 	specified  pr.SpecifiedAttributes
+
+	spotColors pa.SpotColors // used to resolve the spot() references
 }
 
 func newComputedStyle(parentStyle pr.ElementStyle, cascaded cascadedStyle,
 	element Element, pseudoType string, rootStyle rootStyle, baseUrl string, textContext text.TextLayoutContext,
+	spotColors pa.SpotColors,
 ) *ComputedStyle {
 	out := &ComputedStyle{
 		propsCache: newPropsCache(),
@@ -351,6 +365,7 @@ func newComputedStyle(parentStyle pr.ElementStyle, cascaded cascadedStyle,
 		pseudoType:  pseudoType,
 		rootStyle:   rootStyle,
 		baseUrl:     baseUrl,
+		spotColors:  spotColors,
 	}
 
 	// inherit the variables
@@ -386,7 +401,7 @@ func newComputedStyle(parentStyle pr.ElementStyle, cascaded cascadedStyle,
 func (c *ComputedStyle) isRootElement() bool { return c.parentStyle == nil }
 
 func (c *ComputedStyle) Copy() pr.ElementStyle {
-	out := newComputedStyle(c.parentStyle, c.cascaded, c.element, c.pseudoType, c.rootStyle, c.baseUrl, c.textContext)
+	out := newComputedStyle(c.parentStyle, c.cascaded, c.element, c.pseudoType, c.rootStyle, c.baseUrl, c.textContext, c.spotColors)
 	out.propsCache.updateWith(c.propsCache)
 	return out
 }
@@ -515,10 +530,117 @@ func (c *ComputedStyle) Get(key pr.PropKey) pr.CssProperty {
 		// Value not computed yet: compute.
 		out = fn(c, key.KnownProp, out)
 	}
+	if resolved, err := resolveSpotColors(c.spotColors, out); err != nil {
+		// invalid at computed-value time
+		logger.WarningLogger.Printf("Ignored `%s`, %s", key, err)
+		if pr.Inherited.Has(key.KnownProp) && c.parentStyle != nil {
+			out = c.parentStyle.Get(key)
+		} else {
+			out = pr.InitialValues[key.KnownProp]
+		}
+	} else {
+		out = resolved
+	}
 	c.propsCache.Set(key, out)
 	return out
 }
 
+// resolveSpotColors replaces the spot() references found in [value]
+// by the colors declared for the document.
+// Shared values are copied before being modified.
+func resolveSpotColors(spots pa.SpotColors, value pr.CssProperty) (pr.CssProperty, error) {
+	switch value := value.(type) {
+	case pr.Color:
+		color, err := resolveSpotColor(spots, value)
+		return color, err
+	case pr.Shadows:
+		var out pr.Shadows
+		for i, shadow := range value {
+			if shadow.Color.Type != pa.ColorSpot {
+				continue
+			}
+			if out == nil {
+				out = append(pr.Shadows(nil), value...)
+			}
+			var err error
+			if out[i].Color, err = resolveSpotColor(spots, shadow.Color); err != nil {
+				return nil, err
+			}
+		}
+		if out == nil {
+			return value, nil
+		}
+		return out, nil
+	case pr.Images:
+		var out pr.Images
+		for i, image := range value {
+			resolved, changed, err := resolveGradient(spots, image)
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append(pr.Images(nil), value...)
+			}
+			out[i] = resolved
+		}
+		if out == nil {
+			return value, nil
+		}
+		return out, nil
+	case pr.Image:
+		resolved, _, err := resolveGradient(spots, value)
+		return resolved, err
+	}
+	return value, nil
+}
+
+// resolveGradient resolves the color stops of gradients,
+// returning true if [image] has been changed.
+func resolveGradient(spots pa.SpotColors, image pr.Image) (pr.Image, bool, error) {
+	switch image := image.(type) {
+	case pr.LinearGradient:
+		stops, changed, err := resolveColorStops(spots, image.ColorStops)
+		image.ColorStops = stops
+		return image, changed, err
+	case pr.RadialGradient:
+		stops, changed, err := resolveColorStops(spots, image.ColorStops)
+		image.ColorStops = stops
+		return image, changed, err
+	}
+	return image, false, nil
+}
+
+func resolveSpotColor(spots pa.SpotColors, color pr.Color) (pr.Color, error) {
+	resolved, ok := spots.Resolve(pa.Color(color))
+	if !ok {
+		return color, fmt.Errorf("undeclared spot color %s", color.Device.Spot)
+	}
+	return pr.Color(resolved), nil
+}
+
+func resolveColorStops(spots pa.SpotColors, stops pr.ColorsStops) (pr.ColorsStops, bool, error) {
+	var out pr.ColorsStops
+	for i, stop := range stops {
+		if stop.Color.Type != pa.ColorSpot {
+			continue
+		}
+		if out == nil {
+			out = append(pr.ColorsStops(nil), stops...)
+		}
+		var err error
+		if out[i].Color, err = resolveSpotColor(spots, stop.Color); err != nil {
+			return nil, false, err
+		}
+	}
+	if out == nil {
+		return stops, false, nil
+	}
+	return out, true, nil
+}
+
 // AnonymousStyle provides on demand access of computed properties,
 // optimized for anonymous boxes
 type AnonymousStyle struct {
@@ -690,7 +812,7 @@ func findStylesheets(wrapperElement *utils.HTMLNode, deviceMediaType string, url
 			// ElementTree should give us either unicode or  ASCII-only
 			// bytestrings, so we don"t need `encoding` here.
 			css, err := newCSS(utils.InputString(content), baseUrl, urlFetcher, false, deviceMediaType,
-				fontConfig, nil, pageRules, counterStyle)
+				fontConfig, nil, pageRules, counterStyle, nil)
 			if err != nil {
 				logger.WarningLogger.Printf("Invalid style %s : %s \n", content, err)
 			} else {
@@ -704,7 +826,7 @@ func findStylesheets(wrapperElement *utils.HTMLNode, deviceMediaType string, url
 				href := element.GetUrlAttribute("href", baseUrl, false)
 				if href != "" {
 					css, err := newCSS(utils.InputUrl(href), "", urlFetcher, true, deviceMediaType,
-						fontConfig, nil, pageRules, counterStyle)
+						fontConfig, nil, pageRules, counterStyle, nil)
 					if err != nil {
 						logger.WarningLogger.Printf("Failed to load stylesheet at %s : %s \n", href, err)
 					} else {
@@ -1059,17 +1181,17 @@ func declarationPrecedence(origin string, importance bool) uint8 {
 // Get a dict of computed style mixed from parent and cascaded styles.
 func ComputedFromCascaded(element Element, cascaded cascadedStyle, parentStyle pr.ElementStyle, textContext text.TextLayoutContext,
 ) pr.ElementStyle {
-	return computedFromCascaded(element, cascaded, parentStyle, rootStyle{}, "", "", nil, textContext)
+	return computedFromCascaded(element, cascaded, parentStyle, rootStyle{}, "", "", nil, textContext, nil)
 }
 
 func computedFromCascaded(element Element, cascaded cascadedStyle, parentStyle pr.ElementStyle, rootStyle_ rootStyle, pseudoType, baseUrl string,
-	targetCollector *TargetCollector, textContext text.TextLayoutContext,
+	targetCollector *TargetCollector, textContext text.TextLayoutContext, spotColors pa.SpotColors,
 ) pr.ElementStyle {
 	if cascaded == nil && parentStyle != nil {
 		return newAnonymousStyle(parentStyle)
 	}
 
-	style := newComputedStyle(parentStyle, cascaded, element, pseudoType, rootStyle_, baseUrl, textContext)
+	style := newComputedStyle(parentStyle, cascaded, element, pseudoType, rootStyle_, baseUrl, textContext, spotColors)
 	if anchor := string(style.GetAnchor()); targetCollector != nil && anchor != "" {
 		targetCollector.collectAnchor(anchor)
 	}
@@ -1259,7 +1381,7 @@ type PageRule struct {
 // ignoreImports = false
 func preprocessStylesheet(deviceMediaType, baseUrl string, stylesheetRules []pa.Compound,
 	urlFetcher utils.UrlFetcher, matcher *matcher, pageRules *[]PageRule,
-	fontConfig text.FontConfiguration, counterStyle counters.CounterStyle, ignoreImports bool,
+	fontConfig text.FontConfiguration, counterStyle counters.CounterStyle, spotColors pa.SpotColors, ignoreImports bool,
 ) {
 	for _, rule := range stylesheetRules {
 		atRule, isAtRule := rule.(pa.AtRule)
@@ -1327,7 +1449,7 @@ func preprocessStylesheet(deviceMediaType, baseUrl string, stylesheetRules []pa.
 				url = utils.UrlJoin(baseUrl, url, false, "@import")
 				if url != "" {
 					_, err := newCSS(utils.InputUrl(url), "", urlFetcher, false,
-						deviceMediaType, fontConfig, matcher, pageRules, counterStyle)
+						deviceMediaType, fontConfig, matcher, pageRules, counterStyle, spotColors)
 					if err != nil {
 						logger.WarningLogger.Printf("Failed to load stylesheet at %s : %s \n", url, err)
 					}
@@ -1346,7 +1468,7 @@ func preprocessStylesheet(deviceMediaType, baseUrl string, stylesheetRules []pa.
 				contentRules := pa.ParseRuleList(rule.Content, false, false)
 				preprocessStylesheet(
 					deviceMediaType, baseUrl, contentRules, urlFetcher,
-					matcher, pageRules, fontConfig, counterStyle, true)
+					matcher, pageRules, fontConfig, counterStyle, spotColors, true)
 			case "page":
 				data := parsePageSelectors(rule.QualifiedRule)
 				if data == nil {
@@ -1419,6 +1541,31 @@ func preprocessStylesheet(deviceMediaType, baseUrl string, stylesheetRules []pa.
 				}
 
 				counterStyle[name] = ruleDescriptors
+			case "spot-color":
+				var name string
+				if tokens := pa.RemoveWhitespace(rule.Prelude); len(tokens) == 1 {
+					switch token := tokens[0].(type) {
+					case pa.Ident:
+						name = token.Value
+					case pa.String:
+						name = token.Value
+					}
+				}
+				if name == "" {
+					logger.WarningLogger.Printf(`Invalid spot color name %s, the whole @spot-color rule was ignored at %d:%d.`,
+						pa.Serialize(rule.Prelude), rule.Pos().Line, rule.Pos().Column)
+					continue
+				}
+
+				ignoreImports = true
+				content := pa.ParseBlocksContents(rule.Content, false)
+				ruleDescriptors := validation.PreprocessSpotColorDescriptors(baseUrl, content)
+				if ruleDescriptors.AlternateColor.IsNone() {
+					logger.WarningLogger.Printf(`Missing alternate-color descriptor in "@spot-color" rule at %d:%d`+"\n",
+						rule.Pos().Line, rule.Pos().Column)
+					continue
+				}
+				spotColors.Register(name, ruleDescriptors.AlternateColor)
 			}
 		}
 	}
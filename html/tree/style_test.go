@@ -29,7 +29,7 @@ func TestDescriptors(t *testing.T) {
 	stylesheet := parser.ParseStylesheetBytes([]byte("@font-face{}"), false, false)
 	logs := tu.CaptureLogs()
 	preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-		nil, nil, false)
+		nil, nil, nil, false)
 	logs.CheckEqual([]string{
 		`Missing src descriptor in "@font-face" rule at 1:1`,
 	}, t)
@@ -37,7 +37,7 @@ func TestDescriptors(t *testing.T) {
 	stylesheet = parser.ParseStylesheetBytes([]byte("@font-face{src: url(test.woff)}"), false, false)
 	logs = tu.CaptureLogs()
 	preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-		nil, nil, false)
+		nil, nil, nil, false)
 	logs.CheckEqual([]string{
 		`Missing font-family descriptor in "@font-face" rule at 1:1`,
 	}, t)
@@ -45,7 +45,7 @@ func TestDescriptors(t *testing.T) {
 	stylesheet = parser.ParseStylesheetBytes([]byte("@font-face{font-family: test}"), false, false)
 	logs = tu.CaptureLogs()
 	preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-		nil, nil, false)
+		nil, nil, nil, false)
 	logs.CheckEqual([]string{
 		`Missing src descriptor in "@font-face" rule at 1:1`,
 	}, t)
@@ -53,7 +53,7 @@ func TestDescriptors(t *testing.T) {
 	stylesheet = parser.ParseStylesheetBytes([]byte("@font-face { font-family: test; src: wrong }"), false, false)
 	logs = tu.CaptureLogs()
 	preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-		nil, nil, false)
+		nil, nil, nil, false)
 	logs.CheckEqual([]string{
 		"Ignored `src: wrong ` at 1:33, invalid or unsupported values for a known CSS property.",
 		`Missing src descriptor in "@font-face" rule at 1:1`,
@@ -62,7 +62,7 @@ func TestDescriptors(t *testing.T) {
 	stylesheet = parser.ParseStylesheetBytes([]byte("@font-face { font-family: good, bad; src: url(test.woff) }"), false, false)
 	logs = tu.CaptureLogs()
 	preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-		nil, nil, false)
+		nil, nil, nil, false)
 	logs.CheckEqual([]string{
 		"Ignored `font-family: good, bad` at 1:14, invalid or unsupported values for a known CSS property.",
 		`Missing font-family descriptor in "@font-face" rule at 1:1`,
@@ -71,7 +71,7 @@ func TestDescriptors(t *testing.T) {
 	stylesheet = parser.ParseStylesheetBytes([]byte("@font-face { font-family: good, bad; src: really bad }"), false, false)
 	logs = tu.CaptureLogs()
 	preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-		nil, nil, false)
+		nil, nil, nil, false)
 	logs.CheckEqual([]string{
 		"Ignored `font-family: good, bad` at 1:14, invalid or unsupported values for a known CSS property.",
 		"Ignored `src: really bad ` at 1:38, invalid or unsupported values for a known CSS property.",
@@ -579,6 +579,60 @@ func TestFontSize(t *testing.T) {
 	}
 }
 
+func TestFontWeightRelative(t *testing.T) {
+	defer tu.CaptureLogs().AssertNoLogs(t)
+
+	html_, err := newHtml(utils.InputString("<p>a<span>b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := fakeHTML(*html_)
+	for _, te := range []struct {
+		parent, child string
+		expected      int
+	}{
+		{"450", "bolder", 700},
+		{"340", "bolder", 400},
+		{"950", "bolder", 950},
+		{"650", "lighter", 400},
+		{"50", "lighter", 50},
+		{"875", "normal", 400},
+		{"875", "inherit", 875},
+	} {
+		css, err := NewCSSDefault(utils.InputString(fmt.Sprintf("p{font-weight:%s}span{font-weight:%s}", te.parent, te.child)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		styleFor := GetAllComputedStyles(document, []CSS{css}, false, nil, nil, nil, nil, false, nil)
+		span := document.Root.NodeChildren(true)[1].NodeChildren(true)[0].NodeChildren(true)[1]
+		if got := styleFor.Get(span, "").GetFontWeight().Int; got != te.expected {
+			t.Fatalf("%s %s: expected %d, got %d", te.parent, te.child, te.expected, got)
+		}
+	}
+}
+
+func TestWritingModeRoot(t *testing.T) {
+	html_, err := newHtml(utils.InputString("<p>a<span>b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := fakeHTML(*html_)
+	css, err := NewCSSDefault(utils.InputString("html{writing-mode:vertical-rl}span{writing-mode:horizontal-tb}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := tu.CaptureLogs()
+	styleFor := GetAllComputedStyles(document, []CSS{css}, false, nil, nil, nil, nil, false, nil)
+	p := document.Root.NodeChildren(true)[1].NodeChildren(true)[0]
+	span := p.NodeChildren(true)[1]
+	// the principal writing mode is used by all the elements
+	tu.AssertEqual(t, styleFor.Get(p, "").GetWritingMode(), pr.String("vertical-rl"))
+	tu.AssertEqual(t, styleFor.Get(span, "").GetWritingMode(), pr.String("vertical-rl"))
+	logs.CheckEqual([]string{
+		"writing-mode: horizontal-tb is only supported on the root element, ignored",
+	}, t)
+}
+
 func TestCounterStyleInvalid(t *testing.T) {
 	inputs := []string{
 		"@counter-style test {system: alphabetic; symbols: a}",
@@ -591,7 +645,7 @@ func TestCounterStyleInvalid(t *testing.T) {
 		cp := tu.CaptureLogs()
 
 		preprocessStylesheet("print", "http://wp.org/foo/", stylesheet, nil, nil, nil,
-			nil, make(counters.CounterStyle), false)
+			nil, make(counters.CounterStyle), nil, false)
 		if len(cp.Logs()) == 0 {
 			t.Fatal("expected logs")
 		}
@@ -28,6 +28,11 @@ type HTML struct {
 	UAStyleSheet   CSS
 	FormStyleSheet CSS
 	PHStyleSheet   CSS
+
+	// SpotColors are the spot colors available to the "spot()" function
+	// of the style sheets, in addition to the ones declared by "@spot-color" rules.
+	// It is not modified during rendering.
+	SpotColors parser.SpotColors
 }
 
 // `baseUrl` is the base used to resolve relative URLs
@@ -122,11 +127,11 @@ func init() {
 		panic(fmt.Sprintf("invalid embedded stylesheet: %s", err))
 	}
 	UACounterStyle = make(counters.CounterStyle)
-	Html5UAStylesheet, err = newCSS(utils.InputString(html5UACSS), "", nil, false, "", nil, nil, nil, UACounterStyle)
+	Html5UAStylesheet, err = newCSS(utils.InputString(html5UACSS), "", nil, false, "", nil, nil, nil, UACounterStyle, nil)
 	if err != nil {
 		panic(fmt.Sprintf("invalid embedded stylesheet: %s", err))
 	}
-	Html5UAFormsStylesheet, err = newCSS(utils.InputString(html5UAFormsCSS), "", nil, false, "", nil, nil, nil, UACounterStyle)
+	Html5UAFormsStylesheet, err = newCSS(utils.InputString(html5UAFormsCSS), "", nil, false, "", nil, nil, nil, UACounterStyle, nil)
 	if err != nil {
 		panic(fmt.Sprintf("invalid embedded stylesheet: %s", err))
 	}
@@ -138,9 +143,10 @@ func init() {
 
 // CSS represents a parsed CSS stylesheet.
 type CSS struct {
-	matcher   matcher
-	pageRules []PageRule
-	baseUrl   string
+	matcher    matcher
+	pageRules  []PageRule
+	spotColors parser.SpotColors
+	baseUrl    string
 }
 
 // newCSS creates an instance, in the same way as [HTML], except that
@@ -153,7 +159,7 @@ type CSS struct {
 func newCSS(input utils.ContentInput, baseUrl string,
 	urlFetcher utils.UrlFetcher, checkMimeType bool,
 	mediaType string, fontConfig text.FontConfiguration, matcher *matcher,
-	pageRules *[]PageRule, counterStyle counters.CounterStyle,
+	pageRules *[]PageRule, counterStyle counters.CounterStyle, spotColors parser.SpotColors,
 ) (CSS, error) {
 	logger.ProgressLogger.Printf("Step 2 - Fetching and parsing CSS - %s", input)
 
@@ -180,18 +186,22 @@ func newCSS(input utils.ContentInput, baseUrl string,
 	if counterStyle == nil {
 		counterStyle = make(counters.CounterStyle)
 	}
+	if spotColors == nil {
+		spotColors = make(parser.SpotColors)
+	}
 
 	out := CSS{baseUrl: ressource.BaseUrl}
 	preprocessStylesheet(mediaType, ressource.BaseUrl, stylesheet, urlFetcher, matcher,
-		pageRules, fontConfig, counterStyle, false)
+		pageRules, fontConfig, counterStyle, spotColors, false)
 	out.matcher = *matcher
 	out.pageRules = *pageRules
+	out.spotColors = spotColors
 	return out, nil
 }
 
 // NewCSSDefault processes a CSS input.
 func NewCSSDefault(input utils.ContentInput) (CSS, error) {
-	return newCSS(input, "", nil, false, "", nil, nil, nil, nil)
+	return newCSS(input, "", nil, false, "", nil, nil, nil, nil, nil)
 }
 
 func (c CSS) IsNone() bool {
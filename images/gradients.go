@@ -64,7 +64,7 @@ func processColorStops(gradientLineSize pr.Float, positions_ []pr.Dimension) []p
 }
 
 // http://drafts.csswg.org/csswg/css-images-3/#find-the-average-color-of-a-gradient
-func gradientAverageColor(colors []Color, positions []pr.Fl) Color {
+func gradientAverageColor(colors []parser.Color, positions []pr.Fl) parser.Color {
 	nbStops := len(positions)
 	if nbStops <= 1 || nbStops != len(colors) {
 		panic(fmt.Sprintf("expected same length, at least 2, got %d, %d", nbStops, len(colors)))
@@ -80,7 +80,9 @@ func gradientAverageColor(colors []Color, positions []pr.Fl) Color {
 	premulG := make([]utils.Fl, nbStops)
 	premulB := make([]utils.Fl, nbStops)
 	alpha := make([]utils.Fl, nbStops)
-	for i, col := range colors {
+	weights := make([]utils.Fl, nbStops)
+	for i, col_ := range colors {
+		col := col_.RGBA
 		premulR[i] = col.R * col.A
 		premulG[i] = col.G * col.A
 		premulB[i] = col.B * col.A
@@ -92,26 +94,32 @@ func gradientAverageColor(colors []Color, positions []pr.Fl) Color {
 		i := i_ + 1
 		weight := utils.Fl((position - positions[i-1]) / totalWeight)
 		j := i - 1
+		weights[j] += weight
 		resultR += premulR[j] * weight
 		resultG += premulG[j] * weight
 		resultB += premulB[j] * weight
 		resultA += alpha[j] * weight
 		j = i
+		weights[j] += weight
 		resultR += premulR[j] * weight
 		resultG += premulG[j] * weight
 		resultB += premulB[j] * weight
 		resultA += alpha[j] * weight
 	}
+	out := parser.Color{Type: parser.ColorRGBA}
 	// Un-premultiply:
 	if resultA != 0 {
-		return Color{
+		out.RGBA = parser.RGBA{
 			R: resultR / resultA,
 			G: resultG / resultA,
 			B: resultB / resultA,
 			A: resultA,
 		}
 	}
-	return Color{}
+	if device, ok := parser.AverageDeviceColor(colors, weights); ok {
+		out.Device = device
+	}
+	return out
 }
 
 type layouter interface {
@@ -132,7 +140,7 @@ func newGradient(colorStops []pr.ColorStop, repeating bool) gradient {
 	self.colors = make([]Color, len(colorStops))
 	self.stopPositions = make([]pr.Dimension, len(colorStops))
 	for i, v := range colorStops {
-		self.colors[i] = v.Color.RGBA
+		self.colors[i] = parser.Color(v.Color)
 		self.stopPositions[i] = v.Position
 	}
 	self.repeating = repeating
@@ -150,7 +158,7 @@ func (g gradient) Draw(dst backend.Canvas, _ text.TextLayoutContext, concreteWid
 
 	if layout.Kind == "solid" {
 		dst.Rectangle(0, 0, concreteWidth, concreteHeight)
-		dst.State().SetColorRgba(layout.Colors[0], false)
+		dst.State().SetColor(layout.Color(0), false)
 		dst.Paint(backend.FillNonZero)
 		return
 	}
@@ -176,7 +184,7 @@ func NewLinearGradient(from pr.LinearGradient) LinearGradient {
 func (lg LinearGradient) Layout(width, height pr.Float) backend.GradientLayout {
 	// Only one color, render the gradient as a solid color
 	if len(lg.colors) == 1 {
-		return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "solid"}, Colors: []parser.RGBA{lg.colors[0]}}
+		return svg.NewGradientLayout(backend.GradientKind{Kind: "solid"}, nil, []parser.Color{lg.colors[0]})
 	}
 	// (dx, dy) is the unit vector giving the direction of the gradient.
 	// Positive dx: right, positive dy: down.
@@ -219,7 +227,7 @@ func (lg LinearGradient) Layout(width, height pr.Float) backend.GradientLayout {
 		// extend color stops that are not displayed
 		if positions[0] == positions[1] {
 			positions = append([]pr.Fl{positions[0] - 1}, positions...)
-			colors = append([]parser.RGBA{colors[0]}, colors...)
+			colors = append([]parser.Color{colors[0]}, colors...)
 		}
 		if positions[len(positions)-2] == positions[len(positions)-1] {
 			positions = append(positions, positions[len(positions)-1]+1)
@@ -279,7 +287,7 @@ func handleDegenerateRadial(sizeX, sizeY pr.Float) (pr.Float, pr.Float) {
 
 func (rg RadialGradient) Layout(width, height pr.Float) backend.GradientLayout {
 	if len(rg.colors) == 1 {
-		return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "solid"}, Colors: []parser.RGBA{rg.colors[0]}}
+		return svg.NewGradientLayout(backend.GradientKind{Kind: "solid"}, nil, []parser.Color{rg.colors[0]})
 	}
 	originX, centerX_, originY, centerY_ := rg.center.OriginX, rg.center.Pos[0], rg.center.OriginY, rg.center.Pos[1]
 	centerX := pr.ResolvePercentage(centerX_.ToValue(), width).V()
@@ -301,7 +309,7 @@ func (rg RadialGradient) Layout(width, height pr.Float) backend.GradientLayout {
 		// extend color stops that are not displayed
 		if positions[0] > 0 && positions[0] == positions[1] {
 			positions = append([]pr.Fl{0}, positions...)
-			colors = append([]parser.RGBA{colors[0]}, colors...)
+			colors = append([]parser.Color{colors[0]}, colors...)
 		}
 		if positions[len(positions)-2] == positions[len(positions)-1] {
 			positions = append(positions, positions[len(positions)-1]+1)
@@ -324,7 +332,7 @@ func (rg RadialGradient) Layout(width, height pr.Float) backend.GradientLayout {
 			if positions[len(positions)-1] <= 0 {
 				// All stops are negatives,
 				// everything is "padded" with the last color.
-				return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "solid"}, Colors: []parser.RGBA{rg.colors[len(rg.colors)-1]}}
+				return svg.NewGradientLayout(backend.GradientKind{Kind: "solid"}, nil, []parser.Color{rg.colors[len(rg.colors)-1]})
 			}
 
 			for i, position := range positions {
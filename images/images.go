@@ -11,6 +11,7 @@ import (
 	"github.com/benoitkugler/webrender/css/parser"
 	pr "github.com/benoitkugler/webrender/css/properties"
 	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/matrix"
 	"github.com/benoitkugler/webrender/svg"
 	"github.com/benoitkugler/webrender/text"
 	"github.com/benoitkugler/webrender/utils"
@@ -21,7 +22,7 @@ import (
 	_ "image/png"
 )
 
-type Color = parser.RGBA
+type Color = parser.Color
 
 // Image is the common interface for supported image formats,
 // such as gradients, SVG, or JPEG, PNG, etc...
@@ -237,3 +238,40 @@ func (s SVGImage) GetIntrinsicSize(_, fontSize pr.Float) (pr.MaybeFloat, pr.Mayb
 func (img SVGImage) Draw(dst backend.Canvas, textContext text.TextLayoutContext, concreteWidth, concreteHeight pr.Fl, imageRendering string) {
 	img.icon.Draw(dst, concreteWidth, concreteHeight, textContext)
 }
+
+// verticalImage displays an image upright in a vertical writing mode,
+// whose content is laid out in a rotated (or reflected) frame :
+// the intrinsic dimensions are swapped, and the
+// transformation of the frame is reverted when drawing.
+type verticalImage struct {
+	Image
+	rl bool // true for 'vertical-rl', false for 'vertical-lr'
+}
+
+// NewVerticalImage wraps [img] so that it is displayed upright
+// in a 'vertical-rl' (if [rl] is true) or 'vertical-lr' writing mode.
+func NewVerticalImage(img Image, rl bool) Image {
+	if img == nil {
+		return nil
+	}
+	return verticalImage{Image: img, rl: rl}
+}
+
+func (img verticalImage) GetIntrinsicSize(imageResolution, fontSize pr.Float) (width, height, ratio pr.MaybeFloat) {
+	width, height, ratio = img.Image.GetIntrinsicSize(imageResolution, fontSize)
+	if pr.Is(ratio) {
+		ratio = 1 / ratio.V()
+	}
+	return height, width, ratio
+}
+
+func (img verticalImage) Draw(dst backend.Canvas, textContext text.TextLayoutContext, concreteWidth, concreteHeight pr.Fl, imageRendering string) {
+	dst.OnNewStack(func() {
+		if img.rl { // revert the clockwise rotation
+			dst.State().Transform(matrix.New(0, -1, 1, 0, 0, concreteHeight))
+		} else { // revert the transposition
+			dst.State().Transform(matrix.New(0, 1, 1, 0, 0, 0))
+		}
+		img.Image.Draw(dst, textContext, concreteHeight, concreteWidth, imageRendering)
+	})
+}
@@ -441,6 +441,23 @@ class InlineBox(InlineLevelBox, ParentBox):
                 self.border_width(), self.margin_height())
 
 
+class RubyBox(InlineBox):
+    """Box for elements with ``display: ruby``.
+
+    Its children are ruby bases, each one followed by its annotation,
+    and are laid out as a single unbreakable unit.
+
+    """
+
+
+class RubyBaseBox(InlineBox):
+    """Box for elements with ``display: ruby-base``"""
+
+
+class RubyTextBox(InlineBox):
+    """Box for elements with ``display: ruby-text``"""
+
+
 class TextBox(InlineLevelBox):
     """A box that contains only text and has no box children.
 
@@ -189,7 +189,7 @@ func (t *textSpan) draw(dst backend.Canvas, attrs *attributes, svg *SVGImage, di
 	var (
 		bbox   Rectangle
 		texts  []backend.TextDrawing
-		drawer = drawText.Context{Output: dst, Fonts: svg.textContext.Fonts()}
+		drawer = drawText.Context{Output: dst, Fonts: svg.textContext.Fonts(), ColorFonts: make(drawText.ColorFonts), SVGGlyphs: DrawGlyph}
 	)
 	for i, r := range chars {
 		hasX, hasY := i < len(xs), i < len(ys)
@@ -247,10 +247,22 @@ func (t *textSpan) draw(dst backend.Canvas, attrs *attributes, svg *SVGImage, di
 
 		layout.ApplyJustification()
 
-		doFill, doStroke := svg.applyPainters(dst, &svgNode{graphicContent: t, attributes: *attrs}, dims)
+		node := &svgNode{graphicContent: t, attributes: *attrs}
+		doFill, doStroke := svg.applyPainters(dst, node, dims)
+		drawing := drawer.CreateFirstLine(layout, "none", pr.TaggedString{Tag: pr.None}, scaleX, xPosition, yPosition, angle)
+		if drawing.Embolden != 0 && doFill && !doStroke {
+			// synthetic bold : also stroke the glyphs, with the fill paint
+			svg.applyPainter(dst, node, attrs.fill, attrs.fillOpacity, dims, true)
+			dst.State().SetLineWidth(drawing.Embolden)
+			doStroke = true
+		}
 		dst.State().SetTextPaint(newPaintOp(doFill, doStroke, false))
-		texts = append(texts,
-			drawer.CreateFirstLine(layout, "none", pr.TaggedString{Tag: pr.None}, scaleX, xPosition, yPosition, angle))
+		if doFill {
+			foreground := attrs.fill.color.RGBA
+			foreground.A *= attrs.fillOpacity
+			drawer.DrawColorGlyphs(&drawing, foreground)
+		}
+		texts = append(texts, drawing)
 	}
 
 	dst.OnNewStack(func() {
@@ -11,9 +11,9 @@ import (
 // this file exposes gradient functions
 // shared by svg and css rendering engine.
 
-func reverseColors(a []parser.RGBA) []parser.RGBA {
+func reverseColors(a []parser.Color) []parser.Color {
 	n := len(a)
-	out := make([]parser.RGBA, n)
+	out := make([]parser.Color, n)
 	for i := range a {
 		out[n-1-i] = a[i]
 	}
@@ -48,7 +48,7 @@ func normalizeStopPositions(positions []Fl) (Fl, Fl) {
 }
 
 // http://drafts.csswg.org/csswg/css-images-3/#find-the-average-color-of-a-gradient
-func gradientAverageColor(colors []parser.RGBA, positions []Fl) parser.RGBA {
+func gradientAverageColor(colors []parser.Color, positions []Fl) parser.Color {
 	nbStops := len(positions)
 	if nbStops <= 1 || nbStops != len(colors) {
 		panic(fmt.Sprintf("expected same length, at least 2, got %d, %d", nbStops, len(colors)))
@@ -64,7 +64,9 @@ func gradientAverageColor(colors []parser.RGBA, positions []Fl) parser.RGBA {
 	premulG := make([]utils.Fl, nbStops)
 	premulB := make([]utils.Fl, nbStops)
 	alpha := make([]utils.Fl, nbStops)
-	for i, col := range colors {
+	weights := make([]utils.Fl, nbStops)
+	for i, col_ := range colors {
+		col := col_.RGBA
 		premulR[i] = col.R * col.A
 		premulG[i] = col.G * col.A
 		premulB[i] = col.B * col.A
@@ -76,26 +78,39 @@ func gradientAverageColor(colors []parser.RGBA, positions []Fl) parser.RGBA {
 		i := i_ + 1
 		weight := utils.Fl((position - positions[i-1]) / totalWeight)
 		j := i - 1
+		weights[j] += weight
 		resultR += premulR[j] * weight
 		resultG += premulG[j] * weight
 		resultB += premulB[j] * weight
 		resultA += alpha[j] * weight
 		j = i
+		weights[j] += weight
 		resultR += premulR[j] * weight
 		resultG += premulG[j] * weight
 		resultB += premulB[j] * weight
 		resultA += alpha[j] * weight
 	}
+	out := parser.Color{Type: parser.ColorRGBA}
 	// Un-premultiply:
 	if resultA != 0 {
-		return parser.RGBA{
+		out.RGBA = parser.RGBA{
 			R: resultR / resultA,
 			G: resultG / resultA,
 			B: resultB / resultA,
 			A: resultA,
 		}
 	}
-	return parser.RGBA{}
+	if device, ok := parser.AverageDeviceColor(colors, weights); ok {
+		out.Device = device
+	}
+	return out
+}
+
+// NewGradientLayout returns a gradient layout, without vertical scaling.
+func NewGradientLayout(kind backend.GradientKind, positions []Fl, colors []parser.Color) backend.GradientLayout {
+	out := backend.GradientLayout{ScaleY: 1, GradientKind: kind, Positions: positions}
+	out.SetColors(colors)
+	return out
 }
 
 // GradientSpread defines how a gradient should be repeated.
@@ -109,14 +124,14 @@ const (
 
 // LinearGradient handle spread (repeat) for linear gradients
 // It is used for SVG and CSS gradient rendering.
-func (spread GradientSpread) LinearGradient(positions []Fl, colors []parser.RGBA, x1, y1, dx, dy, vectorLength Fl) backend.GradientLayout {
+func (spread GradientSpread) LinearGradient(positions []Fl, colors []parser.Color, x1, y1, dx, dy, vectorLength Fl) backend.GradientLayout {
 	first, last := normalizeStopPositions(positions)
 	if spread != NoRepeat {
 		// Render as a solid color if the first and last positions are equal
 		// See https://drafts.csswg.org/css-images-3/#repeating-gradients
 		if first == last {
 			color := gradientAverageColor(colors, positions)
-			return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "solid"}, Colors: []parser.RGBA{color}}
+			return NewGradientLayout(backend.GradientKind{Kind: "solid"}, nil, []parser.Color{color})
 		}
 
 		// Define defined gradient length and steps between positions
@@ -130,7 +145,7 @@ func (spread GradientSpread) LinearGradient(positions []Fl, colors []parser.RGBA
 		// Create cycles used to add colors
 		var (
 			nextSteps, previousSteps   []Fl
-			nextColors, previousColors []parser.RGBA
+			nextColors, previousColors []parser.Color
 		)
 		if spread == Repeat {
 			nextSteps = append([]Fl{0}, positionSteps...)
@@ -155,7 +170,7 @@ func (spread GradientSpread) LinearGradient(positions []Fl, colors []parser.RGBA
 		// Add colors before last step
 		for i := 0; first > 0; i++ {
 			step := previousSteps[i%len(previousSteps)]
-			colors = append([]parser.RGBA{previousColors[i%len(previousColors)]}, colors...)
+			colors = append([]parser.Color{previousColors[i%len(previousColors)]}, colors...)
 			positions = append([]Fl{positions[0] - step}, positions...)
 			first -= step * stopLength
 		}
@@ -167,17 +182,17 @@ func (spread GradientSpread) LinearGradient(positions []Fl, colors []parser.RGBA
 		x1 + dx*last,
 		y1 + dy*last,
 	}
-	return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "linear", Coords: points}, Positions: positions, Colors: colors}
+	return NewGradientLayout(backend.GradientKind{Kind: "linear", Coords: points}, positions, colors)
 }
 
-func (spread GradientSpread) RadialGradient(positions []Fl, colors []parser.RGBA, fx, fy, fr, cx, cy, r, width, height Fl) backend.GradientLayout {
+func (spread GradientSpread) RadialGradient(positions []Fl, colors []parser.Color, fx, fy, fr, cx, cy, r, width, height Fl) backend.GradientLayout {
 	first, last := normalizeStopPositions(positions)
 
 	if spread != NoRepeat && first == last {
 		// Render as a solid color if the first and last positions are equal
 		// See https://drafts.csswg.org/css-images-3/#repeating-gradients
 		color := gradientAverageColor(colors, positions)
-		return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "solid"}, Colors: []parser.RGBA{color}}
+		return NewGradientLayout(backend.GradientKind{Kind: "solid"}, nil, []parser.Color{color})
 	}
 
 	// Define the coordinates of the gradient circles
@@ -189,13 +204,13 @@ func (spread GradientSpread) RadialGradient(positions []Fl, colors []parser.RGBA
 		circles, positions, colors = spread.repeatRadial(width, height, circles, positions, colors)
 	}
 
-	return backend.GradientLayout{ScaleY: 1, GradientKind: backend.GradientKind{Kind: "radial", Coords: circles}, Positions: positions, Colors: colors}
+	return NewGradientLayout(backend.GradientKind{Kind: "radial", Coords: circles}, positions, colors)
 }
 
 // points = [fx, fy, fr, cx, cy, r]
-func (spread GradientSpread) repeatRadial(width, height Fl, points [6]Fl, positions []Fl, colors []parser.RGBA) ([6]Fl, []Fl, []parser.RGBA) {
+func (spread GradientSpread) repeatRadial(width, height Fl, points [6]Fl, positions []Fl, colors []parser.Color) ([6]Fl, []Fl, []parser.Color) {
 	// Keep original lists and values, they’re useful
-	originalColors := append([]parser.RGBA{}, colors...)
+	originalColors := append([]parser.Color{}, colors...)
 	originalPositions := append([]Fl{}, positions...)
 	gradientLength := points[5] - points[2]
 
@@ -212,7 +227,7 @@ func (spread GradientSpread) repeatRadial(width, height Fl, points [6]Fl, positi
 		// Repeat colors and extrapolate positions
 		repeat := 1 + repeatAfter
 
-		colors = make([]parser.RGBA, len(colors)*repeat)
+		colors = make([]parser.Color, len(colors)*repeat)
 		reversedColors := reverseColors(originalColors)
 		tmpPositions := make([]Fl, 0, len(positions)*repeat)
 		for i := 0; i < repeat; i++ {
@@ -301,10 +316,10 @@ func (spread GradientSpread) repeatRadial(width, height Fl, points [6]Fl, positi
 			color := originalColors[LC-i]
 			nextColor := originalColors[LC-(i-1)]
 			nextPosition := originalPositions[LP-(i-1)]
-			averageColors := []parser.RGBA{color, color, nextColor, nextColor}
+			averageColors := []parser.Color{color, color, nextColor, nextColor}
 			averagePositions := []Fl{position, ratio, ratio, nextPosition}
 			zeroColor := gradientAverageColor(averageColors, averagePositions)
-			colors = append(append([]parser.RGBA{zeroColor}, originalColors[LC-(i-1):]...), colors...)
+			colors = append(append([]parser.Color{zeroColor}, originalColors[LC-(i-1):]...), colors...)
 			tmp := originalPositions[LP-(i-1):]
 			newPositions := make([]Fl, len(tmp))
 			for j, position := range tmp {
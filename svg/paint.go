@@ -13,14 +13,14 @@ import (
 
 // handle painter for fill and stroke values
 
-// painter is either a simple RGBA color,
+// painter is either a simple color,
 // or a reference to a more complex `paintServer`
 type painter struct {
 	// value of the url attribute, refering
 	// to a paintServer element
 	refID string
 
-	color parser.RGBA
+	color parser.Color
 
 	// if 'false', no painting occurs (not the same as black)
 	valid bool
@@ -45,7 +45,11 @@ func newPainter(attr string) (painter, error) {
 
 	color := parser.ParseColorString(attr)
 	// currentColor has been resolved during tree building
-	out.color = color.RGBA
+	if color.Type == parser.ColorSpot {
+		// spot colors are only declared by HTML documents
+		color = parser.Color{}
+	}
+	out.color = color
 	out.valid = true
 
 	return out, nil
@@ -137,8 +141,8 @@ func (svg *SVGImage) applyPainter(dst backend.Canvas, node *svgNode, pt painter,
 		} // else default to a plain color
 	}
 
-	pt.color.A *= opacity // apply the opacity factor
-	dst.State().SetColorRgba(pt.color, stroke)
+	pt.color.RGBA.A *= opacity // apply the opacity factor
+	dst.State().SetColor(pt.color, stroke)
 }
 
 // gradient or pattern
@@ -239,7 +243,7 @@ type gradient struct {
 	spreadMethod GradientSpread // default to NoRepeat
 
 	positions []Value
-	colors    []parser.RGBA
+	colors    []parser.Color
 
 	transforms []transform
 
@@ -249,7 +253,7 @@ type gradient struct {
 // parse a linearGradient or radialGradient node
 func newGradient(node *cascadedNode) (out gradient, err error) {
 	out.positions = make([]Value, len(node.children))
-	out.colors = make([]parser.RGBA, len(node.children))
+	out.colors = make([]parser.Color, len(node.children))
 	for i, child := range node.children {
 		out.positions[i], err = parseValue(child.attrs["offset"])
 		if err != nil {
@@ -260,9 +264,12 @@ func newGradient(node *cascadedNode) (out gradient, err error) {
 		if !has {
 			sc = "black"
 		}
-		stopColor := parser.ParseColorString(sc).RGBA
+		stopColor := parser.ParseColorString(sc)
+		if stopColor.Type == parser.ColorSpot {
+			stopColor = parser.Color{}
+		}
 
-		stopColor.A, err = parseOpacity(child.attrs["stop-opacity"])
+		stopColor.RGBA.A, err = parseOpacity(child.attrs["stop-opacity"])
 		if err != nil {
 			return out, err
 		}
@@ -308,7 +315,7 @@ func (gr gradient) paint(dst backend.Canvas, node *svgNode, opacity Fl, dims dra
 	}
 
 	if len(gr.colors) == 1 { // actually solid
-		dst.State().SetColorRgba(gr.colors[0], stroke)
+		dst.State().SetColor(gr.colors[0], stroke)
 		return true
 	}
 
@@ -331,13 +338,13 @@ func (gr gradient) paint(dst backend.Canvas, node *svgNode, opacity Fl, dims dra
 		positions[i] = utils.MaxF(pos, previousPos) // ensure positions is increasing
 		previousPos = pos
 	}
-	colors := append([]parser.RGBA(nil), gr.colors...)
+	colors := append([]parser.Color(nil), gr.colors...)
 
 	switch gr.spreadMethod {
 	case Repeat, Reflect:
 		if positions[0] > 0 {
 			positions = append([]Fl{0}, positions...)
-			colors = append([]parser.RGBA{colors[0]}, colors...)
+			colors = append([]parser.Color{colors[0]}, colors...)
 		}
 		if positions[len(positions)-1] < 1 {
 			positions = append(positions, 1)
@@ -353,7 +360,7 @@ func (gr gradient) paint(dst backend.Canvas, node *svgNode, opacity Fl, dims dra
 			} else {
 				positions = append([]Fl{positions[0] - 1}, positions...)
 			}
-			colors = append([]parser.RGBA{colors[0]}, colors...)
+			colors = append([]parser.Color{colors[0]}, colors...)
 		}
 		if L := len(positions); positions[L-2] == positions[L-1] {
 			positions = append(positions, positions[L-1]+1)
@@ -442,7 +449,7 @@ func (gr gradient) paint(dst backend.Canvas, node *svgNode, opacity Fl, dims dra
 
 	if laidOutGradient.Kind == "solid" {
 		dst.Rectangle(0, 0, width, height)
-		dst.State().SetColorRgba(laidOutGradient.Colors[0], false)
+		dst.State().SetColor(laidOutGradient.Color(0), false)
 		dst.Paint(backend.FillNonZero)
 		return true
 	}
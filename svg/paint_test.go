@@ -15,22 +15,22 @@ func Test_newPainter(t *testing.T) {
 	}{
 		{
 			"red",
-			painter{"", parser.ColorKeywords["red"].RGBA, true},
+			painter{"", parser.ColorKeywords["red"], true},
 			false,
 		},
 		{
 			"",
-			painter{"", parser.RGBA{}, false},
+			painter{"", parser.Color{}, false},
 			false,
 		},
 		{
 			"none",
-			painter{"", parser.RGBA{}, false},
+			painter{"", parser.Color{}, false},
 			false,
 		},
 		{
 			"black",
-			painter{"", parser.RGBA{A: 1}, true},
+			painter{"", parser.ColorKeywords["black"], true},
 			false,
 		},
 		{
@@ -40,12 +40,12 @@ func Test_newPainter(t *testing.T) {
 		},
 		{
 			"url(#myPaint)",
-			painter{"myPaint", parser.RGBA{}, true},
+			painter{"myPaint", parser.Color{}, true},
 			false,
 		},
 		{
 			"url(#myPaint)  green",
-			painter{"myPaint", parser.ColorKeywords["green"].RGBA, true},
+			painter{"myPaint", parser.ColorKeywords["green"], true},
 			false,
 		},
 	}
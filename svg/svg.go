@@ -5,6 +5,8 @@
 package svg
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -12,6 +14,7 @@ import (
 	"github.com/benoitkugler/webrender/backend"
 	"github.com/benoitkugler/webrender/matrix"
 	"github.com/benoitkugler/webrender/text"
+	drawText "github.com/benoitkugler/webrender/text/draw"
 	"github.com/benoitkugler/webrender/utils"
 	"golang.org/x/net/html"
 )
@@ -92,6 +95,43 @@ func (svg *SVGImage) Draw(dst backend.Canvas, width, height Fl, textContext text
 	svg.drawNode(dst, svg.root, dims, true)
 }
 
+// DrawGlyph draws the glyph [glyph] described in [document], the content of an
+// OpenType 'SVG ' table entry. The element with id "glyph<glyph>" is drawn in
+// font units (the whole document is used if there is no such element).
+// Images and external resources are not supported.
+func DrawGlyph(dst backend.Canvas, document []byte, glyph backend.GID) error {
+	noImage := func(url string) (backend.Image, error) {
+		return nil, errors.New("images are not supported in SVG glyphs")
+	}
+	noURL := func(url string) (utils.RemoteRessource, error) {
+		return utils.RemoteRessource{}, errors.New("external resources are not supported in SVG glyphs")
+	}
+	img, err := Parse(bytes.NewReader(document), "", noImage, noURL)
+	if err != nil {
+		return err
+	}
+
+	node := img.definitions.nodes[fmt.Sprintf("glyph%d", glyph)]
+	if node == nil {
+		node = img.root
+	}
+
+	var dims drawingDims
+	dims.fontSize = defaultFontSize
+	if vb := img.ViewBox(); vb != nil {
+		dims.innerWidth, dims.innerHeight = vb.Width, vb.Height
+	} else {
+		dims.innerWidth, dims.innerHeight = 1000, 1000 // only used for percentages
+	}
+	dims.concreteWidth, dims.concreteHeight = dims.innerWidth, dims.innerHeight
+	dims.setupDiagonal()
+
+	img.drawNode(dst, node, dims, true)
+	return nil
+}
+
+var _ drawText.SVGGlyphDrawer = DrawGlyph
+
 // if paint is false, only the path operations are executed, not the actual filling or drawing
 // moreover, no new graphic stack is created
 func (svg *SVGImage) drawNode(dst backend.Canvas, node *svgNode, dims drawingDims, paint bool) {
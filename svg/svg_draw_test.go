@@ -47,6 +47,10 @@ func (outputPage) SetColorRgba(color parser.RGBA, stroke bool) {
 	outputLog.Println("SetColorRgba")
 }
 
+func (outputPage) SetColor(color parser.Color, stroke bool) {
+	outputLog.Println("SetColor")
+}
+
 func (outputPage) SetLineWidth(width fl) {
 	outputLog.Println("SetLineWidth")
 }
@@ -5,6 +5,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
 )
 
 func TestHandleText(t *testing.T) {
@@ -444,3 +447,36 @@ func TestParseText2(t *testing.T) {
 		t.Fatalf("unexpected dx list: %v", te.x)
 	}
 }
+
+func TestDrawGlyph(t *testing.T) {
+	const document = `<svg xmlns="http://www.w3.org/2000/svg">
+		<rect id="glyph3" x="0" y="-500" width="500" height="500" fill="red" />
+		<rect id="glyph4" x="0" y="-700" width="700" height="700" fill="blue" />
+	</svg>`
+
+	rectangles := func(glyph backend.GID) [][]Fl {
+		doc := recorder.NewDocument()
+		if err := DrawGlyph(doc.AddPage(0, 0, 1000, 1000), []byte(document), glyph); err != nil {
+			t.Fatal(err)
+		}
+		var out [][]Fl
+		for _, cmd := range doc.DisplayList().Pages[0].Commands {
+			if cmd.Op == recorder.OpRectangle {
+				out = append(out, cmd.Values)
+			}
+		}
+		return out
+	}
+
+	if rects := rectangles(4); !reflect.DeepEqual(rects, [][]Fl{{0, -700, 700, 700}}) {
+		t.Fatalf("unexpected glyph %v", rects)
+	}
+	// the whole document is used as fallback
+	if rects := rectangles(5); len(rects) != 2 {
+		t.Fatalf("unexpected glyph %v", rects)
+	}
+
+	if err := DrawGlyph(recorder.NewDocument().AddPage(0, 0, 10, 10), []byte(`<svg><image href="test.png" /></svg>`), 1); err == nil {
+		t.Fatal("expected error for unsupported image")
+	}
+}
@@ -0,0 +1,233 @@
+package text
+
+import (
+	"github.com/benoitkugler/textprocessing/fribidi"
+	pr "github.com/benoitkugler/webrender/css/properties"
+	"github.com/go-text/typesetting/shaping"
+)
+
+// explicit directional formatting characters
+const (
+	lre = '\u202a'
+	rle = '\u202b'
+	lro = '\u202d'
+	rlo = '\u202e'
+	lri = '\u2066'
+	rli = '\u2067'
+	fsi = '\u2068'
+	pdf = '\u202c'
+	pdi = '\u2069'
+)
+
+// BidiLevel is a resolved embedding level : even levels are left to right,
+// odd levels are right to left.
+type BidiLevel = fribidi.Level
+
+// BidiContext stores what is required to resolve the bidirectional
+// embedding levels of a text, as defined by the 'direction' and 'unicode-bidi'
+// properties of the text and its ancestors.
+//
+// See https://www.w3.org/TR/css-writing-modes-3/#unicode-bidi
+type BidiContext struct {
+	// Embeddings are the explicit formatting characters (like RLE or LRI)
+	// equivalent to the 'unicode-bidi' property of the inline ancestors,
+	// outermost first. The matching closing characters are implied
+	// at the end of the text.
+	Embeddings string
+
+	// RTL is the base direction of the paragraph, that is
+	// the 'direction' of the block container.
+	RTL bool
+
+	// Auto is true for 'unicode-bidi: plaintext' : the base direction
+	// is then deduced from the first strong character,
+	// and [RTL] is only used as fallback.
+	Auto bool
+
+	// Resolved is true when the embedding level of the whole text
+	// is already known, and given by [Level]. The other fields are then ignored.
+	Resolved bool
+	Level    BidiLevel
+}
+
+// bidiControls returns the explicit formatting characters opened by an
+// inline box with the given 'unicode-bidi' and 'direction' properties.
+func bidiControls(unicodeBidi pr.String, rtl bool) []rune {
+	embed, override, isolate := lre, lro, lri
+	if rtl {
+		embed, override, isolate = rle, rlo, rli
+	}
+	switch unicodeBidi {
+	case "embed":
+		return []rune{embed}
+	case "isolate":
+		return []rune{isolate}
+	case "bidi-override":
+		return []rune{override}
+	case "isolate-override":
+		return []rune{isolate, override}
+	case "plaintext":
+		return []rune{fsi}
+	default:
+		return nil
+	}
+}
+
+// BidiControls returns the explicit formatting characters opening and closing
+// an inline box with the given 'unicode-bidi' and 'direction' properties.
+func BidiControls(unicodeBidi pr.String, rtl bool) (open, close []rune) {
+	open = bidiControls(unicodeBidi, rtl)
+	for i := len(open) - 1; i >= 0; i-- {
+		if r := open[i]; r == lri || r == rli || r == fsi {
+			close = append(close, pdi)
+		} else {
+			close = append(close, pdf)
+		}
+	}
+	return open, close
+}
+
+// ResolveBidiLevels resolves the embedding levels of the inline content [text]
+// of a block container, where inline boxes are delimited by the characters
+// returned by [BidiControls]. [rtl] is the base direction of the paragraph,
+// and [auto] is true for 'unicode-bidi: plaintext'.
+// The levels of a left to right text are all zero.
+func ResolveBidiLevels(text []rune, rtl, auto bool) []BidiLevel {
+	levels, _ := BidiContext{RTL: rtl, Auto: auto}.levels(text)
+	return levels
+}
+
+// newBidiContext walks up the inline ancestors of [style], until
+// the block container defining the paragraph.
+// If [style] is not a [pr.ElementStyle], it is used as the block container.
+func newBidiContext(style pr.StyleAccessor) BidiContext {
+	var embeddings [][]rune // innermost first
+	for {
+		var parent pr.ElementStyle
+		if es, ok := style.(pr.ElementStyle); ok {
+			parent = es.ParentStyle()
+		}
+		if parent == nil || style.GetDisplay() != (pr.Display{"inline", "flow"}) {
+			break
+		}
+		embeddings = append(embeddings, bidiControls(style.GetUnicodeBidi(), style.GetDirection() == "rtl"))
+		style = parent
+	}
+
+	out := BidiContext{RTL: style.GetDirection() == "rtl"}
+	switch style.GetUnicodeBidi() {
+	case "plaintext":
+		out.Auto = true
+	case "bidi-override", "isolate-override":
+		// the override applies to the inline content of the block container
+		embeddings = append(embeddings, bidiControls("bidi-override", out.RTL))
+	}
+
+	var chars []rune
+	for i := len(embeddings) - 1; i >= 0; i-- {
+		chars = append(chars, embeddings[i]...)
+	}
+	out.Embeddings = string(chars)
+	return out
+}
+
+// levels resolves the embedding levels of [text], following the rules P2 to I2
+// of the Unicode Bidirectional Algorithm (https://www.unicode.org/reports/tr9/).
+// It also returns the level of the paragraph.
+func (bc BidiContext) levels(text []rune) (levels []fribidi.Level, paragraph fribidi.Level) {
+	if bc.Resolved {
+		levels = make([]fribidi.Level, len(text))
+		for i := range levels {
+			levels[i] = bc.Level
+		}
+		return levels, bc.Level % 2
+	}
+
+	all := append([]rune(bc.Embeddings), text...)
+	types := make([]fribidi.CharType, len(all))
+	brackets := make([]fribidi.BracketType, len(all))
+	var oredTypes fribidi.CharType
+	for i, r := range all {
+		types[i] = fribidi.GetBidiType(r)
+		oredTypes |= types[i]
+		if types[i] == fribidi.ON {
+			brackets[i] = fribidi.GetBracket(r)
+		}
+	}
+
+	var baseDir fribidi.ParType = fribidi.LTR
+	if bc.RTL {
+		baseDir = fribidi.RTL
+	}
+	if bc.Auto {
+		baseDir = fribidi.WLTR
+		if bc.RTL {
+			baseDir = fribidi.WRTL
+		}
+	}
+
+	// fast path for the common case of left to right text :
+	// all the levels are even, so that no reordering happens
+	if !baseDir.IsRtl() && !oredTypes.IsRtl() && !oredTypes.IsArabic() {
+		return make([]fribidi.Level, len(text)), 0
+	}
+
+	levels, _ = fribidi.GetParEmbeddingLevels(types, brackets, &baseDir)
+	if baseDir.IsRtl() {
+		paragraph = 1
+	}
+	return levels[len(all)-len(text):], paragraph
+}
+
+// levelRuns returns the boundaries of the maximal sequences
+// of characters with the same level.
+func levelRuns(levels []fribidi.Level) (out [][2]int) {
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		out = append(out, [2]int{start, end})
+		start = end
+	}
+	return out
+}
+
+// reorderRuns sorts the runs of [line], given in logical order,
+// by visual order, applying the rule L2 of the Unicode Bidirectional Algorithm :
+// from the highest level to the lowest odd level, any contiguous
+// sequence of runs at that level or higher is reversed.
+func reorderRuns(line shaping.Line, levels []fribidi.Level) {
+	runLevels := make([]fribidi.Level, len(line))
+	var highest, lowestOdd fribidi.Level = 0, fribidi.Level(127)
+	for i, run := range line {
+		level := levels[run.Runes.Offset]
+		runLevels[i] = level
+		highest = max(highest, level)
+		if level%2 == 1 {
+			lowestOdd = min(lowestOdd, level)
+		}
+	}
+
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(line); {
+			if runLevels[i] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(line) && runLevels[j] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				line[a], line[b] = line[b], line[a]
+				runLevels[a], runLevels[b] = runLevels[b], runLevels[a]
+			}
+			i = j
+		}
+	}
+
+	for i := range line {
+		line[i].VisualIndex = int32(i)
+	}
+}
@@ -0,0 +1,571 @@
+package draw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+type fl = utils.Fl
+
+// ColorFonts caches the color tables of the fonts
+// used by [Context.DrawColorGlyphs], and may be shared between calls.
+type ColorFonts map[faceKey]*colorFont
+
+// SVGGlyphDrawer draws the glyph [glyph], described in [document],
+// the content of an OpenType 'SVG ' table entry : the element with id "glyph<glyph>"
+// should be drawn in font units, with the y axis pointing down.
+//
+// It is implemented by the DrawGlyph function of the svg package,
+// which depends on this package.
+type SVGGlyphDrawer = func(dst backend.Canvas, document []byte, glyph backend.GID) error
+
+type colorFont struct {
+	face   *font.Face // nil for fonts without color glyphs
+	colr   colr
+	hasSVG bool
+}
+
+// colorFont returns the color tables of [f], using [cache].
+func (ctx Context) colorFont(cache ColorFonts, f backend.Font) *colorFont {
+	key := newFaceKey(f)
+	if cf, has := cache[key]; has {
+		return cf
+	}
+	cf := new(colorFont)
+	cache[key] = cf
+
+	content := ctx.Fonts.FontContent(key.origin)
+	lds, err := ot.NewLoaders(bytes.NewReader(content))
+	if err != nil || int(key.origin.Index) >= len(lds) {
+		return cf // invalid fonts are reported by the backends
+	}
+	ld := lds[key.origin.Index]
+	if table, err := ld.RawTable(ot.MustNewTag("COLR")); err == nil {
+		cpal, _ := ld.RawTable(ot.MustNewTag("CPAL"))
+		cf.colr, err = parseCOLR(table, cpal)
+		if err != nil {
+			logger.WarningLogger.Printf("invalid color tables in %s: %s", key.origin.File, err)
+			cf.colr = colr{}
+		}
+	}
+	_, err = ld.RawTable(ot.MustNewTag("SVG "))
+	cf.hasSVG = err == nil
+	if cf.colr.isEmpty() && !cf.hasSVG {
+		return cf
+	}
+
+	if gf, ok := f.(gotextFont); ok {
+		cf.face = gf.face
+	} else {
+		cf.face = Faces{}.Load(f, content)
+	}
+	return cf
+}
+
+// DrawColorGlyphs paints the color glyphs of [text] onto [Context.Output] :
+// the COLR layers and paint graphs are drawn with paths and gradients, and
+// the OpenType SVG glyphs are drawn with [Context.SVGGlyphs], if not nil.
+// [foreground] is the color used for the palette entries referring to the text color.
+//
+// The painted glyphs are marked as [backend.TextGlyph.Hidden], so
+// that a following [backend.Canvas.DrawText] call only uses them for text extraction.
+func (ctx Context) DrawColorGlyphs(text *backend.TextDrawing, foreground parser.RGBA) {
+	cache := ctx.ColorFonts
+	if cache == nil {
+		cache = make(ColorFonts)
+	}
+	mat := text.Matrix()
+	for _, run := range text.Runs {
+		cf := ctx.colorFont(cache, run.Font)
+		if cf.face == nil {
+			continue
+		}
+		scale := text.FontSize / fl(cf.face.Upem())
+		for i, glyph := range run.Glyphs {
+			if glyph.Hidden { // already painted
+				continue
+			}
+			x := (glyph.XAdvance + glyph.Offset) / 1000 * text.FontSize
+			y := -glyph.Rise / 1000
+			glyphMat := matrix.Mul3(mat, matrix.Translation(x, y), matrix.Scaling(scale, scale))
+			if ctx.drawColorGlyph(cf, font.GID(glyph.Glyph), glyphMat, foreground) {
+				run.Glyphs[i].Hidden = true
+			}
+		}
+	}
+}
+
+// drawColorGlyph returns false if [gid] is not a color glyph
+func (ctx Context) drawColorGlyph(cf *colorFont, gid font.GID, mat matrix.Transform, foreground parser.RGBA) bool {
+	dst := ctx.Output
+	if cf.colr.hasGlyph(gid) {
+		dst.OnNewStack(func() {
+			dst.State().Transform(mat)
+			p := colorPainter{dst: dst, face: cf.face, colr: &cf.colr, foreground: foreground, clip: glyphBox(cf.face, gid)}
+			if err := p.drawColrGlyph(gid); err != nil {
+				logger.WarningLogger.Printf("invalid color glyph %d: %s", gid, err)
+			}
+		})
+		return true
+	}
+
+	if !cf.hasSVG || ctx.SVGGlyphs == nil {
+		return false
+	}
+	data, ok := cf.face.GlyphData(gid).(font.GlyphSVG)
+	if !ok {
+		return false
+	}
+	var err error
+	dst.OnNewStack(func() {
+		dst.State().Transform(matrix.Mul(mat, matrix.Scaling(1, -1)))
+		err = ctx.SVGGlyphs(dst, data.Source, backend.GID(gid))
+	})
+	if err != nil {
+		logger.WarningLogger.Printf("invalid SVG glyph %d: %s", gid, err)
+		return false
+	}
+	return true
+}
+
+// box is a rectangle, stored as xMin, yMin, xMax, yMax
+type box [4]fl
+
+func (b box) isEmpty() bool { return b[0] >= b[2] || b[1] >= b[3] }
+
+func (b box) intersect(other box) box {
+	return box{max(b[0], other[0]), max(b[1], other[1]), min(b[2], other[2]), min(b[3], other[3])}
+}
+
+// transform returns the bounding box of the transformed rectangle
+func (b box) transform(mat matrix.Transform) box {
+	out := box{math.MaxFloat32, math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for _, corner := range [4][2]fl{{b[0], b[1]}, {b[2], b[1]}, {b[0], b[3]}, {b[2], b[3]}} {
+		x, y := mat.Apply(corner[0], corner[1])
+		out = out.union(x, y)
+	}
+	return out
+}
+
+func (b box) union(x, y fl) box {
+	return box{min(b[0], x), min(b[1], y), max(b[2], x), max(b[3], y)}
+}
+
+// glyphBox returns the area painted by a color glyph :
+// its ink box, or the line box if the base glyph has no outline.
+func glyphBox(face *font.Face, gid font.GID) box {
+	if ext, ok := face.GlyphExtents(gid); ok && ext.Width != 0 && ext.Height != 0 {
+		return box{ext.XBearing, ext.YBearing + ext.Height, ext.XBearing + ext.Width, ext.YBearing}
+	}
+	out := box{0, 0, face.HorizontalAdvance(gid), fl(face.Upem())}
+	if extents, ok := face.FontHExtents(); ok {
+		out[1], out[3] = extents.Descender, extents.Ascender
+	}
+	return out
+}
+
+// maxPaintDepth protects against cycles in COLR paint graphs
+const maxPaintDepth = 64
+
+// colorPainter draws COLR glyphs in font units.
+type colorPainter struct {
+	dst        backend.Canvas
+	face       *font.Face
+	colr       *colr
+	foreground parser.RGBA
+
+	clip  box // the current clip area, in the current coordinates
+	depth int
+}
+
+func (p colorPainter) drawColrGlyph(gid font.GID) error {
+	if offset, ok := p.colr.basePaints[gid]; ok {
+		return p.drawPaint(offset)
+	}
+	base, ok := p.colr.baseGlyph(gid)
+	if !ok {
+		return fmt.Errorf("missing color glyph %d", gid)
+	}
+	end := base.firstLayer + base.numLayers
+	if end > len(p.colr.layers) {
+		return errInvalidCOLR
+	}
+	for _, layer := range p.colr.layers[base.firstLayer:end] {
+		p.fillGlyph(layer.glyph, p.colr.color(layer.paletteIndex, 1, p.foreground))
+	}
+	return nil
+}
+
+func (p colorPainter) fillGlyph(gid font.GID, color parser.RGBA) {
+	p.dst.State().SetColorRgba(color, false)
+	p.glyphPath(gid)
+	p.dst.Paint(backend.FillNonZero)
+}
+
+// fillClip paints the current clip area with [color]
+func (p colorPainter) fillClip(color parser.RGBA) {
+	if p.clip.isEmpty() {
+		return
+	}
+	p.dst.State().SetColorRgba(color, false)
+	p.dst.Rectangle(p.clip[0], p.clip[1], p.clip[2]-p.clip[0], p.clip[3]-p.clip[1])
+	p.dst.Paint(backend.FillNonZero)
+}
+
+// glyphPath adds the outline of [gid] to the current path,
+// and returns its bounding box.
+func (p colorPainter) glyphPath(gid font.GID) box {
+	var outline font.GlyphOutline
+	switch data := p.face.GlyphData(gid).(type) {
+	case font.GlyphOutline:
+		outline = data
+	case font.GlyphSVG:
+		outline = data.Outline
+	case font.GlyphBitmap:
+		if data.Outline != nil {
+			outline = *data.Outline
+		}
+	}
+
+	bbox := box{math.MaxFloat32, math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	var current ot.SegmentPoint
+	for i, seg := range outline.Segments {
+		for _, pt := range seg.ArgsSlice() {
+			bbox = bbox.union(pt.X, pt.Y)
+		}
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			if i != 0 { // contours are implicitly closed
+				p.dst.ClosePath()
+			}
+			p.dst.MoveTo(seg.Args[0].X, seg.Args[0].Y)
+		case ot.SegmentOpLineTo:
+			p.dst.LineTo(seg.Args[0].X, seg.Args[0].Y)
+		case ot.SegmentOpQuadTo:
+			c, to := seg.Args[0], seg.Args[1]
+			p.dst.CubicTo(current.X+2./3*(c.X-current.X), current.Y+2./3*(c.Y-current.Y),
+				to.X+2./3*(c.X-to.X), to.Y+2./3*(c.Y-to.Y), to.X, to.Y)
+		case ot.SegmentOpCubeTo:
+			p.dst.CubicTo(seg.Args[0].X, seg.Args[0].Y, seg.Args[1].X, seg.Args[1].Y, seg.Args[2].X, seg.Args[2].Y)
+		}
+		args := seg.ArgsSlice()
+		current = args[len(args)-1]
+	}
+	if len(outline.Segments) != 0 {
+		p.dst.ClosePath()
+	}
+	return bbox
+}
+
+func (p colorPainter) drawPaint(offset int) error {
+	if p.depth++; p.depth > maxPaintDepth {
+		return errors.New("too many nested paints")
+	}
+	format, table, err := p.colr.paint(offset)
+	if err != nil {
+		return err
+	}
+	child := func() int { return offset + offset24(table[1:]) }
+
+	switch format {
+	case paintColrLayers:
+		first, count := int(binary.BigEndian.Uint32(table[2:])), int(table[1])
+		if first+count > len(p.colr.layerPaints) {
+			return errInvalidCOLR
+		}
+		for _, layer := range p.colr.layerPaints[first : first+count] {
+			if err := p.drawPaint(layer); err != nil {
+				return err
+			}
+		}
+	case paintSolid, paintVarSolid:
+		p.fillClip(p.colr.color(binary.BigEndian.Uint16(table[1:]), f2dot14(table[3:]), p.foreground))
+	case paintLinearGradient, paintVarLinearGradient, paintRadialGradient, paintVarRadialGradient:
+		isVar := format == paintVarLinearGradient || format == paintVarRadialGradient
+		extend, stops, err := p.colr.colorLine(child(), isVar, p.foreground)
+		if err != nil {
+			return err
+		}
+		var coords [6]fl
+		for i := range coords {
+			coords[i] = fword(table[4+2*i:])
+		}
+		if format == paintLinearGradient || format == paintVarLinearGradient {
+			p.drawLinearGradient(extend, stops, coords)
+		} else {
+			p.drawRadialGradient(extend, stops, coords)
+		}
+	case paintSweepGradient, paintVarSweepGradient:
+		_, stops, err := p.colr.colorLine(child(), format == paintVarSweepGradient, p.foreground)
+		if err != nil {
+			return err
+		}
+		p.drawSweepGradient(stops, fword(table[4:]), fword(table[6:]),
+			f2dot14(table[8:])*math.Pi, f2dot14(table[10:])*math.Pi)
+	case paintGlyph:
+		gid := font.GID(binary.BigEndian.Uint16(table[4:]))
+		// fast path for the common case of plain glyphs
+		if format, sub, err := p.colr.paint(child()); err == nil && (format == paintSolid || format == paintVarSolid) {
+			p.fillGlyph(gid, p.colr.color(binary.BigEndian.Uint16(sub[1:]), f2dot14(sub[3:]), p.foreground))
+			return nil
+		}
+		p.dst.OnNewStack(func() {
+			p.clip = p.clip.intersect(p.glyphPath(gid))
+			p.dst.State().Clip(false)
+			err = p.drawPaint(child())
+		})
+		return err
+	case paintColrGlyph:
+		return p.drawColrGlyph(font.GID(binary.BigEndian.Uint16(table[1:])))
+	case paintTransform, paintVarTransform:
+		start := offset + offset24(table[4:])
+		if len(p.colr.raw) < start+24 {
+			return errInvalidCOLR
+		}
+		aff := p.colr.raw[start:]
+		mat := matrix.New(fixed16(aff), fixed16(aff[4:]), fixed16(aff[8:]), fixed16(aff[12:]), fixed16(aff[16:]), fixed16(aff[20:]))
+		return p.transform(mat, child())
+	case paintTranslate, paintVarTranslate:
+		return p.transform(matrix.Translation(fword(table[4:]), fword(table[6:])), child())
+	case paintScale, paintVarScale:
+		return p.transform(matrix.Scaling(f2dot14(table[4:]), f2dot14(table[6:])), child())
+	case paintScaleAroundCenter, paintVarScaleAroundCenter:
+		mat := aroundCenter(matrix.Scaling(f2dot14(table[4:]), f2dot14(table[6:])), fword(table[8:]), fword(table[10:]))
+		return p.transform(mat, child())
+	case paintScaleUniform, paintVarScaleUniform:
+		s := f2dot14(table[4:])
+		return p.transform(matrix.Scaling(s, s), child())
+	case paintScaleUniformAroundCenter, paintVarScaleUniformAroundCenter:
+		s := f2dot14(table[4:])
+		return p.transform(aroundCenter(matrix.Scaling(s, s), fword(table[6:]), fword(table[8:])), child())
+	case paintRotate, paintVarRotate:
+		return p.transform(matrix.Rotation(f2dot14(table[4:])*math.Pi), child())
+	case paintRotateAroundCenter, paintVarRotateAroundCenter:
+		mat := aroundCenter(matrix.Rotation(f2dot14(table[4:])*math.Pi), fword(table[6:]), fword(table[8:]))
+		return p.transform(mat, child())
+	case paintSkew, paintVarSkew:
+		return p.transform(skew(f2dot14(table[4:]), f2dot14(table[6:])), child())
+	case paintSkewAroundCenter, paintVarSkewAroundCenter:
+		mat := aroundCenter(skew(f2dot14(table[4:]), f2dot14(table[6:])), fword(table[8:]), fword(table[10:]))
+		return p.transform(mat, child())
+	case paintComposite:
+		return p.drawComposite(child(), table[4], offset+offset24(table[5:]))
+	}
+	return nil
+}
+
+// skew returns the skew transform for angles given
+// in counter-clockwise half turns
+func skew(x, y fl) matrix.Transform {
+	return matrix.New(1, fl(math.Tan(float64(y*math.Pi))), -fl(math.Tan(float64(x*math.Pi))), 1, 0, 0)
+}
+
+// aroundCenter applies [mat] with (cx, cy) as origin
+func aroundCenter(mat matrix.Transform, cx, cy fl) matrix.Transform {
+	return matrix.Mul3(matrix.Translation(cx, cy), mat, matrix.Translation(-cx, -cy))
+}
+
+func (p colorPainter) transform(mat matrix.Transform, child int) error {
+	inv := mat
+	if inv.Invert() != nil { // degenerate transform, nothing is visible
+		return nil
+	}
+	p.clip = p.clip.transform(inv)
+	var err error
+	p.dst.OnNewStack(func() {
+		p.dst.State().Transform(mat)
+		err = p.drawPaint(child)
+	})
+	return err
+}
+
+// compositeBlendModes maps the composite modes to
+// CSS blend modes, starting at compositeScreen
+var compositeBlendModes = [...]string{
+	"screen", "overlay", "darken", "lighten", "color-dodge", "color-burn", "hard-light",
+	"soft-light", "difference", "exclusion", "multiply", "hue", "saturation", "color", "luminosity",
+}
+
+const (
+	compositeClear    = 0
+	compositeSrc      = 1
+	compositeDest     = 2
+	compositeDestOver = 4
+	compositeScreen   = 13
+)
+
+// drawComposite approximates the Porter-Duff modes
+// other than "clear", "src", "dest" and "dest-over" by "src-over".
+func (p colorPainter) drawComposite(source int, mode uint8, backdrop int) error {
+	switch mode {
+	case compositeClear:
+		return nil
+	case compositeSrc:
+		return p.drawPaint(source)
+	case compositeDest:
+		return p.drawPaint(backdrop)
+	case compositeDestOver:
+		source, backdrop = backdrop, source
+	}
+	if err := p.drawPaint(backdrop); err != nil {
+		return err
+	}
+	if index := int(mode) - compositeScreen; 0 <= index && index < len(compositeBlendModes) {
+		var err error
+		p.dst.OnNewStack(func() {
+			p.dst.State().SetBlendingMode(compositeBlendModes[index])
+			err = p.drawPaint(source)
+		})
+		return err
+	}
+	return p.drawPaint(source)
+}
+
+// normalizeStops returns the positions of [stops] in [0, 1],
+// and the offsets of the first and last stops, used to adjust
+// the gradient geometry.
+// It returns false if the gradient has less than two different offsets.
+func normalizeStops(extend uint8, stops []colorStop) (layout backend.GradientLayout, first, last fl, ok bool) {
+	if len(stops) == 0 {
+		return layout, 0, 0, false
+	}
+	first, last = stops[0].offset, stops[len(stops)-1].offset
+	if first == last {
+		return layout, first, last, false
+	}
+	layout.ScaleY = 1
+	layout.Reapeating = extend != 0 // reflect is approximated by repeat
+	for _, stop := range stops {
+		layout.Positions = append(layout.Positions, (stop.offset-first)/(last-first))
+		layout.Colors = append(layout.Colors, stop.color)
+	}
+	return layout, first, last, true
+}
+
+// drawGradient paints the current clip area with [layout],
+// whose coordinates are in the current coordinates system.
+func (p colorPainter) drawGradient(layout backend.GradientLayout) {
+	if p.clip.isEmpty() {
+		return
+	}
+	x, y := p.clip[0], p.clip[1]
+	layout.Coords[0] -= x
+	layout.Coords[1] -= y
+	if layout.Kind == "linear" {
+		layout.Coords[2] -= x
+		layout.Coords[3] -= y
+	} else {
+		layout.Coords[3] -= x
+		layout.Coords[4] -= y
+	}
+	p.dst.OnNewStack(func() {
+		p.dst.State().Transform(matrix.Translation(x, y))
+		p.dst.DrawGradient(layout, p.clip[2]-x, p.clip[3]-y)
+	})
+}
+
+// drawLinearGradient draws a gradient defined by the points p0, p1 and p2 in [coords],
+// with the color stops along the projection of p0p1 on the normal of p0p2.
+func (p colorPainter) drawLinearGradient(extend uint8, stops []colorStop, coords [6]fl) {
+	layout, first, last, ok := normalizeStops(extend, stops)
+	if !ok {
+		if len(stops) != 0 {
+			p.fillClip(stops[len(stops)-1].color)
+		}
+		return
+	}
+	x0, y0, x1, y1, x2, y2 := coords[0], coords[1], coords[2], coords[3], coords[4], coords[5]
+	nx, ny := y2-y0, -(x2 - x0) // normal to p0p2
+	if n := nx*nx + ny*ny; n != 0 {
+		d := ((x1-x0)*nx + (y1-y0)*ny) / n
+		x1, y1 = x0+d*nx, y0+d*ny
+	}
+	layout.Kind = "linear"
+	layout.Coords = [6]fl{
+		x0 + first*(x1-x0), y0 + first*(y1-y0),
+		x0 + last*(x1-x0), y0 + last*(y1-y0),
+	}
+	p.drawGradient(layout)
+}
+
+// drawRadialGradient draws a gradient between the circles (x0, y0, r0) and (x1, y1, r1)
+func (p colorPainter) drawRadialGradient(extend uint8, stops []colorStop, coords [6]fl) {
+	layout, first, last, ok := normalizeStops(extend, stops)
+	if !ok {
+		if len(stops) != 0 {
+			p.fillClip(stops[len(stops)-1].color)
+		}
+		return
+	}
+	x0, y0, r0, x1, y1, r1 := coords[0], coords[1], coords[2], coords[3], coords[4], coords[5]
+	lerp := func(a, b, t fl) fl { return a + t*(b-a) }
+	layout.Kind = "radial"
+	layout.Coords = [6]fl{
+		lerp(x0, x1, first), lerp(y0, y1, first), max(0, lerp(r0, r1, first)),
+		lerp(x0, x1, last), lerp(y0, y1, last), max(0, lerp(r0, r1, last)),
+	}
+	p.drawGradient(layout)
+}
+
+// sweepSteps is the number of sectors used to approximate sweep gradients,
+// which are not supported by the backends.
+const sweepSteps = 90
+
+// drawSweepGradient approximates a sweep gradient, centered at (cx, cy),
+// by filling sectors with plain colors.
+func (p colorPainter) drawSweepGradient(stops []colorStop, cx, cy, startAngle, endAngle fl) {
+	if p.clip.isEmpty() || len(stops) == 0 {
+		return
+	}
+	var radius fl
+	for _, corner := range [4][2]fl{{p.clip[0], p.clip[1]}, {p.clip[2], p.clip[1]}, {p.clip[0], p.clip[3]}, {p.clip[2], p.clip[3]}} {
+		radius = max(radius, fl(math.Hypot(float64(corner[0]-cx), float64(corner[1]-cy))))
+	}
+	radius += 1 // avoid rounding issues on the clip border
+	const step = 2 * math.Pi / sweepSteps
+	for i := 0; i < sweepSteps; i++ {
+		a0, a1 := fl(i)*step, fl(i+1)*step+step/10 // overlap to avoid seams
+		t := fl(0)
+		if endAngle != startAngle {
+			t = ((a0+a1)/2 - startAngle) / (endAngle - startAngle)
+		}
+		p.dst.State().SetColorRgba(stopsColor(stops, t), false)
+		p.dst.MoveTo(cx, cy)
+		p.dst.LineTo(cx+radius*fl(math.Cos(float64(a0))), cy+radius*fl(math.Sin(float64(a0))))
+		p.dst.LineTo(cx+radius*fl(math.Cos(float64(a1))), cy+radius*fl(math.Sin(float64(a1))))
+		p.dst.ClosePath()
+		p.dst.Paint(backend.FillNonZero)
+	}
+}
+
+// stopsColor interpolates the color at [t], padding
+// with the first and last colors.
+func stopsColor(stops []colorStop, t fl) parser.RGBA {
+	if t <= stops[0].offset {
+		return stops[0].color
+	}
+	for i := 1; i < len(stops); i++ {
+		s0, s1 := stops[i-1], stops[i]
+		if t <= s1.offset {
+			u := (t - s0.offset) / (s1.offset - s0.offset)
+			return parser.RGBA{
+				R: s0.color.R + u*(s1.color.R-s0.color.R),
+				G: s0.color.G + u*(s1.color.G-s0.color.G),
+				B: s0.color.B + u*(s1.color.B-s0.color.B),
+				A: s0.color.A + u*(s1.color.A-s0.color.A),
+			}
+		}
+	}
+	return stops[len(stops)-1].color
+}
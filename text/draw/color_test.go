@@ -0,0 +1,262 @@
+package draw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
+	"github.com/benoitkugler/webrender/css/parser"
+	pr "github.com/benoitkugler/webrender/css/properties"
+	"github.com/benoitkugler/webrender/css/validation"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/font"
+)
+
+const dejaVuPath = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+
+// addTables returns a copy of the font file [content] with
+// the additional [tables] (checksums are not computed).
+func addTables(content []byte, tables map[string][]byte) []byte {
+	numTables := int(binary.BigEndian.Uint16(content[4:]))
+	all := make(map[string][]byte, numTables+len(tables))
+	for i := 0; i < numTables; i++ {
+		rec := content[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		all[string(rec[:4])] = content[offset : offset+length]
+	}
+	for tag, table := range tables {
+		all[tag] = table
+	}
+	tags := make([]string, 0, len(all))
+	for tag := range all {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	out := binary.BigEndian.AppendUint32(nil, binary.BigEndian.Uint32(content))
+	out = binary.BigEndian.AppendUint16(out, uint16(len(tags)))
+	out = append(out, 0, 0, 0, 0, 0, 0) // search hints, unused
+	offset := 12 + 16*len(tags)
+	var data []byte
+	for _, tag := range tags {
+		table := all[tag]
+		out = append(out, tag...)
+		out = binary.BigEndian.AppendUint32(out, 0)
+		out = binary.BigEndian.AppendUint32(out, uint32(offset+len(data)))
+		out = binary.BigEndian.AppendUint32(out, uint32(len(table)))
+		data = append(data, table...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	return append(out, data...)
+}
+
+type tableBuilder []byte
+
+func (b *tableBuilder) u8(v uint8)   { *b = append(*b, v) }
+func (b *tableBuilder) u16(v uint16) { *b = binary.BigEndian.AppendUint16(*b, v) }
+func (b *tableBuilder) u24(v int)    { *b = append(*b, byte(v>>16), byte(v>>8), byte(v)) }
+func (b *tableBuilder) u32(v int)    { *b = binary.BigEndian.AppendUint32(*b, uint32(v)) }
+
+// colorFontTables builds COLR, CPAL and SVG tables, using
+// the given glyphs :
+//   - gids[0] is made of two layers : gids[1] in red and gids[2] with the text color
+//   - gids[3] is made of gids[1] in blue and gids[2] filled with a red to blue gradient
+//   - gids[4] is an SVG glyph
+func colorFontTables(gids [5]font.GID) map[string][]byte {
+	var colr tableBuilder
+	colr.u16(1)               // version
+	colr.u16(1)               // numBaseGlyphRecords
+	colr.u32(34)              // baseGlyphRecordsOffset
+	colr.u32(40)              // layerRecordsOffset
+	colr.u16(2)               // numLayerRecords
+	colr.u32(48)              // baseGlyphListOffset
+	colr.u32(64)              // layerListOffset
+	colr.u32(0)               // clipListOffset
+	colr.u32(0)               // varIndexMapOffset
+	colr.u32(0)               // itemVariationStoreOffset
+	colr.u16(uint16(gids[0])) // base glyph record
+	colr.u16(0)
+	colr.u16(2)
+	colr.u16(uint16(gids[1])) // layer records
+	colr.u16(0)
+	colr.u16(uint16(gids[2]))
+	colr.u16(foregroundIndex)
+	colr.u32(1) // base glyph list
+	colr.u16(uint16(gids[3]))
+	colr.u32(10)
+	colr.u8(paintColrLayers) // at 58
+	colr.u8(2)
+	colr.u32(0)
+	colr.u32(2) // layer list, at 64
+	colr.u32(12)
+	colr.u32(23)
+	colr.u8(paintGlyph) // at 76
+	colr.u24(6)
+	colr.u16(uint16(gids[1]))
+	colr.u8(paintSolid) // at 82
+	colr.u16(1)
+	colr.u16(1 << 14)
+	colr.u8(paintTranslate) // at 87
+	colr.u24(8)
+	colr.u16(100)
+	colr.u16(0)
+	colr.u8(paintGlyph) // at 95
+	colr.u24(6)
+	colr.u16(uint16(gids[2]))
+	colr.u8(paintLinearGradient) // at 101
+	colr.u24(16)
+	for _, v := range [6]uint16{0, 0, 1000, 0, 0, 1000} {
+		colr.u16(v)
+	}
+	colr.u8(0) // color line, at 117
+	colr.u16(2)
+	colr.u16(0)
+	colr.u16(0)
+	colr.u16(1 << 14)
+	colr.u16(1 << 14)
+	colr.u16(1)
+	colr.u16(1 << 14)
+
+	var cpal tableBuilder
+	cpal.u16(0) // version
+	cpal.u16(2) // numPaletteEntries
+	cpal.u16(1) // numPalettes
+	cpal.u16(2) // numColorRecords
+	cpal.u32(14)
+	cpal.u16(0)
+	cpal = append(cpal, 0, 0, 255, 255, 255, 0, 0, 255) // red and blue, in BGRA
+
+	document := `<svg xmlns="http://www.w3.org/2000/svg"><rect id="glyph` + strconv.Itoa(int(gids[4])) + `" width="500" height="500"/></svg>`
+	var svg tableBuilder
+	svg.u16(0)  // version
+	svg.u32(10) // svgDocumentListOffset
+	svg.u32(0)
+	svg.u16(1) // numEntries
+	svg.u16(uint16(gids[4]))
+	svg.u16(uint16(gids[4]))
+	svg.u32(14)
+	svg.u32(len(document))
+	svg = append(svg, document...)
+
+	return map[string][]byte{"COLR": colr, "CPAL": cpal, "SVG ": svg}
+}
+
+func TestDrawColorGlyphs(t *testing.T) {
+	content, err := os.ReadFile(dejaVuPath)
+	if err != nil {
+		t.Skip("missing DejaVu font:", err)
+	}
+	face, err := font.ParseTTF(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gids [5]font.GID
+	for i, r := range "ABCDE" {
+		gids[i], _ = face.NominalGlyph(r)
+	}
+	path := filepath.Join(t.TempDir(), "color.ttf")
+	if err = os.WriteFile(path, addTables(content, colorFontTables(gids)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := newFontConfigurationGotext(t)
+	url, err := utils.PathToURL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.AddFontFace(validation.FontFaceDescriptors{
+		Src:        []pr.NamedString{{Name: "external", String: url}},
+		FontFamily: "color",
+	}, utils.DefaultUrlFetcher)
+
+	style := pr.InitialValues.Copy()
+	style.SetFontFamily(pr.Strings{"color"})
+	line := text.SplitFirstLine([]rune("ADEx"), style, textContext{fc}, pr.Inf, false, true)
+
+	doc := recorder.NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	var svgGlyphs []backend.GID
+	drawer := Context{
+		Output: page, Fonts: fc,
+		SVGGlyphs: func(dst backend.Canvas, document []byte, glyph backend.GID) error {
+			if !strings.Contains(string(document), "glyph"+strconv.Itoa(int(glyph))) {
+				t.Fatalf("unexpected document %s", document)
+			}
+			svgGlyphs = append(svgGlyphs, glyph)
+			return nil
+		},
+	}
+	drawing := drawer.CreateFirstLine(line.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, 0, 0, 0)
+	drawer.DrawColorGlyphs(&drawing, parser.RGBA{G: 1, A: 1})
+
+	var hidden []bool
+	for _, run := range drawing.Runs {
+		for _, glyph := range run.Glyphs {
+			hidden = append(hidden, glyph.Hidden)
+		}
+	}
+	if len(hidden) != 4 || !hidden[0] || !hidden[1] || !hidden[2] || hidden[3] {
+		t.Fatalf("unexpected hidden glyphs %v", hidden)
+	}
+	if len(svgGlyphs) != 1 || svgGlyphs[0] != backend.GID(gids[4]) {
+		t.Fatalf("unexpected SVG glyphs %v", svgGlyphs)
+	}
+
+	var (
+		colors    [][]pr.Fl
+		gradients []*backend.GradientLayout
+	)
+	for _, cmd := range doc.DisplayList().Pages[0].Commands {
+		switch cmd.Op {
+		case recorder.OpSetColorRgba:
+			colors = append(colors, cmd.Values)
+		case recorder.OpDrawGradient:
+			gradients = append(gradients, cmd.Gradient)
+		}
+	}
+	// red and text color layers, then the blue layer
+	expected := [][]pr.Fl{{1, 0, 0, 1}, {0, 1, 0, 1}, {0, 0, 1, 1}}
+	if len(colors) != len(expected) {
+		t.Fatalf("unexpected colors %v", colors)
+	}
+	for i, color := range expected {
+		for j := range color {
+			if colors[i][j] != color[j] {
+				t.Fatalf("unexpected colors %v", colors)
+			}
+		}
+	}
+	if len(gradients) != 1 || gradients[0].Kind != "linear" || len(gradients[0].Colors) != 2 ||
+		gradients[0].Colors[0] != (parser.RGBA{R: 1, A: 1}) || gradients[0].Colors[1] != (parser.RGBA{B: 1, A: 1}) {
+		t.Fatalf("unexpected gradients %v", gradients)
+	}
+}
+
+func TestParseCOLRInvalid(t *testing.T) {
+	for _, table := range [][]byte{
+		nil,
+		{0, 0, 0, 1, 0, 0, 0, 14, 0, 0, 0, 14, 0, 0}, // truncated base glyph record
+		{0, 1, 0, 0, 0, 0, 0, 14, 0, 0, 0, 14, 0, 0}, // truncated version 1 header
+	} {
+		if _, err := parseCOLR(table, nil); err == nil {
+			t.Fatalf("expected error for %v", table)
+		}
+	}
+
+	// cycles in the paint graph are detected
+	c := colr{raw: []byte{0, paintColrGlyph, 0, 1}, basePaints: map[font.GID]int{1: 1}}
+	p := colorPainter{dst: recorder.NewDocument().AddPage(0, 0, 10, 10), colr: &c}
+	if err := p.drawColrGlyph(1); err == nil {
+		t.Fatal("expected error for cyclic paint")
+	}
+}
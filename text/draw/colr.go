@@ -0,0 +1,283 @@
+package draw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/benoitkugler/webrender/css/parser"
+	"github.com/go-text/typesetting/font"
+)
+
+// This file implements a parser for the OpenType 'COLR' and 'CPAL' tables,
+// which are not supported by go-text.
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/colr
+// and https://learn.microsoft.com/en-us/typography/opentype/spec/cpal
+
+// foregroundIndex is the palette index used for the text color.
+const foregroundIndex = 0xFFFF
+
+var errInvalidCOLR = errors.New("invalid COLR table")
+
+// colr stores the color description of a font.
+// The paint tables (version 1) are only parsed when drawing,
+// directly from [raw].
+type colr struct {
+	raw []byte // the full COLR table
+
+	baseGlyphs []baseGlyph // version 0, sorted by glyph
+	layers     []layer     // version 0
+
+	basePaints  map[font.GID]int // version 1, offset of the root paint, in [raw]
+	layerPaints []int            // version 1, offsets of the LayerList paints, in [raw]
+
+	palette []parser.RGBA // the first palette of the CPAL table
+}
+
+type baseGlyph struct {
+	glyph                 font.GID
+	firstLayer, numLayers int
+}
+
+type layer struct {
+	glyph        font.GID
+	paletteIndex uint16
+}
+
+// parseCOLR parses the 'COLR' and 'CPAL' tables.
+// [cpal] may be empty, in which case only the foreground color is available.
+func parseCOLR(table, cpal []byte) (colr, error) {
+	out := colr{raw: table}
+	if len(table) < 14 {
+		return out, errInvalidCOLR
+	}
+	version := binary.BigEndian.Uint16(table)
+	numBaseGlyphs := int(binary.BigEndian.Uint16(table[2:]))
+	baseGlyphsOffset := int(binary.BigEndian.Uint32(table[4:]))
+	layersOffset := int(binary.BigEndian.Uint32(table[8:]))
+	numLayers := int(binary.BigEndian.Uint16(table[12:]))
+
+	if len(table) < baseGlyphsOffset+6*numBaseGlyphs || len(table) < layersOffset+4*numLayers {
+		return out, errInvalidCOLR
+	}
+	out.baseGlyphs = make([]baseGlyph, numBaseGlyphs)
+	for i := range out.baseGlyphs {
+		rec := table[baseGlyphsOffset+6*i:]
+		out.baseGlyphs[i] = baseGlyph{
+			glyph:      font.GID(binary.BigEndian.Uint16(rec)),
+			firstLayer: int(binary.BigEndian.Uint16(rec[2:])),
+			numLayers:  int(binary.BigEndian.Uint16(rec[4:])),
+		}
+	}
+	sort.Slice(out.baseGlyphs, func(i, j int) bool { return out.baseGlyphs[i].glyph < out.baseGlyphs[j].glyph })
+	out.layers = make([]layer, numLayers)
+	for i := range out.layers {
+		rec := table[layersOffset+4*i:]
+		out.layers[i] = layer{
+			glyph:        font.GID(binary.BigEndian.Uint16(rec)),
+			paletteIndex: binary.BigEndian.Uint16(rec[2:]),
+		}
+	}
+
+	if version >= 1 {
+		if len(table) < 34 {
+			return out, errInvalidCOLR
+		}
+		if offset := int(binary.BigEndian.Uint32(table[14:])); offset != 0 {
+			if len(table) < offset+4 {
+				return out, errInvalidCOLR
+			}
+			count := int(binary.BigEndian.Uint32(table[offset:]))
+			if len(table) < offset+4+6*count {
+				return out, errInvalidCOLR
+			}
+			out.basePaints = make(map[font.GID]int, count)
+			for i := 0; i < count; i++ {
+				rec := table[offset+4+6*i:]
+				out.basePaints[font.GID(binary.BigEndian.Uint16(rec))] = offset + int(binary.BigEndian.Uint32(rec[2:]))
+			}
+		}
+		if offset := int(binary.BigEndian.Uint32(table[18:])); offset != 0 {
+			if len(table) < offset+4 {
+				return out, errInvalidCOLR
+			}
+			count := int(binary.BigEndian.Uint32(table[offset:]))
+			if len(table) < offset+4+4*count {
+				return out, errInvalidCOLR
+			}
+			out.layerPaints = make([]int, count)
+			for i := range out.layerPaints {
+				out.layerPaints[i] = offset + int(binary.BigEndian.Uint32(table[offset+4+4*i:]))
+			}
+		}
+	}
+
+	var err error
+	out.palette, err = parseCPAL(cpal)
+	return out, err
+}
+
+// parseCPAL returns the first palette of the table,
+// or nil if [table] is empty.
+func parseCPAL(table []byte) ([]parser.RGBA, error) {
+	if len(table) == 0 {
+		return nil, nil
+	}
+	if len(table) < 12 {
+		return nil, errors.New("invalid CPAL table")
+	}
+	numEntries := int(binary.BigEndian.Uint16(table[2:]))
+	numPalettes := binary.BigEndian.Uint16(table[4:])
+	recordsOffset := int(binary.BigEndian.Uint32(table[8:]))
+	if numPalettes == 0 {
+		return nil, nil
+	}
+	if len(table) < 14 {
+		return nil, errors.New("invalid CPAL table")
+	}
+	first := int(binary.BigEndian.Uint16(table[12:])) // first palette
+	start := recordsOffset + 4*first
+	if len(table) < start+4*numEntries {
+		return nil, errors.New("invalid CPAL table")
+	}
+	out := make([]parser.RGBA, numEntries)
+	for i := range out {
+		b, g, r, a := table[start+4*i], table[start+4*i+1], table[start+4*i+2], table[start+4*i+3]
+		out[i] = parser.RGBA{R: fl(r) / 255, G: fl(g) / 255, B: fl(b) / 255, A: fl(a) / 255}
+	}
+	return out, nil
+}
+
+// isEmpty returns true if the font has no color glyphs.
+func (c *colr) isEmpty() bool { return len(c.baseGlyphs) == 0 && len(c.basePaints) == 0 }
+
+// baseGlyph returns the version 0 record for [gid].
+func (c *colr) baseGlyph(gid font.GID) (baseGlyph, bool) {
+	i := sort.Search(len(c.baseGlyphs), func(i int) bool { return c.baseGlyphs[i].glyph >= gid })
+	if i < len(c.baseGlyphs) && c.baseGlyphs[i].glyph == gid {
+		return c.baseGlyphs[i], true
+	}
+	return baseGlyph{}, false
+}
+
+// hasGlyph returns true if [gid] has a color description.
+func (c *colr) hasGlyph(gid font.GID) bool {
+	if _, ok := c.basePaints[gid]; ok {
+		return true
+	}
+	_, ok := c.baseGlyph(gid)
+	return ok
+}
+
+// color resolves a palette entry, applying [alpha].
+func (c *colr) color(paletteIndex uint16, alpha fl, foreground parser.RGBA) parser.RGBA {
+	out := foreground
+	if paletteIndex != foregroundIndex {
+		if int(paletteIndex) >= len(c.palette) {
+			return parser.RGBA{} // invalid index: transparent
+		}
+		out = c.palette[paletteIndex]
+	}
+	out.A *= alpha
+	return out
+}
+
+// colorStop is a color stop of a gradient color line
+type colorStop struct {
+	offset fl
+	color  parser.RGBA
+}
+
+// paint table formats
+const (
+	paintColrLayers = 1 + iota
+	paintSolid
+	paintVarSolid
+	paintLinearGradient
+	paintVarLinearGradient
+	paintRadialGradient
+	paintVarRadialGradient
+	paintSweepGradient
+	paintVarSweepGradient
+	paintGlyph
+	paintColrGlyph
+	paintTransform
+	paintVarTransform
+	paintTranslate
+	paintVarTranslate
+	paintScale
+	paintVarScale
+	paintScaleAroundCenter
+	paintVarScaleAroundCenter
+	paintScaleUniform
+	paintVarScaleUniform
+	paintScaleUniformAroundCenter
+	paintVarScaleUniformAroundCenter
+	paintRotate
+	paintVarRotate
+	paintRotateAroundCenter
+	paintVarRotateAroundCenter
+	paintSkew
+	paintVarSkew
+	paintSkewAroundCenter
+	paintVarSkewAroundCenter
+	paintComposite
+)
+
+// paintSizes are the minimum sizes of the paint tables, including the format,
+// indexed by format.
+var paintSizes = [...]int{
+	0, 6, 5, 9, 16, 20, 16, 20, 12, 16, 6, 3, 7, 7, 8, 12, 8, 12, 12, 16,
+	6, 10, 10, 14, 6, 10, 10, 14, 8, 12, 12, 16, 8,
+}
+
+// paint returns the paint table at [offset], after checking its size.
+func (c *colr) paint(offset int) (format uint8, table []byte, err error) {
+	if offset <= 0 || offset >= len(c.raw) {
+		return 0, nil, errInvalidCOLR
+	}
+	format = c.raw[offset]
+	if format == 0 || int(format) >= len(paintSizes) {
+		return 0, nil, fmt.Errorf("unsupported COLR paint format %d", format)
+	}
+	if len(c.raw) < offset+paintSizes[format] {
+		return 0, nil, errInvalidCOLR
+	}
+	return format, c.raw[offset:], nil
+}
+
+// colorLine parses the color line at [offset], resolving the colors.
+// Variable color lines have larger stops; the variations are ignored.
+func (c *colr) colorLine(offset int, isVar bool, foreground parser.RGBA) (extend uint8, stops []colorStop, err error) {
+	if len(c.raw) < offset+3 {
+		return 0, nil, errInvalidCOLR
+	}
+	size := 6
+	if isVar {
+		size = 10
+	}
+	extend = c.raw[offset]
+	count := int(binary.BigEndian.Uint16(c.raw[offset+1:]))
+	if len(c.raw) < offset+3+size*count {
+		return 0, nil, errInvalidCOLR
+	}
+	stops = make([]colorStop, count)
+	for i := range stops {
+		rec := c.raw[offset+3+size*i:]
+		stops[i] = colorStop{
+			offset: f2dot14(rec),
+			color:  c.color(binary.BigEndian.Uint16(rec[2:]), f2dot14(rec[4:]), foreground),
+		}
+	}
+	sort.SliceStable(stops, func(i, j int) bool { return stops[i].offset < stops[j].offset })
+	return extend, stops, nil
+}
+
+func offset24(b []byte) int { return int(b[0])<<16 | int(b[1])<<8 | int(b[2]) }
+
+func f2dot14(b []byte) fl { return fl(int16(binary.BigEndian.Uint16(b))) / (1 << 14) }
+
+func fixed16(b []byte) fl { return fl(int32(binary.BigEndian.Uint32(b))) / (1 << 16) }
+
+func fword(b []byte) fl { return fl(int16(binary.BigEndian.Uint16(b))) }
@@ -4,7 +4,9 @@ package draw
 
 import (
 	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/css/parser"
 	pr "github.com/benoitkugler/webrender/css/properties"
+	"github.com/benoitkugler/webrender/matrix"
 	"github.com/benoitkugler/webrender/text"
 	"github.com/benoitkugler/webrender/utils"
 )
@@ -12,24 +14,73 @@ import (
 type Context struct {
 	Output backend.Canvas         // where to draw the text
 	Fonts  text.FontConfiguration // used to find fonts
+
+	// ColorFonts is an optional cache used by [Context.DrawColorGlyphs].
+	ColorFonts ColorFonts
+	// SVGGlyphs is used to draw the glyphs of OpenType 'SVG ' tables.
+	// If nil, their fallback outlines are drawn instead.
+	SVGGlyphs SVGGlyphDrawer
 }
 
 // CreateFirstLine create the text for the first line of [layout], starting at position `(x,y)`.
 // It also register the fonts used with [backend.Canvas.AddFont].
 func (ctx Context) CreateFirstLine(layout text.EngineLayout, textOverflow string, blockEllipsis pr.TaggedString, scaleX, x, y, angle pr.Fl,
 ) backend.TextDrawing {
-	if layout, ok := layout.(*text.TextLayoutPango); ok {
+	switch layout := layout.(type) {
+	case *text.TextLayoutPango:
 		return ctx.createFirstLinePango(layout, textOverflow, blockEllipsis, scaleX, x, y, angle)
+	case *text.TextLayoutGotext:
+		return ctx.createFirstLineGotext(layout, textOverflow, blockEllipsis, scaleX, x, y, angle)
 	}
 	return backend.TextDrawing{}
 }
 
+// DrawUprightGlyphs paints the glyphs of [text] displayed upright in a vertical
+// writing mode, and marks them as [backend.TextGlyph.Hidden].
+// [frame] is the linear transformation from the physical frame of the glyphs,
+// where they are upright, to the logical frame of the line, that is the inverse
+// of the mapping of the writing mode. The color glyphs are drawn with [foreground].
+//
+// Each glyph is drawn from its pen position on the central baseline of the line,
+// given by [backend.TextGlyph.XAdvance], and its [backend.TextGlyph.Offset] and
+// [backend.TextGlyph.Rise] are applied in the upright frame.
+func (ctx Context) DrawUprightGlyphs(text *backend.TextDrawing, frame matrix.Transform, foreground parser.RGBA) {
+	dst := ctx.Output
+	for _, run := range text.Runs {
+		desc := run.Font.Description()
+		// the central baseline, above the alphabetic one
+		middle := (desc.Ascent - desc.Descent) / 2000 * text.FontSize
+		for i, glyph := range run.Glyphs {
+			if !glyph.Upright || glyph.Hidden {
+				continue
+			}
+			x := text.X + glyph.XAdvance/1000*text.FontSize*text.ScaleX
+			y := text.Y - middle
+			single := *text
+			single.X, single.Y, single.ScaleX, single.Angle = 0, 0, 1, 0
+			single.Runs = []backend.TextRun{{Font: run.Font, Glyphs: []backend.TextGlyph{{
+				Glyph: glyph.Glyph, Offset: glyph.Offset, Rise: glyph.Rise,
+				TextOffset: glyph.TextOffset, TextLength: glyph.TextLength,
+			}}}}
+			dst.OnNewStack(func() {
+				dst.State().Transform(matrix.Mul(matrix.Translation(x, y), frame))
+				ctx.DrawColorGlyphs(&single, foreground)
+				dst.DrawText([]backend.TextDrawing{single})
+			})
+			run.Glyphs[i].Hidden = true
+		}
+	}
+}
+
 // DrawEmoji loads and draws `glyph` onto `dst`.
 // It may be used by backend implementations to render emojis.
 func DrawEmoji(font backend.Font, glyph backend.GID, extents backend.GlyphExtents,
 	fontSize, x, y, xAdvance utils.Fl, dst backend.Canvas,
 ) {
-	if pFont, ok := font.(*pangoFont); ok {
-		drawEmojiPango(pFont, glyph, extents, fontSize, x, y, xAdvance, dst)
+	switch font := font.(type) {
+	case *pangoFont:
+		drawEmojiPango(font, glyph, extents, fontSize, x, y, xAdvance, dst)
+	case gotextFont:
+		drawEmojiGotext(font, glyph, extents, fontSize, x, y, xAdvance, dst)
 	}
 }
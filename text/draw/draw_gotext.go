@@ -0,0 +1,291 @@
+package draw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/benoitkugler/webrender/backend"
+	pr "github.com/benoitkugler/webrender/css/properties"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+)
+
+var _ backend.Font = gotextFont{}
+
+// gotextFont is a face used by the go-text engine.
+// Contrary to pango, the face does not depend on the font size,
+// so that the glyphs of a face are shared between sizes.
+type gotextFont struct {
+	face   *font.Face
+	origin text.FontOrigin
+	desc   backend.FontDescription
+}
+
+func (f gotextFont) Origin() text.FontOrigin { return f.origin }
+
+func (f gotextFont) Description() backend.FontDescription { return f.desc }
+
+func newGotextFont(fts *text.FontConfigurationGotext, face *font.Face, content []byte) gotextFont {
+	origin := fts.FaceOrigin(face)
+	family, aspect := fts.FaceMetadata(face)
+	out := gotextFont{face: face, origin: origin}
+	out.desc = backend.FontDescription{
+		Family:             family,
+		Style:              text.FSyNormal,
+		Weight:             int(aspect.Weight),
+		IsOpentype:         true,
+		IsOpentypeOpentype: isCFF(content, origin.Index),
+		Variations:         text.FormatVariations(fts.FaceVariations(face)),
+	}
+	if aspect.Style == font.StyleItalic {
+		out.desc.Style = text.FSyItalic
+	}
+	if extents, ok := face.FontHExtents(); ok {
+		upem := pr.Fl(face.Upem())
+		out.desc.Ascent = pr.Fl(extents.Ascender) * 1000 / upem
+		out.desc.Descent = -pr.Fl(extents.Descender) * 1000 / upem
+	}
+	return out
+}
+
+// isCFF returns true if the face at [index] in [content]
+// is an OpenType font with PostScript outlines.
+func isCFF(content []byte, index uint16) bool {
+	if bytes.HasPrefix(content, []byte("ttcf")) {
+		start := 12 + 4*int(index)
+		if len(content) < start+4 {
+			return false
+		}
+		offset := int(binary.BigEndian.Uint32(content[start:]))
+		if len(content) < offset {
+			return false
+		}
+		content = content[offset:]
+	}
+	return bytes.HasPrefix(content, []byte("OTTO"))
+}
+
+func fixedToFloat(v fixed.Int26_6) pr.Fl { return pr.Fl(v) / 64 }
+
+func round(v pr.Fl) int { return int(math.Round(float64(v))) }
+
+func lineWidth(line shaping.Line) pr.Fl {
+	var width fixed.Int26_6
+	for _, run := range line {
+		width += run.Advance
+	}
+	return fixedToFloat(width)
+}
+
+// ellipsizeEnd replaces the end of the first line of [layout] by "…"
+// so that it fits in [layout.MaxWidth].
+func ellipsizeEnd(layout *text.TextLayoutGotext) {
+	line, index := layout.GetFirstLine()
+	maxWidth := pr.Fl(layout.MaxWidth)
+	if layout.MaxWidth == pr.Inf || lineWidth(line) <= maxWidth {
+		return
+	}
+	firstLine := layout.Text()
+	if index != -1 {
+		firstLine = firstLine[:index]
+	}
+	// the lines are measured without width constraint
+	layout.MaxWidth = pr.Inf
+	fits := func(n int) bool {
+		layout.SetText(strings.TrimRight(string(firstLine[:n]), " ") + "…")
+		line, _ := layout.GetFirstLine()
+		return lineWidth(line) <= maxWidth
+	}
+	// binary search for the longest fitting text
+	lo, hi := 0, len(firstLine)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	layout.SetText(strings.TrimRight(string(firstLine[:lo]), " ") + "…")
+}
+
+func (ctx Context) createFirstLineGotext(layout_ *text.TextLayoutGotext,
+	textOverflow string, blockEllipsis pr.TaggedString, scaleX, x, y, angle pr.Fl,
+) backend.TextDrawing {
+	fts := ctx.Fonts.(*text.FontConfigurationGotext)
+	layout := *layout_ // layouts are cached and shared: work on a copy
+	style := layout.Style
+
+	if blockEllipsis.Tag != pr.None {
+		ellipsis := blockEllipsis.S
+		if blockEllipsis.Tag == pr.Auto {
+			ellipsis = "…"
+		}
+		// Remove last word if hyphenated
+		newText := layout.Text()
+		if hyph := style.HyphenateCharacter; strings.HasSuffix(string(newText), hyph) {
+			lastWordEnd := fts.LastWordEnd(newText[:len(newText)-len([]rune(hyph))])
+			if lastWordEnd != -1 && lastWordEnd != 0 {
+				newText = newText[:lastWordEnd]
+			}
+		}
+		layout.SetText(string(newText) + ellipsis)
+
+		_, index := layout.GetFirstLine()
+		for index != 0 && index != -1 {
+			t := layout.Text()
+			lastWordEnd := fts.LastWordEnd(t[:len(t)-len([]rune(ellipsis))])
+			if lastWordEnd == -1 {
+				break
+			}
+			layout.SetText(string(t[:lastWordEnd]) + ellipsis)
+			_, index = layout.GetFirstLine()
+		}
+	}
+	if textOverflow == "ellipsis" {
+		ellipsizeEnd(&layout)
+	}
+
+	firstLine, _ := layout.GetFirstLine()
+
+	var (
+		output   backend.TextDrawing
+		lastFont backend.Font
+		xAdvance pr.Fl
+	)
+
+	fontSize := style.FontDescription.Size
+	textRunes := layout.Text()
+
+	output.FontSize = fontSize
+	output.ScaleX = scaleX
+	output.X, output.Y = x, y
+	output.Angle = angle
+	synthesized := layout.Synthesized()
+	output.Skew, output.Embolden = synthesized.Skew, synthesized.Embolden
+	output.Text = textRunes
+
+	if fontSize == 0 {
+		return output
+	}
+
+	for _, run := range firstLine {
+		if run.Face == nil {
+			continue
+		}
+
+		// Font content
+		origin := fts.FaceOrigin(run.Face)
+		content := ctx.Fonts.FontContent(origin)
+		gFont := newGotextFont(fts, run.Face, content)
+		outFont := ctx.Output.AddFont(gFont, content)
+
+		if gFont != lastFont { // add a new "run"
+			output.Runs = append(output.Runs, backend.TextRun{Font: gFont})
+			lastFont = gFont
+		} // else use the last one
+
+		runDst := &output.Runs[len(output.Runs)-1]
+		upem := pr.Fl(run.Face.Upem())
+		for i, glyph := range run.Glyphs {
+			var outGlyph backend.TextGlyph
+
+			outGlyph.Offset = fixedToFloat(glyph.XOffset) * 1000 / fontSize
+			outGlyph.Rise = -fixedToFloat(glyph.YOffset) * 1000
+			outGlyph.Glyph = backend.GID(glyph.GlyphID)
+			outGlyph.Upright = layout.IsUpright(glyph.ClusterIndex)
+
+			// Ink bounding box and logical widths in font
+			if _, in := outFont.Extents[outGlyph.Glyph]; !in {
+				if ink, ok := run.Face.GlyphExtents(glyph.GlyphID); ok {
+					x1, y1 := int(ink.XBearing*1000/upem), int((ink.YBearing+ink.Height)*1000/upem)
+					x2, y2 := int((ink.XBearing+ink.Width)*1000/upem), int(ink.YBearing*1000/upem)
+					outFont.Bbox[0] = min(outFont.Bbox[0], x1)
+					outFont.Bbox[1] = min(outFont.Bbox[1], y1)
+					outFont.Bbox[2] = max(outFont.Bbox[2], x2)
+					outFont.Bbox[3] = max(outFont.Bbox[3], y2)
+				}
+				logical := backend.GlyphExtents{
+					Width: round(run.Face.HorizontalAdvance(glyph.GlyphID) * 1000 / upem),
+				}
+				if extents, ok := run.Face.FontHExtents(); ok {
+					logical.Y = int(-extents.Ascender * 1000 / upem)
+					logical.Height = int((extents.Ascender - extents.Descender) * 1000 / upem)
+				}
+				outFont.Extents[outGlyph.Glyph] = logical
+			}
+
+			// Kerning, word spacing, letter spacing
+			advance := fixedToFloat(glyph.XAdvance) * 1000 / fontSize
+			outGlyph.Kerning = round(pr.Fl(outFont.Extents[outGlyph.Glyph].Width) - advance + outGlyph.Offset)
+
+			// Mapping between glyphs and characters : only the first
+			// glyph of a cluster is mapped to its runes
+			start := min(glyph.ClusterIndex, len(textRunes))
+			end := min(start+glyph.RuneCount, len(textRunes))
+			outGlyph.TextOffset = start
+			if i == 0 || run.Glyphs[i-1].ClusterIndex != glyph.ClusterIndex {
+				outGlyph.TextLength = end - start
+				if _, in := outFont.Cmap[outGlyph.Glyph]; !in {
+					outFont.Cmap[outGlyph.Glyph] = textRunes[start:end]
+				}
+			}
+
+			// advance
+			outGlyph.XAdvance = xAdvance
+			xAdvance += pr.Fl(outFont.Extents[outGlyph.Glyph].Width) + outGlyph.Offset - pr.Fl(outGlyph.Kerning)
+
+			runDst.Glyphs = append(runDst.Glyphs, outGlyph)
+		}
+	}
+
+	return output
+}
+
+// bitmapMimeTypes are the formats of the bitmap glyphs supported
+// by [drawEmojiGotext]
+var bitmapMimeTypes = map[font.BitmapFormat]string{
+	font.PNG:  "image/png",
+	font.JPG:  "image/jpeg",
+	font.TIFF: "image/tiff",
+}
+
+func drawEmojiGotext(font_ gotextFont, glyph backend.GID, extents backend.GlyphExtents,
+	fontSize, x, y, xAdvance utils.Fl, dst backend.Canvas,
+) {
+	face := font_.face
+	switch data := face.GlyphData(font.GID(glyph)).(type) {
+	case font.GlyphBitmap:
+		mimeType, ok := bitmapMimeTypes[data.Format]
+		if !ok {
+			logger.WarningLogger.Printf("unsupported format for bitmap glyph %d in %s", glyph, font_.origin.File)
+			return
+		}
+		img := backend.RasterImage{
+			Content:   bytes.NewReader(data.Data),
+			MimeType:  mimeType,
+			Rendering: "",
+			ID:        utils.Hash(fmt.Sprintf("%p-%d", face, glyph)),
+		}
+
+		d := utils.Fl(extents.Width) / 1000
+		a := utils.Fl(data.Width) / utils.Fl(data.Height) * d
+		f := utils.Fl(-extents.Y-extents.Height)/1000 - fontSize
+		f = y + f
+		e := xAdvance / 1000
+		e = x + e*fontSize
+
+		dst.OnNewStack(func() {
+			dst.State().Transform(matrix.New(a, 0, 0, d, e, f))
+			dst.DrawRasterImage(img, fontSize, fontSize)
+		})
+	}
+}
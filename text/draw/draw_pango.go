@@ -7,6 +7,7 @@ import (
 
 	"github.com/benoitkugler/textlayout/fonts"
 	"github.com/benoitkugler/textlayout/fonts/truetype"
+	fc "github.com/benoitkugler/textprocessing/fontconfig"
 	"github.com/benoitkugler/textprocessing/pango"
 	"github.com/benoitkugler/textprocessing/pango/fcfonts"
 	"github.com/benoitkugler/webrender/backend"
@@ -47,9 +48,36 @@ func (f *pangoFont) Description() backend.FontDescription {
 		out.IsOpentypeOpentype = face.Type == truetype.TypeOpenType
 	}
 
+	if face, ok := font.GetHarfbuzzFont().Face().(truetype.FaceVariable); ok {
+		// fontconfig stores the variations implied by the weight and width,
+		// the font description the ones from 'font-variation-settings'
+		variations, _ := font.Pattern.GetString(fc.FONT_VARIATIONS)
+		out.Variations = pangoVariations(face.Variations(), variations+","+desc.Variations)
+	}
+
 	return out
 }
 
+// pangoVariations returns the coordinates of [variations] differing from the default
+// instance, normalized by [text.FormatVariations]. Later settings have the priority.
+func pangoVariations(fvar truetype.TableFvar, variations string) string {
+	settings := text.ParseVariations(variations)
+	var out []text.Variation
+	for _, axis := range fvar.Axis {
+		value := axis.Default
+		for _, v := range settings {
+			if truetype.NewTag(v.Tag[0], v.Tag[1], v.Tag[2], v.Tag[3]) == axis.Tag {
+				value = min(max(v.Value, axis.Minimum), axis.Maximum)
+			}
+		}
+		if value != axis.Default {
+			tag := [4]byte{byte(axis.Tag >> 24), byte(axis.Tag >> 16), byte(axis.Tag >> 8), byte(axis.Tag)}
+			out = append(out, text.Variation{Tag: tag, Value: value})
+		}
+	}
+	return text.FormatVariations(out)
+}
+
 func (ctx Context) createFirstLinePango(layout *text.TextLayoutPango,
 	textOverflow string, blockEllipsis pr.TaggedString, scaleX, x, y, angle pr.Fl,
 ) backend.TextDrawing {
@@ -109,6 +137,8 @@ func (ctx Context) createFirstLinePango(layout *text.TextLayoutPango,
 	output.ScaleX = scaleX
 	output.X, output.Y = x, y
 	output.Angle = angle
+	synthesized := layout.Synthesized()
+	output.Skew, output.Embolden = synthesized.Skew, synthesized.Embolden
 	output.Text = textRunes
 
 	for run := firstLine.Runs; run != nil; run = run.Next {
@@ -148,6 +178,8 @@ func (ctx Context) createFirstLinePango(layout *text.TextLayoutPango,
 			if glyph == pango.GLYPH_EMPTY || glyph&pango.GLYPH_UNKNOWN_FLAG != 0 {
 				outGlyph.Offset = pr.Fl(width) / fontSize
 				outGlyph.Glyph = backend.GID(fonts.EmptyGlyph)
+				outGlyph.XAdvance = xAdvance
+				xAdvance += outGlyph.Offset
 				continue
 			}
 
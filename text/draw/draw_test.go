@@ -0,0 +1,220 @@
+package draw
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/backend/recorder"
+	"github.com/benoitkugler/webrender/css/parser"
+	pr "github.com/benoitkugler/webrender/css/properties"
+	"github.com/benoitkugler/webrender/css/validation"
+	"github.com/benoitkugler/webrender/matrix"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/text/hyphen"
+	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/fontscan"
+)
+
+type textContext struct {
+	fc text.FontConfiguration
+}
+
+func (tc textContext) Fonts() text.FontConfiguration { return tc.fc }
+func (tc textContext) HyphenCache() map[text.HyphenDictKey]hyphen.Hyphener {
+	return make(map[text.HyphenDictKey]hyphen.Hyphener)
+}
+
+func (tc textContext) StrutLayoutsCache() map[text.StrutLayoutKey][2]pr.Float {
+	return make(map[text.StrutLayoutKey][2]pr.Float)
+}
+
+func newFontConfigurationGotext(t *testing.T) *text.FontConfigurationGotext {
+	fm := fontscan.NewFontMap(log.New(io.Discard, "", 0))
+	if err := fm.UseSystemFonts(t.TempDir()); err != nil {
+		t.Skip("no system fonts:", err)
+	}
+	return text.NewFontConfigurationGotext(fm)
+}
+
+// returns the width of the drawn glyphs, in CSS pixels
+func drawingWidth(td backend.TextDrawing, fonts []*recorder.Font) pr.Fl {
+	var pen pr.Fl
+	for _, run := range td.Runs {
+		var chars *backend.FontChars
+		for _, font := range fonts {
+			if font.Origin == run.Font.Origin() {
+				chars = font.Chars
+			}
+		}
+		for _, glyph := range run.Glyphs {
+			pen += pr.Fl(chars.Extents[glyph.Glyph].Width) + glyph.Offset - pr.Fl(glyph.Kerning)
+		}
+	}
+	return pen / 1000 * td.FontSize
+}
+
+func TestCreateFirstLineGotext(t *testing.T) {
+	fc := newFontConfigurationGotext(t)
+	ctx := textContext{fc}
+
+	style := pr.InitialValues.Copy()
+	style.SetFontFamily(pr.Strings{"DejaVu Sans", "sans-serif"})
+	style.SetFontSize(pr.FToV(16))
+	style.SetWhiteSpace("nowrap")
+
+	const content = "Hello world"
+	line := text.SplitFirstLine([]rune(content), style, ctx, pr.Float(40), false, true)
+
+	doc := recorder.NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	drawer := Context{Output: page, Fonts: fc}
+
+	drawing := drawer.CreateFirstLine(line.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, 0, 0, 0)
+	if string(drawing.Text) != content || len(drawing.Runs) == 0 {
+		t.Fatalf("unexpected drawing %v", drawing)
+	}
+	fonts := doc.DisplayList().Fonts
+	if len(fonts) == 0 || len(fonts[0].Content) == 0 {
+		t.Fatal("font not registered")
+	}
+	var nbGlyphs int
+	for _, run := range drawing.Runs {
+		for _, glyph := range run.Glyphs {
+			nbGlyphs++
+			if glyph.Glyph == 0 {
+				t.Fatalf("missing glyph in %v", run)
+			}
+			if mapped := string(drawing.Text[glyph.TextOffset : glyph.TextOffset+glyph.TextLength]); mapped != string(content[glyph.TextOffset]) {
+				t.Fatalf("unexpected glyph mapping %q", mapped)
+			}
+		}
+	}
+	if nbGlyphs != len(content) {
+		t.Fatalf("expected %d glyphs, got %d", len(content), nbGlyphs)
+	}
+	if w := drawingWidth(drawing, fonts); w < pr.Fl(line.Width)-1 || w > pr.Fl(line.Width)+1 {
+		t.Fatalf("expected width %v, got %v", line.Width, w)
+	}
+
+	// the line is wider than 40px
+	drawing = drawer.CreateFirstLine(line.Layout, "ellipsis", pr.TaggedString{Tag: pr.None}, 1, 0, 0, 0)
+	if s := string(drawing.Text); !strings.HasSuffix(s, "…") || len(s) >= len(content) {
+		t.Fatalf("unexpected ellipsis %s", s)
+	}
+	if w := drawingWidth(drawing, doc.DisplayList().Fonts); w > 41 {
+		t.Fatalf("ellipsis is too wide: %v", w)
+	}
+	// the layout is not modified
+	if string(line.Layout.Text()) != content {
+		t.Fatal("layout modified")
+	}
+
+	// justification enlarges the space
+	line.Layout.SetJustification(10)
+	line.Layout.ApplyJustification()
+	drawing = drawer.CreateFirstLine(line.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, 0, 0, 0)
+	if w := drawingWidth(drawing, doc.DisplayList().Fonts); w < pr.Fl(line.Width)+9 || w > pr.Fl(line.Width)+11 {
+		t.Fatalf("expected justified width %v, got %v", line.Width+10, w)
+	}
+}
+
+func TestVariableFontGotext(t *testing.T) {
+	fc := newFontConfigurationGotext(t)
+	url, err := utils.PathToURL("../../resources_test/Selawik-VF-Subset.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.AddFontFace(validation.FontFaceDescriptors{
+		Src:           []pr.NamedString{{Name: "external", String: url}},
+		FontFamily:    "variable",
+		FontWeight:    pr.IntString{Int: 100},
+		FontWeightEnd: pr.IntString{Int: 900},
+	}, utils.DefaultUrlFetcher)
+
+	doc := recorder.NewDocument()
+	drawer := Context{Output: doc.AddPage(0, 0, 100, 100), Fonts: fc}
+	faces := make(Faces)
+	for _, weight := range []int{400, 700} {
+		style := pr.InitialValues.Copy()
+		style.SetFontFamily(pr.Strings{"variable"})
+		style.SetFontWeight(pr.IntString{Int: weight})
+		line := text.SplitFirstLine([]rune("A"), style, textContext{fc}, pr.Inf, false, true)
+		drawing := drawer.CreateFirstLine(line.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, 0, 0, 0)
+
+		font := drawing.Runs[0].Font
+		if weight == 400 && font.Description().Variations != "" || weight == 700 && font.Description().Variations != "wght=700" {
+			t.Fatalf("unexpected variations %q for weight %d", font.Description().Variations, weight)
+		}
+		// backends use the instance
+		face := faces.Load(font, fc.FontContent(font.Origin()))
+		gid, _ := face.NominalGlyph('A')
+		if advance := map[int]float32{400: 661, 700: 720}[weight]; face.HorizontalAdvance(gid) != advance {
+			t.Fatalf("expected advance %v, got %v", advance, face.HorizontalAdvance(gid))
+		}
+	}
+	if fonts := doc.DisplayList().Fonts; len(fonts) != 2 {
+		t.Fatalf("expected one font per instance, got %d", len(fonts))
+	}
+}
+
+func TestDrawUprightGlyphs(t *testing.T) {
+	fc := newFontConfigurationGotext(t)
+	ctx := textContext{fc}
+
+	style := pr.InitialValues.Copy()
+	style.SetFontFamily(pr.Strings{"DejaVu Sans", "sans-serif"})
+	style.SetFontSize(pr.FToV(16))
+	style.SetWritingMode("vertical-rl")
+	style.SetTextOrientation("upright")
+	line := text.SplitFirstLine([]rune("ab"), style, ctx, pr.Inf, false, true)
+
+	doc := recorder.NewDocument()
+	page := doc.AddPage(0, 0, 100, 100)
+	drawer := Context{Output: page, Fonts: fc}
+
+	const x, y = 10, 50
+	drawing := drawer.CreateFirstLine(line.Layout, "clip", pr.TaggedString{Tag: pr.None}, 1, x, y, 0)
+	drawer.DrawUprightGlyphs(&drawing, matrix.New(0, -1, 1, 0, 0, 0), parser.RGBA{A: 1})
+
+	desc := drawing.Runs[0].Font.Description()
+	middle := (desc.Ascent - desc.Descent) / 2000 * 16
+	var (
+		origins [][2]pr.Fl
+		glyphs  []backend.TextGlyph
+	)
+	for _, cmd := range doc.DisplayList().Pages[0].Commands {
+		switch cmd.Op {
+		case recorder.OpTransform:
+			origins = append(origins, [2]pr.Fl{cmd.Values[4], cmd.Values[5]})
+		case recorder.OpDrawText:
+			glyphs = append(glyphs, cmd.Texts[0].Runs[0].Glyphs...)
+		}
+	}
+	if len(origins) != 2 || len(glyphs) != 2 {
+		t.Fatalf("unexpected commands %v %v", origins, glyphs)
+	}
+	extents := doc.DisplayList().Fonts[0].Chars.Extents
+	for i, glyph := range glyphs {
+		// the pen is on the central baseline, and moves by the vertical advance
+		origin := origins[i]
+		if dx := origin[0] - x - pr.Fl(i)*pr.Fl(line.Width)/2; dx < -0.1 || dx > 0.1 || origin[1] != y-middle {
+			t.Fatalf("unexpected pen position %v", origin)
+		}
+		// the glyph is centered on the pen, in its upright frame...
+		if offset := glyph.Offset + pr.Fl(extents[glyph.Glyph].Width)/2; offset < -1 || offset > 1 {
+			t.Fatalf("unexpected horizontal offset %v", glyph.Offset)
+		}
+		// ... and its baseline is below the pen, inside the vertical advance
+		if rise := glyph.Rise / 1000; rise <= 0 || rise >= pr.Fl(line.Width)/2 {
+			t.Fatalf("unexpected vertical offset %v", glyph.Rise)
+		}
+	}
+	for _, glyph := range drawing.Runs[0].Glyphs {
+		if !glyph.Upright || !glyph.Hidden {
+			t.Fatalf("upright glyph not painted : %v", glyph)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package draw
+
+import (
+	"bytes"
+
+	"github.com/benoitkugler/webrender/backend"
+	"github.com/benoitkugler/webrender/logger"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+// Faces parses and caches the font files received by [backend.Canvas.AddFont],
+// so that backends may access the glyph outlines and bitmaps.
+//
+// It is independent of the text engine used to layout the text, since
+// both engines forward the raw font file content.
+//
+// Variable fonts are instantiated with the coordinates
+// of [backend.FontDescription.Variations].
+type Faces map[faceKey]*font.Face
+
+type faceKey struct {
+	origin     text.FontOrigin
+	variations string
+}
+
+func newFaceKey(font backend.Font) faceKey {
+	return faceKey{font.Origin(), font.Description().Variations}
+}
+
+// Load returns the face for [font], parsing [content] if needed.
+// It returns nil if [content] is not a valid font file,
+// in which case a warning is logged.
+func (fs Faces) Load(font backend.Font, content []byte) *font.Face {
+	key := newFaceKey(font)
+	if face, has := fs[key]; has {
+		return face
+	}
+	face := parseFace(key.origin, content)
+	if face != nil && key.variations != "" {
+		face.SetVariations(newVariations(text.ParseVariations(key.variations)))
+	}
+	fs[key] = face // also cache invalid fonts
+	return face
+}
+
+// Face returns the face previously registered with [Load],
+// or nil.
+func (fs Faces) Face(font backend.Font) *font.Face { return fs[newFaceKey(font)] }
+
+func newVariations(vs []text.Variation) []font.Variation {
+	out := make([]font.Variation, len(vs))
+	for i, v := range vs {
+		out[i] = font.Variation{Tag: ot.NewTag(v.Tag[0], v.Tag[1], v.Tag[2], v.Tag[3]), Value: v.Value}
+	}
+	return out
+}
+
+func parseFace(origin text.FontOrigin, content []byte) *font.Face {
+	faces, err := font.ParseTTC(bytes.NewReader(content))
+	if err != nil {
+		logger.WarningLogger.Printf("invalid font file %s: %s", origin.File, err)
+		return nil
+	}
+	index := int(origin.Index)
+	if index >= len(faces) {
+		logger.WarningLogger.Printf("invalid font index %d for %s", index, origin.File)
+		return nil
+	}
+	return faces[index]
+}
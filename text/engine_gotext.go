@@ -1,12 +1,12 @@
 package text
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"path/filepath"
-	"sort"
 
 	bkLang "github.com/benoitkugler/textlayout/language"
 	pr "github.com/benoitkugler/webrender/css/properties"
@@ -17,6 +17,7 @@ import (
 	"github.com/go-text/typesetting/di"
 	"github.com/go-text/typesetting/font"
 	"github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/font/opentype/tables"
 	"github.com/go-text/typesetting/fontscan"
 	"github.com/go-text/typesetting/language"
 
@@ -27,7 +28,7 @@ import (
 
 var (
 	_ FontConfiguration = (*FontConfigurationGotext)(nil)
-	_ EngineLayout      = layoutGotext{}
+	_ EngineLayout      = (*TextLayoutGotext)(nil)
 )
 
 type FontConfigurationGotext struct {
@@ -38,17 +39,45 @@ type FontConfigurationGotext struct {
 
 	lineWrapper shaping.LineWrapper
 
-	fontsContent  map[string][]byte        // to be embedded in the target
-	fontsFeatures map[*font.Font][]Feature // as requested by @font-face
+	fontsContent   map[string][]byte        // to be embedded in the target
+	fontsFeatures  map[*font.Font][]Feature // as requested by @font-face
+	fontsWeights   map[*font.Font]weightRange
+	fontsStretches map[*font.Font]stretchRange
+
+	// variable fonts are instantiated by copying the [font.Font],
+	// since the shaper caches its fonts by pointer
+	instances     map[instanceKey]*font.Face
+	instanceFonts map[*font.Font]instanceKey
+	fontsAxes     map[*font.Font][]tables.VariationAxisRecord
+
+	// @font-face rules with a 'unicode-range' descriptor are registred
+	// with one (internal) family for each range, since the font map only
+	// selects one face per family.
+	rangeFamilies map[string][]string // normalized family -> internal families
+	familyNames   map[string]string   // normalized internal family -> normalized family
+
+	missingFaces map[string]missingFaces // FontDescription.binary (without size) -> primary face
 
 	textLayoutCache map[textKey]FirstLine
 }
 
+// missingFaces stores the font styles the primary face
+// of a [FontDescription] does not provide.
+type missingFaces struct{ bold, oblique bool }
+
 func NewFontConfigurationGotext(fm *fontscan.FontMap) *FontConfigurationGotext {
 	out := FontConfigurationGotext{
 		fm:              fm,
 		fontsContent:    make(map[string][]byte),
 		fontsFeatures:   make(map[*font.Font][]Feature), // as loaded by loadOneFont
+		fontsWeights:    make(map[*font.Font]weightRange),
+		fontsStretches:  make(map[*font.Font]stretchRange),
+		instances:       make(map[instanceKey]*font.Face),
+		instanceFonts:   make(map[*font.Font]instanceKey),
+		fontsAxes:       make(map[*font.Font][]tables.VariationAxisRecord),
+		rangeFamilies:   make(map[string][]string),
+		familyNames:     make(map[string]string),
+		missingFaces:    make(map[string]missingFaces),
 		textLayoutCache: make(map[textKey]FirstLine),
 	}
 	out.shaper.SetFontCacheSize(64)
@@ -83,6 +112,8 @@ func (f *FontConfigurationGotext) AddFontFace(ruleDescriptors validation.FontFac
 
 // returns an error if the font is not found or has failed to be downloaded.
 func (f *FontConfigurationGotext) loadOneFont(url pr.NamedString, ruleDescriptors validation.FontFaceDescriptors, urlFetcher utils.UrlFetcher) (string, error) {
+	var fragmentIndex int
+	url.String, fragmentIndex = splitFontIndex(url.String)
 	if url.Name == "local" {
 		family := url.String
 		// search through the system fonts, returning the filepath of the font, or an empty string
@@ -98,6 +129,7 @@ func (f *FontConfigurationGotext) loadOneFont(url pr.NamedString, ruleDescriptor
 		if err != nil {
 			return "", fmt.Errorf("failed to load local font %s: %s", family, err)
 		}
+		fragmentIndex = int(location.Index)
 	}
 
 	result, err := urlFetcher(url.String)
@@ -110,39 +142,97 @@ func (f *FontConfigurationGotext) loadOneFont(url pr.NamedString, ruleDescriptor
 		return "", fmt.Errorf("failed to load font at %s", url.String)
 	}
 
-	lds, err := opentype.NewLoaders(result.Content)
+	lds, err := opentype.NewLoaders(bytes.NewReader(content))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse font at %s : %s", url.String, err)
 	}
-	if len(lds) != 1 {
-		return "", fmt.Errorf("font collections are not supported (at %s)", url.String)
+	index, err := collectionIndex(lds, fragmentIndex, ruleDescriptors)
+	if err != nil {
+		return "", fmt.Errorf("failed to load font at %s : %s", url.String, err)
 	}
 
-	ft, err := font.NewFont(lds[0])
+	ft, err := font.NewFont(lds[index])
 	if err != nil {
 		return "", fmt.Errorf("failed to parse font at %s : %s", url.String, err)
 	}
+	if len(ruleDescriptors.UnicodeRange) != 0 {
+		ft.Cmap = rangeCmap{Cmap: ft.Cmap, ranges: ruleDescriptors.UnicodeRange}
+	}
 
 	if url.Name == "external" {
 		f.fontsContent[url.String] = content
 	}
 
+	family := string(ruleDescriptors.FontFamily)
+	if len(ruleDescriptors.UnicodeRange) != 0 {
+		family = f.rangeFamily(family, ruleDescriptors.UnicodeRange)
+	}
+	weights := newWeightRange(ruleDescriptors)
 	desc := font.Description{
-		Family: string(ruleDescriptors.FontFamily),
+		Family: family,
 		Aspect: newAspect(
 			newFontStyle(ruleDescriptors.FontStyle),
-			newFontWeight(ruleDescriptors.FontWeight),
-			newFontStretch(ruleDescriptors.FontStretch),
+			weights.clamp(uint16(font.WeightNormal)),
+			newStretchRange(ruleDescriptors).clamp(FSeNormal),
 		),
 	}
-	f.fm.AddFace(font.NewFace(ft), fontscan.Location{File: url.String}, desc)
+	f.fm.AddFace(font.NewFace(ft), fontscan.Location{File: url.String, Index: index}, desc)
 
 	// track the font features to apply
 	f.fontsFeatures[ft] = getFontFaceFeatures(ruleDescriptors)
+	f.fontsWeights[ft] = weights
+	f.fontsStretches[ft] = newStretchRange(ruleDescriptors)
 
 	return url.String, nil
 }
 
+// rangeFamily returns the internal family used for the faces of
+// [family] restricted to [ranges].
+func (f *FontConfigurationGotext) rangeFamily(family string, ranges validation.UnicodeRanges) string {
+	family = font.NormalizeFamily(family)
+	internal := font.NormalizeFamily(fmt.Sprintf("%s %v", family, ranges))
+	if _, has := f.familyNames[internal]; !has {
+		f.familyNames[internal] = family
+		f.rangeFamilies[family] = append(f.rangeFamilies[family], internal)
+	}
+	return internal
+}
+
+// rangeCmap hides the code points outside of a 'unicode-range' descriptor,
+// so that the font map only selects the face for the code points in the range.
+type rangeCmap struct {
+	font.Cmap
+	ranges validation.UnicodeRanges
+}
+
+func (c rangeCmap) Iter() font.CmapIter { return &rangeCmapIter{iter: c.Cmap.Iter(), ranges: c.ranges} }
+
+func (c rangeCmap) Lookup(r rune) (font.GID, bool) {
+	if !c.ranges.Contains(r) {
+		return 0, false
+	}
+	return c.Cmap.Lookup(r)
+}
+
+type rangeCmapIter struct {
+	iter   font.CmapIter
+	ranges validation.UnicodeRanges
+	r      rune
+	gid    font.GID
+}
+
+func (it *rangeCmapIter) Next() bool {
+	for it.iter.Next() {
+		// Char may only be called once per item
+		if it.r, it.gid = it.iter.Char(); it.ranges.Contains(it.r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *rangeCmapIter) Char() (rune, font.GID) { return it.r, it.gid }
+
 // FontContent returns the content of the given face, which may be needed
 // in the final output.
 func (f *FontConfigurationGotext) FontContent(font FontOrigin) []byte {
@@ -162,26 +252,473 @@ func (f *FontConfigurationGotext) FontContent(font FontOrigin) []byte {
 	return b
 }
 
-type layoutGotext struct {
+// FaceOrigin returns the location of [face], which must have been
+// loaded by the font map of [f].
+func (f *FontConfigurationGotext) FaceOrigin(face *font.Face) FontOrigin {
+	loc := f.fm.FontLocation(f.parentFont(face.Font))
+	return FontOrigin{File: loc.File, Index: loc.Index, Instance: loc.Instance}
+}
+
+// FaceMetadata returns the family and aspect of [face], which must have been
+// loaded by the font map of [f].
+func (f *FontConfigurationGotext) FaceMetadata(face *font.Face) (family string, aspect font.Aspect) {
+	family, aspect = f.fm.FontMetadata(f.parentFont(face.Font))
+	if name, isRange := f.familyNames[family]; isRange {
+		family = name
+	}
+	return family, aspect
+}
+
+// FaceVariations returns the design coordinates used by [face],
+// or nil for the default instance.
+func (f *FontConfigurationGotext) FaceVariations(face *font.Face) []Variation {
+	return ParseVariations(f.instanceFonts[face.Font].variations)
+}
+
+type instanceKey struct {
+	font       *font.Font // the font loaded by the font map
+	variations string     // see [FormatVariations]
+}
+
+// parentFont returns the font loaded by the font map,
+// which is [ft] itself for non variable fonts.
+func (fc *FontConfigurationGotext) parentFont(ft *font.Font) *font.Font {
+	if key, isInstance := fc.instanceFonts[ft]; isInstance {
+		return key.font
+	}
+	return ft
+}
+
+// axes returns the variation axes of [ft], which are not
+// exposed by [font.Font], and must be read from the font file.
+func (fc *FontConfigurationGotext) axes(ft *font.Font) []tables.VariationAxisRecord {
+	if axes, has := fc.fontsAxes[ft]; has {
+		return axes
+	}
+	var axes []tables.VariationAxisRecord
+	loc := fc.fm.FontLocation(ft)
+	lds, err := opentype.NewLoaders(bytes.NewReader(fc.FontContent(FontOrigin{File: loc.File, Index: loc.Index})))
+	if err == nil && int(loc.Index) < len(lds) {
+		if raw, err := lds[loc.Index].RawTable(opentype.MustNewTag("fvar")); err == nil {
+			fvar, _, err := tables.ParseFvar(raw)
+			if err == nil {
+				axes = fvar.FvarRecords.Axis
+			}
+		}
+	}
+	fc.fontsAxes[ft] = axes // also cache non variable fonts
+	return axes
+}
+
+// instance returns the face of [face] matching the weight, width and style of
+// [desc], also applying its 'font-variation-settings'.
+// [face] is returned for non variable fonts and default instances.
+func (fc *FontConfigurationGotext) instance(face *font.Face, desc FontDescription) *font.Face {
+	if face == nil {
+		return nil
+	}
+	axes := fc.axes(face.Font)
+	if len(axes) == 0 {
+		return face
+	}
+	hasAxis := func(tag string) bool {
+		for _, axis := range axes {
+			if axis.Tag == opentype.MustNewTag(tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	weight := desc.Weight
+	if weight == 0 {
+		weight = uint16(font.WeightNormal)
+	}
+	weight = fc.fontsWeights[face.Font].clamp(weight)
+	stretch := desc.Stretch
+	if stretch == 0 {
+		stretch = FSeNormal
+	}
+	stretch = fc.fontsStretches[face.Font].clamp(stretch)
+	settings := []Variation{
+		{Tag: [4]byte{'w', 'g', 'h', 't'}, Value: pr.Fl(weight)},
+		{Tag: [4]byte{'w', 'd', 't', 'h'}, Value: pr.Fl(stretch)},
+	}
+	italic := Variation{Tag: [4]byte{'i', 't', 'a', 'l'}, Value: 1}
+	oblique := Variation{Tag: [4]byte{'s', 'l', 'n', 't'}, Value: -14} // the default oblique angle
+	switch desc.Style {
+	case FSyItalic:
+		if hasAxis("ital") || !hasAxis("slnt") {
+			settings = append(settings, italic)
+		} else {
+			settings = append(settings, oblique)
+		}
+	case FSyOblique:
+		if hasAxis("slnt") || !hasAxis("ital") {
+			settings = append(settings, oblique)
+		} else {
+			settings = append(settings, italic)
+		}
+	}
+	// explicit settings have the priority
+	settings = append(settings, desc.VariationSettings...)
+
+	// resolve the design coordinates, only keeping the non default ones
+	var variations []Variation
+	for _, axis := range axes {
+		value := axis.Default
+		for _, v := range settings {
+			if opentype.NewTag(v.Tag[0], v.Tag[1], v.Tag[2], v.Tag[3]) == axis.Tag {
+				value = min(max(v.Value, axis.Minimum), axis.Maximum)
+			}
+		}
+		if value != axis.Default {
+			variations = append(variations, Variation{Tag: tagBytes(axis.Tag), Value: value})
+		}
+	}
+	if len(variations) == 0 {
+		return face
+	}
+
+	key := instanceKey{font: face.Font, variations: FormatVariations(variations)}
+	if instance, has := fc.instances[key]; has {
+		return instance
+	}
+	ft := *face.Font
+	instance := font.NewFace(&ft)
+	vs := make([]font.Variation, len(variations))
+	for i, v := range variations {
+		vs[i] = font.Variation{Tag: opentype.NewTag(v.Tag[0], v.Tag[1], v.Tag[2], v.Tag[3]), Value: v.Value}
+	}
+	instance.SetVariations(vs)
+	fc.instances[key] = instance
+	fc.instanceFonts[&ft] = key
+	return instance
+}
+
+// synthesized returns the synthesis applied to the text using [desc].
+// It only depends on the primary face, the one used for the spaces,
+// so that the whole text is consistently transformed.
+func (fc *FontConfigurationGotext) synthesized(desc FontDescription) Synthesized {
+	if desc.Synthesis == 0 {
+		return Synthesized{}
+	}
+	key := string(desc.binary(nil, false))
+	missing, has := fc.missingFaces[key]
+	if !has {
+		fc.fm.SetQuery(fc.newQuery(desc))
+		if face := fc.fm.ResolveFace(' '); face != nil {
+			_, aspect := fc.FaceMetadata(face)
+			hasAxis := func(tag string) bool {
+				for _, axis := range fc.axes(face.Font) {
+					if axis.Tag == opentype.MustNewTag(tag) {
+						return true
+					}
+				}
+				return false
+			}
+			// variable fonts are instantiated instead
+			missing.bold = desc.Weight >= 600 && aspect.Weight <= 500 && !hasAxis("wght")
+			missing.oblique = desc.Style != FSyNormal && aspect.Style == font.StyleNormal &&
+				!hasAxis("ital") && !hasAxis("slnt")
+		}
+		fc.missingFaces[key] = missing
+	}
+	return newSynthesized(desc, missing.bold, missing.oblique)
+}
+
+// uprightToLogical converts the result of a vertical shaping so that
+// it may be laid out with horizontal runs : the advances are measured along
+// the horizontal axis and the line bounds are the horizontal ones.
+// See [TextLayoutGotext.IsUpright] for the offsets.
+func uprightToLogical(out *shaping.Output) {
+	for i, g := range out.Glyphs {
+		// the glyph is rotated 90° counterclockwise
+		out.Glyphs[i].XAdvance, out.Glyphs[i].YAdvance = -g.YAdvance, 0
+		out.Glyphs[i].XBearing, out.Glyphs[i].YBearing = -g.YBearing, g.XBearing+g.Width
+		out.Glyphs[i].Width, out.Glyphs[i].Height = -g.Height, -g.Width
+	}
+	out.Direction = di.DirectionLTR
+	out.RecomputeAdvance()
+	if extents, ok := out.Face.FontHExtents(); ok {
+		// use the same rounding as the shaper does for horizontal text
+		scale, upem := float32(out.Size.Ceil()<<6), float32(out.Face.Upem())
+		toFixed := func(v float32) fixed.Int26_6 {
+			return fixed.I(int(math.Round(float64(v*scale/upem)))) >> 6
+		}
+		out.LineBounds = shaping.Bounds{
+			Ascent:  toFixed(extents.Ascender),
+			Descent: toFixed(extents.Descender),
+			Gap:     toFixed(extents.LineGap),
+		}
+	}
+}
+
+// embolden adds [strength] to the advance of the glyphs, except
+// for the zero width ones, like combining marks.
+func embolden(glyphs []shaping.Glyph, strength fixed.Int26_6) {
+	for i, g := range glyphs {
+		if g.XAdvance != 0 {
+			glyphs[i].XAdvance += strength
+		}
+	}
+}
+
+func tagBytes(tag opentype.Tag) [4]byte {
+	return [4]byte{byte(tag >> 24), byte(tag >> 16), byte(tag >> 8), byte(tag)}
+}
+
+// TextLayoutGotext is the layout produced by [FontConfigurationGotext].
+type TextLayoutGotext struct {
+	Style    *TextStyle
+	MaxWidth pr.Float // the width used to wrap the text, or [pr.Inf]
+
+	fonts *FontConfigurationGotext
+
 	text []rune
-	line shaping.Line
+	// the runs of the first line, sorted in visual order
+	line     shaping.Line
+	resumeAt int // the start of the second line, or -1
+
+	justification justification // set by [SetJustification] and [SetCharacterJustification]
+	applied       justification // already added to the glyphs of [line]
+	unjustified   shaping.Line  // the glyphs before justification, shared with the layout cache
+
+	upright []bool // see [IsUpright]
 }
 
 // Text returns a readonly slice of the text in the layout
-func (l layoutGotext) Text() []rune { return l.text }
+func (l *TextLayoutGotext) Text() []rune { return l.text }
+
+// Metrics may return nil when [TextDecorationLine] is empty.
+// Otherwise, it combines the metrics of the faces used in the first line.
+func (l *TextLayoutGotext) Metrics() *LineMetrics {
+	if l.Style.TextDecorationLine == 0 {
+		return nil
+	}
+	var faces []*font.Face
+	for _, run := range l.line {
+		if run.Face != nil {
+			faces = append(faces, run.Face)
+		}
+	}
+	if len(faces) == 0 { // empty line : use the primary face
+		if face := l.fonts.resolveFace(' ', l.Style.FontDescription); face != nil {
+			faces = append(faces, face)
+		}
+	}
+
+	var out *LineMetrics
+	for _, face := range faces {
+		metrics := faceMetrics(face, l.Style.FontDescription.Size)
+		if out == nil {
+			out = &metrics
+			continue
+		}
+		// the decorations must be visible for all the faces
+		out.Ascent = max(out.Ascent, metrics.Ascent)
+		out.UnderlinePosition = min(out.UnderlinePosition, metrics.UnderlinePosition)
+		out.UnderlineThickness = max(out.UnderlineThickness, metrics.UnderlineThickness)
+		out.StrikethroughPosition = max(out.StrikethroughPosition, metrics.StrikethroughPosition)
+		out.StrikethroughThickness = max(out.StrikethroughThickness, metrics.StrikethroughThickness)
+	}
+	if out == nil { // fontmap is broken, use the same defaults as pango
+		out = &LineMetrics{UnderlinePosition: -1, UnderlineThickness: 1, StrikethroughThickness: 1}
+	}
+	return out
+}
+
+// faceMetrics returns the metrics of [face] at the given size, read
+// from the 'post' and 'OS/2' tables.
+// Missing values are replaced by the defaults used by pango.
+func faceMetrics(face *font.Face, size pr.Fl) LineMetrics {
+	scale := size / pr.Fl(face.Upem())
+	var out LineMetrics
+	if extents, ok := face.FontHExtents(); ok {
+		out.Ascent = extents.Ascender * scale
+	}
+	out.UnderlinePosition, out.UnderlineThickness = -1, 1
+	out.StrikethroughPosition, out.StrikethroughThickness = out.Ascent/2, 1
+	if v := face.LineMetric(font.UnderlineThickness); v != 0 {
+		out.UnderlineThickness = v * scale
+	}
+	if v := face.LineMetric(font.UnderlinePosition); v != 0 {
+		out.UnderlinePosition = v * scale
+	}
+	if v := face.LineMetric(font.StrikethroughThickness); v != 0 {
+		out.StrikethroughThickness = v * scale
+	}
+	if v := face.LineMetric(font.StrikethroughPosition); v != 0 {
+		out.StrikethroughPosition = v * scale
+	}
+	return out
+}
 
-// Metrics may return nil when [TextDecorationLine] is empty
-func (layoutGotext) Metrics() *LineMetrics { return nil }
+// justification is the extra spacing of a justified line
+type justification struct {
+	spacing pr.Fl
+	// characters is true for inter-character justification,
+	// false to widen the U+0020 spaces
+	characters bool
+	trailing   bool // space the last character of the line
+}
 
 // Justification returns the current justification
-func (layoutGotext) Justification() pr.Float { return 0 }
+func (l *TextLayoutGotext) Justification() pr.Float { return pr.Float(l.justification.spacing) }
 
 // SetJustification add an additional spacing between words
-// to justify text. Depending on the implementation, it
-// may be ignored until [ApplyJustification] is called.
-func (layoutGotext) SetJustification(spacing pr.Float) {}
+// to justify text. It is ignored until [ApplyJustification] is called.
+func (l *TextLayoutGotext) SetJustification(spacing pr.Float) {
+	l.justification = justification{spacing: pr.Fl(spacing)}
+}
+
+// SetCharacterJustification add an additional spacing after the characters,
+// to justify text. It is ignored until [ApplyJustification] is called.
+func (l *TextLayoutGotext) SetCharacterJustification(spacing pr.Float, trailing bool) {
+	l.justification = justification{spacing: pr.Fl(spacing), characters: true, trailing: trailing}
+}
+
+// ApplyJustification enlarges the advance of the glyphs of the first line,
+// as required by [SetJustification] or [SetCharacterJustification].
+// As in [html/layout], only the U+0020 spaces are considered
+// for word justification.
+func (l *TextLayoutGotext) ApplyJustification() {
+	if l.justification == l.applied {
+		return
+	}
+	if l.unjustified == nil {
+		l.unjustified = l.line
+	}
+	just := l.justification
+	spacing := floatToFixed(just.spacing)
+	length := l.resumeAt
+	if length == -1 {
+		length = len(l.text)
+	}
+	spacings := characterSpacings(l.text, length, just.trailing)
+	// the glyphs are shared with the layout cache : copy them
+	line := make(shaping.Line, len(l.unjustified))
+	for i, run := range l.unjustified {
+		run.Glyphs = append([]shaping.Glyph(nil), run.Glyphs...)
+		if spacing != 0 && just.characters {
+			justifyClusters(run, spacings, spacing)
+		} else if spacing != 0 {
+			for j, g := range run.Glyphs {
+				if g.RuneCount == 1 && g.GlyphCount == 1 && g.ClusterIndex < len(l.text) && l.text[g.ClusterIndex] == ' ' {
+					run.Glyphs[j].XAdvance += spacing
+				}
+			}
+		}
+		run.RecomputeAdvance()
+		line[i] = run
+	}
+	l.line = line
+	l.applied = just
+}
+
+// justifyClusters adds [spacing] after each character of the clusters
+// of [run], as counted by [spacings], that is, on the right of the clusters
+// of a left-to-right run and on their left otherwise.
+func justifyClusters(run shaping.Output, spacings func(start, end int) int, spacing fixed.Int26_6) {
+	rtl := run.Direction.Progression() == di.TowardTopLeft
+	glyphs := run.Glyphs
+	for start := 0; start < len(glyphs); {
+		end := start + 1 // the glyphs of the cluster are glyphs[start:end]
+		for end < len(glyphs) && glyphs[end].ClusterIndex == glyphs[start].ClusterIndex {
+			end++
+		}
+		cluster := glyphs[start].ClusterIndex
+		if n := spacings(cluster, cluster+glyphs[start].RuneCount); n != 0 {
+			extra := fixed.Int26_6(n) * spacing
+			if rtl { // the next glyphs follow the first one
+				glyphs[start].XAdvance += extra
+				glyphs[start].XOffset += extra
+			} else {
+				glyphs[end-1].XAdvance += extra
+			}
+		}
+		start = end
+	}
+}
+
+// SetText lays out [text], using the same style and width.
+// Since layouts are cached and shared, it should only be
+// called on a copy.
+func (l *TextLayoutGotext) SetText(text string) {
+	*l = *l.fonts.wrap([]rune(text), l.Style, l.MaxWidth).Layout.(*TextLayoutGotext)
+}
+
+// GetFirstLine returns the runs of the first line, in visual order,
+// and the index of the second line, or -1.
+// The returned slice must not be modified.
+func (l *TextLayoutGotext) GetFirstLine() (shaping.Line, int) { return l.line, l.resumeAt }
+
+// IsUpright returns true if the rune at [index] is displayed upright in a
+// vertical writing mode. The glyphs of such runes are measured with their
+// vertical advance along the line, but their offsets are kept in
+// the (unrotated) frame of the glyph : they give the position of the glyph
+// origin relative to the pen, on the central baseline, which is
+// halfway between the ascent and the descent of the font.
+func (l *TextLayoutGotext) IsUpright(index int) bool {
+	return index < len(l.upright) && l.upright[index]
+}
+
+// Synthesized returns the transformations to apply to the glyphs
+// when the fonts lack the requested weight or style.
+// They are already taken into account in the glyph advances.
+func (l *TextLayoutGotext) Synthesized() Synthesized {
+	return l.fonts.synthesized(l.Style.FontDescription)
+}
+
+// Coverage implements [EngineLayout]. The .notdef glyph
+// always has index 0.
+func (l *TextLayoutGotext) Coverage() FontCoverage {
+	var out FontCoverage
+	for _, run := range l.line {
+		if run.Face != nil {
+			out.addFont(l.fonts.FaceOrigin(run.Face))
+		}
+		for _, glyph := range run.Glyphs {
+			if run.Face != nil && glyph.GlyphID != 0 {
+				continue
+			}
+			start := min(glyph.ClusterIndex, len(l.text))
+			end := min(start+glyph.RuneCount, len(l.text))
+			for _, r := range l.text[start:end] {
+				out.addMissing(r)
+			}
+		}
+	}
+	return out
+}
 
-func (layoutGotext) ApplyJustification() {}
+func (l *TextLayoutGotext) ClusterBounds() [][2]pr.Fl {
+	length := l.resumeAt
+	if length == -1 {
+		length = len(l.text)
+	}
+	out := make([][2]pr.Fl, length)
+	set := make([]bool, len(out))
+	var x pr.Fl
+	for _, run := range l.line {
+		if run.Face == nil { // not drawn
+			continue
+		}
+		for _, glyph := range run.Glyphs {
+			width := pr.Fl(fixedToFloat(glyph.XAdvance))
+			if index := glyph.ClusterIndex; index < len(out) {
+				if !set[index] {
+					out[index], set[index] = [2]pr.Fl{x, x + width}, true
+				} else { // extend the cluster
+					out[index][0], out[index][1] = min(out[index][0], x), max(out[index][1], x+width)
+				}
+			}
+			x += width
+		}
+	}
+	fillClusterBounds(out, set)
+	return out
+}
 
 func newAspect(style FontStyle, weight uint16, stretch FontStretch) font.Aspect {
 	aspect := font.Aspect{
@@ -191,32 +728,25 @@ func newAspect(style FontStyle, weight uint16, stretch FontStretch) font.Aspect
 	if style == FSyItalic || style == FSyOblique {
 		aspect.Style = font.StyleItalic
 	}
-	switch stretch {
-	case FSeUltraCondensed:
-		aspect.Stretch = font.StretchUltraCondensed
-	case FSeExtraCondensed:
-		aspect.Stretch = font.StretchExtraCondensed
-	case FSeCondensed:
-		aspect.Stretch = font.StretchCondensed
-	case FSeSemiCondensed:
-		aspect.Stretch = font.StretchSemiCondensed
-	case FSeNormal:
-		aspect.Stretch = font.StretchNormal
-	case FSeSemiExpanded:
-		aspect.Stretch = font.StretchSemiExpanded
-	case FSeExpanded:
-		aspect.Stretch = font.StretchExpanded
-	case FSeExtraExpanded:
-		aspect.Stretch = font.StretchExtraExpanded
-	case FSeUltraExpanded:
-		aspect.Stretch = font.StretchUltraExpanded
+	if stretch == 0 { // not specified
+		stretch = FSeNormal
 	}
+	aspect.Stretch = font.Stretch(stretch / 100)
 	return aspect
 }
 
-func newQuery(fd FontDescription) fontscan.Query {
+// newQuery also includes the families registred for 'unicode-range' subsets
+func (fc *FontConfigurationGotext) newQuery(fd FontDescription) fontscan.Query {
+	families := fd.Family
+	if len(fc.rangeFamilies) != 0 {
+		families = nil
+		for _, family := range fd.Family {
+			families = append(families, family)
+			families = append(families, fc.rangeFamilies[font.NormalizeFamily(family)]...)
+		}
+	}
 	return fontscan.Query{
-		Families: fd.Family,
+		Families: families,
 		Aspect:   newAspect(fd.Style, fd.Weight, fd.Stretch),
 	}
 }
@@ -233,9 +763,9 @@ func newFeatures(features []Feature) []shaping.FontFeature {
 }
 
 func (fc *FontConfigurationGotext) resolveFace(r rune, font FontDescription) *font.Face {
-	query := newQuery(font)
+	query := fc.newQuery(font)
 	fc.fm.SetQuery(query)
-	return fc.fm.ResolveFace(r)
+	return fc.instance(fc.fm.ResolveFace(r), font)
 }
 
 // sizeFactor is used to get a better precision
@@ -259,6 +789,9 @@ func (fc *FontConfigurationGotext) shapeRune(r rune, desc FontDescription, featu
 		// float to fixed, the size factor is to get a better precision
 		Size: floatToFixed(desc.Size) * sizeFactor,
 	})
+	if s := fc.synthesized(desc); s.Embolden != 0 {
+		embolden(out.Glyphs, floatToFixed(s.Embolden)*sizeFactor)
+	}
 
 	return out.Glyphs, out.LineBounds
 }
@@ -322,6 +855,24 @@ func (fc *FontConfigurationGotext) wordBoundaries(t []rune) *[2]int {
 	return nil
 }
 
+// LastWordEnd returns the index in [t] of the end of the last word
+// (excluding a word ending [t]), or -1
+func (fc *FontConfigurationGotext) LastWordEnd(t []rune) int {
+	if len(t) < 2 {
+		return -1
+	}
+	fc.unicodeSeg.Init(t)
+	iter := fc.unicodeSeg.WordIterator()
+	out := -1
+	for iter.Next() {
+		word := iter.Word()
+		if end := word.Offset + len(word.Text); end < len(t) {
+			out = end
+		}
+	}
+	return out
+}
+
 // returns the first occurence of c, or -1 if not found
 func index(text []rune, c rune) int {
 	for i, r := range text {
@@ -374,9 +925,10 @@ type textKey struct {
 
 // same as wrap, but may allows break inside words
 func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle, maxWidth pr.Float, allowWordBreak bool) FirstLine {
+	emptyLayout := &TextLayoutGotext{Style: style, MaxWidth: maxWidth, fonts: fc, resumeAt: -1}
 	if len(text) == 0 {
 		return FirstLine{
-			Layout:   layoutGotext{},
+			Layout:   emptyLayout,
 			Length:   0,
 			ResumeAt: -1,
 			Width:    0, Height: 0, Baseline: 0,
@@ -386,7 +938,7 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 
 	key := textKey{string(text), style.key(), maxWidth, allowWordBreak}
 	if l, ok := fc.textLayoutCache[key]; ok {
-		return l
+		return withOwnLayout(l)
 	}
 
 	textWrap, spaceCollapse := style.textWrap(), style.spaceCollapse()
@@ -405,29 +957,75 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 		lang = language.DefaultLanguage()
 	}
 
-	// select the proper fonts
-	fc.fm.SetQuery(newQuery(style.FontDescription))
+	synthesized := fc.synthesized(style.FontDescription)
 
-	// segment the input text, with proper lang and size
-	inputs := fc.inputSeg.Split(shaping.Input{
-		Text:      text,
-		RunEnd:    len(text),
-		Language:  lang,
-		Size:      floatToFixed(style.FontDescription.Size),
-		Direction: di.DirectionLTR, // default, will be overriden
-	}, fc.fm)
+	// select the proper fonts
+	fc.fm.SetQuery(fc.newQuery(style.FontDescription))
+
+	// resolve the bidi embedding levels, and segment each level run
+	// with proper lang and size
+	levels, paragraphLevel := style.Bidi.levels(text)
+	// in vertical text, the segmenter also resolves the orientation
+	// of the glyphs, which are shaped vertically when upright
+	segmentDirection := di.DirectionTTB
+	switch style.Orientation {
+	case OHorizontal, OSideways:
+		segmentDirection = di.DirectionLTR
+	case OUpright:
+		segmentDirection.SetSideways(false)
+	}
+	var (
+		inputs  []shaping.Input
+		upright []bool // nil for horizontal text
+	)
+	for _, run := range levelRuns(levels) {
+		direction := di.DirectionLTR
+		if levels[run[0]]%2 == 1 {
+			direction = di.DirectionRTL
+		}
+		for _, input := range fc.inputSeg.Split(shaping.Input{
+			Text:      text,
+			RunStart:  run[0],
+			RunEnd:    run[1],
+			Language:  lang,
+			Size:      floatToFixed(style.FontDescription.Size),
+			Direction: segmentDirection,
+		}, fc.fm) {
+			if input.Direction.IsVertical() && !input.Direction.IsSideways() {
+				input.Direction = di.DirectionTTB
+				input.Direction.SetSideways(false)
+				if upright == nil {
+					upright = make([]bool, len(text))
+				}
+				for i := input.RunStart; i < input.RunEnd; i++ {
+					upright[i] = true
+				}
+			} else {
+				input.Direction = direction // the segmenter does not support explicit embeddings
+			}
+			inputs = append(inputs, input)
+		}
+	}
 
 	// TODO: lazy iterator
 	outputs := make(shaping.Line, len(inputs))
 	for i, input := range inputs {
 		// the features are comming either from the style,
 		// or registred via CSS @font-face rule
-		defaults := newFeatureSet(fc.fontsFeatures[input.Face.Font])
+		input.Face = fc.instance(input.Face, style.FontDescription)
+		defaults := newFeatureSet(fc.fontsFeatures[fc.parentFont(input.Face.Font)])
 		defaults.merge(style.FontFeatures)
 		input.FontFeatures = newFeatures(defaults.list())
 
 		// shape !
 		output := fc.shaper.Shape(input)
+		if output.Direction.IsVertical() {
+			uprightToLogical(&output)
+		}
+		if synthesized.Embolden != 0 {
+			embolden(output.Glyphs, floatToFixed(synthesized.Embolden))
+			output.RecomputeAdvance()
+		}
 		outputs[i] = output
 	}
 
@@ -442,8 +1040,11 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 
 	// now we can wrap the runs
 	config := shaping.WrapConfig{
-		Direction:   outputs[0].Direction, // overall direction of the text, deduced from the first runes
-		BreakPolicy: shaping.Never,        // mimic the default pango behavior
+		Direction:   di.DirectionLTR,
+		BreakPolicy: shaping.Never, // mimic the default pango behavior
+	}
+	if paragraphLevel == 1 {
+		config.Direction = di.DirectionRTL
 	}
 	if allowWordBreak {
 		config.BreakPolicy = shaping.Always
@@ -454,7 +1055,7 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 
 	if len(line) == 0 {
 		return FirstLine{
-			Layout:   layoutGotext{},
+			Layout:   emptyLayout,
 			Length:   0,
 			ResumeAt: -1,
 			Width:    0, Height: 0, Baseline: 0,
@@ -468,27 +1069,35 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 		resumeAt = -1
 	}
 
-	firstLineRTL := line[0].Direction.Progression() == di.TowardTopLeft
-
-	// sort the line by visual order
-	sort.Slice(line, func(i, j int) bool { return line[i].VisualIndex < line[j].VisualIndex })
-
 	if !fitsOnFirstLine && spaceCollapse {
 		// remove the space runes...
 		text = trimTrailingSpaces(text[:firstLineLength])
 		firstLineLength = len(text)
-		// and the matching glyphs
+		// and the matching glyphs, at the logical end of the line
 		lastRun := &line[len(line)-1]
-		i := len(lastRun.Glyphs) - 1
-		for ; i >= 0; i-- {
-			if lastRun.Glyphs[i].Width != 0 {
-				break
+		if lastRun.Direction.Progression() == di.TowardTopLeft {
+			i := 0
+			for ; i < len(lastRun.Glyphs); i++ {
+				if lastRun.Glyphs[i].Width != 0 {
+					break
+				}
+			}
+			lastRun.Glyphs = lastRun.Glyphs[i:]
+		} else {
+			i := len(lastRun.Glyphs) - 1
+			for ; i >= 0; i-- {
+				if lastRun.Glyphs[i].Width != 0 {
+					break
+				}
 			}
+			lastRun.Glyphs = lastRun.Glyphs[:i+1]
 		}
-		lastRun.Glyphs = lastRun.Glyphs[:i+1]
 		lastRun.RecalculateAll()
 	}
 
+	// sort the line by visual order
+	reorderRuns(line, levels)
+
 	var width, height, top fixed.Int26_6
 	for _, run := range line {
 		width += run.Advance
@@ -509,10 +1118,10 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 	copy(outLine, line)
 
 	out := FirstLine{
-		Layout:       layoutGotext{text: text, line: outLine},
+		Layout:       &TextLayoutGotext{Style: style, MaxWidth: maxWidth, fonts: fc, text: text, line: outLine, resumeAt: resumeAt, upright: upright},
 		Length:       firstLineLength,
 		ResumeAt:     resumeAt,
-		FirstLineRTL: firstLineRTL,
+		FirstLineRTL: paragraphLevel == 1,
 		Width:        fixedToFloat(width),
 		Height:       fixedToFloat(height),
 		Baseline:     fixedToFloat(top),
@@ -520,7 +1129,16 @@ func (fc *FontConfigurationGotext) wrapWordBreak(text []rune, style *TextStyle,
 
 	fc.textLayoutCache[key] = out
 
-	return out
+	return withOwnLayout(out)
+}
+
+// withOwnLayout returns [fl] with a copy of its layout,
+// so that the justification of the returned layout may be changed
+// without altering the cached one.
+func withOwnLayout(fl FirstLine) FirstLine {
+	layout := *fl.Layout.(*TextLayoutGotext)
+	fl.Layout = &layout
+	return fl
 }
 
 // splitFirstLineGotext fit as much text from [text_] as possible in the available width given by [maxWidth].
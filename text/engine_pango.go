@@ -19,6 +19,7 @@ import (
 	"github.com/benoitkugler/webrender/logger"
 	"github.com/benoitkugler/webrender/text/hyphen"
 	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/font/opentype"
 )
 
 func PangoUnitsFromFloat(v pr.Fl) int32 { return int32(v*pango.Scale + 0.5) }
@@ -33,6 +34,8 @@ type FontConfigurationPango struct {
 
 	userFonts    map[FontOrigin]fonts.Face
 	fontsContent map[string][]byte // to be embedded in the target
+
+	synthesizedCache map[string]Synthesized // FontDescription.binary -> synthesis
 }
 
 // NewFontConfigurationPango uses a fontconfig database to create a new
@@ -54,6 +57,32 @@ func (f *FontConfigurationPango) LoadFace(key fonts.FaceID, format fc.FontFormat
 	return fcfonts.DefaultLoadFace(key, format)
 }
 
+// synthesized returns the synthesis applied to the text using [desc],
+// as decided by fontconfig for the primary font.
+func (f *FontConfigurationPango) synthesized(desc FontDescription) Synthesized {
+	if desc.Synthesis == 0 {
+		return Synthesized{}
+	}
+	key := string(desc.binary(nil, true))
+	if s, has := f.synthesizedCache[key]; has {
+		return s
+	}
+	var missingBold, missingOblique bool
+	fontDesc := getFontDescription(desc)
+	if font, ok := pango.LoadFont(f.fontmap, pango.NewContext(f.fontmap), &fontDesc).(*fcfonts.Font); ok {
+		embolden, _ := font.Pattern.GetBool(fc.EMBOLDEN)
+		missingBold = embolden == fc.True
+		mat, _ := font.Pattern.GetMatrix(fc.MATRIX)
+		missingOblique = mat.Xy != 0
+	}
+	if f.synthesizedCache == nil {
+		f.synthesizedCache = make(map[string]Synthesized)
+	}
+	s := newSynthesized(desc, missingBold, missingOblique)
+	f.synthesizedCache[key] = s
+	return s
+}
+
 func (fc *FontConfigurationPango) spaceHeight(style *TextStyle) (height, baseline pr.Float) {
 	layout := newTextLayout(fc, style, nil)
 	layout.SetText(" ")
@@ -187,6 +216,8 @@ func escapeXML(s string) string {
 func (f *FontConfigurationPango) loadOneFont(url pr.NamedString, ruleDescriptors validation.FontFaceDescriptors, urlFetcher utils.UrlFetcher) (string, error) {
 	config := f.fontmap.Config
 
+	var fragmentIndex int
+	url.String, fragmentIndex = splitFontIndex(url.String)
 	if url.Name == "local" {
 		fontName := url.String
 		pattern := fc.NewPattern()
@@ -204,12 +235,13 @@ func (f *FontConfigurationPango) loadOneFont(url pr.NamedString, ruleDescriptors
 		family, _ := matchingPattern.GetString(fc.FULLNAME)
 		postscript, _ := matchingPattern.GetString(fc.POSTSCRIPT_NAME)
 		if fn := strings.ToLower(fontName); fn == strings.ToLower(family) || fn == strings.ToLower(postscript) {
-			filename := matchingPattern.FaceID().File
+			faceID := matchingPattern.FaceID()
 			var err error
-			url.String, err = filepath.Abs(filename)
+			url.String, err = filepath.Abs(faceID.File)
 			if err != nil {
 				return "", fmt.Errorf("failed to load local font %s: %s", fontName, err)
 			}
+			fragmentIndex = int(faceID.Index)
 		} else {
 			return "", fmt.Errorf("failed to load local font %s", fontName)
 		}
@@ -231,15 +263,26 @@ func (f *FontConfigurationPango) loadOneFont(url pr.NamedString, ruleDescriptors
 		return "", fmt.Errorf("failed to load font at %s : unsupported format", fontFilename)
 	}
 
-	if len(faces) != 1 {
-		return "", fmt.Errorf("font collections are not supported (%s)", url.String)
+	var index uint16
+	if len(faces) > 1 {
+		lds, err := opentype.NewLoaders(bytes.NewReader(content))
+		if err != nil {
+			return "", fmt.Errorf("failed to parse font collection at %s : %s", url.String, err)
+		}
+		index, err = collectionIndex(lds, fragmentIndex, ruleDescriptors)
+		if err != nil {
+			return "", fmt.Errorf("failed to load font at %s : %s", url.String, err)
+		}
+	} else if fragmentIndex > 0 {
+		return "", fmt.Errorf("failed to load font at %s : invalid face index %d", url.String, fragmentIndex)
 	}
 
 	if url.Name == "external" {
 		key := FontOrigin{
-			File: fontFilename,
+			File:  fontFilename,
+			Index: index,
 		}
-		f.userFonts[key] = faces[0]
+		f.userFonts[key] = faces[index]
 		f.fontsContent[key.File] = content
 	}
 
@@ -251,14 +294,22 @@ func (f *FontConfigurationPango) loadOneFont(url pr.NamedString, ruleDescriptors
 	if !ok {
 		fontconfigStyle = "roman"
 	}
-	fontconfigWeight, ok := fcWeight[ruleDescriptors.FontWeight]
-	if !ok {
-		fontconfigWeight = "regular"
+	// weight ranges are not forwarded to fontconfig, since pango
+	// does not support the resulting variations: use the weight
+	// of the range closest to 'normal'
+	weight := pr.IntString{Int: int(newWeightRange(ruleDescriptors).clamp(400))}
+	if ruleDescriptors.FontWeightEnd == (pr.IntString{}) {
+		weight = ruleDescriptors.FontWeight
 	}
-	fontconfigStretch, ok := fcStretch[ruleDescriptors.FontStretch]
+	if weight.Int != 0 { // fontconfig only names the multiples of 100
+		weight.Int = min(max((weight.Int+50)/100*100, 100), 900)
+	}
+	fontconfigWeight, ok := fcWeight[weight]
 	if !ok {
-		fontconfigStretch = "normal"
+		fontconfigWeight = "regular"
 	}
+	// as for the weights, use the width of the range closest to 'normal'
+	fontconfigStretch := fcStretch[newStretchRange(ruleDescriptors).clamp(FSeNormal).keyword()]
 
 	xmlConfig := fmt.Sprintf(`<?xml version="1.0"?>
 		<!DOCTYPE fontconfig SYSTEM "fonts.dtd">
@@ -298,12 +349,44 @@ func (f *FontConfigurationPango) loadOneFont(url pr.NamedString, ruleDescriptors
 	if err != nil {
 		return "", fmt.Errorf("failed to load font at %s", url.String)
 	}
+	// the configuration above applies to all the faces of a collection:
+	// only register the selected one
+	selected := fs[:0]
+	for _, pattern := range fs {
+		if pattern.FaceID().Index == index {
+			selected = append(selected, pattern)
+		}
+	}
+	fs = selected
+	if ranges := ruleDescriptors.UnicodeRange; len(ranges) != 0 {
+		for _, pattern := range fs {
+			restrictCharset(pattern, faces[index], ranges)
+		}
+	}
 
 	f.fontmap.Database = append(f.fontmap.Database, fs...)
 	f.fontmap.SetConfig(config, f.fontmap.Database)
 	return fontFilename, nil
 }
 
+// restrictCharset removes the code points outside of [ranges] from the
+// charset of [pattern], which is used by pango to select the fonts.
+// Since the ranges may be far larger than the font coverage, only the
+// code points mapped by [face] are visited.
+func restrictCharset(pattern fc.Pattern, face fonts.Face, ranges validation.UnicodeRanges) {
+	charset, _ := pattern.GetCharset(fc.CHARSET)
+	var restricted fc.Charset
+	if cmap, _ := face.Cmap(); cmap != nil {
+		for iter := cmap.Iter(); iter.Next(); {
+			if r, _ := iter.Char(); ranges.Contains(r) && charset.HasChar(r) {
+				restricted.AddChar(r)
+			}
+		}
+	}
+	pattern.Del(fc.CHARSET)
+	pattern.Add(fc.CHARSET, restricted, true)
+}
+
 // Fontconfig features
 var (
 	fcWeight = map[pr.IntString]string{
@@ -324,16 +407,10 @@ var (
 		"italic":  "italic",
 		"oblique": "oblique",
 	}
-	fcStretch = map[pr.String]string{
-		"normal":          "normal",
-		"ultra-condensed": "ultracondensed",
-		"extra-condensed": "extracondensed",
-		"condensed":       "condensed",
-		"semi-condensed":  "semicondensed",
-		"semi-expanded":   "semiexpanded",
-		"expanded":        "expanded",
-		"extra-expanded":  "extraexpanded",
-		"ultra-expanded":  "ultraexpanded",
+	// indexed by [FontStretch.keyword]
+	fcStretch = [...]string{
+		"ultracondensed", "extracondensed", "condensed", "semicondensed", "normal",
+		"semiexpanded", "expanded", "extraexpanded", "ultraexpanded",
 	}
 )
 
@@ -342,7 +419,7 @@ func getFontDescription(fd FontDescription) pango.FontDescription {
 	fontDesc.SetFamily(strings.Join(fd.Family, ","))
 
 	fontDesc.SetStyle(pango.Style(fd.Style))
-	fontDesc.SetStretch(pango.Stretch(fd.Stretch))
+	fontDesc.SetStretch(pango.Stretch(fd.Stretch.keyword()))
 	fontDesc.SetWeight(pango.Weight(fd.Weight))
 
 	fontDesc.SetAbsoluteSize(PangoUnitsFromFloat(fd.Size))
@@ -405,7 +482,7 @@ func firstLineMetrics(firstLine *pango.LayoutLine, text []rune, layout *TextLayo
 		resumeAt = len(text)
 	}
 
-	width, height := lineSize(firstLine, style.LetterSpacing)
+	width, height := lineSize(firstLine, layout.letterSpacing())
 	baseline := PangoUnitsToFloat(layout.Layout.GetBaseline())
 	return FirstLine{
 		Layout: layout,
@@ -426,7 +503,20 @@ type TextLayoutPango struct {
 
 	Layout pango.Layout
 
-	justificationSpacing pr.Fl
+	justification justification
+
+	synthesized Synthesized
+}
+
+// Synthesized returns the transformations to apply to the glyphs
+// when the fonts lack the requested weight or style.
+// Emboldening is already taken into account in the glyph advances.
+func (p *TextLayoutPango) Synthesized() Synthesized { return p.synthesized }
+
+// letterSpacing includes the additional advance of emboldened glyphs,
+// applied with a Pango letter spacing attribute.
+func (p *TextLayoutPango) letterSpacing() pr.Fl {
+	return p.Style.LetterSpacing + p.synthesized.Embolden
 }
 
 func newTextLayout(fonts FontConfiguration, style *TextStyle, maxWidth pr.MaybeFloat) *TextLayoutPango {
@@ -460,13 +550,20 @@ func (p *TextLayoutPango) Text() []rune { return p.Layout.Text }
 
 func (p *TextLayoutPango) Metrics() *LineMetrics { return p.metrics }
 
-func (p *TextLayoutPango) Justification() pr.Float           { return pr.Float(p.justificationSpacing) }
-func (p *TextLayoutPango) SetJustification(spacing pr.Float) { p.justificationSpacing = pr.Fl(spacing) }
+func (p *TextLayoutPango) Justification() pr.Float { return pr.Float(p.justification.spacing) }
+func (p *TextLayoutPango) SetJustification(spacing pr.Float) {
+	p.justification = justification{spacing: pr.Fl(spacing)}
+}
+
+func (p *TextLayoutPango) SetCharacterJustification(spacing pr.Float, trailing bool) {
+	p.justification = justification{spacing: pr.Fl(spacing), characters: true, trailing: trailing}
+}
 
 func (p *TextLayoutPango) setup(fonts FontConfiguration, style *TextStyle) {
 	p.fonts = fonts
 	p.Style = style
 	fontmap := fonts.(*FontConfigurationPango).fontmap
+	p.synthesized = fonts.(*FontConfigurationPango).synthesized(style.FontDescription)
 	pc := pango.NewContext(fontmap)
 	pc.SetRoundGlyphPositions(false)
 
@@ -479,6 +576,9 @@ func (p *TextLayoutPango) setup(fonts FontConfiguration, style *TextStyle) {
 		lang = pango.DefaultLanguage()
 	}
 	pc.SetLanguage(lang)
+	// pango vertical gravities are not used : in vertical writing modes,
+	// the text is always shaped sideways, as with 'text-orientation: sideways'
+	// (see [Orientation])
 
 	fontDesc := getFontDescription(style.FontDescription)
 	p.Layout = *pango.NewLayout(pc)
@@ -509,6 +609,48 @@ func (p *TextLayoutPango) SetText(text string) { p.setText(text, false) }
 func (p *TextLayoutPango) ApplyJustification() {
 	p.Layout.SetWidth(-1)
 	p.setText(string(p.Layout.Text), true)
+	if p.justification.characters && p.justification.spacing != 0 {
+		p.justifyClusters()
+	}
+}
+
+// justifyClusters widens the clusters of the first line, adding the
+// character justification spacing on their right (left-to-right runs)
+// or on their left.
+func (p *TextLayoutPango) justifyClusters() {
+	line, _ := p.GetFirstLine()
+	if line == nil {
+		return
+	}
+	spacings := characterSpacings(p.Layout.Text, line.StartIndex+line.Length, p.justification.trailing)
+	spacing := PangoUnitsFromFloat(p.justification.spacing)
+	for run := line.Runs; run != nil; run = run.Next {
+		item, glyphs := run.Data.Item, run.Data.Glyphs
+		rtl := item.Analysis.Level%2 == 1
+		for start := 0; start < len(glyphs.Glyphs); {
+			end := start + 1 // the glyphs of the cluster are glyphs[start:end]
+			for end < len(glyphs.Glyphs) && glyphs.LogClusters[end] == glyphs.LogClusters[start] {
+				end++
+			}
+			// the cluster spans up to the next cluster in logical order
+			clusterStart, clusterEnd := glyphs.LogClusters[start], item.Length
+			for _, c := range glyphs.LogClusters {
+				if c > clusterStart && c < clusterEnd {
+					clusterEnd = c
+				}
+			}
+			if n := spacings(item.Offset+clusterStart, item.Offset+clusterEnd); n != 0 {
+				extra := pango.Unit(int32(n) * spacing)
+				if rtl { // the next glyphs follow the first one
+					glyphs.Glyphs[start].Geometry.Width += extra
+					glyphs.Glyphs[start].Geometry.XOffset += extra
+				} else {
+					glyphs.Glyphs[end-1].Geometry.Width += extra
+				}
+			}
+			start = end
+		}
+	}
 }
 
 func (p *TextLayoutPango) setText(text string, justify bool) {
@@ -523,10 +665,12 @@ func (p *TextLayoutPango) setText(text string, justify bool) {
 	if justify {
 		// Justification is needed when drawing text but is useless during
 		// layout, when it can be ignored.
-		wordSpacing += p.justificationSpacing
+		if !p.justification.characters {
+			wordSpacing += p.justification.spacing
+		}
 	}
 
-	letterSpacing := p.Style.LetterSpacing
+	letterSpacing := p.letterSpacing()
 
 	wordBreaking := p.Style.OverflowWrap == OAnywhere || p.Style.OverflowWrap == OBreakWord
 
@@ -587,7 +731,7 @@ func (p *TextLayoutPango) setTabs() {
 		layout := newTextLayout(p.fonts, p.Style, nil)
 		layout.SetText(strings.Repeat(" ", width))
 		line, _ := layout.GetFirstLine()
-		widthTmp, _ := lineSize(line, p.Style.LetterSpacing)
+		widthTmp, _ := lineSize(line, p.letterSpacing())
 		width = int(widthTmp + 0.5)
 	}
 	// 0 is not handled correctly by Pango
@@ -610,6 +754,61 @@ func (p *TextLayoutPango) GetFirstLine() (*pango.LayoutLine, int) {
 	return firstLine, index
 }
 
+// Coverage implements [EngineLayout]. The characters not supported
+// are either shaped to the .notdef glyph, or, without font,
+// to special glyph values storing the rune.
+func (p *TextLayoutPango) Coverage() FontCoverage {
+	var out FontCoverage
+	line, _ := p.GetFirstLine()
+	if line == nil {
+		return out
+	}
+	for run := line.Runs; run != nil; run = run.Next {
+		if font, ok := run.Data.Item.Analysis.Font.(*fcfonts.Font); ok {
+			out.addFont(FontOrigin(font.FaceID()))
+		}
+		glyphs := run.Data.Glyphs
+		for i, glyph := range glyphs.Glyphs {
+			if glyph.Glyph == pango.GLYPH_EMPTY {
+				continue
+			} else if glyph.Glyph&pango.GLYPH_UNKNOWN_FLAG != 0 {
+				out.addMissing(rune(glyph.Glyph &^ pango.GLYPH_UNKNOWN_FLAG))
+			} else if glyph.Glyph == 0 {
+				if index := run.Data.Item.Offset + glyphs.LogClusters[i]; index < len(p.Layout.Text) {
+					out.addMissing(p.Layout.Text[index])
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (p *TextLayoutPango) ClusterBounds() [][2]pr.Fl {
+	line, _ := p.GetFirstLine()
+	if line == nil {
+		return nil
+	}
+	out := make([][2]pr.Fl, line.StartIndex+line.Length)
+	set := make([]bool, len(out))
+	var x pr.Fl
+	for run := line.Runs; run != nil; run = run.Next {
+		glyphs := run.Data.Glyphs
+		for i, glyph := range glyphs.Glyphs {
+			width := PangoUnitsToFloat(glyph.Geometry.Width)
+			if index := run.Data.Item.Offset + glyphs.LogClusters[i]; index < len(out) {
+				if !set[index] {
+					out[index], set[index] = [2]pr.Fl{x, x + width}, true
+				} else { // extend the cluster
+					out[index][0], out[index][1] = min(out[index][0], x), max(out[index][1], x+width)
+				}
+			}
+			x += width
+		}
+	}
+	fillClusterBounds(out, set)
+	return out
+}
+
 // lineSize gets the logical width and height of the given `line`.
 // [letterSpacing] is added, a value of 0 has no impact
 func lineSize(line *pango.LayoutLine, letterSpacing pr.Fl) (pr.Fl, pr.Fl) {
@@ -663,7 +862,7 @@ func (fc *FontConfigurationPango) splitFirstLine(hyphenCache map[HyphenDictKey]h
 	}
 	maxWidthV := pr.Fl(maxWidth.V())
 
-	firstLineWidth, _ := lineSize(firstLine, style.LetterSpacing)
+	firstLineWidth, _ := lineSize(firstLine, layout.letterSpacing())
 
 	if resumeIndex == -1 && firstLineWidth <= maxWidthV {
 		// The first line fits in the available width
@@ -749,7 +948,7 @@ func (fc *FontConfigurationPango) splitFirstLine(hyphenCache map[HyphenDictKey]h
 			nextWord = string(secondLineText[startWord:stopWord])
 			if stopWord-startWord >= limit.Total {
 				// This word is long enough
-				firstLineWidth, _ = lineSize(firstLine, style.LetterSpacing)
+				firstLineWidth, _ = lineSize(firstLine, layout.letterSpacing())
 				space := maxWidthV - firstLineWidth
 				zone := style.HyphenateLimitZone
 				limitZone := zone.Limit
@@ -803,7 +1002,7 @@ func (fc *FontConfigurationPango) splitFirstLine(hyphenCache map[HyphenDictKey]h
 			hyphenatedFirstLineText = (newFirstLineText + hyphenateCharacter)
 			newLayout := createLayout(hyphenatedFirstLineText, style, fc, maxWidth)
 			newFirstLine, newIndex := newLayout.GetFirstLine()
-			newFirstLineWidth, _ := lineSize(newFirstLine, style.LetterSpacing)
+			newFirstLineWidth, _ := lineSize(newFirstLine, newLayout.letterSpacing())
 			newSpace := maxWidthV - newFirstLineWidth
 			hyphenated = newIndex == -1 && (newSpace >= 0 || firstWordPart == dictionaryIterations[len(dictionaryIterations)-1])
 			if hyphenated {
@@ -841,7 +1040,7 @@ func (fc *FontConfigurationPango) splitFirstLine(hyphenCache map[HyphenDictKey]h
 
 	// Step 5: Try to break word if it's too long for the line
 	overflowWrap, wordBreak := style.OverflowWrap, style.WordBreak
-	firstLineWidth, _ = lineSize(firstLine, style.LetterSpacing)
+	firstLineWidth, _ = lineSize(firstLine, layout.letterSpacing())
 	space := maxWidthV - firstLineWidth
 	// If we can break words and the first line is too long
 	canBreak := wordBreak == WBBreakAll ||
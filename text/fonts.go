@@ -1,10 +1,16 @@
 package text
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	pr "github.com/benoitkugler/webrender/css/properties"
 	"github.com/benoitkugler/webrender/css/validation"
 	"github.com/benoitkugler/webrender/text/hyphen"
 	"github.com/benoitkugler/webrender/utils"
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/font/opentype"
 )
 
 // FontOrigin is a reference to a binary font file, either
@@ -57,3 +63,147 @@ type FontConfiguration interface {
 	// next call to runeProps
 	// runeProps([]rune) []runeProp
 }
+
+// splitFontIndex removes the fragment selecting a face
+// in a font collection from [url], as in "fonts.ttc#2".
+// It returns -1 if [url] has no such fragment.
+func splitFontIndex(url string) (string, int) {
+	i := strings.LastIndexByte(url, '#')
+	if i == -1 {
+		return url, -1
+	}
+	index, err := strconv.ParseUint(url[i+1:], 10, 16)
+	if err != nil {
+		return url, -1
+	}
+	return url[:i], int(index)
+}
+
+// collectionIndex returns the index of the face to load in the font file
+// parsed as [lds]. For collections, the face is given by [fragmentIndex],
+// if it is not -1, or is the one best matching the family and aspect [descriptors].
+func collectionIndex(lds []*opentype.Loader, fragmentIndex int, descriptors validation.FontFaceDescriptors) (uint16, error) {
+	if fragmentIndex != -1 {
+		if fragmentIndex >= len(lds) {
+			return 0, fmt.Errorf("invalid face index %d (%d faces)", fragmentIndex, len(lds))
+		}
+		return uint16(fragmentIndex), nil
+	}
+	if len(lds) == 1 {
+		return 0, nil
+	}
+
+	target := newAspect(newFontStyle(descriptors.FontStyle), newWeightRange(descriptors).clamp(uint16(font.WeightNormal)), newStretchRange(descriptors).clamp(FSeNormal))
+	score := func(desc font.Description) float32 {
+		var out float32
+		if strings.EqualFold(desc.Family, string(descriptors.FontFamily)) {
+			out += 10_000
+		}
+		if desc.Aspect.Style == target.Style {
+			out += 1_000
+		}
+		out -= abs(float32(desc.Aspect.Weight - target.Weight))
+		out -= 100 * abs(float32(desc.Aspect.Stretch-target.Stretch))
+		return out
+	}
+
+	var (
+		best      int
+		bestScore float32
+	)
+	for i, ld := range lds {
+		desc, _ := font.Describe(ld, nil)
+		if s := score(desc); i == 0 || s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	return uint16(best), nil
+}
+
+// weightRange is the range of weights supported by a font face,
+// as specified by the 'font-weight' descriptor of a @font-face rule.
+// It is empty (zero) if the descriptor is missing.
+type weightRange [2]uint16
+
+func newWeightRange(descriptors validation.FontFaceDescriptors) weightRange {
+	start := newFontWeight(descriptors.FontWeight)
+	if descriptors.FontWeightEnd == (pr.IntString{}) {
+		return weightRange{start, start}
+	}
+	end := newFontWeight(descriptors.FontWeightEnd)
+	if end < start { // reversed ranges are swapped
+		start, end = end, start
+	}
+	return weightRange{start, end}
+}
+
+// clamp returns the weight of the range closest to [weight].
+// An empty range returns [weight] unchanged.
+func (wr weightRange) clamp(weight uint16) uint16 {
+	if wr == (weightRange{}) {
+		return weight
+	}
+	return min(max(weight, wr[0]), wr[1])
+}
+
+// stretchRange is the range of widths supported by a font face,
+// as specified by the 'font-stretch' descriptor of a @font-face rule.
+// It is empty (zero) if the descriptor is missing.
+type stretchRange [2]FontStretch
+
+func newStretchRange(descriptors validation.FontFaceDescriptors) stretchRange {
+	start := newFontStretch(descriptors.FontStretch)
+	if descriptors.FontStretchEnd == 0 {
+		return stretchRange{start, start}
+	}
+	end := newFontStretch(descriptors.FontStretchEnd)
+	if end < start { // reversed ranges are swapped
+		start, end = end, start
+	}
+	return stretchRange{start, end}
+}
+
+// clamp returns the width of the range closest to [stretch].
+// An empty range returns [stretch] unchanged.
+func (sr stretchRange) clamp(stretch FontStretch) FontStretch {
+	if sr == (stretchRange{}) {
+		return stretch
+	}
+	return min(max(stretch, sr[0]), sr[1])
+}
+
+// Synthesized describes how the glyphs of a font are transformed
+// to mimic the bold or italic face it lacks.
+// The zero value means no synthesis.
+type Synthesized struct {
+	// Embolden is the width of the stroke added to the glyph outlines,
+	// in pixels. It is also added to the advance of each glyph.
+	Embolden pr.Fl
+
+	// Skew is the horizontal shear applied to the glyph outlines.
+	Skew pr.Fl
+}
+
+// syntheticSkew is tan(14°), the default angle of 'font-style: oblique'.
+const syntheticSkew = 0.25
+
+// newSynthesized returns the synthesis to apply to a font whose weight or style
+// do not match [desc], as allowed by 'font-synthesis'.
+func newSynthesized(desc FontDescription, missingBold, missingOblique bool) Synthesized {
+	var out Synthesized
+	if missingBold && desc.Synthesis&SynthesisWeight != 0 {
+		// the strength used by FreeType
+		out.Embolden = desc.Size / 24
+	}
+	if missingOblique && desc.Synthesis&SynthesisStyle != 0 {
+		out.Skew = syntheticSkew
+	}
+	return out
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -2,8 +2,11 @@ package text
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -11,6 +14,7 @@ import (
 
 	"github.com/benoitkugler/textlayout/fonts"
 	"github.com/benoitkugler/textprocessing/fontconfig"
+	"github.com/benoitkugler/textprocessing/pango/fcfonts"
 	"github.com/benoitkugler/webrender/css/properties"
 	pr "github.com/benoitkugler/webrender/css/properties"
 	"github.com/benoitkugler/webrender/css/validation"
@@ -116,7 +120,7 @@ func TestAddFontFaceAspect(t *testing.T) {
 		// provide user metadata
 		FontStyle:   "italic",
 		FontWeight:  pr.IntString{String: "bold"},
-		FontStretch: "condensed",
+		FontStretch: 75,
 	}
 
 	_ = fcG.AddFontFace(desc, utils.DefaultUrlFetcher)
@@ -266,3 +270,327 @@ func BenchmarkMetrics(b *testing.B) {
 		}
 	})
 }
+
+// newFontmaps returns font maps isolated from the ones shared by the tests,
+// so that the fonts added do not leak into other tests.
+func newFontmaps(t *testing.T) (*fcfonts.FontMap, *fontscan.FontMap) {
+	database := append(fontconfig.Fontset(nil), fontmapPango.Database...)
+	fmP := fcfonts.NewFontMap(fontconfig.Standard.Copy(), database)
+
+	fmG := fontscan.NewFontMap(log.New(io.Discard, "", 0))
+	if err := fmG.UseSystemFonts("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	return fmP, fmG
+}
+
+// buildCollection returns a font collection made of the given font files
+func buildCollection(t *testing.T, files ...string) []byte {
+	var fonts [][]byte
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Skip(err)
+		}
+		fonts = append(fonts, content)
+	}
+	header := 12 + 4*len(fonts)
+	out := binary.BigEndian.AppendUint32(nil, 0x74746366) // ttcf
+	out = binary.BigEndian.AppendUint32(out, 0x00010000)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(fonts)))
+	offset := header
+	for _, content := range fonts {
+		out = binary.BigEndian.AppendUint32(out, uint32(offset))
+		offset += len(content)
+	}
+	for _, content := range fonts {
+		// table offsets are relative to the start of the collection
+		start := len(out)
+		out = append(out, content...)
+		numTables := int(binary.BigEndian.Uint16(content[4:]))
+		for i := 0; i < numTables; i++ {
+			record := out[start+12+16*i+8:]
+			binary.BigEndian.PutUint32(record, binary.BigEndian.Uint32(record)+uint32(start))
+		}
+	}
+	return out
+}
+
+func TestAddFontFaceCollection(t *testing.T) {
+	collection := buildCollection(t, "../resources_test/AHEM____.TTF",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf", "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf")
+	file := filepath.Join(t.TempDir(), "collection.ttc")
+	if err := os.WriteFile(file, collection, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	url, err := utils.PathToURL(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		src, family string
+		weight      int
+		index       uint16
+	}{
+		{url + "#1", "test-fragment", 400, 1},
+		{url + "#0", "test-fragment-0", 400, 0},
+		{url, "Ahem", 400, 0},        // selected by family
+		{url, "dejavu sans", 400, 1}, // selected by family
+		{url, "DejaVu Sans", 700, 2}, // selected by family and weight
+	} {
+		desc := validation.FontFaceDescriptors{
+			Src:        []properties.NamedString{{Name: "external", String: test.src}},
+			FontFamily: pr.String(test.family),
+			FontWeight: pr.IntString{Int: test.weight},
+		}
+
+		fmP, fmG := newFontmaps(t)
+
+		// Pango
+		fcP := NewFontConfigurationPango(fmP)
+		filename := fcP.AddFontFace(desc, utils.DefaultUrlFetcher)
+		tu.AssertEqual(t, filename, url)
+		_, err = fcP.LoadFace(fonts.FaceID{File: filename, Index: test.index}, fontconfig.TrueType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, has := fcP.userFonts[FontOrigin{File: filename, Index: test.index}]; !has {
+			t.Fatalf("face %d not registered", test.index)
+		}
+
+		// Gotext
+		fcG := NewFontConfigurationGotext(fmG)
+		filename = fcG.AddFontFace(desc, utils.DefaultUrlFetcher)
+		tu.AssertEqual(t, filename, url)
+		face := fcG.resolveFace('a', FontDescription{Family: []string{test.family}, Weight: uint16(test.weight)})
+		tu.AssertEqual(t, face != nil, true)
+		origin := fcG.FaceOrigin(face)
+		tu.AssertEqual(t, origin, FontOrigin{File: url, Index: test.index})
+		if !bytes.Equal(collection, fcG.FontContent(origin)) {
+			t.Fatal("expected the whole collection")
+		}
+	}
+
+	// invalid index
+	desc := validation.FontFaceDescriptors{
+		Src:        []properties.NamedString{{Name: "external", String: url + "#3"}},
+		FontFamily: "invalid",
+	}
+	fmP, fmG := newFontmaps(t)
+	tu.AssertEqual(t, NewFontConfigurationGotext(fmG).AddFontFace(desc, utils.DefaultUrlFetcher), "")
+	tu.AssertEqual(t, NewFontConfigurationPango(fmP).AddFontFace(desc, utils.DefaultUrlFetcher), "")
+}
+
+func TestSplitFontIndex(t *testing.T) {
+	for _, test := range []struct {
+		url, expURL string
+		expIndex    int
+	}{
+		{"file:///fonts/font.ttc#2", "file:///fonts/font.ttc", 2},
+		{"file:///fonts/font.ttc", "file:///fonts/font.ttc", -1},
+		{"file:///fonts/font.svg#id", "file:///fonts/font.svg#id", -1},
+	} {
+		url, index := splitFontIndex(test.url)
+		tu.AssertEqual(t, url, test.expURL)
+		tu.AssertEqual(t, index, test.expIndex)
+	}
+}
+
+func TestAddFontFaceUnicodeRange(t *testing.T) {
+	const (
+		regular = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+		bold    = "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf"
+	)
+	var urls [2]string
+	for i, file := range [2]string{regular, bold} {
+		if _, err := os.Stat(file); err != nil {
+			t.Skip(err)
+		}
+		var err error
+		urls[i], err = utils.PathToURL(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// two faces with the same family and aspect, but disjoint ranges
+	descs := [2]validation.FontFaceDescriptors{
+		{
+			Src:          []properties.NamedString{{Name: "external", String: urls[0]}},
+			FontFamily:   "subset",
+			UnicodeRange: validation.UnicodeRanges{{Start: 0, End: 'm'}},
+		},
+		{
+			Src:        []properties.NamedString{{Name: "external", String: urls[1]}},
+			FontFamily: "subset",
+			// up to the end of the code space, far larger than the font coverage
+			UnicodeRange: validation.UnicodeRanges{{Start: 'n', End: 0x10FFFF}},
+		},
+	}
+	style := &TextStyle{FontDescription: FontDescription{Family: []string{"subset"}, Weight: 400, Stretch: FSeNormal, Size: 12}}
+	fmP, fmG := newFontmaps(t)
+
+	// Pango
+	fcP := NewFontConfigurationPango(fmP)
+	for _, desc := range descs {
+		fcP.AddFontFace(desc, utils.DefaultUrlFetcher)
+	}
+	line, _ := wrapPango(fcP, "amnz", style, nil).Layout.(*TextLayoutPango).GetFirstLine()
+	var files []string
+	for run := line.Runs; run != nil; run = run.Next {
+		files = append(files, run.Data.Item.Analysis.Font.(*fcfonts.Font).Pattern.FaceID().File)
+	}
+	tu.AssertEqual(t, files, []string{urls[0], urls[1]})
+
+	// Gotext
+	fcG := NewFontConfigurationGotext(fmG)
+	for _, desc := range descs {
+		fcG.AddFontFace(desc, utils.DefaultUrlFetcher)
+	}
+	files = nil
+	for _, run := range fcG.wrap([]rune("amnz"), style, pr.Inf).Layout.(*TextLayoutGotext).line {
+		files = append(files, fcG.FaceOrigin(run.Face).File)
+	}
+	tu.AssertEqual(t, files, []string{urls[0], urls[1]})
+}
+
+func TestVariableFontGotext(t *testing.T) {
+	url, err := utils.PathToURL("../resources_test/Selawik-VF-Subset.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fm := newFontmaps(t)
+	fc := NewFontConfigurationGotext(fm)
+	fc.AddFontFace(validation.FontFaceDescriptors{
+		Src:           []properties.NamedString{{Name: "external", String: url}},
+		FontFamily:    "variable",
+		FontWeight:    properties.IntString{Int: 300},
+		FontWeightEnd: properties.IntString{Int: 900},
+	}, utils.DefaultUrlFetcher)
+
+	layout := func(weight uint16, settings ...Variation) (pr.Float, string) {
+		style := &TextStyle{FontDescription: FontDescription{
+			Family: []string{"variable"}, Weight: weight, Stretch: FSeNormal, Size: 12,
+			VariationSettings: settings,
+		}}
+		line := fc.wrap([]rune("AAA"), style, pr.Inf)
+		face := line.Layout.(*TextLayoutGotext).line[0].Face
+		tu.AssertEqual(t, fc.FaceOrigin(face).File, url)
+		return line.Width, FormatVariations(fc.FaceVariations(face))
+	}
+
+	regularWidth, variations := layout(400)
+	tu.AssertEqual(t, variations, "")
+	boldWidth, variations := layout(700)
+	tu.AssertEqual(t, variations, "wght=700")
+	tu.AssertEqual(t, boldWidth > regularWidth, true)
+	// the font only supports weights up to 700
+	clampedWidth, variations := layout(900)
+	tu.AssertEqual(t, variations, "wght=700")
+	tu.AssertEqual(t, clampedWidth, boldWidth)
+	// explicit settings override the weight
+	_, variations = layout(700, Variation{Tag: [4]byte{'w', 'g', 'h', 't'}, Value: 500})
+	tu.AssertEqual(t, variations, "wght=500")
+	// instances are shared between sizes
+	faceAt := func(size pr.Fl) *font.Face {
+		style := &TextStyle{FontDescription: FontDescription{Family: []string{"variable"}, Weight: 600, Size: size}}
+		return fc.wrap([]rune("A"), style, pr.Inf).Layout.(*TextLayoutGotext).line[0].Face
+	}
+	tu.AssertEqual(t, faceAt(12) == faceAt(10), true)
+
+	// intermediate weights are instantiated
+	mediumWidth, variations := layout(450)
+	tu.AssertEqual(t, variations, "wght=450")
+	tu.AssertEqual(t, regularWidth < mediumWidth && mediumWidth < boldWidth, true)
+
+	// ranges are not restricted to the multiples of 100
+	fc.AddFontFace(validation.FontFaceDescriptors{
+		Src:            []properties.NamedString{{Name: "external", String: url}},
+		FontFamily:     "variable-range",
+		FontWeight:     properties.IntString{Int: 350},
+		FontWeightEnd:  properties.IntString{Int: 650},
+		FontStretch:    75,
+		FontStretchEnd: 125,
+	}, utils.DefaultUrlFetcher)
+	for _, test := range []struct {
+		weight   uint16
+		expected string
+	}{
+		{300, "wght=350"},
+		{550, "wght=550"},
+		{900, "wght=650"},
+	} {
+		style := &TextStyle{FontDescription: FontDescription{Family: []string{"variable-range"}, Weight: test.weight, Stretch: 80, Size: 12}}
+		face := fc.wrap([]rune("A"), style, pr.Inf).Layout.(*TextLayoutGotext).line[0].Face
+		tu.AssertEqual(t, FormatVariations(fc.FaceVariations(face)), test.expected)
+	}
+}
+
+func TestStretchRange(t *testing.T) {
+	sr := newStretchRange(validation.FontFaceDescriptors{FontStretch: 125, FontStretchEnd: 75})
+	tu.AssertEqual(t, sr, stretchRange{75, 125})
+	tu.AssertEqual(t, sr.clamp(FSeUltraCondensed), FontStretch(75))
+	tu.AssertEqual(t, sr.clamp(110), FontStretch(110))
+	tu.AssertEqual(t, stretchRange{}.clamp(110), FontStretch(110))
+
+	tu.AssertEqual(t, FSeCondensed.keyword(), 2)
+	tu.AssertEqual(t, FontStretch(80).keyword(), 2)
+	tu.AssertEqual(t, FontStretch(95).keyword(), 4)
+	tu.AssertEqual(t, FontStretch(300).keyword(), 8)
+
+	tu.AssertEqual(t, newAspect(FSyNormal, 400, 80).Stretch, font.Stretch(0.8))
+}
+
+func TestFontSynthesis(t *testing.T) {
+	url, err := utils.PathToURL("../resources_test/weasyprint.otf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmP, fmG := newFontmaps(t)
+	for _, fc := range []FontConfiguration{NewFontConfigurationPango(fmP), NewFontConfigurationGotext(fmG)} {
+		fc.AddFontFace(validation.FontFaceDescriptors{
+			Src:        []properties.NamedString{{Name: "external", String: url}},
+			FontFamily: "weasyprint",
+		}, utils.DefaultUrlFetcher)
+
+		layout := func(family string, weight uint16, style FontStyle, synthesis FontSynthesis) (pr.Float, Synthesized) {
+			ts := &TextStyle{FontDescription: FontDescription{
+				Family: []string{family}, Weight: weight, Style: style, Stretch: FSeNormal, Size: 24,
+				Synthesis: synthesis,
+			}}
+			line := fc.splitFirstLine(nil, []rune("abc"), ts, nil, false, true)
+			switch layout := line.Layout.(type) {
+			case *TextLayoutPango:
+				return line.Width, layout.Synthesized()
+			case *TextLayoutGotext:
+				return line.Width, layout.Synthesized()
+			}
+			return 0, Synthesized{}
+		}
+		all := SynthesisWeight | SynthesisStyle
+
+		regular, s := layout("weasyprint", 400, FSyNormal, all)
+		tu.AssertEqual(t, s, Synthesized{})
+		bold, s := layout("weasyprint", 700, FSyNormal, all)
+		tu.AssertEqual(t, s, Synthesized{Embolden: 1})
+		tu.AssertEqual(t, pr.Abs(bold-regular-3) < 0.01, true) // the advances are enlarged
+		boldNone, s := layout("weasyprint", 700, FSyNormal, SynthesisStyle)
+		tu.AssertEqual(t, s, Synthesized{})
+		tu.AssertEqual(t, boldNone, regular)
+
+		// DejaVu Sans has a bold face, but no italic one
+		_, s = layout("DejaVu Sans", 700, FSyItalic, all)
+		tu.AssertEqual(t, s, Synthesized{Skew: syntheticSkew})
+		_, s = layout("DejaVu Sans", 700, FSyItalic, SynthesisWeight)
+		tu.AssertEqual(t, s, Synthesized{})
+	}
+}
+
+func TestParseVariations(t *testing.T) {
+	vs := []Variation{{Tag: [4]byte{'w', 'g', 'h', 't'}, Value: 650}, {Tag: [4]byte{'w', 'd', 't', 'h'}, Value: 87.5}}
+	s := FormatVariations(vs)
+	tu.AssertEqual(t, s, "wght=650,wdth=87.5")
+	tu.AssertEqual(t, ParseVariations(s), vs)
+	tu.AssertEqual(t, ParseVariations(""), []Variation(nil))
+	tu.AssertEqual(t, ParseVariations("wght=a,slnt=-14,x=1"), []Variation{{Tag: [4]byte{'s', 'l', 'n', 't'}, Value: -14}})
+}
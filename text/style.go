@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 
 	pr "github.com/benoitkugler/webrender/css/properties"
@@ -40,13 +41,15 @@ type FontDescription struct {
 	Weight            uint16
 	Size              pr.Fl
 	VariationSettings []Variation // empty for 'normal'
+	Synthesis         FontSynthesis
 }
 
 func (fd FontDescription) binary(dst []byte, includeSize bool) []byte {
 	for _, f := range fd.Family {
 		dst = append(dst, f...)
 	}
-	dst = append(dst, byte(fd.Style), byte(fd.Stretch))
+	dst = append(dst, byte(fd.Style))
+	dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(float32(fd.Stretch)))
 	dst = binary.BigEndian.AppendUint16(dst, fd.Weight)
 	if includeSize {
 		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(fd.Size))
@@ -55,6 +58,7 @@ func (fd FontDescription) binary(dst []byte, includeSize bool) []byte {
 		dst = append(dst, v.Tag[:]...)
 		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(v.Value))
 	}
+	dst = append(dst, byte(fd.Synthesis))
 	return dst
 }
 
@@ -103,6 +107,10 @@ type TextStyle struct {
 	WordSpacing   pr.Fl
 	LetterSpacing pr.Fl // 0 for 'normal'
 	TabSize       TabSize
+
+	Bidi BidiContext
+
+	Orientation Orientation
 }
 
 // If ignoreSpacing is true, 'word-spacing' and 'letter-spacing' are
@@ -116,6 +124,7 @@ func NewTextStyle(style pr.StyleAccessor, ignoreSpacing bool) *TextStyle {
 	out.FontDescription.Stretch = newFontStretch(style.GetFontStretch())
 	out.FontDescription.Size = pr.Fl(style.GetFontSize().Value)
 	out.FontDescription.VariationSettings = newFontVariationSettings(style.GetFontVariationSettings())
+	out.FontDescription.Synthesis = newFontSynthesis(style.GetFontSynthesis())
 
 	out.FontLanguageOverride = newFontLanguageOverrride(style.GetFontLanguageOverride())
 	out.Lang = style.GetLang().S
@@ -142,6 +151,10 @@ func NewTextStyle(style pr.StyleAccessor, ignoreSpacing bool) *TextStyle {
 
 	out.FontFeatures = getFontFeatures(style)
 
+	out.Bidi = newBidiContext(style)
+
+	out.Orientation = newOrientation(style.GetWritingMode(), style.GetTextOrientation())
+
 	return &out
 }
 
@@ -166,6 +179,10 @@ type styleKey struct {
 	WordSpacing   pr.Fl
 	LetterSpacing pr.Fl // 0 for 'normal'
 	TabSize       TabSize
+
+	Bidi BidiContext
+
+	Orientation Orientation
 }
 
 func (ts *TextStyle) key() styleKey {
@@ -185,6 +202,8 @@ func (ts *TextStyle) key() styleKey {
 		ts.WordSpacing,
 		ts.LetterSpacing,
 		ts.TabSize,
+		ts.Bidi,
+		ts.Orientation,
 	}
 }
 
@@ -492,6 +511,35 @@ func newHyphens(h pr.String) Hyphens {
 	}
 }
 
+// Orientation is the orientation of the glyphs in a vertical writing mode.
+// Lines are always shaped and measured as horizontal lines :
+// upright glyphs use their vertical advance, and must be drawn
+// rotated back in the logical frame of the line.
+// Only the go-text engine shapes upright glyphs : the pango engine
+// always lays out the text sideways.
+type Orientation uint8
+
+const (
+	OHorizontal Orientation = iota // horizontal writing mode
+	OMixed                         // upright or sideways, depending on the script
+	OUpright                       // all glyphs are upright
+	OSideways                      // all glyphs are rotated, like horizontal text
+)
+
+func newOrientation(writingMode, textOrientation pr.String) Orientation {
+	if writingMode == "" || writingMode == "horizontal-tb" {
+		return OHorizontal
+	}
+	switch textOrientation {
+	case "upright":
+		return OUpright
+	case "sideways":
+		return OSideways
+	default:
+		return OMixed
+	}
+}
+
 type HyphenateZone struct {
 	Limit        pr.Fl
 	IsPercentage bool
@@ -534,43 +582,60 @@ func newFontWeight(weight pr.IntString) uint16 {
 	return uint16(weight.Int)
 }
 
-type FontStretch uint8
+// FontStretch is the width of a font face, as a
+// percentage of the normal width.
+type FontStretch pr.Fl
 
 const (
-	FSeUltraCondensed FontStretch = iota // ultra condensed width
-	FSeExtraCondensed                    // extra condensed width
-	FSeCondensed                         // condensed width
-	FSeSemiCondensed                     // semi condensed width
-	FSeNormal                            // the normal width
-	FSeSemiExpanded                      // semi expanded width
-	FSeExpanded                          // expanded width
-	FSeExtraExpanded                     // extra expanded width
-	FSeUltraExpanded                     // ultra expanded width
+	FSeUltraCondensed FontStretch = 50    // ultra condensed width
+	FSeExtraCondensed FontStretch = 62.5  // extra condensed width
+	FSeCondensed      FontStretch = 75    // condensed width
+	FSeSemiCondensed  FontStretch = 87.5  // semi condensed width
+	FSeNormal         FontStretch = 100   // the normal width
+	FSeSemiExpanded   FontStretch = 112.5 // semi expanded width
+	FSeExpanded       FontStretch = 125   // expanded width
+	FSeExtraExpanded  FontStretch = 150   // extra expanded width
+	FSeUltraExpanded  FontStretch = 200   // ultra expanded width
 )
 
-func newFontStretch(stretch pr.String) FontStretch {
-	switch strings.ToLower(string(stretch)) {
-	case "", "normal":
-		return FSeNormal
-	case "ultra-condensed":
-		return FSeUltraCondensed
-	case "extra-condensed":
-		return FSeExtraCondensed
-	case "condensed":
-		return FSeCondensed
-	case "semi-condensed":
-		return FSeSemiCondensed
-	case "semi-expanded":
-		return FSeSemiExpanded
-	case "expanded":
-		return FSeExpanded
-	case "extra-expanded":
-		return FSeExtraExpanded
-	case "ultra-expanded":
-		return FSeUltraExpanded
-	default:
-		return FSeNormal
+func newFontStretch(stretch pr.Float) FontStretch { return FontStretch(stretch) }
+
+// keyword returns the index of the keyword closest to [s], from 0 for
+// ultra condensed to 8 for ultra expanded, as used by pango and fontconfig.
+func (s FontStretch) keyword() int {
+	keywords := [...]FontStretch{
+		FSeUltraCondensed, FSeExtraCondensed, FSeCondensed, FSeSemiCondensed, FSeNormal,
+		FSeSemiExpanded, FSeExpanded, FSeExtraExpanded, FSeUltraExpanded,
+	}
+	best := 0
+	for i, kw := range keywords {
+		if abs(float32(kw-s)) < abs(float32(keywords[best]-s)) {
+			best = i
+		}
 	}
+	return best
+}
+
+// FontSynthesis is a bit mask storing the styles which may
+// be synthesized when the font lacks the requested face.
+type FontSynthesis uint8
+
+const (
+	SynthesisWeight FontSynthesis = 1 << iota
+	SynthesisStyle
+)
+
+func newFontSynthesis(fs pr.SStrings) FontSynthesis {
+	var out FontSynthesis
+	for _, s := range fs.Strings {
+		switch s {
+		case "weight":
+			out |= SynthesisWeight
+		case "style":
+			out |= SynthesisStyle
+		}
+	}
+	return out
 }
 
 type Variation struct {
@@ -578,6 +643,37 @@ type Variation struct {
 	Value pr.Fl
 }
 
+// FormatVariations returns a compact representation of [vs],
+// like "wght=700,wdth=100", which may be parsed back with [ParseVariations].
+func FormatVariations(vs []Variation) string {
+	chunks := make([]string, len(vs))
+	for i, v := range vs {
+		chunks[i] = string(v.Tag[:]) + "=" + strconv.FormatFloat(float64(v.Value), 'g', -1, 32)
+	}
+	return strings.Join(chunks, ",")
+}
+
+// ParseVariations parses the output of [FormatVariations],
+// ignoring invalid entries.
+func ParseVariations(s string) []Variation {
+	var out []Variation
+	for _, chunk := range strings.Split(s, ",") {
+		tag, value, ok := strings.Cut(strings.TrimSpace(chunk), "=")
+		if !ok || len(tag) != 4 {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			continue
+		}
+		var va Variation
+		copy(va.Tag[:], tag)
+		va.Value = pr.Fl(v)
+		out = append(out, va)
+	}
+	return out
+}
+
 func newFontVariationSettings(vs pr.SFloatStrings) []Variation {
 	if vs.String == "normal" {
 		return nil
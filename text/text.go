@@ -3,6 +3,7 @@ package text
 import (
 	"math"
 	"strings"
+	"unicode"
 
 	"github.com/benoitkugler/textlayout/language"
 	pr "github.com/benoitkugler/webrender/css/properties"
@@ -32,8 +33,130 @@ type EngineLayout interface {
 	// to justify text. Depending on the implementation, it
 	// may be ignored until [ApplyJustification] is called.
 	SetJustification(spacing pr.Float)
+	// SetCharacterJustification add an additional spacing after each
+	// character (see [CharacterOpportunities]), to justify text
+	// without word separators. If [trailing] is false, the last character
+	// of the line is not spaced. Depending on the implementation, it
+	// may be ignored until [ApplyJustification] is called.
+	SetCharacterJustification(spacing pr.Float, trailing bool)
 
 	ApplyJustification()
+
+	// Coverage returns the fonts used by the first line,
+	// and the runes not supported by any of them.
+	Coverage() FontCoverage
+
+	// ClusterBounds returns, for each rune of the first line, the horizontal
+	// start and end of its cluster, in pixels from the left of the line.
+	// The runes of a cluster share the same bounds.
+	ClusterBounds() [][2]pr.Fl
+}
+
+// fillClusterBounds copies the bounds of the first rune of each
+// cluster, as marked by [set], to the following runes.
+func fillClusterBounds(bounds [][2]pr.Fl, set []bool) {
+	for i := 1; i < len(bounds); i++ {
+		if !set[i] {
+			bounds[i] = bounds[i-1]
+		}
+	}
+}
+
+// FontCoverage reports how the text of a line has been
+// resolved by the font fallback.
+type FontCoverage struct {
+	// Fonts are the font files used, without duplicates.
+	Fonts []FontOrigin
+	// Missing are the (graphic) runes displayed with a .notdef glyph,
+	// without duplicates.
+	Missing []rune
+}
+
+func (fc *FontCoverage) addFont(origin FontOrigin) {
+	for _, o := range fc.Fonts {
+		if o == origin {
+			return
+		}
+	}
+	fc.Fonts = append(fc.Fonts, origin)
+}
+
+// addMissing ignores control and format characters, which
+// are not expected to be displayed.
+func (fc *FontCoverage) addMissing(r rune) {
+	if !unicode.IsGraphic(r) {
+		return
+	}
+	for _, m := range fc.Missing {
+		if m == r {
+			return
+		}
+	}
+	fc.Missing = append(fc.Missing, r)
+}
+
+// isJustifiedCharacter returns true if inter-character justification
+// adds a spacing after [r]. Combining marks, control and format characters,
+// which have no advance of their own, are skipped.
+func isJustifiedCharacter(r rune) bool {
+	return !unicode.In(r, unicode.M, unicode.Cc, unicode.Cf)
+}
+
+// unspacedScripts are the scripts written without
+// separators between words
+var unspacedScripts = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Bopomofo, unicode.Yi,
+	unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar,
+}
+
+// HasUnspacedScript returns true if [text] contains characters of a
+// script written without separators between words, such as Chinese or Thai.
+// Lines of such text are justified between characters.
+func HasUnspacedScript(text []rune) bool {
+	for _, r := range text {
+		if unicode.In(r, unspacedScripts...) {
+			return true
+		}
+	}
+	return false
+}
+
+// CharacterOpportunities returns the number of characters of [text]
+// receiving a spacing with inter-character justification.
+func CharacterOpportunities(text []rune) int {
+	var out int
+	for _, r := range text {
+		if isJustifiedCharacter(r) {
+			out++
+		}
+	}
+	return out
+}
+
+// characterSpacings returns a function giving the number of spacings
+// to add after the cluster text[start:end], with inter-character
+// justification of the line text[:length].
+// Unless [trailing] is true, the last character of the line is not spaced.
+func characterSpacings(text []rune, length int, trailing bool) func(start, end int) int {
+	last := -1
+	if !trailing {
+		for i := length - 1; i >= 0; i-- {
+			if isJustifiedCharacter(text[i]) {
+				last = i
+				break
+			}
+		}
+	}
+	return func(start, end int) int {
+		end = min(end, length)
+		var out int
+		for i := start; i < end; i++ {
+			if i != last && isJustifiedCharacter(text[i]) {
+				out++
+			}
+		}
+		return out
+	}
 }
 
 // FirstLine exposes the result of laying out
@@ -110,6 +233,18 @@ func SplitFirstLine(text []rune, style_ pr.StyleAccessor, context TextLayoutCont
 	return context.Fonts().splitFirstLine(context.HyphenCache(), text, style, maxWidth, minimum, isLineStart)
 }
 
+// SplitFirstLineLevel is the same as [SplitFirstLine], for a text whose
+// embedding [level] has been resolved with its paragraph (see [ResolveBidiLevels]) :
+// the text is laid out in the direction of [level].
+// (The pango engine still deduces the direction from the text content.)
+func SplitFirstLineLevel(text []rune, style_ pr.StyleAccessor, level BidiLevel, context TextLayoutContext,
+	maxWidth pr.MaybeFloat, minimum, isLineStart bool,
+) FirstLine {
+	style := NewTextStyle(style_, false)
+	style.Bidi = BidiContext{Resolved: true, Level: level}
+	return context.Fonts().splitFirstLine(context.HyphenCache(), text, style, maxWidth, minimum, isLineStart)
+}
+
 type StrutLayoutKey struct {
 	lang                 string
 	fontFamily           string // joined
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/benoitkugler/textprocessing/fontconfig"
@@ -14,6 +15,7 @@ import (
 	"github.com/benoitkugler/webrender/utils"
 	tu "github.com/benoitkugler/webrender/utils/testutils"
 	"github.com/go-text/typesetting/fontscan"
+	"golang.org/x/image/math/fixed"
 )
 
 var (
@@ -282,7 +284,7 @@ func TestHeightAndBaseline(t *testing.T) {
 		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/googlesans/v36/4UaGrENHsxJlGDuGo1OIlL3Owps.ttf"}}, FontFamily: "Google Sans", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 400}},
 		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/googlesans/v36/4UabrENHsxJlGDuGo1OIlLU94YtzCwM.ttf"}}, FontFamily: "Google Sans", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 500}},
 		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/materialicons/v117/flUhRq6tzZclQEJ-Vdg-IuiaDsNZ.ttf"}}, FontFamily: "Material Icons", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 400}},
-		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/opensans/v27/memSYaGs126MiZpBA-UvWbX2vVnXBbObj2OVZyOOSr4dVJWUgsjZ0B4gaVc.ttf"}}, FontFamily: "Open Sans", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 400}, FontStretch: "normal"},
+		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/opensans/v27/memSYaGs126MiZpBA-UvWbX2vVnXBbObj2OVZyOOSr4dVJWUgsjZ0B4gaVc.ttf"}}, FontFamily: "Open Sans", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 400}, FontStretch: 100},
 		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/roboto/v29/KFOmCnqEu92Fr1Mu4mxP.ttf"}}, FontFamily: "Roboto", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 400}},
 		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/roboto/v29/KFOlCnqEu92Fr1MmEU9fBBc9.ttf"}}, FontFamily: "Roboto", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 500}},
 		{Src: []pr.NamedString{{Name: "external", String: "https://fonts.gstatic.com/s/roboto/v29/KFOlCnqEu92Fr1MmWUlfBBc9.ttf"}}, FontFamily: "Roboto", FontStyle: "normal", FontWeight: pr.IntString{String: "", Int: 700}},
@@ -575,7 +577,7 @@ func TestWrap(t *testing.T) {
 					line := fcG.wrap([]rune(text), style, pr.Inf)
 					tu.AssertEqual(t, line.Length, len([]rune(text)))
 					tu.AssertEqual(t, line.ResumeAt, -1)
-					// for _, run := range line.Layout.(layoutGotext).line {
+					// for _, run := range line.Layout.(*TextLayoutGotext).line {
 					// 	fmt.Println(run.GlyphBounds, fixedToFloat(run.GlyphBounds.LineThickness()))
 					// }
 
@@ -737,6 +739,203 @@ func TestWordBoundaries(t *testing.T) {
 	}
 }
 
+func TestJustificationGotext(t *testing.T) {
+	fcGotext := NewFontConfigurationGotext(fontmapGotext)
+	style := &TextStyle{FontDescription: FontDescription{
+		Family:  []string{"DejaVu Sans"},
+		Weight:  400,
+		Stretch: FSeNormal,
+		Size:    12,
+	}}
+	const text = "a few words"
+
+	lineWidth := func(layout *TextLayoutGotext) (width fixed.Int26_6) {
+		line, _ := layout.GetFirstLine()
+		for _, run := range line {
+			width += run.Advance
+		}
+		return width
+	}
+
+	line := fcGotext.wrap([]rune(text), style, pr.Inf)
+	layout := line.Layout.(*TextLayoutGotext)
+	ref := lineWidth(layout)
+
+	layout.SetJustification(5)
+	tu.AssertEqual(t, lineWidth(layout), ref) // not applied yet
+	tu.AssertEqual(t, layout.Justification(), pr.Float(5))
+
+	layout.ApplyJustification()
+	layout.ApplyJustification() // idempotent
+	tu.AssertEqual(t, lineWidth(layout), ref+2*floatToFixed(5))
+
+	// the cached layout is not modified
+	other := fcGotext.wrap([]rune(text), style, pr.Inf).Layout.(*TextLayoutGotext)
+	tu.AssertEqual(t, other.Justification(), pr.Float(0))
+	tu.AssertEqual(t, lineWidth(other), ref)
+
+	// inter-character justification skips the combining marks
+	line = fcGotext.wrap([]rune("abc\u0301d"), style, pr.Inf)
+	layout = line.Layout.(*TextLayoutGotext)
+	ref = lineWidth(layout)
+	tu.AssertEqual(t, CharacterOpportunities(layout.Text()), 4)
+
+	layout.SetCharacterJustification(5, false)
+	layout.ApplyJustification()
+	tu.AssertEqual(t, lineWidth(layout), ref+3*floatToFixed(5))
+
+	layout.SetCharacterJustification(5, true)
+	layout.ApplyJustification()
+	tu.AssertEqual(t, lineWidth(layout), ref+4*floatToFixed(5))
+
+	layout.SetJustification(0)
+	layout.ApplyJustification()
+	tu.AssertEqual(t, lineWidth(layout), ref)
+}
+
+func TestMetricsGotext(t *testing.T) {
+	fcGotext := NewFontConfigurationGotext(fontmapGotext)
+	fcPango := &FontConfigurationPango{fontmap: fontmapPango}
+	style := &TextStyle{FontDescription: FontDescription{
+		Family:  []string{"DejaVu Sans"},
+		Weight:  400,
+		Stretch: FSeNormal,
+		Size:    16,
+	}}
+
+	lineG := fcGotext.wrap([]rune("Some text"), style, pr.Inf)
+	tu.AssertEqual(t, lineG.Layout.Metrics() == nil, true)
+
+	style.TextDecorationLine = pr.Underline
+	for _, text := range []string{"Some text", ""} {
+		lineP := wrapPango(fcPango, text, style, nil)
+		lineG = fcGotext.wrap([]rune(text), style, pr.Inf)
+		mP, mG := lineP.Layout.Metrics(), lineG.Layout.Metrics()
+		assert(t, pr.Abs(pr.Float(mG.Ascent-mP.Ascent)) < 0.01, "ascent")
+		assert(t, pr.Abs(pr.Float(mG.UnderlinePosition-mP.UnderlinePosition)) < 0.01, "underline position")
+		assert(t, pr.Abs(pr.Float(mG.UnderlineThickness-mP.UnderlineThickness)) < 0.01, "underline thickness")
+		assert(t, pr.Abs(pr.Float(mG.StrikethroughPosition-mP.StrikethroughPosition)) < 0.01, "strikethrough position")
+		assert(t, pr.Abs(pr.Float(mG.StrikethroughThickness-mP.StrikethroughThickness)) < 0.01, "strikethrough thickness")
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	fmP, fmG := newFontmaps(t)
+	fcGotext := NewFontConfigurationGotext(fmG)
+	fcPango := &FontConfigurationPango{fontmap: fmP}
+	style := &TextStyle{FontDescription: FontDescription{
+		Family:  []string{"DejaVu Sans"},
+		Weight:  400,
+		Stretch: FSeNormal,
+		Size:    16,
+	}}
+
+	for _, text := range []string{"Some text", "中 text 中\u200b"} {
+		lineP := wrapPango(fcPango, text, style, nil)
+		lineG := fcGotext.wrap([]rune(text), style, pr.Inf)
+		for _, coverage := range []FontCoverage{lineP.Layout.Coverage(), lineG.Layout.Coverage()} {
+			tu.AssertEqual(t, len(coverage.Fonts), 1)
+			tu.AssertEqual(t, strings.HasSuffix(coverage.Fonts[0].File, "DejaVuSans.ttf"), true)
+			if strings.ContainsRune(text, '中') {
+				tu.AssertEqual(t, coverage.Missing, []rune{'中'})
+			} else {
+				tu.AssertEqual(t, len(coverage.Missing), 0)
+			}
+		}
+	}
+}
+
+func TestClusterBounds(t *testing.T) {
+	fmP, fmG := newFontmaps(t)
+	fcGotext := NewFontConfigurationGotext(fmG)
+	fcPango := &FontConfigurationPango{fontmap: fmP}
+	style := &TextStyle{FontDescription: FontDescription{
+		Family:  []string{"DejaVu Sans"},
+		Weight:  400,
+		Stretch: FSeNormal,
+		Size:    16,
+	}}
+
+	const text = "Some text"
+	lineP := wrapPango(fcPango, text, style, nil)
+	lineG := fcGotext.wrap([]rune(text), style, pr.Inf)
+	for _, line := range []FirstLine{lineP, lineG} {
+		bounds := line.Layout.ClusterBounds()
+		tu.AssertEqual(t, len(bounds), len(text))
+		tu.AssertEqual(t, bounds[0][0], pr.Fl(0))
+		for i := 1; i < len(bounds); i++ {
+			assert(t, bounds[i][0] == bounds[i-1][1], "contiguous clusters")
+		}
+		assert(t, pr.Abs(pr.Float(bounds[len(bounds)-1][1])-line.Width) < 0.01, "line width")
+	}
+}
+
+func TestBidiGotext(t *testing.T) {
+	ct := textContextGotext()
+	for _, test := range []struct {
+		text, direction, unicodeBidi string
+		rtl                          bool
+		runs                         []string // in visual order
+	}{
+		{"שלום 123 abc עולם", "rtl", "normal", true, []string{" עולם", "abc", " ", "123", "שלום "}},
+		{"abc שלום 123 עולם def", "ltr", "normal", false, []string{"abc ", " עולם", "123", "שלום ", " def"}},
+		{"سعر 12.50 دولار", "rtl", "normal", true, []string{" دولار", "12.50", "سعر "}},
+		{"abc def", "rtl", "bidi-override", true, []string{"abc def"}},
+		{"שלום abc", "ltr", "plaintext", true, []string{"abc", "שלום "}},
+		{"123 abc", "rtl", "plaintext", false, []string{"123 abc"}},
+		{"123 !", "ltr", "normal", false, []string{"123 !"}},
+		{"123 !", "rtl", "plaintext", true, []string{" !", "123"}},
+	} {
+		style := pr.InitialValues.Copy()
+		style.SetDirection(pr.String(test.direction))
+		style.SetUnicodeBidi(pr.String(test.unicodeBidi))
+		line := SplitFirstLine([]rune(test.text), style, ct, pr.Inf, false, true)
+		tu.AssertEqual(t, line.FirstLineRTL, test.rtl)
+
+		layout := line.Layout.(*TextLayoutGotext)
+		var runs []string
+		for _, run := range layout.line {
+			runs = append(runs, string(layout.text[run.Runes.Offset:run.Runes.Offset+run.Runes.Count]))
+		}
+		tu.AssertEqual(t, runs, test.runs)
+	}
+}
+
+func TestVerticalGotext(t *testing.T) {
+	ct := textContextGotext()
+	horizontal := SplitFirstLine([]rune("ab"), pr.InitialValues.Copy(), ct, pr.Inf, false, true)
+
+	for _, test := range []struct {
+		writingMode, textOrientation string
+		upright                      bool
+	}{
+		{"horizontal-tb", "upright", false},
+		{"vertical-rl", "mixed", false}, // latin text is sideways
+		{"vertical-rl", "sideways", false},
+		{"vertical-lr", "upright", true},
+	} {
+		style := pr.InitialValues.Copy()
+		style.SetWritingMode(pr.String(test.writingMode))
+		style.SetTextOrientation(pr.String(test.textOrientation))
+		line := SplitFirstLine([]rune("ab"), style, ct, pr.Inf, false, true)
+		layout := line.Layout.(*TextLayoutGotext)
+		tu.AssertEqual(t, layout.IsUpright(0), test.upright)
+		tu.AssertEqual(t, layout.IsUpright(1), test.upright)
+		// the line height does not depend on the orientation
+		tu.AssertEqual(t, line.Height, horizontal.Height)
+		tu.AssertEqual(t, line.Baseline, horizontal.Baseline)
+		if test.upright {
+			// upright glyphs use the vertical advance, which is
+			// the same for all glyphs in fonts without 'vmtx' table
+			run := layout.line[0]
+			tu.AssertEqual(t, run.Glyphs[0].XAdvance, run.Glyphs[1].XAdvance)
+			tu.AssertEqual(t, line.Width > horizontal.Width, true)
+		} else {
+			tu.AssertEqual(t, line.Width, horizontal.Width)
+		}
+	}
+}
+
 func TestDebug(t *testing.T) {
 	fcGotext := NewFontConfigurationGotext(fontmapGotext)
 	fcPango := &FontConfigurationPango{fontmap: fontmapPango}
@@ -823,7 +1022,7 @@ func resolveFacePango(fc *FontConfigurationPango, text string, style *TextStyle)
 
 func resolveFaceGotext(fc *FontConfigurationGotext, text string, style *TextStyle) (out []faceRun) {
 	lineG := fc.wrap([]rune(text), style, pr.Inf)
-	line := lineG.Layout.(layoutGotext).line
+	line := lineG.Layout.(*TextLayoutGotext).line
 	for _, run := range line {
 		out = append(out, faceRun{
 			run.Runes.Offset, run.Runes.Count,
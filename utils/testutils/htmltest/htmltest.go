@@ -0,0 +1,43 @@
+// Package htmltest provides helpers to lay out HTML documents in tests,
+// using the font cache shipped with the text package.
+package htmltest
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/benoitkugler/textprocessing/fontconfig"
+	"github.com/benoitkugler/textprocessing/pango/fcfonts"
+	"github.com/benoitkugler/webrender/html/document"
+	"github.com/benoitkugler/webrender/html/tree"
+	"github.com/benoitkugler/webrender/text"
+	"github.com/benoitkugler/webrender/utils"
+)
+
+// fontmapCache returns the absolute path of the test font cache,
+// so that the helpers may be used from any package.
+func fontmapCache() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "../../../text/testdata/cache.fc")
+}
+
+// FontConfig returns a pango font configuration backed by the test font cache.
+func FontConfig(t testing.TB) *text.FontConfigurationPango {
+	t.Helper()
+	fs, err := fontconfig.LoadFontsetFile(fontmapCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text.NewFontConfigurationPango(fcfonts.NewFontMap(fontconfig.Standard.Copy(), fs))
+}
+
+// Document parses [html] and lays it out with [FontConfig].
+func Document(t testing.TB, html string) document.Document {
+	t.Helper()
+	root, err := tree.NewHTML(utils.InputString(html), "", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document.Render(root, nil, false, FontConfig(t))
+}
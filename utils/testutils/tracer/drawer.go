@@ -97,6 +97,10 @@ func (dr Drawer) SetBookmarks([]backend.BookmarkNode) {
 	dr.println("AddBookmark :")
 }
 
+func (dr Drawer) SetPageLabels(labels []backend.PageLabel) {
+	dr.println("SetPageLabels :", len(labels))
+}
+
 func (dr Drawer) AddInternalLink(x, y, w, h fl, anchorName string) {
 	dr.println("AddInternalLink :")
 }
@@ -109,6 +113,20 @@ func (dr Drawer) AddFileAnnotation(x, y, w, h fl, id string) {
 	dr.println("AddFileAnnotation :")
 }
 
+func (dr Drawer) AddFormField(x, y, w, h fl, field backend.FormField) {
+	dr.printf("AddFormField : %d %s %s", field.Kind, field.Name, field.Value)
+}
+
+func (dr *Drawer) BeginStructure(element backend.StructureElement) {
+	dr.printf("BeginStructure : %s %d", element.Tag, element.ID)
+	dr.indent++
+}
+
+func (dr *Drawer) EndStructure() {
+	dr.indent--
+	dr.println("EndStructure :")
+}
+
 func (dr Drawer) GetBoundingBox() (left, top, right, bottom fl) {
 	dr.println("GetBoundingBox :")
 	return 0, 0, 10, 10
@@ -161,6 +179,22 @@ func (dr Drawer) SetColorRgba(color parser.RGBA, stroke bool) {
 	}
 }
 
+func (dr Drawer) SetColor(color parser.Color, stroke bool) {
+	mode := "fill"
+	if stroke {
+		mode = "stroke"
+	}
+	switch device := color.Device; device.Space {
+	case parser.SpaceCMYK:
+		dr.printf("SetColor : %s cmyk %.2f %.2f %.2f %.2f", mode, device.CMYK[0], device.CMYK[1], device.CMYK[2], device.CMYK[3])
+	case parser.SpaceSeparation:
+		dr.printf("SetColor : %s spot %s %.2f", mode, device.Spot, device.Tint)
+	default:
+		rgba := color.RGBA
+		dr.printf("SetColor : %s %.2f %.2f %.2f %.2f", mode, rgba.R, rgba.G, rgba.B, rgba.A)
+	}
+}
+
 func (dr Drawer) SetLineWidth(width fl) {
 	dr.println("SetLineWidth :", width)
 }